@@ -91,6 +91,7 @@ var (
 		utils.MaxPeersFlag,
 		utils.MaxPendingPeersFlag,
 		utils.MinFreeDiskSpaceFlag,
+		utils.MinerDryRunFlag,
 		utils.MinerEtherbaseFlag,
 		utils.MinerGasPriceFlag,
 		utils.NATFlag,
@@ -113,6 +114,9 @@ var (
 		utils.SyncModeFlag,
 		utils.TxLookupLimitFlag,
 		utils.TxPoolAccountQueueFlag,
+		utils.TxPoolEtxSlotsFlag,
+		utils.TxPoolPrecheckFlag,
+		utils.TxPoolPrecheckRejectFlag,
 		utils.TxPoolAccountSlotsFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolGlobalSlotsFlag,
@@ -121,8 +125,12 @@ var (
 		utils.TxPoolLocalsFlag,
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolPriceBumpFlag,
+		utils.TxPoolReplaceGasLimitFlag,
+		utils.TxPoolLocalSamePriceFlag,
 		utils.TxPoolPriceLimitFlag,
 		utils.TxPoolRejournalFlag,
+		utils.TxPoolNoRemoteJournalFlag,
+		utils.TxPoolRemoteJournalCapFlag,
 		utils.USBFlag,
 		utils.UnlockedAccountFlag,
 		utils.VMEnableDebugFlag,
@@ -70,8 +70,15 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.TxPoolNoLocalsFlag,
 			utils.TxPoolJournalFlag,
 			utils.TxPoolRejournalFlag,
+			utils.TxPoolNoRemoteJournalFlag,
+			utils.TxPoolRemoteJournalCapFlag,
 			utils.TxPoolPriceLimitFlag,
 			utils.TxPoolPriceBumpFlag,
+			utils.TxPoolReplaceGasLimitFlag,
+			utils.TxPoolLocalSamePriceFlag,
+			utils.TxPoolEtxSlotsFlag,
+			utils.TxPoolPrecheckFlag,
+			utils.TxPoolPrecheckRejectFlag,
 			utils.TxPoolAccountSlotsFlag,
 			utils.TxPoolGlobalSlotsFlag,
 			utils.TxPoolAccountQueueFlag,
@@ -148,6 +155,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 		Flags: []cli.Flag{
 			utils.MinerGasPriceFlag,
 			utils.MinerEtherbaseFlag,
+			utils.MinerDryRunFlag,
 		},
 	},
 	{
@@ -256,9 +256,18 @@ var (
 	}
 	TxPoolRejournalFlag = cli.DurationFlag{
 		Name:  "txpool.rejournal",
-		Usage: "Time interval to regenerate the local transaction journal",
+		Usage: "Time interval to regenerate the local and remote transaction journals",
 		Value: core.DefaultTxPoolConfig.Rejournal,
 	}
+	TxPoolNoRemoteJournalFlag = cli.BoolFlag{
+		Name:  "txpool.noremotejournal",
+		Usage: "Disables the disk journal of remote transactions across node restarts",
+	}
+	TxPoolRemoteJournalCapFlag = cli.Uint64Flag{
+		Name:  "txpool.remotejournalcap",
+		Usage: "Maximum number of remote transactions kept in the disk journal",
+		Value: core.DefaultTxPoolConfig.RemoteJournalCap,
+	}
 	TxPoolPriceLimitFlag = cli.Uint64Flag{
 		Name:  "txpool.pricelimit",
 		Usage: "Minimum gas price limit to enforce for acceptance into the pool",
@@ -269,6 +278,27 @@ var (
 		Usage: "Price bump percentage to replace an already existing transaction",
 		Value: ethconfig.Defaults.TxPool.PriceBump,
 	}
+	TxPoolReplaceGasLimitFlag = cli.BoolFlag{
+		Name:  "txpool.replacegaslimit",
+		Usage: "Allow replacing a transaction with one raising only the gas limit by the price bump, without fee bump",
+	}
+	TxPoolLocalSamePriceFlag = cli.BoolFlag{
+		Name:  "txpool.localsameprice",
+		Usage: "Allow local transactions to be replaced at the same price, without price bump",
+	}
+	TxPoolEtxSlotsFlag = cli.Uint64Flag{
+		Name:  "txpool.etxslots",
+		Usage: "Maximum number of inbound ETXs offered for inclusion per block (0 = unlimited)",
+		Value: ethconfig.Defaults.TxPool.EtxSlots,
+	}
+	TxPoolPrecheckFlag = cli.BoolFlag{
+		Name:  "txpool.precheck",
+		Usage: "Execute incoming transactions against the latest state to screen out the reverting ones",
+	}
+	TxPoolPrecheckRejectFlag = cli.BoolFlag{
+		Name:  "txpool.precheck.reject",
+		Usage: "Reject the transactions reverting in the precheck instead of tagging them",
+	}
 	TxPoolAccountSlotsFlag = cli.Uint64Flag{
 		Name:  "txpool.accountslots",
 		Usage: "Minimum number of executable transaction slots guaranteed per account",
@@ -355,6 +385,10 @@ var (
 		Usage: "Public address for block mining rewards (default = first account)",
 		Value: "0",
 	}
+	MinerDryRunFlag = cli.BoolFlag{
+		Name:  "miner.dryrun",
+		Usage: "Assemble blocks and keep the pending state up to date without sealing them (RPC-only and relay nodes)",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -1211,12 +1245,33 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolRejournalFlag.Name) {
 		cfg.Rejournal = ctx.GlobalDuration(TxPoolRejournalFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolNoRemoteJournalFlag.Name) && ctx.GlobalBool(TxPoolNoRemoteJournalFlag.Name) {
+		cfg.RemoteJournal = ""
+	}
+	if ctx.GlobalIsSet(TxPoolRemoteJournalCapFlag.Name) {
+		cfg.RemoteJournalCap = ctx.GlobalUint64(TxPoolRemoteJournalCapFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolPriceLimitFlag.Name) {
 		cfg.PriceLimit = ctx.GlobalUint64(TxPoolPriceLimitFlag.Name)
 	}
 	if ctx.GlobalIsSet(TxPoolPriceBumpFlag.Name) {
 		cfg.PriceBump = ctx.GlobalUint64(TxPoolPriceBumpFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolReplaceGasLimitFlag.Name) {
+		cfg.ReplaceGasLimitOnly = ctx.GlobalBool(TxPoolReplaceGasLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolLocalSamePriceFlag.Name) {
+		cfg.ReplaceLocalSamePrice = ctx.GlobalBool(TxPoolLocalSamePriceFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolEtxSlotsFlag.Name) {
+		cfg.EtxSlots = ctx.GlobalUint64(TxPoolEtxSlotsFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolPrecheckFlag.Name) {
+		cfg.PrecheckExecution = ctx.GlobalBool(TxPoolPrecheckFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolPrecheckRejectFlag.Name) {
+		cfg.PrecheckReject = ctx.GlobalBool(TxPoolPrecheckRejectFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolAccountSlotsFlag.Name) {
 		cfg.AccountSlots = ctx.GlobalUint64(TxPoolAccountSlotsFlag.Name)
 	}
@@ -1397,6 +1452,10 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	setGPO(ctx, &cfg.GPO, ctx.GlobalString(SyncModeFlag.Name) == "light")
 	setTxPool(ctx, &cfg.TxPool)
 
+	if ctx.GlobalIsSet(MinerDryRunFlag.Name) {
+		cfg.Miner.DryRun = ctx.GlobalBool(MinerDryRunFlag.Name)
+	}
+
 	// If blake3 consensus engine is specifically asked use the blake3 engine
 	if ctx.GlobalString(ConsensusEngineFlag.Name) == "blake3" {
 		cfg.ConsensusEngine = "blake3"
@@ -0,0 +1,292 @@
+package core
+
+import (
+	"math/big"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/state"
+)
+
+// accessKey identifies an account, or one of its storage slots, in the state.
+type accessKey struct {
+	addr    common.InternalAddress
+	slot    common.Hash
+	storage bool
+}
+
+// accessValue is the value of an account, or of one of its storage slots, in the
+// state.
+type accessValue struct {
+	exist    bool
+	balance  *big.Int
+	nonce    uint64
+	codeHash common.Hash
+	code     []byte // Code of a written account, only set if it changed
+	slot     common.Hash
+}
+
+// stateValue returns the value of the given account or storage slot in the state.
+func stateValue(statedb *state.StateDB, key accessKey) accessValue {
+	if key.storage {
+		return accessValue{slot: statedb.GetState(key.addr, key.slot)}
+	}
+	return accessValue{
+		exist:    statedb.Exist(key.addr),
+		balance:  new(big.Int).Set(statedb.GetBalance(key.addr)),
+		nonce:    statedb.GetNonce(key.addr),
+		codeHash: statedb.GetCodeHash(key.addr),
+	}
+}
+
+// equal reports whether two values of the same account or storage slot match.
+func (v accessValue) equal(other accessValue) bool {
+	return v.exist == other.exist && v.nonce == other.nonce && v.codeHash == other.codeHash && v.slot == other.slot &&
+		(v.balance == nil) == (other.balance == nil) && (v.balance == nil || v.balance.Cmp(other.balance) == 0)
+}
+
+// txAccess records the state a transaction depended on and the state it wrote,
+// so that its effects can be applied to another state holding the same values
+// without executing it again.
+type txAccess struct {
+	pre       map[accessKey]accessValue // Values of the accessed state before the transaction
+	post      map[accessKey]accessValue // Values of the written state after the transaction
+	credit    *big.Int                  // Fees credited to the coinbase
+	mergeable bool                      // Whether the effects can be applied as values, false if accounts were recreated or deleted
+}
+
+// valid reports whether the given state holds the values the transaction depended
+// on, in which case applying its effects is equivalent to executing it.
+func (a *txAccess) valid(statedb *state.StateDB) bool {
+	for key, value := range a.pre {
+		if !value.equal(stateValue(statedb, key)) {
+			return false
+		}
+	}
+	return true
+}
+
+// apply writes the effects of the transaction to the given state, which must be
+// valid for it.
+func (a *txAccess) apply(statedb *state.StateDB, coinbase common.InternalAddress) {
+	for key, value := range a.post {
+		if key.storage {
+			continue
+		}
+		if !statedb.Exist(key.addr) {
+			statedb.CreateAccount(key.addr)
+		}
+		statedb.SetBalance(key.addr, value.balance)
+		statedb.SetNonce(key.addr, value.nonce)
+		if value.code != nil {
+			statedb.SetCode(key.addr, value.code)
+		}
+	}
+	for key, value := range a.post {
+		if key.storage {
+			statedb.SetState(key.addr, key.slot, value.slot)
+		}
+	}
+	// The credits are part of the coinbase balance if it was written
+	if _, ok := a.post[accessKey{addr: coinbase}]; !ok {
+		statedb.AddBalance(coinbase, a.credit)
+	}
+}
+
+// accessTracker wraps a state database, recording the accounts and storage slots
+// read and written by the transactions applied through it. Balance credits to
+// the coinbase are not recorded, as every transaction pays fees to it and the
+// credits commute.
+type accessTracker struct {
+	*state.StateDB
+
+	coinbase        common.InternalAddress
+	touchedCoinbase bool // Whether the coinbase was accessed beyond fee credits, which other transactions affect
+
+	reads  map[accessKey]struct{}
+	writes map[accessKey]struct{}
+
+	tx       *txAccess              // Accesses of the transaction being applied, if recorded
+	txWrites map[accessKey]struct{} // State written by the transaction being applied
+}
+
+// newAccessTracker creates an access tracker on top of the given state.
+func newAccessTracker(statedb *state.StateDB, coinbase common.InternalAddress) *accessTracker {
+	return &accessTracker{
+		StateDB:  statedb,
+		coinbase: coinbase,
+		reads:    make(map[accessKey]struct{}),
+		writes:   make(map[accessKey]struct{}),
+	}
+}
+
+// beginTx starts recording the accesses of the next transaction applied.
+func (t *accessTracker) beginTx() {
+	t.tx = &txAccess{pre: make(map[accessKey]accessValue), credit: new(big.Int), mergeable: true}
+	t.txWrites = make(map[accessKey]struct{})
+}
+
+// endTx stops recording the accesses of the transaction applied since beginTx,
+// returning them along with the values it wrote. The state must be finalised.
+func (t *accessTracker) endTx() *txAccess {
+	tx := t.tx
+	tx.post = make(map[accessKey]accessValue, len(t.txWrites))
+	for key := range t.txWrites {
+		value := stateValue(t.StateDB, key)
+		if !key.storage {
+			if !value.exist {
+				tx.mergeable = false
+			}
+			if value.codeHash != tx.pre[key].codeHash {
+				value.code = t.StateDB.GetCode(key.addr)
+			}
+		}
+		tx.post[key] = value
+	}
+	t.tx, t.txWrites = nil, nil
+	return tx
+}
+
+// capture records the value of the given state before the transaction being
+// applied first accessed it.
+func (t *accessTracker) capture(key accessKey) {
+	if t.tx == nil {
+		return
+	}
+	if _, ok := t.tx.pre[key]; !ok {
+		t.tx.pre[key] = stateValue(t.StateDB, key)
+	}
+}
+
+func (t *accessTracker) read(addr common.InternalAddress) {
+	if addr == t.coinbase {
+		t.touchedCoinbase = true
+	}
+	key := accessKey{addr: addr}
+	t.capture(key)
+	t.reads[key] = struct{}{}
+}
+
+func (t *accessTracker) readSlot(addr common.InternalAddress, slot common.Hash) {
+	key := accessKey{addr: addr, slot: slot, storage: true}
+	t.capture(key)
+	t.reads[key] = struct{}{}
+}
+
+func (t *accessTracker) write(addr common.InternalAddress) {
+	if addr == t.coinbase {
+		t.touchedCoinbase = true
+	}
+	key := accessKey{addr: addr}
+	t.capture(key)
+	t.writes[key] = struct{}{}
+	if t.txWrites != nil {
+		t.txWrites[key] = struct{}{}
+	}
+}
+
+func (t *accessTracker) writeSlot(addr common.InternalAddress, slot common.Hash) {
+	key := accessKey{addr: addr, slot: slot, storage: true}
+	t.capture(key)
+	t.writes[key] = struct{}{}
+	if t.txWrites != nil {
+		t.txWrites[key] = struct{}{}
+	}
+}
+
+func (t *accessTracker) GetBalance(addr common.InternalAddress) *big.Int {
+	t.read(addr)
+	return t.StateDB.GetBalance(addr)
+}
+
+func (t *accessTracker) GetNonce(addr common.InternalAddress) uint64 {
+	t.read(addr)
+	return t.StateDB.GetNonce(addr)
+}
+
+func (t *accessTracker) GetCodeHash(addr common.InternalAddress) common.Hash {
+	t.read(addr)
+	return t.StateDB.GetCodeHash(addr)
+}
+
+func (t *accessTracker) GetCode(addr common.InternalAddress) []byte {
+	t.read(addr)
+	return t.StateDB.GetCode(addr)
+}
+
+func (t *accessTracker) GetCodeSize(addr common.InternalAddress) int {
+	t.read(addr)
+	return t.StateDB.GetCodeSize(addr)
+}
+
+func (t *accessTracker) GetCommittedState(addr common.InternalAddress, slot common.Hash) common.Hash {
+	t.readSlot(addr, slot)
+	return t.StateDB.GetCommittedState(addr, slot)
+}
+
+func (t *accessTracker) GetState(addr common.InternalAddress, slot common.Hash) common.Hash {
+	t.readSlot(addr, slot)
+	return t.StateDB.GetState(addr, slot)
+}
+
+func (t *accessTracker) HasSuicided(addr common.InternalAddress) bool {
+	t.read(addr)
+	return t.StateDB.HasSuicided(addr)
+}
+
+func (t *accessTracker) Exist(addr common.InternalAddress) bool {
+	t.read(addr)
+	return t.StateDB.Exist(addr)
+}
+
+func (t *accessTracker) Empty(addr common.InternalAddress) bool {
+	t.read(addr)
+	return t.StateDB.Empty(addr)
+}
+
+func (t *accessTracker) ForEachStorage(addr common.InternalAddress, cb func(key, value common.Hash) bool) error {
+	t.read(addr)
+	return t.StateDB.ForEachStorage(addr, cb)
+}
+
+func (t *accessTracker) CreateAccount(addr common.InternalAddress) {
+	t.write(addr)
+	// Recreating an account resets its storage, which can't be applied as values
+	if t.tx != nil && t.tx.pre[accessKey{addr: addr}].exist {
+		t.tx.mergeable = false
+	}
+	t.StateDB.CreateAccount(addr)
+}
+
+func (t *accessTracker) SubBalance(addr common.InternalAddress, amount *big.Int) {
+	t.write(addr)
+	t.StateDB.SubBalance(addr, amount)
+}
+
+func (t *accessTracker) AddBalance(addr common.InternalAddress, amount *big.Int) {
+	if addr != t.coinbase {
+		t.write(addr)
+	} else if t.tx != nil {
+		t.tx.credit.Add(t.tx.credit, amount)
+	}
+	t.StateDB.AddBalance(addr, amount)
+}
+
+func (t *accessTracker) SetNonce(addr common.InternalAddress, nonce uint64) {
+	t.write(addr)
+	t.StateDB.SetNonce(addr, nonce)
+}
+
+func (t *accessTracker) SetCode(addr common.InternalAddress, code []byte) {
+	t.write(addr)
+	t.StateDB.SetCode(addr, code)
+}
+
+func (t *accessTracker) SetState(addr common.InternalAddress, slot, value common.Hash) {
+	t.writeSlot(addr, slot)
+	t.StateDB.SetState(addr, slot, value)
+}
+
+func (t *accessTracker) Suicide(addr common.InternalAddress) bool {
+	t.write(addr)
+	return t.StateDB.Suicide(addr)
+}
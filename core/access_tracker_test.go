@@ -0,0 +1,137 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+func TestAccessTrackerSets(t *testing.T) {
+	statedb := newSponsorTestState(t)
+	_, coinbase := zoneKey(t)
+	_, reader := zoneKey(t)
+	_, writer := zoneKey(t)
+
+	tracker := newAccessTracker(statedb, coinbase)
+	tracker.GetBalance(reader)
+	tracker.SetState(writer, common.Hash{1}, common.Hash{2})
+	tracker.AddBalance(coinbase, big.NewInt(10))
+
+	if _, ok := tracker.reads[accessKey{addr: reader}]; !ok {
+		t.Errorf("account read not recorded")
+	}
+	if _, ok := tracker.writes[accessKey{addr: writer, slot: common.Hash{1}, storage: true}]; !ok {
+		t.Errorf("storage write not recorded")
+	}
+	// Fee credits to the coinbase commute, they are not accesses
+	if _, ok := tracker.writes[accessKey{addr: coinbase}]; ok || tracker.touchedCoinbase {
+		t.Errorf("coinbase credit recorded as a write")
+	}
+	tracker.GetBalance(coinbase)
+	if !tracker.touchedCoinbase {
+		t.Errorf("coinbase read not recorded")
+	}
+}
+
+// Tests that the recorded effects of a transaction, applied to a state holding
+// the values it read, result in the same state as executing it.
+func TestTxAccessApply(t *testing.T) {
+	statedb := newSponsorTestState(t)
+	_, coinbase := zoneKey(t)
+	_, sender := zoneKey(t)
+	_, contract := zoneKey(t)
+	_, created := zoneKey(t)
+
+	statedb.SetBalance(sender, big.NewInt(1000))
+	statedb.SetCode(contract, storeValueCode)
+	statedb.Finalise(true)
+	target := statedb.Copy()
+
+	tracker := newAccessTracker(statedb, coinbase)
+	tracker.beginTx()
+	tracker.SetNonce(sender, tracker.GetNonce(sender)+1)
+	tracker.SubBalance(sender, big.NewInt(300))
+	tracker.AddBalance(contract, big.NewInt(200))
+	tracker.SetState(contract, common.Hash{}, common.Hash{1})
+	tracker.CreateAccount(created)
+	tracker.AddBalance(created, big.NewInt(100))
+	tracker.SetCode(created, []byte{0x00})
+	tracker.AddBalance(coinbase, big.NewInt(50))
+	tracker.Finalise(true)
+	access := tracker.endTx()
+
+	if !access.mergeable {
+		t.Fatalf("transaction creating an account not mergeable")
+	}
+	if !access.valid(target) {
+		t.Fatalf("effects invalid on the state the transaction read")
+	}
+	access.apply(target, coinbase)
+	target.Finalise(true)
+	if have, want := target.IntermediateRoot(true), statedb.IntermediateRoot(true); have != want {
+		t.Fatalf("state root mismatch: have %x, want %x", have, want)
+	}
+	// The transaction depends on the sender balance
+	if access.valid(target) {
+		t.Errorf("effects valid on a state changed since")
+	}
+}
+
+func TestTxAccessUnmergeable(t *testing.T) {
+	statedb := newSponsorTestState(t)
+	_, coinbase := zoneKey(t)
+	_, recreated := zoneKey(t)
+	_, destroyed := zoneKey(t)
+
+	statedb.SetBalance(recreated, big.NewInt(1))
+	statedb.SetBalance(destroyed, big.NewInt(1))
+	statedb.Finalise(true)
+
+	// Recreating an account resets its storage
+	tracker := newAccessTracker(statedb, coinbase)
+	tracker.beginTx()
+	tracker.CreateAccount(recreated)
+	tracker.Finalise(true)
+	if access := tracker.endTx(); access.mergeable {
+		t.Errorf("transaction recreating an account mergeable")
+	}
+	// Destroying an account deletes it
+	tracker.beginTx()
+	tracker.Suicide(destroyed)
+	tracker.Finalise(true)
+	if access := tracker.endTx(); access.mergeable {
+		t.Errorf("transaction destroying an account mergeable")
+	}
+}
+
+func TestCheckEtxLimits(t *testing.T) {
+	common.NodeLocation = common.Location{0, 0}
+
+	etx := func(to common.Address) *types.Transaction {
+		return types.NewTx(&types.ExternalTx{To: &to, Value: big.NewInt(1), Gas: 21000, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(1)})
+	}
+	region := common.HexToAddress("0x1e00000000000000000000000000000000000001") // cyprus2
+	prime := common.HexToAddress("0x5800000000000000000000000000000000000001")  // paxos1
+	tx := types.NewTx(&types.InternalTx{Nonce: 0, Gas: 21000, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(1), Value: big.NewInt(0)})
+
+	etxRLimit, etxPLimit := 2, 1
+	if err := checkEtxLimits(tx, types.Transactions{etx(region), etx(prime)}, &etxRLimit, &etxPLimit); err != nil {
+		t.Fatalf("etxs within the limits refused: %v", err)
+	}
+	if etxRLimit != 1 || etxPLimit != 0 {
+		t.Fatalf("limits not deducted: have %d/%d, want %d/%d", etxRLimit, etxPLimit, 1, 0)
+	}
+	if err := checkEtxLimits(tx, types.Transactions{etx(prime)}, &etxRLimit, &etxPLimit); !errors.Is(err, ErrEtxLimitReached) {
+		t.Fatalf("cross-prime limit error mismatch: have %v, want %v", err, ErrEtxLimitReached)
+	}
+	if err := checkEtxLimits(tx, types.Transactions{etx(region), etx(region)}, &etxRLimit, &etxPLimit); !errors.Is(err, ErrEtxLimitReached) {
+		t.Fatalf("cross-region limit error mismatch: have %v, want %v", err, ErrEtxLimitReached)
+	}
+	// Refused etxs leave the limits untouched
+	if etxRLimit != 1 || etxPLimit != 0 {
+		t.Errorf("limits deducted by refused etxs: have %d/%d, want %d/%d", etxRLimit, etxPLimit, 1, 0)
+	}
+}
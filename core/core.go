@@ -107,6 +107,7 @@ func NewCore(db ethdb.Database, config *Config, isLocalBlock func(block *types.H
 	go c.updateAppendQueue()
 	go c.startStatsTimer()
 	go c.checkSyncTarget()
+
 	return c, nil
 }
 
@@ -626,6 +627,17 @@ func (c *Core) ConstructLocalMinedBlock(header *types.Header) (*types.Block, err
 	return c.sl.ConstructLocalMinedBlock(header)
 }
 
+// JournalSealedBlock persists a locally sealed block until it is imported.
+func (c *Core) JournalSealedBlock(block *types.Block) {
+	c.sl.miner.JournalSealedBlock(block)
+}
+
+// ReplaySealedBlocks imports the blocks sealed before the last shutdown which
+// did not make it into the chain.
+func (c *Core) ReplaySealedBlocks() {
+	c.sl.miner.ReplaySealedBlocks(c.WriteBlock)
+}
+
 func (c *Core) SubRelayPendingHeader(slPendingHeader types.PendingHeader, newEntropy *big.Int, location common.Location, subReorg bool, order int) {
 	c.sl.SubRelayPendingHeader(slPendingHeader, newEntropy, location, subReorg, order)
 }
@@ -939,6 +951,12 @@ func (c *Core) SetGasCeil(ceil uint64) {
 	c.sl.miner.SetGasCeil(ceil)
 }
 
+// SendBundle submits a bundle of transactions to be included atomically and in
+// order into the block of the given number.
+func (c *Core) SendBundle(txs types.Transactions, blockNumber uint64) error {
+	return c.sl.miner.SendBundle(txs, blockNumber)
+}
+
 // EnablePreseal turns on the preseal mining feature. It's enabled by default.
 // Note this function shouldn't be exposed to API, it's unnecessary for users
 // (miners) to actually know the underlying detail. It's only for outside project
@@ -960,6 +978,65 @@ func (c *Core) StopMining() {
 	c.sl.miner.StopMining()
 }
 
+// SetEtxCap sets the maximum number and total gas of the ETXs emitted per block.
+func (c *Core) SetEtxCap(count uint64, gas uint64) {
+	c.sl.miner.SetEtxCap(count, gas)
+}
+
+// SimulatePendingBlock builds the block the miner would build on top of the
+// chain head if the given transactions were in the pool.
+func (c *Core) SimulatePendingBlock(txs types.Transactions) (*SimulatedBlock, error) {
+	return c.sl.miner.SimulatePendingBlock(txs)
+}
+
+// StartSealing resumes committing sealing work.
+func (c *Core) StartSealing() {
+	c.sl.miner.StartSealing()
+}
+
+// StopSealing pauses committing sealing work.
+func (c *Core) StopSealing() {
+	c.sl.miner.StopSealing()
+}
+
+// MinerStats returns a summary of the runtime state of the miner.
+func (c *Core) MinerStats() WorkerStats {
+	return c.sl.miner.Stats()
+}
+
+// SetMinerDryRun toggles the dry-run mode of the miner, in which blocks are
+// assembled and the pending state is kept up to date, but nothing is sealed.
+func (c *Core) SetMinerDryRun(enabled bool) {
+	c.sl.miner.SetDryRun(enabled)
+}
+
+// GeneratePendingHeaderOnAncestor generates a pending header on top of the
+// ancestor of the chain head with the given hash, within the reorg window.
+func (c *Core) GeneratePendingHeaderOnAncestor(hash common.Hash, fill bool) (*types.Header, error) {
+	return c.sl.miner.GeneratePendingHeaderOnAncestor(hash, fill)
+}
+
+// RecordBlockPeer notes the peer a block was received from, for the provenance
+// of the uncle candidates.
+func (c *Core) RecordBlockPeer(hash common.Hash, peer string) {
+	c.sl.miner.RecordBlockPeer(hash, peer)
+}
+
+// UncleCandidates returns the side blocks kept by the miner as possible uncles.
+func (c *Core) UncleCandidates() []UncleCandidate {
+	return c.sl.miner.UncleCandidates()
+}
+
+// RecommitStats returns the state of the recommit interval controller.
+func (c *Core) RecommitStats() RecommitStats {
+	return c.sl.miner.RecommitStats()
+}
+
+// NotifyHealth returns the delivery records of the endpoints notified of new work.
+func (c *Core) NotifyHealth() []NotifyEndpointHealth {
+	return c.sl.miner.NotifyHealth()
+}
+
 // Pending returns the currently pending block and associated state.
 func (c *Core) Pending() *types.Block {
 	return c.sl.miner.Pending()
@@ -983,6 +1060,27 @@ func (c *Core) SetEtherbase(addr common.Address) {
 	c.sl.miner.SetEtherbase(addr)
 }
 
+// SetPrioritySenders sets the senders whose transactions are committed first.
+func (c *Core) SetPrioritySenders(addrs []common.Address) {
+	c.sl.miner.SetPrioritySenders(addrs)
+}
+
+// PrioritySenders returns the senders whose transactions are committed first.
+func (c *Core) PrioritySenders() []common.Address {
+	return c.sl.miner.PrioritySenders()
+}
+
+// SetCoinbaseValidator sets the function used to validate the coinbase before
+// preparing sealing work.
+func (c *Core) SetCoinbaseValidator(validator func(addr common.Address) error) {
+	c.sl.miner.SetCoinbaseValidator(validator)
+}
+
+// SetEtherbases sets the etherbases the block rewards rotate between.
+func (c *Core) SetEtherbases(addrs []common.Address, weights []uint64) error {
+	return c.sl.miner.SetEtherbases(addrs, weights)
+}
+
 // SubscribePendingLogs starts delivering logs from pending transactions
 // to the given channel.
 func (c *Core) SubscribePendingLogs(ch chan<- []*types.Log) event.Subscription {
@@ -1065,8 +1163,11 @@ func (c *Core) TrieNode(hash common.Hash) ([]byte, error) {
 // TxPool methods //
 //----------------//
 
+// SetGasPrice sets the minimum tip of the transactions accepted by the pool and
+// included by the miner.
 func (c *Core) SetGasPrice(price *big.Int) {
 	c.sl.txPool.SetGasPrice(price)
+	c.sl.miner.SetGasPrice(price)
 }
 
 func (c *Core) AddLocal(tx *types.Transaction) error {
@@ -1097,6 +1198,36 @@ func (c *Core) Content() (map[common.InternalAddress]types.Transactions, map[com
 	return c.sl.txPool.Content()
 }
 
+// ContentPage retrieves up to limit pending or queued transactions of the pool,
+// ordered by sender and nonce, starting at the given sender and nonce.
+func (c *Core) ContentPage(queued bool, sender common.InternalAddress, nonce uint64, limit int) map[common.InternalAddress]types.Transactions {
+	return c.sl.txPool.ContentPage(queued, sender, nonce, limit)
+}
+
+// TxPoolStuckAccounts returns the accounts whose queued transactions can't be
+// promoted because of a nonce gap or an insufficient balance.
+func (c *Core) TxPoolStuckAccounts() []StuckAccount {
+	return c.sl.txPool.StuckAccounts()
+}
+
+// EtxPoolStatus returns the state of the inbound ETXs at the last selection for
+// inclusion.
+func (c *Core) EtxPoolStatus() EtxPoolStatus {
+	return c.sl.txPool.EtxPoolStatus()
+}
+
+// TxPoolReplacementPolicy returns the rules transactions must meet to replace
+// pooled ones with the same nonce.
+func (c *Core) TxPoolReplacementPolicy() ReplacementPolicy {
+	return c.sl.txPool.ReplacementPolicy()
+}
+
+// SetTxPoolReplacementPolicy updates the rules transactions must meet to
+// replace pooled ones with the same nonce.
+func (c *Core) SetTxPoolReplacementPolicy(policy ReplacementPolicy) error {
+	return c.sl.txPool.SetReplacementPolicy(policy)
+}
+
 func (c *Core) ContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
 	internal, err := addr.InternalAddress()
 	if err != nil {
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+
+	mapset "github.com/deckarep/golang-set"
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+// envPool recycles the sealing environments released by the worker, along with
+// their uncle bookkeeping sets and transaction lists, to cut down the allocations
+// of every build cycle.
+var envPool = sync.Pool{
+	New: func() interface{} {
+		return &environment{
+			ancestors: mapset.NewSet(),
+			family:    mapset.NewSet(),
+			uncles:    make(map[common.Hash]*types.Header),
+		}
+	},
+}
+
+// newEnvironment takes an empty environment from the pool, holding a single
+// reference for the caller. The uncle sets are empty, and the transaction lists
+// are empty with a capacity of at least txCapacity.
+func newEnvironment(txCapacity int) *environment {
+	env := envPool.Get().(*environment)
+	env.refs = 1
+	if cap(env.txs) < txCapacity {
+		env.txs = make([]*types.Transaction, 0, txCapacity)
+	}
+	if cap(env.etxs) < txCapacity {
+		env.etxs = make([]*types.Transaction, 0, txCapacity)
+	}
+	env.receipts = make([]*types.Receipt, 0, txCapacity)
+	return env
+}
+
+// retain takes an additional reference on the environment, which then stays out
+// of the pool until the reference is released.
+func (env *environment) retain() {
+	atomic.AddInt32(&env.refs, 1)
+}
+
+// release drops a reference on the environment. Once the last one is dropped,
+// the background prefetcher is terminated and the environment returns to the
+// pool, so the holder must not use it anymore. The receipts may have been handed
+// out and are never reused. Environments which don't come from the pool are left
+// to the garbage collector.
+func (env *environment) release() {
+	if atomic.AddInt32(&env.refs, -1) != 0 {
+		return
+	}
+	env.discard()
+
+	ancestors, family, uncles := env.ancestors, env.family, env.uncles
+	if ancestors == nil || family == nil || uncles == nil {
+		// Not created by the pool, leave it to the garbage collector
+		return
+	}
+	ancestors.Clear()
+	family.Clear()
+	for hash := range uncles {
+		delete(uncles, hash)
+	}
+	// Drop the references to the transactions, so that they are not retained
+	// while the lists sit in the pool
+	txs, etxs := env.txs, env.etxs
+	for i := range txs {
+		txs[i] = nil
+	}
+	for i := range etxs {
+		etxs[i] = nil
+	}
+	*env = environment{
+		ancestors: ancestors,
+		family:    family,
+		uncles:    uncles,
+		txs:       txs[:0],
+		etxs:      etxs[:0],
+	}
+	envPool.Put(env)
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"testing"
+
+	mapset "github.com/deckarep/golang-set"
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/params"
+)
+
+const benchEnvTxs = 1000
+
+// fillBenchEnv populates an environment the way a build cycle does.
+func fillBenchEnv(env *environment, hashes []common.Hash, tx *types.Transaction) {
+	for _, hash := range hashes {
+		env.ancestors.Add(hash)
+		env.family.Add(hash)
+	}
+	for i := 0; i < benchEnvTxs; i++ {
+		env.txs = append(env.txs, tx)
+		env.etxs = append(env.etxs, tx)
+	}
+}
+
+func benchEnvInputs() ([]common.Hash, *types.Transaction) {
+	hashes := make([]common.Hash, 7)
+	for i := range hashes {
+		hashes[i] = common.BytesToHash([]byte{byte(i + 1)})
+	}
+	return hashes, types.NewTx(&types.InternalTx{})
+}
+
+func BenchmarkEnvironmentFresh(b *testing.B) {
+	hashes, tx := benchEnvInputs()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		env := &environment{
+			ancestors: mapset.NewSet(),
+			family:    mapset.NewSet(),
+			uncles:    make(map[common.Hash]*types.Header),
+			txs:       make([]*types.Transaction, 0, benchEnvTxs),
+			etxs:      make([]*types.Transaction, 0, benchEnvTxs),
+			receipts:  make([]*types.Receipt, 0, benchEnvTxs),
+		}
+		fillBenchEnv(env, hashes, tx)
+	}
+}
+
+func BenchmarkEnvironmentPooled(b *testing.B) {
+	hashes, tx := benchEnvInputs()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		env := newEnvironment(benchEnvTxs)
+		fillBenchEnv(env, hashes, tx)
+		env.release()
+	}
+}
+
+func TestEnvironmentRelease(t *testing.T) {
+	hashes, tx := benchEnvInputs()
+	env := newEnvironment(benchEnvTxs)
+	fillBenchEnv(env, hashes, tx)
+
+	// A retained environment is not recycled until all references are dropped
+	env.retain()
+	env.release()
+	if len(env.txs) != benchEnvTxs {
+		t.Fatalf("environment reset while referenced: have %d txs, want %d", len(env.txs), benchEnvTxs)
+	}
+	txs := env.txs
+	env.release()
+	if len(env.txs) != 0 || env.ancestors.Cardinality() != 0 || env.family.Cardinality() != 0 {
+		t.Fatalf("environment not reset: txs %d, ancestors %d, family %d", len(env.txs), env.ancestors.Cardinality(), env.family.Cardinality())
+	}
+	for i, tx := range txs {
+		if tx != nil {
+			t.Fatalf("transaction %d retained by the released environment", i)
+		}
+	}
+}
+
+func TestEnvironmentTxCapacity(t *testing.T) {
+	common.NodeLocation = common.Location{0, 0}
+	key, from := zoneKey(t)
+	pool := &TxPool{pending: map[common.InternalAddress]*txList{
+		from: stuckTestList(precheckTx(t, key, 0, plainRecipient()), precheckTx(t, key, 1, plainRecipient()), precheckTx(t, key, 2, plainRecipient())),
+	}}
+	w := &worker{config: &Config{}, txPool: pool}
+
+	// The lists are sized for the pending transactions, up to what fits in a block
+	if have := w.txCapacity(params.TxGas * 100); have != 3 {
+		t.Errorf("capacity mismatch: have %d, want %d", have, 3)
+	}
+	if have := w.txCapacity(params.TxGas * 2); have != 2 {
+		t.Errorf("capacity mismatch: have %d, want %d", have, 2)
+	}
+	w.config.TxCapacityHint = 50
+	if have := w.txCapacity(params.TxGas * 2); have != 50 {
+		t.Errorf("hinted capacity mismatch: have %d, want %d", have, 50)
+	}
+}
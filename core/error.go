@@ -18,7 +18,9 @@ package core
 
 import (
 	"errors"
+	"fmt"
 
+	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/core/types"
 )
 
@@ -59,6 +61,23 @@ var (
 	//ErrBloomNotFound is returned when bloom cannot be found for a hash
 	ErrBloomNotFound = errors.New("bloom not found")
 
+	// ErrGenerationInProgress is returned when a pending header generation is
+	// requested while another one is in flight.
+	ErrGenerationInProgress = errors.New("pending header generation in progress")
+
+	// ErrGenerationInterrupted is returned when a pending header generation is
+	// aborted by a new dominant header superseding its parent.
+	ErrGenerationInterrupted = errors.New("pending header generation interrupted")
+
+	// ErrSealingWorkVetoed is returned when the commit veto of the worker rejects
+	// an assembled block.
+	ErrSealingWorkVetoed = errors.New("sealing work vetoed")
+
+	// ErrAncestorOutsideReorgWindow is returned when a pending header is requested
+	// on a block which is not a canonical ancestor of the chain head within the
+	// worker reorg window.
+	ErrAncestorOutsideReorgWindow = errors.New("block is not an ancestor within the reorg window")
+
 	//ErrPendingEtxRollupNotFound is returned when pendingEtxsRollup cannot be found for a hash given in the submanifest
 	ErrPendingEtxRollupNotFound = errors.New("pending etx rollup not found")
 
@@ -137,6 +156,54 @@ var (
 	// ErrSenderNoEOA is returned if the sender of a transaction is a contract.
 	ErrSenderNoEOA = errors.New("sender not an eoa")
 
+	// ErrPayerNoEOA is returned if the fee payer of a sponsored transaction is a
+	// contract.
+	ErrPayerNoEOA = errors.New("fee payer not an eoa")
+
 	// ErrSenderInoperable is returned if the sender of a transaction is outside of context.
 	ErrSenderInoperable = errors.New("sender is in inoperable state")
+
+	// ErrInvalidDestination is returned if the recipient of a transaction is not
+	// in a zone the transaction can reach.
+	ErrInvalidDestination = errors.New("invalid transaction destination")
 )
+
+// DestinationErrorCode is the JSON-RPC error code of the transactions rejected
+// for their destination.
+const DestinationErrorCode = -32020
+
+// DestinationError is returned if the recipient of a transaction is not in a
+// zone the transaction can reach. It carries a JSON-RPC error code and the
+// location of the recipient as error data.
+type DestinationError struct {
+	To       common.Address
+	Location *common.Location // Location of the recipient, nil if no chain contains it
+	Reason   string
+}
+
+func (e *DestinationError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrInvalidDestination, e.Reason)
+}
+
+// Unwrap returns ErrInvalidDestination, so that all the destination errors match
+// it with errors.Is.
+func (e *DestinationError) Unwrap() error {
+	return ErrInvalidDestination
+}
+
+// ErrorCode returns the JSON-RPC error code of a destination error.
+func (e *DestinationError) ErrorCode() int {
+	return DestinationErrorCode
+}
+
+// ErrorData returns the recipient and its location.
+func (e *DestinationError) ErrorData() interface{} {
+	data := map[string]interface{}{
+		"to":     e.To.Hex(),
+		"reason": e.Reason,
+	}
+	if e.Location != nil {
+		data["location"] = e.Location.RPCMarshal()
+	}
+	return data
+}
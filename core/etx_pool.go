@@ -0,0 +1,144 @@
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/metrics"
+	"github.com/dominant-strategies/go-quai/params"
+)
+
+// etxExpiringWindow is the number of blocks before their expiration from which
+// the inbound ETXs are reported as expiring.
+const etxExpiringWindow = params.EtxExpirationAge / 10
+
+var (
+	etxAvailableGauge   = metrics.NewRegisteredGauge("txpool/etx/available", nil)
+	etxOfferedGauge     = metrics.NewRegisteredGauge("txpool/etx/offered", nil)
+	etxExpiringGauge    = metrics.NewRegisteredGauge("txpool/etx/expiring", nil)
+	etxDeferredMeter    = metrics.NewRegisteredMeter("txpool/etx/deferred", nil)    // Held back by the slot limit
+	etxOverSlotsMeter   = metrics.NewRegisteredMeter("txpool/etx/overslots", nil)   // Offered over the slot limit as expiring
+	etxUnderpricedMeter = metrics.NewRegisteredMeter("txpool/etx/underpriced", nil) // Held back by the tip floor
+)
+
+// EtxPoolStatus is the state of the inbound ETXs at the last selection.
+type EtxPoolStatus struct {
+	Height       uint64 // Number of the block the ETXs were selected for
+	Slots        uint64 // Maximum number of ETXs offered per block (0 = unlimited)
+	Available    int    // ETXs confirmed by the dom and not yet included or expired
+	Offered      int    // ETXs offered for inclusion
+	Deferred     int    // ETXs held back by the slot limit
+	OverSlots    int    // Expiring ETXs offered over the slot limit
+	Underpriced  int    // ETXs held back by the tip floor
+	Expiring     int    // Available ETXs expiring within etxExpiringWindow blocks
+	OldestHeight uint64 // Height at which the oldest available ETX became available
+}
+
+// EtxPool selects the inbound ETXs offered for inclusion in the zone blocks.
+// The ETXs destined to this zone become available once confirmed by the dom
+// and are kept in the consensus ETX set until included or expired, apart from
+// the pool of user transactions. Their expiry is a consensus rule of the set,
+// the pool only orders them. The pool offers them in arrival order, so that the
+// ones closest to expiry go first, and caps their number per block so that a
+// flood of cross-chain messages can't crowd the user transactions out. The ETXs
+// within etxExpiringWindow blocks of their expiry are offered over the cap, not
+// to be lost to it.
+type EtxPool struct {
+	slots uint64
+
+	mu     sync.RWMutex
+	status EtxPoolStatus
+}
+
+// NewEtxPool creates a pool offering up to slots ETXs per block, zero lifting
+// the limit.
+func NewEtxPool(slots uint64) *EtxPool {
+	return &EtxPool{slots: slots, status: EtxPoolStatus{Slots: slots}}
+}
+
+// Status returns the state of the inbound ETXs at the last selection.
+func (p *EtxPool) Status() EtxPoolStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.status
+}
+
+// pending selects the ETXs of the set to offer for inclusion in the block of
+// the given number, and records the selection as the status of the pool.
+func (p *EtxPool) pending(etxSet types.EtxSet, number uint64, enforceTips bool, gasPrice, baseFee *big.Int) types.Transactions {
+	etxs, status := p.selectEtxs(etxSet, number, enforceTips, gasPrice, baseFee)
+
+	p.mu.Lock()
+	p.status = status
+	p.mu.Unlock()
+
+	etxAvailableGauge.Update(int64(status.Available))
+	etxOfferedGauge.Update(int64(status.Offered))
+	etxExpiringGauge.Update(int64(status.Expiring))
+	etxDeferredMeter.Mark(int64(status.Deferred))
+	etxOverSlotsMeter.Mark(int64(status.OverSlots))
+	etxUnderpricedMeter.Mark(int64(status.Underpriced))
+	return etxs
+}
+
+// expiring reports whether an ETX available at the given height expires within
+// etxExpiringWindow blocks of the block of the given number.
+func expiring(height, number uint64) bool {
+	return height+params.EtxExpirationAge < number+etxExpiringWindow
+}
+
+// selectEtxs selects the ETXs of the set to offer for inclusion in the block of
+// the given number, in arrival order. If tips are enforced, the ETXs paying less
+// than the gas price on top of the base fee are held back. The selection is not
+// recorded, so that simulations don't overwrite the status of the pool.
+func (p *EtxPool) selectEtxs(etxSet types.EtxSet, number uint64, enforceTips bool, gasPrice, baseFee *big.Int) (types.Transactions, EtxPoolStatus) {
+	status := EtxPoolStatus{Height: number, Slots: p.slots}
+	entries := make([]types.EtxSetEntry, 0, len(etxSet))
+	for _, entry := range etxSet {
+		if entry.ETX.ETXSender().Location().Equal(common.NodeLocation) { // Sanity check
+			log.Error("ETX sender is in our location!", "tx", entry.ETX.Hash().String(), "sender", entry.ETX.ETXSender().String())
+			continue // skip this tx
+		}
+		entries = append(entries, entry)
+		if status.OldestHeight == 0 || entry.Height < status.OldestHeight {
+			status.OldestHeight = entry.Height
+		}
+		if expiring(entry.Height, number) {
+			status.Expiring++
+		}
+	}
+	status.Available = len(entries)
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Height != entries[j].Height {
+			return entries[i].Height < entries[j].Height
+		}
+		hi, hj := entries[i].ETX.Hash(), entries[j].ETX.Hash()
+		return bytes.Compare(hi[:], hj[:]) < 0
+	})
+	etxs := make(types.Transactions, 0, len(entries))
+	for i := range entries {
+		tx := &entries[i].ETX
+		// If the miner requests tip enforcement, cap the lists now
+		if enforceTips && tx.EffectiveGasTipIntCmp(gasPrice, baseFee) < 0 {
+			log.Debug("ETX has incorrect or low miner tip", "tx", tx.Hash().String(), "gasTipCap", tx.GasTipCap().String(), "poolGasPrice", gasPrice.String(), "baseFee", baseFee.String())
+			status.Underpriced++
+			continue // skip this tx
+		}
+		if p.slots > 0 && uint64(len(etxs)) >= p.slots {
+			if !expiring(entries[i].Height, number) {
+				status.Deferred++
+				continue
+			}
+			status.OverSlots++
+		}
+		etxs = append(etxs, tx)
+	}
+	status.Offered = len(etxs)
+	return etxs, status
+}
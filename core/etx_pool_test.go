@@ -0,0 +1,90 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/params"
+)
+
+// etxPoolTestSet creates an ETX set holding an inbound ETX available at each of
+// the given heights, paying the given tip.
+func etxPoolTestSet(tip int64, heights ...uint64) types.EtxSet {
+	to := common.HexToAddress("0x0000000000000000000000000000000000000001")     // cyprus1
+	sender := common.HexToAddress("0x1e00000000000000000000000000000000000001") // cyprus2
+	set := types.NewEtxSet()
+	for i, height := range heights {
+		etx := types.NewTx(&types.ExternalTx{Nonce: uint64(i), To: &to, Sender: sender, Value: big.NewInt(1), Gas: params.TxGas, GasTipCap: big.NewInt(tip), GasFeeCap: big.NewInt(tip + 10)})
+		set[etx.Hash()] = types.EtxSetEntry{Height: height, ETX: *etx}
+	}
+	return set
+}
+
+func TestEtxPoolArrivalOrder(t *testing.T) {
+	common.NodeLocation = common.Location{0, 0}
+
+	set := etxPoolTestSet(1, 30, 10, 20)
+	etxs := NewEtxPool(0).pending(set, 40, false, big.NewInt(1), big.NewInt(1))
+	if len(etxs) != 3 {
+		t.Fatalf("offered etxs mismatch: have %d, want %d", len(etxs), 3)
+	}
+	for i, want := range []uint64{10, 20, 30} {
+		if have := set[etxs[i].Hash()].Height; have != want {
+			t.Errorf("etx %d arrival mismatch: have %d, want %d", i, have, want)
+		}
+	}
+}
+
+func TestEtxPoolSlots(t *testing.T) {
+	common.NodeLocation = common.Location{0, 0}
+
+	number := uint64(1000)
+	expiring := number - params.EtxExpirationAge // last block it can be included in
+	pool := NewEtxPool(2)
+
+	// The newest ETXs are deferred by the slot limit
+	etxs := pool.pending(etxPoolTestSet(1, 990, 991, 992, 993), number, false, big.NewInt(1), big.NewInt(1))
+	if len(etxs) != 2 {
+		t.Fatalf("offered etxs mismatch: have %d, want %d", len(etxs), 2)
+	}
+	if status := pool.Status(); status.Deferred != 2 || status.Offered != 2 || status.Available != 4 {
+		t.Errorf("status mismatch: have %+v", status)
+	}
+	// The expiring ETXs are offered over the slot limit
+	etxs = pool.pending(etxPoolTestSet(1, expiring, expiring, expiring, 990), number, false, big.NewInt(1), big.NewInt(1))
+	if len(etxs) != 3 {
+		t.Fatalf("offered etxs mismatch: have %d, want %d", len(etxs), 3)
+	}
+	if status := pool.Status(); status.OverSlots != 1 || status.Deferred != 1 || status.Expiring != 3 {
+		t.Errorf("status mismatch: have %+v", status)
+	}
+}
+
+func TestEtxPoolUnderpriced(t *testing.T) {
+	common.NodeLocation = common.Location{0, 0}
+
+	pool := NewEtxPool(0)
+	if etxs := pool.pending(etxPoolTestSet(1, 10, 11), 20, true, big.NewInt(2), big.NewInt(1)); len(etxs) != 0 {
+		t.Fatalf("underpriced etxs offered: have %d", len(etxs))
+	}
+	if status := pool.Status(); status.Underpriced != 2 {
+		t.Errorf("underpriced etxs mismatch: have %d, want %d", status.Underpriced, 2)
+	}
+}
+
+// Tests that selecting the ETXs for a simulation leaves the status untouched.
+func TestEtxPoolSelectionStatus(t *testing.T) {
+	common.NodeLocation = common.Location{0, 0}
+
+	pool := NewEtxPool(1)
+	pool.pending(etxPoolTestSet(1, 10, 11), 20, false, big.NewInt(1), big.NewInt(1))
+	etxs, status := pool.selectEtxs(etxPoolTestSet(1, 12, 13, 14), 30, false, big.NewInt(1), big.NewInt(1))
+	if len(etxs) != 1 || status.Available != 3 {
+		t.Fatalf("selection mismatch: have %d of %d etxs", len(etxs), status.Available)
+	}
+	if have := pool.Status(); have.Height != 20 || have.Available != 2 {
+		t.Errorf("status overwritten by the selection: have %+v", have)
+	}
+}
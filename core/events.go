@@ -41,3 +41,26 @@ type ChainSideEvent struct {
 }
 
 type ChainHeadEvent struct{ Block *types.Block }
+
+// PendingInvalidatedEvent is posted when a new chain head supersedes the parent
+// the pending block was built on.
+type PendingInvalidatedEvent struct {
+	OldNumber uint64 // Number of the superseded parent of the pending block
+	NewNumber uint64 // Number of the new chain head
+}
+
+// DomHeaderEvent is posted when a pending header arrives from the dominant
+// chain, changing the dominant parent and manifest expected of the sealing block.
+type DomHeaderEvent struct {
+	Header *types.Header
+	Order  int // Order of the dominant block the header was built on
+}
+
+// TxFailureEvent is posted when a transaction fails to be committed to the
+// sealing block.
+type TxFailureEvent struct {
+	Hash   common.Hash
+	Sender common.Address
+	Err    error
+	Number uint64 // Number of the sealing block
+}
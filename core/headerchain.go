@@ -47,6 +47,7 @@ type HeaderChain struct {
 
 	chainHeadFeed event.Feed
 	chainSideFeed event.Feed
+	domHeaderFeed event.Feed
 	scope         event.SubscriptionScope
 
 	headerDb      ethdb.Database
@@ -970,6 +971,11 @@ func (hc *HeaderChain) SubscribeChainSideEvent(ch chan<- ChainSideEvent) event.S
 	return hc.scope.Track(hc.chainSideFeed.Subscribe(ch))
 }
 
+// SubscribeDomHeaderEvent registers a subscription of DomHeaderEvent.
+func (hc *HeaderChain) SubscribeDomHeaderEvent(ch chan<- DomHeaderEvent) event.Subscription {
+	return hc.scope.Track(hc.domHeaderFeed.Subscribe(ch))
+}
+
 func (hc *HeaderChain) StateAt(root common.Hash) (*state.StateDB, error) {
 	return hc.bc.processor.StateAt(root)
 }
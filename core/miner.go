@@ -19,6 +19,7 @@ package core
 
 import (
 	"fmt"
+	"math/big"
 	"runtime"
 	"time"
 
@@ -52,6 +53,9 @@ func New(hc *HeaderChain, txPool *TxPool, config *Config, db ethdb.Database, cha
 		worker:   newWorker(config, chainConfig, db, engine, hc, txPool, isLocalBlock, true, processingState),
 		coinbase: config.Etherbase,
 	}
+	if len(config.Etherbases) > 0 {
+		miner.coinbase = config.Etherbases[0]
+	}
 	go miner.update()
 
 	miner.Start(miner.coinbase)
@@ -93,6 +97,76 @@ func (miner *Miner) Mining() bool {
 	return miner.worker.isRunning()
 }
 
+// SetEtxCap sets the maximum number and total gas of the ETXs emitted per block.
+func (miner *Miner) SetEtxCap(count uint64, gas uint64) {
+	miner.worker.setEtxCap(count, gas)
+}
+
+// SimulatePendingBlock builds the block the miner would build on top of the
+// chain head if the given transactions were in the pool.
+func (miner *Miner) SimulatePendingBlock(txs types.Transactions) (*SimulatedBlock, error) {
+	return miner.worker.SimulatePendingBlock(txs)
+}
+
+// StartSealing resumes committing sealing work with the current etherbase.
+func (miner *Miner) StartSealing() {
+	miner.worker.start()
+}
+
+// StopSealing pauses committing sealing work. Unlike Stop, the worker is kept
+// alive and can be started again.
+func (miner *Miner) StopSealing() {
+	miner.worker.pause()
+}
+
+// Stats returns a summary of the runtime state of the miner.
+func (miner *Miner) Stats() WorkerStats {
+	return miner.worker.Stats()
+}
+
+// SetDryRun toggles the dry-run mode, in which blocks are assembled and the
+// pending state is kept up to date, but nothing is pushed for sealing.
+func (miner *Miner) SetDryRun(enabled bool) {
+	miner.worker.setDryRun(enabled)
+}
+
+// GeneratePendingHeaderOnAncestor generates a pending header on top of the
+// ancestor of the chain head with the given hash, within the reorg window.
+func (miner *Miner) GeneratePendingHeaderOnAncestor(hash common.Hash, fill bool) (*types.Header, error) {
+	return miner.worker.GeneratePendingHeaderOnAncestor(hash, fill)
+}
+
+// JournalSealedBlock persists a locally sealed block until it is imported.
+func (miner *Miner) JournalSealedBlock(block *types.Block) {
+	miner.worker.JournalSealedBlock(block)
+}
+
+// ReplaySealedBlocks passes the journaled sealed blocks not yet imported to write.
+func (miner *Miner) ReplaySealedBlocks(write func(block *types.Block)) {
+	miner.worker.ReplaySealedBlocks(write)
+}
+
+// RecordBlockPeer notes the peer a block was received from, for the provenance
+// of the uncle candidates.
+func (miner *Miner) RecordBlockPeer(hash common.Hash, peer string) {
+	miner.worker.RecordBlockPeer(hash, peer)
+}
+
+// UncleCandidates returns the side blocks kept as possible uncles.
+func (miner *Miner) UncleCandidates() []UncleCandidate {
+	return miner.worker.UncleCandidates()
+}
+
+// RecommitStats returns the state of the recommit interval controller.
+func (miner *Miner) RecommitStats() RecommitStats {
+	return miner.worker.RecommitStats()
+}
+
+// NotifyHealth returns the delivery records of the endpoints notified of new work.
+func (miner *Miner) NotifyHealth() []NotifyEndpointHealth {
+	return miner.worker.NotifyHealth()
+}
+
 func (miner *Miner) StopMining() {
 	// Update the thread count within the consensus engine
 	type threaded interface {
@@ -165,11 +239,50 @@ func (miner *Miner) SetEtherbase(addr common.Address) {
 	miner.worker.setEtherbase(addr)
 }
 
+// SetPrioritySenders sets the senders whose transactions are committed ahead of
+// the price ordered ones.
+func (miner *Miner) SetPrioritySenders(addrs []common.Address) {
+	miner.worker.setPrioritySenders(addrs)
+}
+
+// PrioritySenders returns the senders whose transactions are committed first.
+func (miner *Miner) PrioritySenders() []common.Address {
+	return miner.worker.PrioritySenders()
+}
+
+// SetCoinbaseValidator sets the function used to validate the coinbase before
+// preparing sealing work. A nil function accepts any coinbase.
+func (miner *Miner) SetCoinbaseValidator(validator func(addr common.Address) error) {
+	miner.worker.setCoinbaseValidator(validator)
+}
+
+// SetEtherbases sets the etherbases the block rewards rotate between, weighted
+// by the given weights or round-robin if none are given.
+func (miner *Miner) SetEtherbases(addrs []common.Address, weights []uint64) error {
+	if err := miner.worker.setEtherbases(addrs, weights); err != nil {
+		return err
+	}
+	miner.coinbase = addrs[0]
+	return nil
+}
+
 // SetGasCeil sets the gaslimit to strive for when mining blocks.
 func (miner *Miner) SetGasCeil(ceil uint64) {
 	miner.worker.setGasCeil(ceil)
 }
 
+// SetGasPrice sets the minimum effective tip of the remote transactions included
+// in the sealing block.
+func (miner *Miner) SetGasPrice(price *big.Int) {
+	miner.worker.setGasPrice(price)
+}
+
+// SendBundle submits a bundle of transactions to be included atomically and in
+// order into the block of the given number.
+func (miner *Miner) SendBundle(txs types.Transactions, blockNumber uint64) error {
+	return miner.worker.addBundle(&Bundle{Txs: txs, BlockNumber: blockNumber})
+}
+
 // EnablePreseal turns on the preseal mining feature. It's enabled by default.
 // Note this function shouldn't be exposed to API, it's unnecessary for users
 // (miners) to actually know the underlying detail. It's only for outside project
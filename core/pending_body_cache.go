@@ -0,0 +1,117 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// pendingBodyCache is a thread-safe fixed size LRU cache of the pending block
+// bodies. Unlike lru.Cache, which loses track of the entries removed explicitly,
+// the eviction callback is invoked for every entry leaving the cache, outside of
+// the critical section.
+type pendingBodyCache struct {
+	lru       *simplelru.LRU
+	evicted   []evictedEntry // Entries left the cache within the critical section
+	onEvicted func(key, value interface{})
+	lock      sync.Mutex
+}
+
+// evictedEntry is an entry which left the pending block body cache.
+type evictedEntry struct {
+	key, value interface{}
+}
+
+// newPendingBodyCacheWithEvict creates a pending block body cache of the given
+// size, invoking the given callback for every entry leaving it.
+func newPendingBodyCacheWithEvict(size int, onEvicted func(key, value interface{})) *pendingBodyCache {
+	c := &pendingBodyCache{onEvicted: onEvicted}
+	c.lru, _ = simplelru.NewLRU(size, func(key, value interface{}) {
+		c.evicted = append(c.evicted, evictedEntry{key, value})
+	})
+	return c
+}
+
+// unlock leaves the critical section, and then notifies the entries which left
+// the cache within it.
+func (c *pendingBodyCache) unlock() {
+	evicted := c.evicted
+	c.evicted = nil
+	c.lock.Unlock()
+
+	if c.onEvicted != nil {
+		for _, entry := range evicted {
+			c.onEvicted(entry.key, entry.value)
+		}
+	}
+}
+
+// Add adds a value to the cache, replacing any existing one for the key. Returns
+// true if an eviction occurred.
+func (c *pendingBodyCache) Add(key, value interface{}) bool {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.lru.Add(key, value)
+}
+
+// ContainsOrAdd checks if a key is in the cache without updating its recent-ness,
+// and if not, adds the value. Returns whether found and whether an eviction
+// occurred.
+func (c *pendingBodyCache) ContainsOrAdd(key, value interface{}) (ok, evicted bool) {
+	c.lock.Lock()
+	defer c.unlock()
+	if c.lru.Contains(key) {
+		return true, false
+	}
+	return false, c.lru.Add(key, value)
+}
+
+// Get looks up a key's value from the cache, updating its recent-ness.
+func (c *pendingBodyCache) Get(key interface{}) (interface{}, bool) {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.lru.Get(key)
+}
+
+// Peek looks up a key's value from the cache without updating its recent-ness.
+func (c *pendingBodyCache) Peek(key interface{}) (interface{}, bool) {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.lru.Peek(key)
+}
+
+// Remove removes the provided key from the cache, returning whether it was
+// present.
+func (c *pendingBodyCache) Remove(key interface{}) bool {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.lru.Remove(key)
+}
+
+// RemoveOldest removes the oldest entry from the cache.
+func (c *pendingBodyCache) RemoveOldest() (key, value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.lru.RemoveOldest()
+}
+
+// Keys returns the keys in the cache, from the oldest to the newest.
+func (c *pendingBodyCache) Keys() []interface{} {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.lru.Keys()
+}
+
+// Len returns the number of entries in the cache.
+func (c *pendingBodyCache) Len() int {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.lru.Len()
+}
+
+// Purge removes all the entries from the cache.
+func (c *pendingBodyCache) Purge() {
+	c.lock.Lock()
+	defer c.unlock()
+	c.lru.Purge()
+}
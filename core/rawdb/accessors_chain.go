@@ -505,6 +505,138 @@ func DeleteAllPbBodyKeys(db ethdb.KeyValueWriter) {
 	}
 }
 
+// PendingBodyMeta is the metadata persisted along with a pending block body.
+type PendingBodyMeta struct {
+	Number uint64 // Number of the block the body was assembled for
+	Size   uint64 // Approximate size of the body
+}
+
+// ReadPendingBodyMeta retrieves the metadata of the persisted pending block body
+// corresponding to the hash.
+func ReadPendingBodyMeta(db ethdb.KeyValueReader, hash common.Hash) *PendingBodyMeta {
+	data, _ := db.Get(pbStoreMetaKey(hash))
+	if len(data) == 0 {
+		return nil
+	}
+	meta := new(PendingBodyMeta)
+	if err := rlp.DecodeBytes(data, meta); err != nil {
+		log.Error("Invalid pending block body metadata RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return meta
+}
+
+// WritePendingBodyMeta stores the metadata of a persisted pending block body.
+func WritePendingBodyMeta(db ethdb.KeyValueWriter, hash common.Hash, meta *PendingBodyMeta) {
+	data, err := rlp.EncodeToBytes(meta)
+	if err != nil {
+		log.Fatal("Failed to RLP encode pending block body metadata", "err", err)
+	}
+	if err := db.Put(pbStoreMetaKey(hash), data); err != nil {
+		log.Fatal("Failed to store pending block body metadata", "err", err)
+	}
+}
+
+// ReadPendingBody retrieves the persisted pending block body corresponding to
+// the hash.
+func ReadPendingBody(db ethdb.KeyValueReader, hash common.Hash) *types.Body {
+	data, _ := db.Get(pbStoreBodyKey(hash))
+	if len(data) == 0 {
+		return nil
+	}
+	body := new(types.Body)
+	if err := rlp.DecodeBytes(data, body); err != nil {
+		log.Error("Invalid pending block body RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return body
+}
+
+// WritePendingBody stores a pending block body along with its metadata. The
+// metadata is what marks the body as present, so the two should be written in
+// the same batch for a crash not to leave either behind.
+func WritePendingBody(db ethdb.KeyValueWriter, hash common.Hash, body *types.Body, meta *PendingBodyMeta) {
+	data, err := rlp.EncodeToBytes(body)
+	if err != nil {
+		log.Fatal("Failed to RLP encode pending block body", "err", err)
+	}
+	if err := db.Put(pbStoreBodyKey(hash), data); err != nil {
+		log.Fatal("Failed to store pending block body", "err", err)
+	}
+	WritePendingBodyMeta(db, hash, meta)
+}
+
+// DeletePendingBody removes a persisted pending block body and its metadata.
+func DeletePendingBody(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Delete(pbStoreMetaKey(hash)); err != nil {
+		log.Fatal("Failed to delete pending block body metadata", "err", err)
+	}
+	if err := db.Delete(pbStoreBodyKey(hash)); err != nil {
+		log.Fatal("Failed to delete pending block body", "err", err)
+	}
+}
+
+// ReadAllPendingBodyHashes retrieves the hashes of all the persisted pending
+// block bodies.
+func ReadAllPendingBodyHashes(db ethdb.Iteratee) []common.Hash {
+	var hashes []common.Hash
+	it := db.NewIterator(pbStoreMetaPrefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		if key := it.Key(); len(key) == len(pbStoreMetaPrefix)+common.HashLength {
+			hashes = append(hashes, common.BytesToHash(key[len(pbStoreMetaPrefix):]))
+		}
+	}
+	return hashes
+}
+
+// ReadSealedBlock retrieves the journaled sealed block corresponding to the hash.
+func ReadSealedBlock(db ethdb.KeyValueReader, hash common.Hash) *types.Block {
+	data, _ := db.Get(sealedBlockKey(hash))
+	if len(data) == 0 {
+		return nil
+	}
+	block := new(types.Block)
+	if err := rlp.DecodeBytes(data, block); err != nil {
+		log.Error("Invalid sealed block RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return block
+}
+
+// WriteSealedBlock journals a sealed block until it is imported into the chain.
+func WriteSealedBlock(db ethdb.KeyValueWriter, block *types.Block) {
+	data, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		log.Fatal("Failed to RLP encode sealed block", "err", err)
+	}
+	if err := db.Put(sealedBlockKey(block.Hash()), data); err != nil {
+		log.Fatal("Failed to store sealed block", "err", err)
+	}
+}
+
+// DeleteSealedBlock removes a journaled sealed block.
+func DeleteSealedBlock(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Delete(sealedBlockKey(hash)); err != nil {
+		log.Fatal("Failed to delete sealed block", "err", err)
+	}
+}
+
+// ReadAllSealedBlockHashes retrieves the hashes of all the journaled sealed blocks.
+func ReadAllSealedBlockHashes(db ethdb.Iteratee) []common.Hash {
+	var hashes []common.Hash
+	it := db.NewIterator(sealedBlockPrefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		if key := it.Key(); len(key) == len(sealedBlockPrefix)+common.HashLength {
+			hashes = append(hashes, common.BytesToHash(key[len(sealedBlockPrefix):]))
+		}
+	}
+	return hashes
+}
+
 // ReadHeadsHashes retreive's the heads hashes of the blockchain.
 func ReadTermini(db ethdb.Reader, hash common.Hash) *types.Termini {
 	key := terminiKey(hash)
@@ -0,0 +1,51 @@
+package rawdb
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+// Tests pending block body storage and retrieval operations.
+func TestPendingBodyStorage(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	uncle := types.EmptyHeader()
+	uncle.SetNumber(big.NewInt(1))
+	body := &types.Body{Uncles: []*types.Header{uncle}, SubManifest: types.BlockManifest{common.Hash{1}}}
+	hash := common.Hash{0xaa}
+
+	if entry := ReadPendingBody(db, hash); entry != nil {
+		t.Fatalf("non existent pending body returned: %v", entry)
+	}
+	WritePendingBody(db, hash, body, &PendingBodyMeta{Number: 2, Size: 100})
+	stored := ReadPendingBody(db, hash)
+	if stored == nil {
+		t.Fatalf("stored pending body not found")
+	}
+	if len(stored.Uncles) != 1 || stored.Uncles[0].Hash() != uncle.Hash() || len(stored.SubManifest) != 1 {
+		t.Fatalf("retrieved pending body mismatch: have %v, want %v", stored, body)
+	}
+	if meta := ReadPendingBodyMeta(db, hash); meta == nil || meta.Number != 2 || meta.Size != 100 {
+		t.Fatalf("retrieved pending body metadata mismatch: have %+v", meta)
+	}
+	// Only the complete keys of the metadata prefix are listed
+	other := common.Hash{0xbb}
+	WritePendingBodyMeta(db, other, &PendingBodyMeta{Number: 3})
+	db.Put(append(append([]byte{}, pbStoreMetaPrefix...), 0x01), []byte{0x01})
+	if hashes := ReadAllPendingBodyHashes(db); len(hashes) != 2 {
+		t.Fatalf("listed pending bodies mismatch: have %d, want %d", len(hashes), 2)
+	}
+	DeletePendingBody(db, hash)
+	if entry := ReadPendingBody(db, hash); entry != nil {
+		t.Fatalf("deleted pending body returned: %v", entry)
+	}
+	if meta := ReadPendingBodyMeta(db, hash); meta != nil {
+		t.Fatalf("deleted pending body metadata returned: %+v", meta)
+	}
+	if hashes := ReadAllPendingBodyHashes(db); len(hashes) != 1 || hashes[0] != other {
+		t.Fatalf("listed pending bodies mismatch: have %x, want %x", hashes, other)
+	}
+}
@@ -91,11 +91,14 @@ var (
 	candidateBodyPrefix = []byte("cb")    // candidateBodyPrefix + hash -> Body
 	pbBodyPrefix        = []byte("pb")    // pbBodyPrefix + hash -> *types.Body
 	pbBodyHashPrefix    = []byte("pbKey") // pbBodyPrefix -> []common.Hash
+	pbStoreBodyPrefix   = []byte("pv")    // pbStoreBodyPrefix + hash -> *types.Body
+	pbStoreMetaPrefix   = []byte("pm")    // pbStoreMetaPrefix + hash -> PendingBodyMeta
 	phTerminiPrefix     = []byte("pht")   // phTerminiPrefix + hash -> []common.Hash
 	phBodyPrefix        = []byte("pc")    // phBodyPrefix + hash -> []common.Hash + Td
 	terminiPrefix       = []byte("tk")    //terminiPrefix + hash -> []common.Hash
 	badHashesListPrefix = []byte("bh")
 	inboundEtxsPrefix   = []byte("ie") // inboundEtxsPrefix + hash -> types.Transactions
+	sealedBlockPrefix   = []byte("sj") // sealedBlockPrefix + hash -> sealed block awaiting import
 
 	blockBodyPrefix         = []byte("b")  // blockBodyPrefix + num (uint64 big endian) + hash -> block body
 	blockReceiptsPrefix     = []byte("r")  // blockReceiptsPrefix + num (uint64 big endian) + hash -> block receipts
@@ -197,6 +200,21 @@ func pbBodyHashKey() []byte {
 	return pbBodyHashPrefix
 }
 
+// pbStoreBodyKey = pbStoreBodyPrefix + hash
+func pbStoreBodyKey(hash common.Hash) []byte {
+	return append(pbStoreBodyPrefix, hash.Bytes()...)
+}
+
+// pbStoreMetaKey = pbStoreMetaPrefix + hash
+func pbStoreMetaKey(hash common.Hash) []byte {
+	return append(pbStoreMetaPrefix, hash.Bytes()...)
+}
+
+// sealedBlockKey = sealedBlockPrefix + hash
+func sealedBlockKey(hash common.Hash) []byte {
+	return append(sealedBlockPrefix, hash.Bytes()...)
+}
+
 // phBodyTerminiKey = phTerminiPrefix + hash
 func phBodyTerminiKey(hash common.Hash) []byte {
 	return append(phTerminiPrefix, hash.Bytes()...)
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+const (
+	// adaptiveRecommitWindow is the number of recent zone blocks the adaptive
+	// recommit interval is derived from.
+	adaptiveRecommitWindow = 32
+
+	// adaptiveRecommitsPerBlock is the number of times the sealing work is aimed
+	// to be recommitted within an average block interval.
+	adaptiveRecommitsPerBlock = 4
+
+	// adaptiveEtxLoadHalf is the average number of ETXs per block at which the
+	// adaptive recommit interval is shortened by a quarter. Blocks emitting many
+	// ETXs change the pending state more, so the work is refreshed more often.
+	adaptiveEtxLoadHalf = 16
+)
+
+// RecommitStats is the state of the recommit interval controller.
+type RecommitStats struct {
+	Adaptive         bool
+	Recommit         time.Duration // Current recommit interval
+	Min              time.Duration // Lower bound of the adaptive recommit interval
+	Max              time.Duration // Upper bound of the adaptive recommit interval
+	Target           time.Duration // Last interval targeted by the controller, zero if none
+	AvgBlockInterval time.Duration // Average interval of the recent zone blocks
+	AvgEtxs          float64       // Average number of ETXs emitted by the recent zone blocks
+	Samples          int           // Number of recent zone blocks observed
+}
+
+// recommitController tracks the intervals and ETX counts of the recent zone
+// blocks to derive the recommit interval of the sealing work.
+type recommitController struct {
+	min, max time.Duration
+
+	mu        sync.Mutex
+	intervals []time.Duration
+	etxs      []int
+	next      int // Index of the oldest sample once the window is full
+	target    time.Duration
+}
+
+func newRecommitController(min, max time.Duration) *recommitController {
+	if max < min {
+		max = min
+	}
+	return &recommitController{min: min, max: max}
+}
+
+// observe records a new zone block given its parent header, and returns the
+// recommit interval targeted after it.
+func (c *recommitController) observe(block *types.Block, parent *types.Header) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var interval time.Duration
+	if block.Time() > parent.Time() {
+		interval = time.Duration(block.Time()-parent.Time()) * time.Second
+	}
+	if len(c.intervals) < adaptiveRecommitWindow {
+		c.intervals = append(c.intervals, interval)
+		c.etxs = append(c.etxs, len(block.ExtTransactions()))
+	} else {
+		c.intervals[c.next] = interval
+		c.etxs[c.next] = len(block.ExtTransactions())
+		c.next = (c.next + 1) % adaptiveRecommitWindow
+	}
+	avgInterval, avgEtxs := c.averages()
+
+	load := avgEtxs / (avgEtxs + adaptiveEtxLoadHalf)
+	target := time.Duration(float64(avgInterval) / adaptiveRecommitsPerBlock * (1 - load/2))
+	if target < c.min {
+		target = c.min
+	}
+	if target > c.max {
+		target = c.max
+	}
+	c.target = target
+	return target
+}
+
+// averages returns the average block interval and ETX count of the recorded
+// samples. It assumes the lock is held.
+func (c *recommitController) averages() (time.Duration, float64) {
+	if len(c.intervals) == 0 {
+		return 0, 0
+	}
+	var (
+		intervals time.Duration
+		etxs      int
+	)
+	for i := range c.intervals {
+		intervals += c.intervals[i]
+		etxs += c.etxs[i]
+	}
+	return intervals / time.Duration(len(c.intervals)), float64(etxs) / float64(len(c.etxs))
+}
+
+// stats fills the controller state into the given recommit statistics.
+func (c *recommitController) stats(stats *RecommitStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats.Min, stats.Max = c.min, c.max
+	stats.Target = c.target
+	stats.AvgBlockInterval, stats.AvgEtxs = c.averages()
+	stats.Samples = len(c.intervals)
+}
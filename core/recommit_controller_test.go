@@ -0,0 +1,102 @@
+package core
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveRecommitBounds(t *testing.T) {
+	tests := []struct {
+		config   Config
+		recommit time.Duration
+		min, max time.Duration
+	}{
+		{Config{}, 3 * time.Second, 3 * time.Second, 12 * time.Second},
+		{Config{MaxRecommitInterval: 8 * time.Second}, 3 * time.Second, 3 * time.Second, 8 * time.Second},
+		{Config{AdaptiveRecommitMin: 2 * time.Second, AdaptiveRecommitMax: 5 * time.Second}, 3 * time.Second, 2 * time.Second, 5 * time.Second},
+		// A floor below the minimal interval is clamped, not reset to the recommit
+		{Config{AdaptiveRecommitMin: time.Millisecond}, 3 * time.Second, minRecommitInterval, 12 * time.Second},
+		// A ceiling below the floor is raised to it
+		{Config{MaxRecommitInterval: 500 * time.Millisecond}, minRecommitInterval, minRecommitInterval, minRecommitInterval},
+	}
+	for i, tt := range tests {
+		min, max := adaptiveRecommitBounds(&tt.config, tt.recommit)
+		if min != tt.min || max != tt.max {
+			t.Errorf("test %d: bounds mismatch: have [%v, %v], want [%v, %v]", i, min, max, tt.min, tt.max)
+		}
+	}
+}
+
+func TestSanitizeRecommit(t *testing.T) {
+	tests := []struct {
+		interval, max time.Duration
+		want          time.Duration
+	}{
+		{3 * time.Second, 0, 3 * time.Second},
+		{time.Millisecond, 0, minRecommitInterval},
+		{10 * time.Second, 5 * time.Second, 5 * time.Second},
+		// A ceiling below the minimal interval doesn't take the interval under it
+		{3 * time.Second, 500 * time.Millisecond, minRecommitInterval},
+	}
+	for i, tt := range tests {
+		if have := sanitizeRecommit(tt.interval, tt.max); have != tt.want {
+			t.Errorf("test %d: interval mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that the interval adjusted by feedback stays above the minimal one, even
+// with a ceiling configured below it.
+func TestRecalcRecommitFloor(t *testing.T) {
+	prev := minRecommitInterval
+	for i := 0; i < 10; i++ {
+		prev = recalcRecommit(minRecommitInterval, 500*time.Millisecond, prev, float64(4*time.Second), true)
+		if prev < minRecommitInterval {
+			t.Fatalf("increase %d: interval below the minimum: %v", i, prev)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		prev = recalcRecommit(minRecommitInterval, 0, prev, float64(minRecommitInterval), false)
+		if prev < minRecommitInterval {
+			t.Fatalf("decrease %d: interval below the minimum: %v", i, prev)
+		}
+	}
+}
+
+func TestPushRecommitTarget(t *testing.T) {
+	w := &worker{recommitTargetCh: make(chan time.Duration, 1)}
+
+	done := make(chan struct{})
+	go func() {
+		w.pushRecommitTarget(time.Second)
+		w.pushRecommitTarget(2 * time.Second) // the loop isn't draining
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("pushing a target blocked on a busy recommit loop")
+	}
+	if target := <-w.recommitTargetCh; target != time.Second {
+		t.Errorf("pushed target mismatch: have %v, want %v", target, time.Second)
+	}
+}
+
+func TestResubmitDeadline(t *testing.T) {
+	w := &worker{config: &Config{}}
+
+	// A pending header generated since the timer was armed postpones the resubmit
+	atomic.StoreInt64(&w.recommitAt, time.Now().Add(time.Hour).UnixNano())
+	if next := w.resubmit(3 * time.Second); next <= 3*time.Second {
+		t.Fatalf("resubmit before the deadline: next check in %v", next)
+	}
+	// Past the deadline the next one is scheduled a recommit interval later
+	atomic.StoreInt64(&w.recommitAt, time.Now().Add(-time.Second).UnixNano())
+	if next := w.resubmit(3 * time.Second); next != 3*time.Second {
+		t.Fatalf("next check mismatch: have %v, want %v", next, 3*time.Second)
+	}
+	if remaining := w.TimeToNextRecommit(); remaining <= 0 || remaining > 3*time.Second {
+		t.Errorf("scheduled recommit mismatch: have %v, want up to %v", remaining, 3*time.Second)
+	}
+}
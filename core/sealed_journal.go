@@ -0,0 +1,97 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/ethdb"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/metrics"
+)
+
+var (
+	sealedJournalGauge   = metrics.NewRegisteredGauge("miner/sealed/journaled", nil)
+	sealedReplayedMeter  = metrics.NewRegisteredMeter("miner/sealed/replayed", nil)
+	sealedStaleDropMeter = metrics.NewRegisteredMeter("miner/sealed/stale", nil)
+)
+
+// sealedJournal persists the locally sealed blocks until they are imported into
+// the chain, so that a block sealed right before a restart is not lost with the
+// in-memory append queue.
+type sealedJournal struct {
+	db ethdb.Database
+
+	mu     sync.Mutex
+	blocks map[common.Hash]uint64 // Numbers of the journaled blocks by hash
+}
+
+func newSealedJournal(db ethdb.Database) *sealedJournal {
+	return &sealedJournal{db: db, blocks: make(map[common.Hash]uint64)}
+}
+
+// add journals a sealed block.
+func (j *sealedJournal) add(block *types.Block) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.blocks[block.Hash()]; ok {
+		return
+	}
+	rawdb.WriteSealedBlock(j.db, block)
+	j.blocks[block.Hash()] = block.NumberU64()
+	sealedJournalGauge.Update(int64(len(j.blocks)))
+}
+
+// prune removes the journaled blocks which were imported or became too deep
+// below the given chain head to be imported anymore.
+func (j *sealedJournal) prune(hc *HeaderChain, head *types.Block) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for hash, number := range j.blocks {
+		if number+staleThreshold <= head.NumberU64() || hc.GetHeaderByHash(hash) != nil {
+			rawdb.DeleteSealedBlock(j.db, hash)
+			delete(j.blocks, hash)
+		}
+	}
+	sealedJournalGauge.Update(int64(len(j.blocks)))
+}
+
+// replay loads the blocks journaled before the last shutdown and passes the
+// ones still importable on top of the given chain head to write. Stale and
+// already imported blocks are discarded.
+func (j *sealedJournal) replay(hc *HeaderChain, head *types.Block, write func(block *types.Block)) {
+	var replayed, stale int
+	for _, hash := range rawdb.ReadAllSealedBlockHashes(j.db) {
+		block := rawdb.ReadSealedBlock(j.db, hash)
+		if block == nil {
+			rawdb.DeleteSealedBlock(j.db, hash)
+			continue
+		}
+		if block.NumberU64()+staleThreshold <= head.NumberU64() {
+			rawdb.DeleteSealedBlock(j.db, hash)
+			stale++
+			continue
+		}
+		if hc.GetHeaderByHash(hash) != nil {
+			rawdb.DeleteSealedBlock(j.db, hash)
+			continue
+		}
+		j.mu.Lock()
+		j.blocks[hash] = block.NumberU64()
+		j.mu.Unlock()
+
+		write(block)
+		replayed++
+	}
+	sealedReplayedMeter.Mark(int64(replayed))
+	sealedStaleDropMeter.Mark(int64(stale))
+
+	j.mu.Lock()
+	sealedJournalGauge.Update(int64(len(j.blocks)))
+	j.mu.Unlock()
+
+	if replayed > 0 || stale > 0 {
+		log.Info("Replayed journaled sealed blocks", "replayed", replayed, "stale", stale)
+	}
+}
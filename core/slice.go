@@ -350,6 +350,9 @@ func (sl *Slice) Append(header *types.Header, domPendingHeader *types.Header, do
 
 	if subReorg {
 		sl.hc.chainHeadFeed.Send(ChainHeadEvent{Block: block})
+	} else if !setHead {
+		// The block is on a side chain, the worker keeps it as a possible uncle
+		sl.hc.chainSideFeed.Send(ChainSideEvent{Block: block})
 	}
 
 	// Relay the new pendingHeader
@@ -400,7 +403,7 @@ func (sl *Slice) relayPh(block *types.Block, pendingHeaderWithTermini types.Pend
 		bestPh, exists := sl.readPhCache(sl.bestPhKey)
 		if exists {
 			bestPh.Header().SetLocation(common.NodeLocation)
-			sl.miner.worker.pendingHeaderFeed.Send(bestPh.Header())
+			sl.miner.worker.sendPendingHeader(bestPh.Header())
 			return
 		} else {
 			log.Warn("Pending Header for Best ph key does not exist", "best ph key", sl.bestPhKey)
@@ -504,7 +507,7 @@ func (sl *Slice) asyncPendingHeaderLoop() {
 			bestPh, exists := sl.readPhCache(sl.bestPhKey)
 			if exists {
 				bestPh.Header().SetLocation(common.NodeLocation)
-				sl.miner.worker.pendingHeaderFeed.Send(bestPh.Header())
+				sl.miner.worker.sendPendingHeader(bestPh.Header())
 			}
 		case <-sl.asyncPhSub.Err():
 			return
@@ -704,6 +707,9 @@ func (sl *Slice) poem(externS *big.Int, currentS *big.Int) bool {
 
 // GetPendingHeader is used by the miner to request the current pending header
 func (sl *Slice) GetPendingHeader() (*types.Header, error) {
+	if sl.miner.worker.isDryRun() {
+		return nil, errDryRun
+	}
 	if ph, exists := sl.readPhCache(sl.bestPhKey); exists {
 		return ph.Header(), nil
 	} else {
@@ -829,13 +835,14 @@ func (sl *Slice) SubRelayPendingHeader(pendingHeader types.PendingHeader, newEnt
 			if err != nil {
 				return
 			}
+			sl.hc.domHeaderFeed.Send(DomHeaderEvent{Header: pendingHeader.Header(), Order: order})
 		}
 
 		if !bytes.Equal(location, common.NodeLocation) {
 			bestPh, exists := sl.readPhCache(sl.bestPhKey)
 			if exists {
 				bestPh.Header().SetLocation(common.NodeLocation)
-				sl.miner.worker.pendingHeaderFeed.Send(bestPh.Header())
+				sl.miner.worker.sendPendingHeader(bestPh.Header())
 			}
 		}
 	}
@@ -1103,6 +1110,9 @@ func (sl *Slice) ConstructLocalBlock(header *types.Header) (*types.Block, error)
 // body from the workers pendingBlockBodyCache. This method is used when the miner sends in the
 // header.
 func (sl *Slice) ConstructLocalMinedBlock(header *types.Header) (*types.Block, error) {
+	if sl.miner.worker.isDryRun() {
+		return nil, errDryRun
+	}
 	nodeCtx := common.NodeLocation.Context()
 	var pendingBlockBody *types.Body
 	if nodeCtx == common.ZONE_CTX {
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+// maxSpeculativeResults is the maximum number of pre-execution results kept.
+const maxSpeculativeResults = 4
+
+// speculativeResult is the outcome of pre-executing the pending transactions on
+// top of the state of a pending block, for reuse when building its child.
+type speculativeResult struct {
+	number uint64                   // Number of the block the transactions were pre-executed for
+	failed map[common.Hash]struct{} // Transactions found bound to fail
+}
+
+// prune removes the transactions found bound to fail, along with the following
+// ones of the same sender, from the given pending set. It returns the number of
+// transactions removed.
+func (r *speculativeResult) prune(pending map[common.AddressBytes]types.Transactions) int {
+	var pruned int
+	for sender, txs := range pending {
+		for i, tx := range txs {
+			if _, ok := r.failed[tx.Hash()]; !ok {
+				continue
+			}
+			pruned += len(txs) - i
+			if i == 0 {
+				delete(pending, sender)
+			} else {
+				pending[sender] = txs[:i]
+			}
+			break
+		}
+	}
+	return pruned
+}
+
+// pendingParentChain is a chain context resolving the header of a pending block,
+// not in the chain yet, for its child to be executed on top of it.
+type pendingParentChain struct {
+	ChainContext
+	parent *types.Header
+}
+
+// GetHeader implements ChainContext, returning the pending parent if requested.
+func (c *pendingParentChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if hash == c.parent.Hash() && number == c.parent.NumberU64() {
+		return c.parent
+	}
+	return c.ChainContext.GetHeader(hash, number)
+}
+
+// speculativeResults keeps the pre-execution results keyed by the seal hash of
+// the parent they were computed on, which is known before the parent is sealed
+// and stays the same once it is.
+type speculativeResults struct {
+	mu      sync.Mutex
+	results map[common.Hash]*speculativeResult
+}
+
+func newSpeculativeResults() *speculativeResults {
+	return &speculativeResults{results: make(map[common.Hash]*speculativeResult)}
+}
+
+// add records the result of a pre-execution on top of the given parent, dropping
+// the one for the lowest block if too many are kept.
+func (s *speculativeResults) add(parent common.Hash, result *speculativeResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.results[parent] = result
+	for len(s.results) > maxSpeculativeResults {
+		var (
+			oldest common.Hash
+			lowest *speculativeResult
+		)
+		for hash, result := range s.results {
+			if lowest == nil || result.number < lowest.number {
+				oldest, lowest = hash, result
+			}
+		}
+		delete(s.results, oldest)
+	}
+}
+
+// get returns the result of the pre-execution on top of the given parent.
+func (s *speculativeResults) get(parent common.Hash) *speculativeResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.results[parent]
+}
+
+// retain drops the results of all parents but the given one, which became the
+// chain head. Results computed on other parents can't be used anymore, be it due
+// to a reorg or to a block sealed elsewhere.
+func (s *speculativeResults) retain(parent common.Hash) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash := range s.results {
+		if hash != parent {
+			delete(s.results, hash)
+		}
+	}
+}
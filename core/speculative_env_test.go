@@ -0,0 +1,85 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/params"
+)
+
+func TestSpeculativeResultPrune(t *testing.T) {
+	accounts, _ := newTestAccounts(t, 2)
+	first, second := accounts[0], accounts[1]
+	firstTxs := types.Transactions{first.transfer(t, 0, params.GWei), first.transfer(t, 1, params.GWei), first.transfer(t, 2, params.GWei)}
+	secondTxs := types.Transactions{second.transfer(t, 0, params.GWei)}
+
+	pending := map[common.AddressBytes]types.Transactions{
+		first.address().Bytes20():  firstTxs,
+		second.address().Bytes20(): secondTxs,
+	}
+	// A failing transaction drops the following ones of the sender too
+	result := &speculativeResult{failed: map[common.Hash]struct{}{
+		firstTxs[1].Hash():  {},
+		secondTxs[0].Hash(): {},
+	}}
+	if pruned := result.prune(pending); pruned != 3 {
+		t.Fatalf("pruned transactions mismatch: have %d, want %d", pruned, 3)
+	}
+	if have := pending[first.address().Bytes20()]; len(have) != 1 || have[0].Hash() != firstTxs[0].Hash() {
+		t.Errorf("transactions kept before the failing one mismatch: have %d", len(have))
+	}
+	if _, ok := pending[second.address().Bytes20()]; ok {
+		t.Errorf("sender without transactions left kept")
+	}
+}
+
+func TestSpeculativeResultsRetention(t *testing.T) {
+	results := newSpeculativeResults()
+	for i := 0; i <= maxSpeculativeResults; i++ {
+		results.add(common.Hash{byte(i)}, &speculativeResult{number: uint64(i)})
+	}
+	// The result for the lowest block is dropped first
+	if results.get(common.Hash{0}) != nil {
+		t.Errorf("result for the lowest block kept")
+	}
+	if results.get(common.Hash{maxSpeculativeResults}) == nil {
+		t.Errorf("result for the highest block dropped")
+	}
+	// Only the result on top of the new head survives it
+	results.retain(common.Hash{1})
+	if results.get(common.Hash{1}) == nil {
+		t.Errorf("result on top of the head dropped")
+	}
+	if results.get(common.Hash{2}) != nil {
+		t.Errorf("result on top of another block kept")
+	}
+}
+
+// Tests that the child of a pending block is pre-executed with the block context
+// it will be executed with once its parent is in the chain.
+func TestPendingParentBlockContext(t *testing.T) {
+	common.NodeLocation = common.Location{0, 0}
+
+	parent := types.EmptyHeader()
+	parent.SetNumber(new(big.Int).SetUint64(params.CarbonForkBlockNumber + 1))
+	parent.SetTime(1000)
+
+	child := types.EmptyHeader()
+	child.SetParentHash(parent.Hash())
+	child.SetNumber(new(big.Int).SetUint64(params.CarbonForkBlockNumber + 2))
+	child.SetTime(parent.Time() + 1)
+
+	var coinbase common.Address
+	blockCtx := NewEVMBlockContext(child, &pendingParentChain{parent: parent}, &coinbase)
+	if blockCtx.Time.Uint64() != parent.Time() {
+		t.Errorf("block context time mismatch: have %d, want %d", blockCtx.Time, parent.Time())
+	}
+	if blockCtx.BlockNumber.Cmp(child.Number()) != 0 {
+		t.Errorf("block context number mismatch: have %d, want %d", blockCtx.BlockNumber, child.Number())
+	}
+	if have := blockCtx.GetHash(parent.NumberU64()); have != parent.Hash() {
+		t.Errorf("parent hash mismatch: have %x, want %x", have, parent.Hash())
+	}
+}
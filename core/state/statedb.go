@@ -171,6 +171,17 @@ func (s *StateDB) StopPrefetcher() {
 	}
 }
 
+// PrefetcherStats returns the number of items queued up in the trie prefetcher
+// along with the number of tries it delivered and missed, and whether there is
+// a prefetcher at all.
+func (s *StateDB) PrefetcherStats() (pending int, hits, misses uint64, ok bool) {
+	if s.prefetcher == nil {
+		return 0, 0, 0, false
+	}
+	pending, hits, misses = s.prefetcher.stats()
+	return pending, hits, misses, true
+}
+
 // setError remembers the first non-nil error it is called with.
 func (s *StateDB) setError(err error) {
 	if s.dbErr == nil {
@@ -18,6 +18,7 @@ package state
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/log"
@@ -40,6 +41,9 @@ type triePrefetcher struct {
 	fetches  map[common.Hash]Trie        // Partially or fully fetcher tries
 	fetchers map[common.Hash]*subfetcher // Subfetchers for each trie
 
+	hits   uint64 // Number of tries delivered from the prefetcher, accessed atomically
+	misses uint64 // Number of tries requested but not prefetched, accessed atomically
+
 	deliveryMissMeter metrics.Meter
 	accountLoadMeter  metrics.Meter
 	accountDupMeter   metrics.Meter
@@ -161,14 +165,17 @@ func (p *triePrefetcher) trie(root common.Hash) Trie {
 		trie := p.fetches[root]
 		if trie == nil {
 			p.deliveryMissMeter.Mark(1)
+			atomic.AddUint64(&p.misses, 1)
 			return nil
 		}
+		atomic.AddUint64(&p.hits, 1)
 		return p.db.CopyTrie(trie)
 	}
 	// Otherwise the prefetcher is active, bail if no trie was prefetched for this root
 	fetcher := p.fetchers[root]
 	if fetcher == nil {
 		p.deliveryMissMeter.Mark(1)
+		atomic.AddUint64(&p.misses, 1)
 		return nil
 	}
 	// Interrupt the prefetcher if it's by any chance still running and return
@@ -178,8 +185,10 @@ func (p *triePrefetcher) trie(root common.Hash) Trie {
 	trie := fetcher.peek()
 	if trie == nil {
 		p.deliveryMissMeter.Mark(1)
+		atomic.AddUint64(&p.misses, 1)
 		return nil
 	}
+	atomic.AddUint64(&p.hits, 1)
 	return trie
 }
 
@@ -191,6 +200,17 @@ func (p *triePrefetcher) used(root common.Hash, used [][]byte) {
 	}
 }
 
+// stats returns the number of items queued up for retrieval, along with the
+// number of tries delivered from and missed in the prefetcher.
+func (p *triePrefetcher) stats() (pending int, hits, misses uint64) {
+	for _, fetcher := range p.fetchers {
+		fetcher.lock.Lock()
+		pending += len(fetcher.tasks)
+		fetcher.lock.Unlock()
+	}
+	return pending, atomic.LoadUint64(&p.hits), atomic.LoadUint64(&p.misses)
+}
+
 // subfetcher is a trie fetcher goroutine responsible for pulling entries for a
 // single trie. It is spawned when a new root is encountered and lives until the
 // main prefetcher is paused and either all requested items are processed or if
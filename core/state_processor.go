@@ -228,7 +228,7 @@ func (p *StateProcessor) Process(block *types.Block, etxSet types.EtxSet) (types
 	numInternalTxs := 0
 	p.hc.pool.SendersMutex.RLock()
 	for _, tx := range block.Transactions() { // get all senders of internal txs from cache - easier on the SendersMutex to do it all at once here
-		if tx.Type() == types.InternalTxType || tx.Type() == types.InternalToExternalTxType {
+		if tx.Type() == types.InternalTxType || tx.Type() == types.InternalToExternalTxType || tx.Type() == types.SponsoredTxType {
 			numInternalTxs++
 			if sender, ok := p.hc.pool.GetSenderThreadUnsafe(tx.Hash()); ok {
 				senders[tx.Hash()] = &sender // This pointer must never be modified
@@ -254,6 +254,10 @@ func (p *StateProcessor) Process(block *types.Block, etxSet types.EtxSet) (types
 	}
 
 	for i, tx := range block.Transactions() {
+		// Sponsored transactions are only valid from their fork block on
+		if tx.Type() == types.SponsoredTxType && !p.config.IsSponsoredTx(blockNumber) {
+			return nil, nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), ErrTxTypeNotSupported)
+		}
 		startProcess := time.Now()
 		msg, err := tx.AsMessageWithSender(types.MakeSigner(p.config, header.Number()), header.BaseFee(), senders[tx.Hash()])
 		if err != nil {
@@ -275,7 +279,7 @@ func (p *StateProcessor) Process(block *types.Block, etxSet types.EtxSet) (types
 				return nil, nil, nil, 0, fmt.Errorf("invalid external transaction: etx %x not found in unspent etx set", tx.Hash())
 			}
 			prevZeroBal := prepareApplyETX(statedb, &etxEntry.ETX)
-			receipt, err = applyTransaction(msg, p.config, p.hc, nil, gp, statedb, blockNumber, blockHash, &etxEntry.ETX, usedGas, vmenv, &etxRLimit, &etxPLimit)
+			receipt, err = applyTransaction(msg, p.config, p.hc, nil, gp, statedb, blockNumber, blockHash, &etxEntry.ETX, usedGas, vmenv, &etxRLimit, &etxPLimit, nil)
 			statedb.SetBalance(common.ZeroInternal, prevZeroBal) // Reset the balance to what it previously was. Residual balance will be lost
 
 			if err != nil {
@@ -286,10 +290,10 @@ func (p *StateProcessor) Process(block *types.Block, etxSet types.EtxSet) (types
 			timeEtxDelta := time.Since(startTimeEtx)
 			timeEtx += timeEtxDelta
 
-		} else if tx.Type() == types.InternalTxType || tx.Type() == types.InternalToExternalTxType {
+		} else if tx.Type() == types.InternalTxType || tx.Type() == types.InternalToExternalTxType || tx.Type() == types.SponsoredTxType {
 			startTimeTx := time.Now()
 
-			receipt, err = applyTransaction(msg, p.config, p.hc, nil, gp, statedb, blockNumber, blockHash, tx, usedGas, vmenv, &etxRLimit, &etxPLimit)
+			receipt, err = applyTransaction(msg, p.config, p.hc, nil, gp, statedb, blockNumber, blockHash, tx, usedGas, vmenv, &etxRLimit, &etxPLimit, nil)
 			if err != nil {
 				return nil, nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
 			}
@@ -316,7 +320,15 @@ func (p *StateProcessor) Process(block *types.Block, etxSet types.EtxSet) (types
 	return receipts, allLogs, statedb, *usedGas, nil
 }
 
-func applyTransaction(msg types.Message, config *params.ChainConfig, bc ChainContext, author *common.Address, gp *GasPool, statedb *state.StateDB, blockNumber *big.Int, blockHash common.Hash, tx *types.Transaction, usedGas *uint64, evm *vm.EVM, etxRLimit, etxPLimit *int) (*types.Receipt, error) {
+// resultCheck vets the outcome of a transaction before its state changes are
+// finalised, the transaction is rejected if it returns an error.
+type resultCheck func(result *ExecutionResult) error
+
+func applyTransaction(msg types.Message, config *params.ChainConfig, bc ChainContext, author *common.Address, gp *GasPool, statedb *state.StateDB, blockNumber *big.Int, blockHash common.Hash, tx *types.Transaction, usedGas *uint64, evm *vm.EVM, etxRLimit, etxPLimit *int, check resultCheck) (*types.Receipt, error) {
+	// Sponsored transactions are only valid from their fork block on
+	if tx.Type() == types.SponsoredTxType && !config.IsSponsoredTx(blockNumber) {
+		return nil, ErrTxTypeNotSupported
+	}
 	// Create a new context to be used in the EVM environment.
 	txContext := NewEVMTxContext(msg)
 	evm.Reset(txContext, statedb)
@@ -326,26 +338,14 @@ func applyTransaction(msg types.Message, config *params.ChainConfig, bc ChainCon
 	if err != nil {
 		return nil, err
 	}
-	var ETXRCount int
-	var ETXPCount int
-	for _, tx := range result.Etxs {
-		// Count which ETXs are cross-region
-		if tx.To().Location().CommonDom(common.NodeLocation).Context() == common.REGION_CTX {
-			ETXRCount++
-		}
-		// Count which ETXs are cross-prime
-		if tx.To().Location().CommonDom(common.NodeLocation).Context() == common.PRIME_CTX {
-			ETXPCount++
+	if check != nil {
+		if err := check(result); err != nil {
+			return nil, err
 		}
 	}
-	if ETXRCount > *etxRLimit {
-		return nil, fmt.Errorf("tx %032x emits too many cross-region ETXs for block. emitted: %d, limit: %d", tx.Hash(), ETXRCount, *etxRLimit)
-	}
-	if ETXPCount > *etxPLimit {
-		return nil, fmt.Errorf("tx %032x emits too many cross-prime ETXs for block. emitted: %d, limit: %d", tx.Hash(), ETXPCount, *etxPLimit)
+	if err := checkEtxLimits(tx, result.Etxs, etxRLimit, etxPLimit); err != nil {
+		return nil, err
 	}
-	*etxRLimit -= ETXRCount
-	*etxPLimit -= ETXPCount
 
 	// Update the state with pending changes.
 	var root []byte
@@ -379,6 +379,33 @@ func applyTransaction(msg types.Message, config *params.ChainConfig, bc ChainCon
 	return receipt, err
 }
 
+// checkEtxLimits checks that the ETXs emitted by a transaction fit within the
+// remaining cross-region and cross-prime ETX limits of the block, and deducts
+// them from the limits if so.
+func checkEtxLimits(tx *types.Transaction, etxs types.Transactions, etxRLimit, etxPLimit *int) error {
+	var ETXRCount int
+	var ETXPCount int
+	for _, etx := range etxs {
+		// Count which ETXs are cross-region
+		if etx.To().Location().CommonDom(common.NodeLocation).Context() == common.REGION_CTX {
+			ETXRCount++
+		}
+		// Count which ETXs are cross-prime
+		if etx.To().Location().CommonDom(common.NodeLocation).Context() == common.PRIME_CTX {
+			ETXPCount++
+		}
+	}
+	if ETXRCount > *etxRLimit {
+		return fmt.Errorf("%w: tx %032x emits too many cross-region ETXs for block. emitted: %d, limit: %d", ErrEtxLimitReached, tx.Hash(), ETXRCount, *etxRLimit)
+	}
+	if ETXPCount > *etxPLimit {
+		return fmt.Errorf("%w: tx %032x emits too many cross-prime ETXs for block. emitted: %d, limit: %d", ErrEtxLimitReached, tx.Hash(), ETXPCount, *etxPLimit)
+	}
+	*etxRLimit -= ETXRCount
+	*etxPLimit -= ETXPCount
+	return nil
+}
+
 var lastWrite uint64
 
 // Apply State
@@ -444,6 +471,12 @@ func (p *StateProcessor) Apply(batch ethdb.Batch, block *types.Block, newInbound
 // for the transaction, gas used and an error if the transaction failed,
 // indicating the block was invalid.
 func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64, cfg vm.Config, etxRLimit, etxPLimit *int) (*types.Receipt, error) {
+	return applyCheckedTransaction(config, bc, author, gp, statedb, header, tx, usedGas, cfg, etxRLimit, etxPLimit, nil)
+}
+
+// applyCheckedTransaction applies a transaction like ApplyTransaction, rejecting
+// it without finalising its state changes if its outcome fails the given check.
+func applyCheckedTransaction(config *params.ChainConfig, bc ChainContext, author *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64, cfg vm.Config, etxRLimit, etxPLimit *int, check resultCheck) (*types.Receipt, error) {
 	msg, err := tx.AsMessage(types.MakeSigner(config, header.Number()), header.BaseFee())
 	if err != nil {
 		return nil, err
@@ -453,11 +486,11 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *commo
 	vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, config, cfg)
 	if tx.Type() == types.ExternalTxType {
 		prevZeroBal := prepareApplyETX(statedb, tx)
-		receipt, err := applyTransaction(msg, config, bc, author, gp, statedb, header.Number(), header.Hash(), tx, usedGas, vmenv, etxRLimit, etxPLimit)
+		receipt, err := applyTransaction(msg, config, bc, author, gp, statedb, header.Number(), header.Hash(), tx, usedGas, vmenv, etxRLimit, etxPLimit, check)
 		statedb.SetBalance(common.ZeroInternal, prevZeroBal) // Reset the balance to what it previously was (currently a failed external transaction removes all the sent coins from the supply and any residual balance is gone as well)
 		return receipt, err
 	}
-	return applyTransaction(msg, config, bc, author, gp, statedb, header.Number(), header.Hash(), tx, usedGas, vmenv, etxRLimit, etxPLimit)
+	return applyTransaction(msg, config, bc, author, gp, statedb, header.Number(), header.Hash(), tx, usedGas, vmenv, etxRLimit, etxPLimit, check)
 }
 
 // GetVMConfig returns the block chain VM config.
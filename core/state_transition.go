@@ -80,6 +80,7 @@ type Message interface {
 	Data() []byte
 	AccessList() types.AccessList
 	ETXSender() common.Address
+	FeePayer() *common.Address
 	Type() byte
 
 	ETXGasLimit() uint64
@@ -197,6 +198,15 @@ func (st *StateTransition) to() common.Address {
 	return *st.msg.To()
 }
 
+// payer returns the account paying for the gas of the message, the fee payer of
+// a sponsored transaction or the sender otherwise.
+func (st *StateTransition) payer() common.Address {
+	if payer := st.msg.FeePayer(); payer != nil {
+		return *payer
+	}
+	return st.msg.From()
+}
+
 func (st *StateTransition) buyGas() error {
 	mgval := new(big.Int).SetUint64(st.msg.Gas())
 	mgval = mgval.Mul(mgval, st.gasPrice)
@@ -204,14 +214,18 @@ func (st *StateTransition) buyGas() error {
 	if st.gasFeeCap != nil {
 		balanceCheck = new(big.Int).SetUint64(st.msg.Gas())
 		balanceCheck = balanceCheck.Mul(balanceCheck, st.gasFeeCap)
-		balanceCheck.Add(balanceCheck, st.value)
+		// The value of a sponsored transaction is checked against the sender
+		// balance before the transfer
+		if st.msg.FeePayer() == nil {
+			balanceCheck.Add(balanceCheck, st.value)
+		}
 	}
-	from, err := st.msg.From().InternalAddress()
+	payer, err := st.payer().InternalAddress()
 	if err != nil {
 		return err
 	}
-	if have, want := st.state.GetBalance(from), balanceCheck; have.Cmp(want) < 0 {
-		return fmt.Errorf("%w: address %v have %v want %v", ErrInsufficientFunds, st.msg.From().Hex(), have, want)
+	if have, want := st.state.GetBalance(payer), balanceCheck; have.Cmp(want) < 0 {
+		return fmt.Errorf("%w: address %v have %v want %v", ErrInsufficientFunds, st.payer().Hex(), have, want)
 	}
 	if err := st.gp.SubGas(st.msg.Gas()); err != nil {
 		return err
@@ -219,7 +233,7 @@ func (st *StateTransition) buyGas() error {
 	st.gas += st.msg.Gas()
 
 	st.initialGas = st.msg.Gas()
-	st.state.SubBalance(from, mgval)
+	st.state.SubBalance(payer, mgval)
 	return nil
 }
 
@@ -244,6 +258,17 @@ func (st *StateTransition) preCheck() error {
 		return fmt.Errorf("%w: address %v, codehash: %s", ErrSenderNoEOA,
 			st.msg.From().Hex(), codeHash)
 	}
+	// Make sure the fee payer of a sponsored transaction is an EOA
+	if payer := st.msg.FeePayer(); payer != nil {
+		internal, err := payer.InternalAddress()
+		if err != nil {
+			return err
+		}
+		if codeHash := st.state.GetCodeHash(internal); codeHash != emptyCodeHash && codeHash != (common.Hash{}) {
+			return fmt.Errorf("%w: address %v, codehash: %s", ErrPayerNoEOA,
+				payer.Hex(), codeHash)
+		}
+	}
 	// Make sure that transaction gasFeeCap is greater than the baseFee
 	// Skip the checks if gas fields are zero and baseFee was explicitly disabled (eth_call)
 	if !st.evm.Config.NoBaseFee || st.gasFeeCap.BitLen() > 0 || st.gasTipCap.BitLen() > 0 {
@@ -375,11 +400,11 @@ func (st *StateTransition) refundGas(refundQuotient uint64) {
 
 	// Return ETH for remaining gas, exchanged at the original rate.
 	remaining := new(big.Int).Mul(new(big.Int).SetUint64(st.gas), st.gasPrice)
-	from, err := st.msg.From().InternalAddress()
+	payer, err := st.payer().InternalAddress()
 	if err != nil {
 		return
 	}
-	st.state.AddBalance(from, remaining)
+	st.state.AddBalance(payer, remaining)
 
 	// Also return remaining gas to the block gas counter so it is
 	// available for the next transaction.
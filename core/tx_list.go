@@ -273,18 +273,20 @@ func (l *txList) Overlaps(tx *types.Transaction) bool {
 
 // Add tries to insert a new transaction into the list, returning whether the
 // transaction was accepted, and if yes, any previous transaction it replaced.
+// A transaction with the nonce of a listed one must meet the replacement
+// policy, local tying whether the sender is local.
 //
 // If the new transaction is accepted into the list, the lists' cost and gas
 // thresholds are also potentially updated.
-func (l *txList) Add(tx *types.Transaction, priceBump uint64) (bool, *types.Transaction) {
+func (l *txList) Add(tx *types.Transaction, policy ReplacementPolicy, local bool) (bool, *types.Transaction) {
 	// If there's an older better transaction, abort
 	old := l.txs.Get(tx.Nonce())
-	if old != nil {
+	if old != nil && !policy.allowsWithoutBump(old, tx, local) {
 		if old.GasFeeCapCmp(tx) >= 0 || old.GasTipCapCmp(tx) >= 0 {
 			return false, nil
 		}
 		// thresholdFeeCap = oldFC  * (100 + priceBump) / 100
-		a := big.NewInt(100 + int64(priceBump))
+		a := big.NewInt(100 + int64(policy.PriceBump))
 		aFeeCap := new(big.Int).Mul(a, old.GasFeeCap())
 		aTip := a.Mul(a, old.GasTipCap())
 
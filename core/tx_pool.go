@@ -17,6 +17,8 @@
 package core
 
 import (
+	"bytes"
+	"container/heap"
 	"errors"
 	"fmt"
 	"math"
@@ -27,6 +29,7 @@ import (
 
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/common/prque"
+	"github.com/dominant-strategies/go-quai/consensus"
 	"github.com/dominant-strategies/go-quai/consensus/misc"
 	"github.com/dominant-strategies/go-quai/core/state"
 	"github.com/dominant-strategies/go-quai/core/types"
@@ -66,6 +69,19 @@ var (
 	// ErrInvalidSender is returned if the transaction contains an invalid signature.
 	ErrInvalidSender = errors.New("invalid sender")
 
+	// ErrInvalidPayer is returned if a sponsored transaction contains an invalid
+	// fee payer signature.
+	ErrInvalidPayer = errors.New("invalid fee payer")
+
+	// ErrSelfSponsored is returned if the fee payer of a sponsored transaction is
+	// its sender.
+	ErrSelfSponsored = errors.New("fee payer is the sender")
+
+	// ErrPayerInsufficientFunds is returned if the fee payer of a sponsored
+	// transaction can't pay for its gas on top of the gas of the other transactions
+	// it sponsors in the pool.
+	ErrPayerInsufficientFunds = errors.New("insufficient fee payer funds for gas * price")
+
 	// ErrUnderpriced is returned if a transaction's gas price is below the minimum
 	// configured for the transaction pool.
 	ErrUnderpriced = errors.New("transaction underpriced")
@@ -90,6 +106,10 @@ var (
 	// than some meaningful limit a user might use. This is not a consensus error
 	// making the transaction invalid, rather a DOS protection.
 	ErrOversizedData = errors.New("oversized data")
+
+	// ErrInvalidPriceBump is returned if a replacement policy is set with a price
+	// bump below one percent.
+	ErrInvalidPriceBump = errors.New("price bump must be at least 1 percent")
 )
 
 var (
@@ -142,6 +162,8 @@ type blockChain interface {
 	CurrentBlock() *types.Block
 	GetBlock(hash common.Hash, number uint64) *types.Block
 	StateAt(root common.Hash) (*state.StateDB, error)
+	Engine() consensus.Engine
+	GetHeader(hash common.Hash, number uint64) *types.Header
 
 	SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Subscription
 }
@@ -151,11 +173,24 @@ type TxPoolConfig struct {
 	Locals    []common.InternalAddress // Addresses that should be treated by default as local
 	NoLocals  bool                     // Whether local transaction handling should be disabled
 	Journal   string                   // Journal of local transactions to survive node restarts
-	Rejournal time.Duration            // Time interval to regenerate the local transaction journal
+	Rejournal time.Duration            // Time interval to regenerate the local and remote transaction journals
+
+	RemoteJournal    string // Journal of the pending and queued remote transactions to survive node restarts ("" = disabled)
+	RemoteJournalCap uint64 // Maximum number of remote transactions persisted in the journal
+
+	EtxSlots uint64 // Maximum number of inbound ETXs offered for inclusion per block (0 = unlimited)
+
+	PrecheckExecution bool          // Execute incoming transactions against the latest state to screen out the reverting ones
+	PrecheckReject    bool          // Reject the transactions reverting in the precheck instead of tagging them for the miner to skip
+	PrecheckGas       uint64        // Gas limit above which transactions are not prechecked
+	PrecheckTimeout   time.Duration // Maximum execution time of a precheck
 
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
 
+	ReplaceGasLimitOnly   bool // Allow replacing a transaction by an identical one with a gas limit raised by the price bump, without price bump
+	ReplaceLocalSamePrice bool // Allow local transactions to be replaced at the same price, without price bump
+
 	AccountSlots    uint64 // Number of executable transaction slots guaranteed per account
 	GlobalSlots     uint64 // Maximum number of executable transaction slots for all accounts
 	MaxSenders      uint64 // Maximum number of senders in the senders cache
@@ -166,12 +201,59 @@ type TxPoolConfig struct {
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
 }
 
+// ReplacementPolicy are the rules a transaction must meet to replace a pooled
+// one with the same nonce.
+type ReplacementPolicy struct {
+	PriceBump      uint64 // Minimum price bump percentage of the fee cap and tip
+	GasLimitOnly   bool   // Whether a raise of the gas limit alone by the price bump is accepted without price bump
+	LocalSamePrice bool   // Whether local transactions are accepted at the same price without price bump
+}
+
+// allowsWithoutBump returns whether the policy lets tx replace old without
+// checking the price bump. A gas limit change alone has to raise the limit by
+// the price bump percentage, so that the replacements of a transaction are not
+// free to repeat.
+func (p ReplacementPolicy) allowsWithoutBump(old, tx *types.Transaction, local bool) bool {
+	sameFees := old.GasFeeCapCmp(tx) == 0 && old.GasTipCapCmp(tx) == 0
+	if p.GasLimitOnly && sameFees && sameExceptGas(old, tx) {
+		threshold := new(big.Int).SetUint64(old.Gas())
+		threshold.Mul(threshold, big.NewInt(100+int64(p.PriceBump)))
+		threshold.Div(threshold, big.NewInt(100))
+		if new(big.Int).SetUint64(tx.Gas()).Cmp(threshold) >= 0 {
+			return true
+		}
+	}
+	if p.LocalSamePrice && local && old.GasFeeCapCmp(tx) <= 0 && old.GasTipCapCmp(tx) <= 0 {
+		return true
+	}
+	return false
+}
+
+// sameExceptGas returns whether two transactions have the same recipient, value
+// and data, so that the fees and the gas limit are the only possible changes.
+func sameExceptGas(a, b *types.Transaction) bool {
+	if a.Type() != b.Type() || a.Value().Cmp(b.Value()) != 0 || !bytes.Equal(a.Data(), b.Data()) {
+		return false
+	}
+	aTo, bTo := a.To(), b.To()
+	if aTo == nil || bTo == nil {
+		return aTo == nil && bTo == nil
+	}
+	return aTo.Equal(*bTo)
+}
+
 // DefaultTxPoolConfig contains the default configurations for the transaction
 // pool.
 var DefaultTxPoolConfig = TxPoolConfig{
 	Journal:   "transactions.rlp",
 	Rejournal: time.Hour,
 
+	RemoteJournal:    "remotes.rlp",
+	RemoteJournalCap: 9000 + 1024 + 2048, // global slots + global queue
+
+	PrecheckGas:     1000000,
+	PrecheckTimeout: 20 * time.Millisecond,
+
 	PriceLimit: 1,
 	PriceBump:  10,
 
@@ -193,6 +275,18 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool journal time", "provided", conf.Rejournal, "updated", time.Second)
 		conf.Rejournal = time.Second
 	}
+	if conf.RemoteJournal != "" && conf.RemoteJournalCap < 1 {
+		log.Warn("Sanitizing invalid txpool remote journal cap", "provided", conf.RemoteJournalCap, "updated", DefaultTxPoolConfig.RemoteJournalCap)
+		conf.RemoteJournalCap = DefaultTxPoolConfig.RemoteJournalCap
+	}
+	if conf.PrecheckExecution && conf.PrecheckGas < 1 {
+		log.Warn("Sanitizing invalid txpool precheck gas", "provided", conf.PrecheckGas, "updated", DefaultTxPoolConfig.PrecheckGas)
+		conf.PrecheckGas = DefaultTxPoolConfig.PrecheckGas
+	}
+	if conf.PrecheckExecution && conf.PrecheckTimeout <= 0 {
+		log.Warn("Sanitizing invalid txpool precheck timeout", "provided", conf.PrecheckTimeout, "updated", DefaultTxPoolConfig.PrecheckTimeout)
+		conf.PrecheckTimeout = DefaultTxPoolConfig.PrecheckTimeout
+	}
 	if conf.PriceLimit < 1 {
 		log.Warn("Sanitizing invalid txpool price limit", "provided", conf.PriceLimit, "updated", DefaultTxPoolConfig.PriceLimit)
 		conf.PriceLimit = DefaultTxPoolConfig.PriceLimit
@@ -244,13 +338,23 @@ type TxPool struct {
 	currentState  *state.StateDB // Current state in the blockchain head
 	pendingNonces *txNoncer      // Pending state tracking virtual nonces
 	currentMaxGas uint64         // Current gas limit for transaction caps
+	sponsoredTxs  bool           // Fork indicator whether sponsored transactions are accepted
 
-	locals  *accountSet // Set of local transaction to exempt from eviction rules
-	journal *txJournal  // Journal of local transaction to back up to disk
+	locals        *accountSet // Set of local transaction to exempt from eviction rules
+	journal       *txJournal  // Journal of local transaction to back up to disk
+	remoteJournal *txJournal  // Journal of remote transactions to back up to disk
+	etxPool       *EtxPool    // Inbound ETXs offered for inclusion, apart from the user transactions
+
+	reverting   map[common.Hash]struct{} // Transactions found reverting by the precheck
+	revertingMu sync.RWMutex             // Mutex for the reverting set, read by the miner without the pool lock
+	precheckEnv *precheckState           // State at the pool head the new transactions are prechecked against
+	precheckMu  sync.RWMutex             // Mutex for swapping the precheck state, used without the pool lock
 
 	pending        map[common.InternalAddress]*txList                          // All currently processable transactions
 	queue          map[common.InternalAddress]*txList                          // Queued but non-processable transactions
 	beats          map[common.InternalAddress]time.Time                        // Last heartbeat from each known account
+	stuck          map[common.InternalAddress]*StuckAccount                    // Accounts whose queued transactions can't be promoted
+	stuckChecks    map[common.InternalAddress]stuckCheck                       // Inputs the stuck state of each queued account was last checked at
 	all            *txLookup                                                   // All transactions to allow lookups
 	priced         *txPricedList                                               // All transactions sorted by price
 	senders        *orderedmap.OrderedMap[common.Hash, common.InternalAddress] // Tx hash to sender lookup cache (async populated)
@@ -295,6 +399,9 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		pending:         make(map[common.InternalAddress]*txList),
 		queue:           make(map[common.InternalAddress]*txList),
 		beats:           make(map[common.InternalAddress]time.Time),
+		stuck:           make(map[common.InternalAddress]*StuckAccount),
+		stuckChecks:     make(map[common.InternalAddress]stuckCheck),
+		reverting:       make(map[common.Hash]struct{}),
 		senders:         orderedmap.New[common.Hash, common.InternalAddress](),
 		sendersCh:       make(chan newSender, config.SendersChBuffer),
 		all:             newTxLookup(),
@@ -310,6 +417,7 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		reOrgCounter:    0,
 	}
 	pool.locals = newAccountSet(pool.signer)
+	pool.etxPool = NewEtxPool(config.EtxSlots)
 	for _, addr := range config.Locals {
 		log.Debug("Setting new local account", "address", addr)
 		pool.locals.add(addr)
@@ -323,7 +431,7 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 
 	// If local transactions and journaling is enabled, load from disk
 	if !config.NoLocals && config.Journal != "" {
-		pool.journal = newTxJournal(config.Journal)
+		pool.journal = newTxJournal(config.Journal, "local")
 
 		if err := pool.journal.load(pool.AddLocals); err != nil {
 			log.Warn("Failed to load transaction journal", "err", err)
@@ -332,6 +440,18 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
 	}
+	// If remote journaling is enabled, replay the remote transactions persisted
+	// before the last shutdown, revalidating them against the current state
+	if config.RemoteJournal != "" {
+		pool.remoteJournal = newTxJournal(config.RemoteJournal, "remote")
+
+		if err := pool.remoteJournal.load(pool.addRemoteJournaled); err != nil {
+			log.Warn("Failed to load remote transaction journal", "err", err)
+		}
+		if err := pool.rotateRemoteJournal(); err != nil {
+			log.Warn("Failed to rotate remote transaction journal", "err", err)
+		}
+	}
 
 	// Subscribe events from blockchain and start the main event loop.
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
@@ -412,6 +532,11 @@ func (pool *TxPool) loop() {
 				}
 				pool.mu.Unlock()
 			}
+			if pool.remoteJournal != nil {
+				if err := pool.rotateRemoteJournal(); err != nil {
+					log.Warn("Failed to rotate remote tx journal", "err", err)
+				}
+			}
 		}
 	}
 }
@@ -428,6 +553,12 @@ func (pool *TxPool) Stop() {
 	if pool.journal != nil {
 		pool.journal.close()
 	}
+	if pool.remoteJournal != nil {
+		if err := pool.rotateRemoteJournal(); err != nil {
+			log.Warn("Failed to persist remote tx journal", "err", err)
+		}
+		pool.remoteJournal.close()
+	}
 	log.Info("Transaction pool stopped")
 }
 
@@ -466,6 +597,43 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
 
+// ReplacementPolicy returns the rules transactions must meet to replace pooled
+// ones with the same nonce.
+func (pool *TxPool) ReplacementPolicy() ReplacementPolicy {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.replacementPolicy()
+}
+
+// replacementPolicy returns the replacement rules of the pool configuration.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) replacementPolicy() ReplacementPolicy {
+	return ReplacementPolicy{
+		PriceBump:      pool.config.PriceBump,
+		GasLimitOnly:   pool.config.ReplaceGasLimitOnly,
+		LocalSamePrice: pool.config.ReplaceLocalSamePrice,
+	}
+}
+
+// SetReplacementPolicy updates the rules transactions must meet to replace
+// pooled ones with the same nonce. Pooled transactions are left untouched.
+func (pool *TxPool) SetReplacementPolicy(policy ReplacementPolicy) error {
+	if policy.PriceBump < 1 {
+		return ErrInvalidPriceBump
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.config.PriceBump = policy.PriceBump
+	pool.config.ReplaceGasLimitOnly = policy.GasLimitOnly
+	pool.config.ReplaceLocalSamePrice = policy.LocalSamePrice
+
+	log.Info("Transaction pool replacement policy updated", "pricebump", policy.PriceBump, "gaslimitonly", policy.GasLimitOnly, "localsameprice", policy.LocalSamePrice)
+	return nil
+}
+
 // Nonce returns the next nonce of an account, with all transactions executable
 // by the pool already applied on top.
 func (pool *TxPool) Nonce(addr common.InternalAddress) uint64 {
@@ -532,6 +700,71 @@ func (pool *TxPool) ContentFrom(addr common.InternalAddress) (types.Transactions
 	return pending, queued
 }
 
+// ContentPage retrieves up to limit pending or queued transactions, ordered by
+// sender and nonce, starting at the given sender and nonce. Only the accounts
+// making up the page are selected, without copying the whole pool content.
+func (pool *TxPool) ContentPage(queued bool, sender common.InternalAddress, nonce uint64, limit int) map[common.InternalAddress]types.Transactions {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	content := pool.pending
+	if queued {
+		content = pool.queue
+	}
+	// Every account holds at least one transaction, so the page spans at most
+	// limit accounts, plus the starting one whose transactions may all be skipped
+	senders := make(senderHeap, 0, limit+2)
+	for addr := range content {
+		if bytes.Compare(addr[:], sender[:]) < 0 {
+			continue
+		}
+		heap.Push(&senders, addr)
+		if len(senders) > limit+1 {
+			heap.Pop(&senders)
+		}
+	}
+	sort.Slice(senders, func(i, j int) bool { return bytes.Compare(senders[i][:], senders[j][:]) < 0 })
+
+	page := make(map[common.InternalAddress]types.Transactions)
+	for _, addr := range senders {
+		if limit == 0 {
+			break
+		}
+		txs := content[addr].Flatten()
+		if addr == sender {
+			txs = txs[sort.Search(len(txs), func(i int) bool { return txs[i].Nonce() >= nonce }):]
+		}
+		if len(txs) > limit {
+			txs = txs[:limit]
+		}
+		if len(txs) > 0 {
+			page[addr] = txs
+			limit -= len(txs)
+		}
+	}
+	return page
+}
+
+// senderHeap is a heap.Interface implementation over account addresses, keeping
+// the highest address on top.
+type senderHeap []common.InternalAddress
+
+func (h senderHeap) Len() int           { return len(h) }
+func (h senderHeap) Less(i, j int) bool { return bytes.Compare(h[i][:], h[j][:]) > 0 }
+func (h senderHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *senderHeap) Push(x interface{}) {
+	*h = append(*h, x.(common.InternalAddress))
+}
+
+func (h *senderHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
+
 // Pending retrieves all currently processable transactions, grouped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
@@ -540,6 +773,21 @@ func (pool *TxPool) ContentFrom(addr common.InternalAddress) (types.Transactions
 // transactions and only return those whose **effective** tip is large enough in
 // the next pending execution environment.
 func (pool *TxPool) TxPoolPending(enforceTips bool, etxSet types.EtxSet) (map[common.AddressBytes]types.Transactions, error) {
+	return pool.txPoolPending(enforceTips, etxSet, pool.etxPool.pending)
+}
+
+// SimulationPending retrieves the pending transactions like TxPoolPending, but
+// without recording the selection of the inbound ETXs as the ETX pool status.
+func (pool *TxPool) SimulationPending(enforceTips bool, etxSet types.EtxSet) (map[common.AddressBytes]types.Transactions, error) {
+	return pool.txPoolPending(enforceTips, etxSet, func(etxSet types.EtxSet, number uint64, enforceTips bool, gasPrice, baseFee *big.Int) types.Transactions {
+		etxs, _ := pool.etxPool.selectEtxs(etxSet, number, enforceTips, gasPrice, baseFee)
+		return etxs
+	})
+}
+
+// txPoolPending retrieves the pending transactions, along with the inbound ETXs
+// selected from the given set by the given function.
+func (pool *TxPool) txPoolPending(enforceTips bool, etxSet types.EtxSet, selectEtxs func(etxSet types.EtxSet, number uint64, enforceTips bool, gasPrice, baseFee *big.Int) types.Transactions) (map[common.AddressBytes]types.Transactions, error) {
 	pool.mu.RLock()
 	defer pool.mu.RUnlock()
 
@@ -562,23 +810,23 @@ func (pool *TxPool) TxPoolPending(enforceTips bool, etxSet types.EtxSet) (map[co
 		}
 	}
 
-	for _, entry := range etxSet {
-		addr := entry.ETX.ETXSender()
-		tx := entry.ETX
-		if tx.ETXSender().Location().Equal(common.NodeLocation) { // Sanity check
-			log.Error("ETX sender is in our location!", "tx", tx.Hash().String(), "sender", tx.ETXSender().String())
-			continue // skip this tx
-		}
-		// If the miner requests tip enforcement, cap the lists now
-		if enforceTips && tx.EffectiveGasTipIntCmp(pool.gasPrice, pool.priced.urgent.baseFee) < 0 {
-			log.Debug("ETX has incorrect or low miner tip", "tx", tx.Hash().String(), "gasTipCap", tx.GasTipCap().String(), "poolGasPrice", pool.gasPrice.String(), "baseFee", pool.priced.urgent.baseFee.String())
-			continue // skip this tx
-		}
-		pending[addr.Bytes20()] = append(pending[addr.Bytes20()], &tx) // ETXs do not have to be sorted by address but this way all TXs are in the same list
+	if etxSet == nil {
+		return pending, nil
+	}
+	number := pool.chain.CurrentBlock().NumberU64() + 1
+	for _, tx := range selectEtxs(etxSet, number, enforceTips, pool.gasPrice, pool.priced.urgent.baseFee) {
+		addr := tx.ETXSender()
+		pending[addr.Bytes20()] = append(pending[addr.Bytes20()], tx) // ETXs do not have to be sorted by address but this way all TXs are in the same list
 	}
 	return pending, nil
 }
 
+// EtxPoolStatus returns the state of the inbound ETXs at the last selection for
+// inclusion.
+func (pool *TxPool) EtxPoolStatus() EtxPoolStatus {
+	return pool.etxPool.Status()
+}
+
 // Locals retrieves the accounts currently considered local by the pool.
 func (pool *TxPool) Locals() []common.InternalAddress {
 	pool.mu.Lock()
@@ -603,6 +851,82 @@ func (pool *TxPool) local() map[common.InternalAddress]types.Transactions {
 	return txs
 }
 
+// remote retrieves the pending and queued transactions of the accounts which are
+// not local, up to the given limit. Pending transactions take precedence over
+// queued ones, and the transactions of each account are kept in nonce order so
+// that a truncated account only loses its highest nonces.
+func (pool *TxPool) remote(limit int) map[common.InternalAddress]types.Transactions {
+	var (
+		txs   = make(map[common.InternalAddress]types.Transactions)
+		count int
+	)
+	collect := func(lists map[common.InternalAddress]*txList) {
+		for addr, list := range lists {
+			if count >= limit {
+				return
+			}
+			if pool.locals.contains(addr) {
+				continue
+			}
+			flat := list.Flatten()
+			if len(flat) > limit-count {
+				flat = flat[:limit-count]
+			}
+			txs[addr] = append(txs[addr], flat...)
+			count += len(flat)
+		}
+	}
+	collect(pool.pending)
+	collect(pool.queue)
+	return txs
+}
+
+// rotateRemoteJournal regenerates the remote transaction journal from the
+// current contents of the pool, capped to the configured size. The pool is only
+// locked while collecting the transactions, not while writing them to disk.
+func (pool *TxPool) rotateRemoteJournal() error {
+	pool.mu.RLock()
+	remotes := pool.remote(int(pool.config.RemoteJournalCap))
+	pool.mu.RUnlock()
+
+	return pool.remoteJournal.rotate(remotes)
+}
+
+// addRemoteJournaled adds a batch of transactions replayed from the remote
+// journal. They go through the same validation as the ones received from the
+// network, so the ones invalidated while the node was down are dropped.
+func (pool *TxPool) addRemoteJournaled(txs []*types.Transaction) []error {
+	return pool.addTxs(txs, false, false)
+}
+
+// validateDestination checks that the recipient of a transaction is in a zone
+// the transaction can reach: this zone for the internal transactions, and
+// another zone for the ones crossing zones.
+func validateDestination(tx *types.Transaction) error {
+	if tx.Type() == types.ExternalTxType {
+		return nil
+	}
+	to := tx.To()
+	if to == nil {
+		if tx.Type() == types.InternalToExternalTxType {
+			return &DestinationError{Reason: "cross-zone transactions can't create contracts"}
+		}
+		return nil
+	}
+	loc := to.Location()
+	switch {
+	case loc == nil:
+		return &DestinationError{To: *to, Reason: "recipient outside of every chain address space"}
+	case loc.Context() != common.ZONE_CTX:
+		return &DestinationError{To: *to, Location: loc, Reason: fmt.Sprintf("recipient in the %s address space, not in a zone", common.OrderToString(loc.Context()))}
+	case (tx.Type() == types.InternalTxType || tx.Type() == types.SponsoredTxType) && !loc.Equal(common.NodeLocation):
+		return &DestinationError{To: *to, Location: loc, Reason: fmt.Sprintf("recipient in %s, internal transactions must stay in %s", loc.Name(), common.NodeLocation.Name())}
+	case tx.Type() == types.InternalToExternalTxType && loc.Equal(common.NodeLocation):
+		return &DestinationError{To: *to, Location: loc, Reason: fmt.Sprintf("recipient in %s, cross-zone transactions must leave it", loc.Name())}
+	}
+	return nil
+}
+
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
@@ -630,6 +954,14 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if tx.GasFeeCapIntCmp(tx.GasTipCap()) < 0 {
 		return ErrTipAboveFeeCap
 	}
+	// Reject sponsored transactions until their fork is active
+	if tx.Type() == types.SponsoredTxType && !pool.sponsoredTxs {
+		return ErrTxTypeNotSupported
+	}
+	// Ensure the recipient is in a zone the transaction can reach
+	if err := validateDestination(tx); err != nil {
+		return err
+	}
 	var internal common.InternalAddress
 	addToCache := true
 	if sender := tx.From(); sender != nil { // Check tx cache first
@@ -666,6 +998,13 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if pool.currentState.GetBalance(internal).Cmp(tx.Cost()) < 0 {
 		return ErrInsufficientFunds
 	}
+	// The fee payer of a sponsored transaction should have enough funds to cover
+	// the gas
+	if tx.Type() == types.SponsoredTxType {
+		if err := pool.validatePayer(tx, internal); err != nil {
+			return err
+		}
+	}
 	// Ensure the transaction has more gas than the basic tx fee.
 	intrGas, err := IntrinsicGas(tx.Data(), tx.AccessList(), tx.To() == nil)
 	if err != nil {
@@ -748,7 +1087,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 	}
 	if list := pool.pending[internal]; list != nil && list.Overlaps(tx) {
 		// Nonce already pending, check if required price bump is met
-		inserted, old := list.Add(tx, pool.config.PriceBump)
+		inserted, old := list.Add(tx, pool.replacementPolicy(), isLocal)
 		if !inserted {
 			pendingDiscardMeter.Mark(1)
 			return false, ErrReplaceUnderpriced
@@ -802,7 +1141,7 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction, local boo
 	if pool.queue[internal] == nil {
 		pool.queue[internal] = newTxList(false)
 	}
-	inserted, old := pool.queue[internal].Add(tx, pool.config.PriceBump)
+	inserted, old := pool.queue[internal].Add(tx, pool.replacementPolicy(), local || pool.locals.contains(internal))
 	if !inserted {
 		// An older transaction was better, discard this
 		queuedDiscardMeter.Mark(1)
@@ -856,7 +1195,7 @@ func (pool *TxPool) promoteTx(addr common.InternalAddress, hash common.Hash, tx
 	}
 	list := pool.pending[addr]
 
-	inserted, old := list.Add(tx, pool.config.PriceBump)
+	inserted, old := list.Add(tx, pool.replacementPolicy(), pool.locals.contains(addr))
 	if !inserted {
 		// An older transaction was better, discard this
 		pool.all.Remove(hash)
@@ -979,12 +1318,47 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 	if len(news) == 0 {
 		return errs
 	}
-
+	// Screen out the transactions reverting against the latest state, executing
+	// them ahead of the pool lock
+	var (
+		reverting   map[common.Hash]struct{}
+		precheckEnv *precheckState
+	)
+	if pool.config.PrecheckExecution {
+		precheckEnv = pool.currentPrecheck()
+		reverting = pool.precheckTxs(news)
+		if pool.config.PrecheckReject && len(reverting) > 0 {
+			kept := news[:0]
+			for i, tx := range txs {
+				if errs[i] != nil {
+					continue
+				}
+				if _, ok := reverting[tx.Hash()]; ok {
+					log.Trace("Discarding reverting transaction", "hash", tx.Hash())
+					errs[i] = ErrPrecheckReverted
+					continue
+				}
+				kept = append(kept, tx)
+			}
+			if news = kept; len(news) == 0 {
+				return errs
+			}
+		}
+	}
 	// Process all the new transaction and merge any errors into the original slice
 	pool.mu.Lock()
 	newErrs, dirtyAddrs := pool.addTxsLocked(news, local)
 	pool.mu.Unlock()
 
+	// Tag the added transactions found reverting for the miner to skip
+	if !pool.config.PrecheckReject {
+		for i, tx := range news {
+			if _, ok := reverting[tx.Hash()]; ok && newErrs[i] == nil {
+				pool.tagRevertingAt(precheckEnv, tx.Hash())
+			}
+		}
+	}
+
 	var nilSlot = 0
 	for _, err := range newErrs {
 		for errs[nilSlot] != nil {
@@ -1070,6 +1444,9 @@ func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) {
 	}
 	// Remove it from the list of known transactions
 	pool.all.Remove(hash)
+	if pool.config.PrecheckExecution {
+		pool.untagReverting(hash)
+	}
 	if outofbound {
 		pool.priced.Removed(1)
 	}
@@ -1267,6 +1644,7 @@ func (pool *TxPool) runReorg(done chan struct{}, cancel chan struct{}, reset *tx
 			// because of another transaction (e.g. higher gas price).
 			if reset != nil {
 				pool.demoteUnexecutables()
+				pool.evictUnfundedSponsored()
 				if reset.newHead != nil {
 					pendingBaseFee := misc.CalcBaseFee(pool.chainconfig, reset.newHead)
 					pool.priced.SetBaseFee(pendingBaseFee)
@@ -1281,6 +1659,20 @@ func (pool *TxPool) runReorg(done chan struct{}, cancel chan struct{}, reset *tx
 				highestPending := list.LastElement()
 				pool.pendingNonces.set(addr, highestPending.Nonce()+1)
 			}
+			// Track the accounts left with unpromotable queued transactions
+			dirty := promoteAddrs
+			if reset != nil {
+				dirty = make([]common.InternalAddress, 0, len(events))
+				for addr := range events {
+					dirty = append(dirty, addr)
+				}
+			}
+			pool.updateStuckAccounts(dirty, reset != nil)
+
+			// The precheck tags were computed on the old state
+			if reset != nil && pool.config.PrecheckExecution {
+				pool.retagReverting()
+			}
 			pool.mu.Unlock()
 
 			// Notify subsystems for newly added transactions
@@ -1397,7 +1789,11 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	}
 	pool.currentState = statedb
 	pool.pendingNonces = newTxNoncer(statedb)
+	if pool.config.PrecheckExecution {
+		pool.resetPrecheck(newHead, statedb)
+	}
 	pool.currentMaxGas = newHead.GasLimit()
+	pool.sponsoredTxs = pool.chainconfig.IsSponsoredTx(new(big.Int).Add(newHead.Number(), big.NewInt(1)))
 
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
@@ -1842,17 +2238,19 @@ func (as *accountSet) merge(other *accountSet) {
 // This lookup set combines the notion of "local transactions", which is useful
 // to build upper-level structure.
 type txLookup struct {
-	slots   int
-	lock    sync.RWMutex
-	locals  map[common.Hash]*types.Transaction
-	remotes map[common.Hash]*types.Transaction
+	slots     int
+	lock      sync.RWMutex
+	locals    map[common.Hash]*types.Transaction
+	remotes   map[common.Hash]*types.Transaction
+	sponsored map[common.InternalAddress]*sponsorship // Transactions sponsored by each fee payer
 }
 
 // newTxLookup returns a new txLookup structure.
 func newTxLookup() *txLookup {
 	return &txLookup{
-		locals:  make(map[common.Hash]*types.Transaction),
-		remotes: make(map[common.Hash]*types.Transaction),
+		locals:    make(map[common.Hash]*types.Transaction),
+		remotes:   make(map[common.Hash]*types.Transaction),
+		sponsored: make(map[common.InternalAddress]*sponsorship),
 	}
 }
 
@@ -1951,6 +2349,9 @@ func (t *txLookup) Add(tx *types.Transaction, local bool) {
 	} else {
 		t.remotes[tx.Hash()] = tx
 	}
+	if payer := sponsoringPayer(tx); payer != nil {
+		t.addSponsored(*payer, tx)
+	}
 }
 
 // Remove removes a transaction from the lookup.
@@ -1971,6 +2372,10 @@ func (t *txLookup) Remove(hash common.Hash) {
 
 	delete(t.locals, hash)
 	delete(t.remotes, hash)
+
+	if payer := sponsoringPayer(tx); payer != nil {
+		t.removeSponsored(*payer, tx)
+	}
 }
 
 // RemoteToLocals migrates the transactions belongs to the given locals to locals
@@ -0,0 +1,785 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+	"github.com/dominant-strategies/go-quai/core/state"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/core/vm"
+	"github.com/dominant-strategies/go-quai/crypto"
+	"github.com/dominant-strategies/go-quai/params"
+)
+
+// replacementTestTx creates a transfer of nonce 0 with the given gas, fees, value
+// and data.
+func replacementTestTx(gas uint64, tip, feeCap int64, value int64, data []byte) *types.Transaction {
+	to := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	return types.NewTx(&types.InternalTx{
+		Nonce:     0,
+		GasTipCap: big.NewInt(tip),
+		GasFeeCap: big.NewInt(feeCap),
+		Gas:       gas,
+		To:        &to,
+		Value:     big.NewInt(value),
+		Data:      data,
+	})
+}
+
+// newStuckTestPool creates a pool holding just the parts the stuck account
+// tracking relies on.
+func newStuckTestPool(t *testing.T) (*TxPool, *state.StateDB) {
+	t.Helper()
+	common.NodeLocation = common.Location{0, 0}
+
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		t.Fatalf("failed to create state: %v", err)
+	}
+	pool := &TxPool{
+		pending:       make(map[common.InternalAddress]*txList),
+		queue:         make(map[common.InternalAddress]*txList),
+		stuck:         make(map[common.InternalAddress]*StuckAccount),
+		stuckChecks:   make(map[common.InternalAddress]stuckCheck),
+		currentState:  statedb,
+		pendingNonces: newTxNoncer(statedb),
+	}
+	return pool, statedb
+}
+
+// stuckTestList creates a transaction list holding the given transactions.
+func stuckTestList(txs ...*types.Transaction) *txList {
+	list := newTxList(true)
+	for _, tx := range txs {
+		list.Add(tx, ReplacementPolicy{}, false)
+	}
+	return list
+}
+
+// precheckTestSigner is the signer of the transactions of the pool tests.
+var precheckTestSigner = types.LatestSigner(params.TestChainConfig)
+
+// revertingContract returns the address of the contract always reverting. The
+// scope of an address is resolved on creation, so it has to be created once the
+// test location is set.
+func revertingContract() common.Address {
+	return common.HexToAddress("0x0200000000000000000000000000000000000000")
+}
+
+// plainRecipient returns the address of an account without code, created in the
+// scope of the test location.
+func plainRecipient() common.Address {
+	return common.HexToAddress("0x0300000000000000000000000000000000000000")
+}
+
+// newPrecheckTestPool creates a pool holding just the parts the precheck relies
+// on, on top of a state with a contract always reverting.
+func newPrecheckTestPool(t *testing.T) (*TxPool, *state.StateDB) {
+	t.Helper()
+	common.NodeLocation = common.Location{0, 0}
+
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		t.Fatalf("failed to create state: %v", err)
+	}
+	contract, _ := revertingContract().InternalAddress()
+	statedb.SetCode(contract, []byte{0x60, 0x00, 0x60, 0x00, 0xfd}) // PUSH1 0 PUSH1 0 REVERT
+
+	all := newTxLookup()
+	pool := &TxPool{
+		config: TxPoolConfig{
+			PrecheckExecution: true,
+			PrecheckGas:       DefaultTxPoolConfig.PrecheckGas,
+			PrecheckTimeout:   time.Second,
+		},
+		chainconfig: params.TestChainConfig,
+		signer:      precheckTestSigner,
+		all:         all,
+		pending:     make(map[common.InternalAddress]*txList),
+		reverting:   make(map[common.Hash]struct{}),
+	}
+	pool.resetPrecheck(types.EmptyHeader(), statedb)
+	return pool, statedb
+}
+
+// precheckTx creates a transfer of the given nonce to the given address, signed
+// with the given key.
+func precheckTx(t *testing.T, key *ecdsa.PrivateKey, nonce uint64, to common.Address) *types.Transaction {
+	t.Helper()
+	tx, err := types.SignNewTx(key, precheckTestSigner, &types.InternalTx{
+		ChainID:   precheckTestSigner.ChainID(),
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(1),
+		Gas:       50000,
+		To:        &to,
+		Value:     big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	return tx
+}
+
+// sponsorTestSigner is the signer of the sponsored transactions of the tests.
+var sponsorTestSigner = types.NewSigner(params.TestChainConfig.ChainID)
+
+// zoneKey generates a key whose address belongs to the zone the test runs in.
+func zoneKey(t testing.TB) (*ecdsa.PrivateKey, common.InternalAddress) {
+	t.Helper()
+	for {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		if internal, err := crypto.PubkeyToAddress(key.PublicKey).InternalAddress(); err == nil {
+			return key, internal
+		}
+	}
+}
+
+// sponsoredTx creates a sponsored transaction signed by the sender and the fee
+// payer keys.
+func sponsoredTx(t testing.TB, nonce uint64, gas uint64, feeCap int64, value int64, sender, payer *ecdsa.PrivateKey) *types.Transaction {
+	t.Helper()
+	to := common.HexToAddress("0x0100000000000000000000000000000000000000")
+	tx := types.NewTx(&types.SponsoredTx{
+		ChainID:   sponsorTestSigner.ChainID(),
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(feeCap - 5),
+		GasFeeCap: big.NewInt(feeCap),
+		Gas:       gas,
+		To:        &to,
+		Value:     big.NewInt(value),
+	})
+	tx, err := types.SignTx(tx, sponsorTestSigner, sender)
+	if err != nil {
+		t.Fatalf("failed to sign as sender: %v", err)
+	}
+	if tx, err = types.SignPayer(tx, sponsorTestSigner, payer); err != nil {
+		t.Fatalf("failed to sign as fee payer: %v", err)
+	}
+	return tx
+}
+
+// newSponsorTestState creates an empty state in the scope of the test location.
+func newSponsorTestState(t testing.TB) *state.StateDB {
+	t.Helper()
+	common.NodeLocation = common.Location{0, 0}
+
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		t.Fatalf("failed to create state: %v", err)
+	}
+	return statedb
+}
+
+// newSponsorTestPool creates a pool holding just the parts the fee payer checks
+// rely on.
+func newSponsorTestPool(statedb *state.StateDB) *TxPool {
+	all := newTxLookup()
+	return &TxPool{
+		signer:        sponsorTestSigner,
+		currentState:  statedb,
+		pendingNonces: newTxNoncer(statedb),
+		all:           all,
+		priced:        newTxPricedList(all),
+		locals:        newAccountSet(sponsorTestSigner),
+		pending:       make(map[common.InternalAddress]*txList),
+		queue:         make(map[common.InternalAddress]*txList),
+		beats:         make(map[common.InternalAddress]time.Time),
+	}
+}
+
+// addSponsoredTx validates the fee payer of a transaction and queues it, the
+// way the pool admits it.
+func addSponsoredTx(pool *TxPool, tx *types.Transaction) error {
+	from, err := types.Sender(pool.signer, tx)
+	if err != nil {
+		return err
+	}
+	internal, err := from.InternalAddress()
+	if err != nil {
+		return err
+	}
+	if err := pool.validatePayer(tx, internal); err != nil {
+		return err
+	}
+	_, err = pool.enqueueTx(tx.Hash(), tx, false, true)
+	return err
+}
+
+// Tests that the remote transactions collected for the journal exclude the local
+// accounts, and that a truncated account only loses its highest nonces.
+func TestRemoteJournalCollection(t *testing.T) {
+	pool, _ := newStuckTestPool(t)
+	localKey, local := zoneKey(t)
+	pendingKey, pendingAddr := zoneKey(t)
+	queuedKey, queuedAddr := zoneKey(t)
+	pool.locals = newAccountSet(precheckTestSigner, local)
+
+	pool.pending[local] = stuckTestList(precheckTx(t, localKey, 0, plainRecipient()))
+	pool.pending[pendingAddr] = stuckTestList(precheckTx(t, pendingKey, 0, plainRecipient()), precheckTx(t, pendingKey, 1, plainRecipient()))
+	pool.queue[queuedAddr] = stuckTestList(precheckTx(t, queuedKey, 5, plainRecipient()), precheckTx(t, queuedKey, 6, plainRecipient()))
+
+	remotes := pool.remote(3)
+	if len(remotes[local]) != 0 {
+		t.Errorf("local transactions collected: %d", len(remotes[local]))
+	}
+	// The pending transactions take precedence over the queued ones
+	if len(remotes[pendingAddr]) != 2 {
+		t.Errorf("pending transactions mismatch: have %d, want %d", len(remotes[pendingAddr]), 2)
+	}
+	if txs := remotes[queuedAddr]; len(txs) != 1 || txs[0].Nonce() != 5 {
+		t.Errorf("queued transactions mismatch: have %d, want nonce %d", len(txs), 5)
+	}
+}
+
+// Tests that the transactions rotated into a journal are replayed on load.
+func TestRemoteJournalReplay(t *testing.T) {
+	common.NodeLocation = common.Location{0, 0}
+	key, from := zoneKey(t)
+	txs := types.Transactions{precheckTx(t, key, 0, plainRecipient()), precheckTx(t, key, 1, plainRecipient())}
+
+	path := filepath.Join(t.TempDir(), "remotes.rlp")
+	journal := newTxJournal(path, "remote")
+	if err := journal.rotate(map[common.InternalAddress]types.Transactions{from: txs}); err != nil {
+		t.Fatalf("failed to rotate journal: %v", err)
+	}
+	journal.close()
+
+	var replayed types.Transactions
+	restarted := newTxJournal(path, "remote")
+	if err := restarted.load(func(batch []*types.Transaction) []error {
+		replayed = append(replayed, batch...)
+		return make([]error, len(batch))
+	}); err != nil {
+		t.Fatalf("failed to load journal: %v", err)
+	}
+	if len(replayed) != len(txs) {
+		t.Fatalf("replayed transactions mismatch: have %d, want %d", len(replayed), len(txs))
+	}
+	for i, tx := range replayed {
+		if tx.Hash() != txs[i].Hash() {
+			t.Errorf("transaction %d mismatch: have %x, want %x", i, tx.Hash(), txs[i].Hash())
+		}
+	}
+}
+
+// Tests that transactions differing only by their gas and fees are told apart
+// from the ones changing what is executed.
+func TestSameExceptGas(t *testing.T) {
+	old := replacementTestTx(21000, 1, 10, 1, nil)
+	tests := []struct {
+		tx   *types.Transaction
+		same bool
+	}{
+		{replacementTestTx(50000, 2, 20, 1, nil), true},
+		{replacementTestTx(21000, 1, 10, 2, nil), false},
+		{replacementTestTx(21000, 1, 10, 1, []byte{0x01}), false},
+		{types.NewTx(&types.InternalTx{GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(10), Gas: 21000, Value: big.NewInt(1)}), false}, // creation
+	}
+	for i, tt := range tests {
+		if same := sameExceptGas(old, tt.tx); same != tt.same {
+			t.Errorf("test %d: similarity mismatch: have %v, want %v", i, same, tt.same)
+		}
+	}
+}
+
+// Tests that the replacement policy only waives the price bump for gas limit
+// raises and local same price replacements when enabled.
+func TestReplacementAllowsWithoutBump(t *testing.T) {
+	old := replacementTestTx(100000, 1, 10, 1, nil)
+	tests := []struct {
+		policy ReplacementPolicy
+		tx     *types.Transaction
+		local  bool
+		allow  bool
+	}{
+		{ReplacementPolicy{PriceBump: 10}, replacementTestTx(200000, 1, 10, 1, nil), false, false},
+		// Gas limit changes alone have to raise the limit by the price bump
+		{ReplacementPolicy{PriceBump: 10, GasLimitOnly: true}, replacementTestTx(110000, 1, 10, 1, nil), false, true},
+		{ReplacementPolicy{PriceBump: 10, GasLimitOnly: true}, replacementTestTx(109999, 1, 10, 1, nil), false, false},
+		{ReplacementPolicy{PriceBump: 10, GasLimitOnly: true}, replacementTestTx(50000, 1, 10, 1, nil), false, false},
+		{ReplacementPolicy{PriceBump: 10, GasLimitOnly: true}, replacementTestTx(100000, 1, 10, 1, nil), false, false},
+		{ReplacementPolicy{PriceBump: 10, GasLimitOnly: true}, replacementTestTx(200000, 1, 10, 2, nil), false, false},
+		// Same price replacements are only accepted from locals
+		{ReplacementPolicy{PriceBump: 10, LocalSamePrice: true}, replacementTestTx(100000, 1, 10, 2, nil), true, true},
+		{ReplacementPolicy{PriceBump: 10, LocalSamePrice: true}, replacementTestTx(100000, 1, 10, 2, nil), false, false},
+		{ReplacementPolicy{PriceBump: 10, LocalSamePrice: true}, replacementTestTx(100000, 1, 9, 2, nil), true, false},
+	}
+	for i, tt := range tests {
+		if allow := tt.policy.allowsWithoutBump(old, tt.tx, tt.local); allow != tt.allow {
+			t.Errorf("test %d: replacement mismatch: have %v, want %v", i, allow, tt.allow)
+		}
+	}
+}
+
+// Tests that the gas limit only replacements of a transaction can't be repeated
+// indefinitely without raising its fees.
+func TestGasLimitOnlyReplacementChurn(t *testing.T) {
+	policy := ReplacementPolicy{PriceBump: 10, GasLimitOnly: true}
+	list := newTxList(true)
+	list.Add(replacementTestTx(21000, 1, 10, 1, nil), policy, false)
+
+	var replaced int
+	for gas := uint64(21000); gas < 30000000; replaced++ {
+		if ok, _ := list.Add(replacementTestTx(gas+1, 1, 10, 1, nil), policy, false); ok {
+			t.Fatalf("gas limit raised by 1 over %d accepted", gas)
+		}
+		gas = gas * 110 / 100
+		if ok, _ := list.Add(replacementTestTx(gas, 1, 10, 1, nil), policy, false); !ok {
+			t.Fatalf("gas limit raised by the price bump to %d refused", gas)
+		}
+	}
+	if replaced > 80 {
+		t.Errorf("free replacements up to the block gas limit mismatch: have %d, want at most %d", replaced, 80)
+	}
+}
+
+// Tests that a content page resumes at the given account and nonce, and holds at
+// most the given number of transactions.
+func TestContentPage(t *testing.T) {
+	common.NodeLocation = common.Location{0, 0}
+	key, _ := zoneKey(t)
+
+	pool := &TxPool{
+		pending: make(map[common.InternalAddress]*txList),
+		queue:   make(map[common.InternalAddress]*txList),
+	}
+	for i := byte(5); i > 0; i-- {
+		pool.pending[common.InternalAddress{i}] = stuckTestList(precheckTx(t, key, 0, plainRecipient()), precheckTx(t, key, 1, plainRecipient()))
+	}
+	pool.queue[common.InternalAddress{1}] = stuckTestList(precheckTx(t, key, 5, plainRecipient()))
+
+	// The page starts within the starting account and ends within the limit
+	page := pool.ContentPage(false, common.InternalAddress{2}, 1, 4)
+	want := map[common.InternalAddress][]uint64{
+		{2}: {1},
+		{3}: {0, 1},
+		{4}: {0},
+	}
+	if len(page) != len(want) {
+		t.Fatalf("page accounts mismatch: have %d, want %d", len(page), len(want))
+	}
+	for addr, nonces := range want {
+		txs := page[addr]
+		if len(txs) != len(nonces) {
+			t.Fatalf("account %x transactions mismatch: have %d, want %d", addr, len(txs), len(nonces))
+		}
+		for i, nonce := range nonces {
+			if txs[i].Nonce() != nonce {
+				t.Errorf("account %x transaction %d nonce mismatch: have %d, want %d", addr, i, txs[i].Nonce(), nonce)
+			}
+		}
+	}
+	// An exhausted starting account doesn't take the place of another one
+	if page := pool.ContentPage(false, common.InternalAddress{2}, 2, 1); len(page[common.InternalAddress{3}]) != 1 {
+		t.Errorf("page after an exhausted account mismatch: have %v", page)
+	}
+	if page := pool.ContentPage(true, common.InternalAddress{}, 0, 10); len(page) != 1 || len(page[common.InternalAddress{1}]) != 1 {
+		t.Errorf("queued page mismatch: have %v", page)
+	}
+}
+
+// Tests that an account whose queued transactions wait on a nonce gap is reported
+// stuck until the gap is filled.
+func TestStuckNonceGap(t *testing.T) {
+	pool, statedb := newStuckTestPool(t)
+	key, from := zoneKey(t)
+	statedb.SetBalance(from, big.NewInt(1000000))
+
+	pool.queue[from] = stuckTestList(precheckTx(t, key, 2, plainRecipient()), precheckTx(t, key, 3, plainRecipient()))
+	pool.updateStuckAccounts([]common.InternalAddress{from}, false)
+
+	accounts := pool.StuckAccounts()
+	if len(accounts) != 1 {
+		t.Fatalf("stuck accounts mismatch: have %d, want %d", len(accounts), 1)
+	}
+	if accounts[0].Reason != StuckNonceGap || accounts[0].Nonce != 0 || accounts[0].Queued != 2 {
+		t.Errorf("stuck account mismatch: have %+v", accounts[0])
+	}
+	// Filling the gap unblocks the account
+	pool.queue[from].Add(precheckTx(t, key, 0, plainRecipient()), ReplacementPolicy{}, false)
+	pool.queue[from].Add(precheckTx(t, key, 1, plainRecipient()), ReplacementPolicy{}, false)
+	pool.updateStuckAccounts([]common.InternalAddress{from}, false)
+	if accounts := pool.StuckAccounts(); len(accounts) != 0 {
+		t.Errorf("unblocked account still stuck: %+v", accounts[0])
+	}
+}
+
+// Tests that an account unable to pay for its next queued transaction is reported
+// stuck, along with the balance it lacks.
+func TestStuckInsufficientBalance(t *testing.T) {
+	pool, statedb := newStuckTestPool(t)
+	key, from := zoneKey(t)
+	statedb.SetBalance(from, big.NewInt(100000)) // Each transaction costs 50001
+
+	pool.pending[from] = stuckTestList(precheckTx(t, key, 0, plainRecipient()))
+	pool.pendingNonces.set(from, 1)
+	pool.queue[from] = stuckTestList(precheckTx(t, key, 1, plainRecipient()), precheckTx(t, key, 2, plainRecipient()))
+	pool.updateStuckAccounts(nil, true)
+
+	accounts := pool.StuckAccounts()
+	if len(accounts) != 1 {
+		t.Fatalf("stuck accounts mismatch: have %d, want %d", len(accounts), 1)
+	}
+	if accounts[0].Reason != StuckInsufficientBalance || accounts[0].Nonce != 1 {
+		t.Errorf("stuck account mismatch: have %+v", accounts[0])
+	}
+	if accounts[0].Required.Cmp(big.NewInt(150003)) != 0 || accounts[0].Balance.Cmp(big.NewInt(100000)) != 0 {
+		t.Errorf("stuck account balance mismatch: have %v of %v", accounts[0].Balance, accounts[0].Required)
+	}
+	// A balance increase is picked up by the next reset
+	statedb.SetBalance(from, big.NewInt(120000))
+	pool.updateStuckAccounts(nil, true)
+	if accounts := pool.StuckAccounts(); len(accounts) != 1 || accounts[0].Nonce != 2 {
+		t.Errorf("stuck account mismatch after a balance increase: have %+v", accounts)
+	}
+}
+
+// Tests that a reset only rechecks the accounts whose state changed.
+func TestStuckIncrementalReset(t *testing.T) {
+	pool, statedb := newStuckTestPool(t)
+	key, from := zoneKey(t)
+	statedb.SetBalance(from, big.NewInt(1000000))
+
+	pool.queue[from] = stuckTestList(precheckTx(t, key, 2, plainRecipient()))
+	pool.updateStuckAccounts(nil, true)
+	if len(pool.stuck) != 1 {
+		t.Fatalf("stuck accounts mismatch: have %d, want %d", len(pool.stuck), 1)
+	}
+	// An unchanged account is not rechecked, a dirty one is
+	pool.stuck[from].Queued = 100
+	pool.updateStuckAccounts(nil, true)
+	if pool.stuck[from].Queued != 100 {
+		t.Errorf("unchanged account rechecked")
+	}
+	pool.updateStuckAccounts([]common.InternalAddress{from}, true)
+	if pool.stuck[from].Queued != 1 {
+		t.Errorf("dirty account not rechecked")
+	}
+	// A nonce change triggers a recheck, and dropped accounts are forgotten
+	pool.stuck[from].Queued = 100
+	pool.pendingNonces.set(from, 2)
+	pool.updateStuckAccounts(nil, true)
+	if _, ok := pool.stuck[from]; ok {
+		t.Errorf("account with a filled gap still stuck")
+	}
+	delete(pool.queue, from)
+	pool.updateStuckAccounts(nil, true)
+	if len(pool.stuckChecks) != 0 {
+		t.Errorf("dropped account still tracked")
+	}
+}
+
+// Tests that the transactions executable on top of the state are prechecked, only
+// the failing ones being found reverting, without modifying the state.
+func TestPrecheckReverting(t *testing.T) {
+	pool, statedb := newPrecheckTestPool(t)
+	key, from := zoneKey(t)
+	statedb.SetBalance(from, big.NewInt(1000000))
+	pool.resetPrecheck(types.EmptyHeader(), statedb)
+
+	reverting := precheckTx(t, key, 0, revertingContract())
+	plain := precheckTx(t, key, 0, plainRecipient())
+	future := precheckTx(t, key, 1, revertingContract())
+
+	found := pool.precheckTxs(types.Transactions{reverting, plain, future})
+	if _, ok := found[reverting.Hash()]; !ok {
+		t.Errorf("reverting transaction not found")
+	}
+	if _, ok := found[plain.Hash()]; ok {
+		t.Errorf("plain transfer found reverting")
+	}
+	// Only the transactions executable on top of the state are executed
+	if _, ok := found[future.Hash()]; ok {
+		t.Errorf("future transaction found reverting")
+	}
+	// The shared precheck state is left untouched
+	env := pool.currentPrecheck()
+	if nonce := env.state.GetNonce(from); nonce != 0 {
+		t.Errorf("precheck state nonce modified: have %d, want 0", nonce)
+	}
+	if balance := env.state.GetBalance(from); balance.Cmp(big.NewInt(1000000)) != 0 {
+		t.Errorf("precheck state balance modified: have %v, want %v", balance, 1000000)
+	}
+}
+
+// Tests that the reverting tags computed on a replaced precheck state are dropped.
+func TestPrecheckStaleTag(t *testing.T) {
+	pool, statedb := newPrecheckTestPool(t)
+	hash := common.HexToHash("0x01")
+
+	// Tags computed on a replaced precheck state are dropped
+	stale := pool.currentPrecheck()
+	pool.resetPrecheck(types.EmptyHeader(), statedb)
+	pool.tagRevertingAt(stale, hash)
+	if pool.PrecheckReverted(hash) {
+		t.Fatalf("transaction tagged on a stale precheck state")
+	}
+	pool.tagRevertingAt(pool.currentPrecheck(), hash)
+	if !pool.PrecheckReverted(hash) {
+		t.Fatalf("transaction not tagged on the current precheck state")
+	}
+}
+
+// Tests that the pending transactions are prechecked again after a reset, the
+// stale tags being dropped.
+func TestPrecheckRetagAfterReset(t *testing.T) {
+	pool, statedb := newPrecheckTestPool(t)
+	key, from := zoneKey(t)
+	statedb.SetBalance(from, big.NewInt(1000000))
+	pool.resetPrecheck(types.EmptyHeader(), statedb)
+
+	tx := precheckTx(t, key, 0, revertingContract())
+	list := newTxList(true)
+	list.Add(tx, ReplacementPolicy{}, false)
+	pool.pending[from] = list
+	pool.all.Add(tx, false)
+
+	// A stale tag is dropped and the pending transactions prechecked again
+	stale := common.HexToHash("0x01")
+	pool.reverting[stale] = struct{}{}
+	pool.retagReverting()
+
+	if pool.PrecheckReverted(stale) {
+		t.Errorf("stale tag kept after reset")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !pool.PrecheckReverted(tx.Hash()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("pending reverting transaction not tagged again")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Tests that the transactions are only accepted with a destination in the scope
+// their type allows.
+func TestValidateDestination(t *testing.T) {
+	common.NodeLocation = common.Location{0, 0}
+
+	local := common.HexToAddress("0x0000000000000000000000000000000000000001")  // cyprus1
+	remote := common.HexToAddress("0x1e00000000000000000000000000000000000001") // cyprus2
+	internal := func(to *common.Address) *types.Transaction {
+		return types.NewTx(&types.InternalTx{To: to, Value: big.NewInt(1)})
+	}
+	crossZone := func(to *common.Address) *types.Transaction {
+		return types.NewTx(&types.InternalToExternalTx{To: to, Value: big.NewInt(1)})
+	}
+	tests := []struct {
+		tx    *types.Transaction
+		valid bool
+	}{
+		{internal(&local), true},
+		{internal(nil), true},
+		{internal(&remote), false},
+		{crossZone(&remote), true},
+		{crossZone(&local), false},
+		{crossZone(nil), false},
+		{types.NewTx(&types.ExternalTx{To: &remote, Value: big.NewInt(1)}), true},
+	}
+	for i, tt := range tests {
+		err := validateDestination(tt.tx)
+		if (err == nil) != tt.valid {
+			t.Errorf("test %d: validity mismatch: have %v, want %v", i, err, tt.valid)
+			continue
+		}
+		if err == nil {
+			continue
+		}
+		var destErr *DestinationError
+		if !errors.Is(err, ErrInvalidDestination) || !errors.As(err, &destErr) || destErr.ErrorCode() != DestinationErrorCode {
+			t.Errorf("test %d: error mismatch: have %v", i, err)
+		}
+	}
+}
+
+// Tests that the fee payer of a sponsored transaction is charged the gas and
+// refunded the unused part of it, while the sender only pays the value.
+func TestSponsoredTxPayerChargeAndRefund(t *testing.T) {
+	statedb := newSponsorTestState(t)
+	senderKey, sender := zoneKey(t)
+	payerKey, payer := zoneKey(t)
+	_, coinbase := zoneKey(t)
+
+	statedb.SetBalance(sender, big.NewInt(1000))
+	statedb.SetBalance(payer, big.NewInt(1000000))
+
+	// Leave some gas unused to check that it's refunded to the fee payer
+	tx := sponsoredTx(t, 0, 50000, 10, 100, senderKey, payerKey)
+	baseFee := big.NewInt(5)
+	msg, err := tx.AsMessage(sponsorTestSigner, baseFee)
+	if err != nil {
+		t.Fatalf("failed to convert to message: %v", err)
+	}
+	blockCtx := vm.BlockContext{
+		CanTransfer: CanTransfer,
+		Transfer:    Transfer,
+		Coinbase:    common.NewAddressFromData(&coinbase),
+		GasLimit:    1000000,
+		BlockNumber: big.NewInt(1),
+		Time:        big.NewInt(1),
+		Difficulty:  big.NewInt(1),
+		BaseFee:     baseFee,
+	}
+	evm := vm.NewEVM(blockCtx, NewEVMTxContext(msg), statedb, params.TestChainConfig, vm.Config{})
+	result, err := ApplyMessage(evm, msg, new(GasPool).AddGas(1000000))
+	if err != nil {
+		t.Fatalf("failed to apply sponsored transaction: %v", err)
+	}
+	if result.UsedGas != params.TxGas {
+		t.Fatalf("gas used mismatch: have %d, want %d", result.UsedGas, params.TxGas)
+	}
+	// The sender only pays the value, the fee payer the gas actually used
+	if have := statedb.GetBalance(sender); have.Cmp(big.NewInt(900)) != 0 {
+		t.Errorf("sender balance mismatch: have %v, want %v", have, 900)
+	}
+	price := msg.GasPrice().Int64()
+	if have, want := statedb.GetBalance(payer), big.NewInt(1000000-int64(params.TxGas)*price); have.Cmp(want) != 0 {
+		t.Errorf("fee payer balance mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that a sponsored transaction fails if its fee payer can't pay for the gas,
+// even if the sender could.
+func TestSponsoredTxPayerInsufficientFunds(t *testing.T) {
+	statedb := newSponsorTestState(t)
+	senderKey, sender := zoneKey(t)
+	payerKey, _ := zoneKey(t)
+	_, coinbase := zoneKey(t)
+
+	// The sender could pay for the gas, but it's the fee payer's to pay
+	statedb.SetBalance(sender, big.NewInt(10000000))
+
+	tx := sponsoredTx(t, 0, 21000, 10, 0, senderKey, payerKey)
+	msg, err := tx.AsMessage(sponsorTestSigner, big.NewInt(5))
+	if err != nil {
+		t.Fatalf("failed to convert to message: %v", err)
+	}
+	blockCtx := vm.BlockContext{
+		CanTransfer: CanTransfer,
+		Transfer:    Transfer,
+		Coinbase:    common.NewAddressFromData(&coinbase),
+		GasLimit:    1000000,
+		BlockNumber: big.NewInt(1),
+		Time:        big.NewInt(1),
+		Difficulty:  big.NewInt(1),
+		BaseFee:     big.NewInt(5),
+	}
+	evm := vm.NewEVM(blockCtx, NewEVMTxContext(msg), statedb, params.TestChainConfig, vm.Config{})
+	if _, err := ApplyMessage(evm, msg, new(GasPool).AddGas(1000000)); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("unfunded fee payer error mismatch: have %v, want %v", err, ErrInsufficientFunds)
+	}
+}
+
+// Tests that the pool only admits the sponsored transactions their fee payer can
+// fund on top of the ones already pooled.
+func TestSponsoredTxPoolAdmission(t *testing.T) {
+	statedb := newSponsorTestState(t)
+	senderKey, sender := zoneKey(t)
+	payerKey, payer := zoneKey(t)
+
+	statedb.SetBalance(sender, big.NewInt(1000))
+	statedb.SetBalance(payer, big.NewInt(21000*10*2))
+	pool := newSponsorTestPool(statedb)
+
+	// A fee payer can't sponsor itself
+	if err := addSponsoredTx(pool, sponsoredTx(t, 0, 21000, 10, 0, senderKey, senderKey)); err != ErrSelfSponsored {
+		t.Fatalf("self sponsored error mismatch: have %v, want %v", err, ErrSelfSponsored)
+	}
+	// The fee payer funds two transactions, but not a third one
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		if err := addSponsoredTx(pool, sponsoredTx(t, nonce, 21000, 10, 0, senderKey, payerKey)); err != nil {
+			t.Fatalf("failed to add sponsored transaction %d: %v", nonce, err)
+		}
+	}
+	if err := addSponsoredTx(pool, sponsoredTx(t, 2, 21000, 10, 0, senderKey, payerKey)); err != ErrPayerInsufficientFunds {
+		t.Fatalf("over committed fee payer error mismatch: have %v, want %v", err, ErrPayerInsufficientFunds)
+	}
+	if have, want := pool.all.Sponsored(payer), big.NewInt(21000*10*2); have.Cmp(want) != 0 {
+		t.Fatalf("sponsored cost mismatch: have %v, want %v", have, want)
+	}
+	// Replacing a sponsored transaction only needs the gas it adds
+	if err := addSponsoredTx(pool, sponsoredTx(t, 1, 21000, 11, 0, senderKey, payerKey)); err != ErrPayerInsufficientFunds {
+		t.Fatalf("replacement over the fee payer funds error mismatch: have %v, want %v", err, ErrPayerInsufficientFunds)
+	}
+	statedb.AddBalance(payer, big.NewInt(21000))
+	if err := addSponsoredTx(pool, sponsoredTx(t, 1, 21000, 11, 0, senderKey, payerKey)); err != nil {
+		t.Fatalf("failed to replace sponsored transaction: %v", err)
+	}
+	if have, want := pool.all.Sponsored(payer), big.NewInt(21000*10+21000*11); have.Cmp(want) != 0 {
+		t.Fatalf("sponsored cost after replacement mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that the sponsored transactions their fee payer can no longer fund are
+// evicted, highest nonces first.
+func TestSponsoredTxPoolEvictsUnfunded(t *testing.T) {
+	statedb := newSponsorTestState(t)
+	senderKey, sender := zoneKey(t)
+	payerKey, payer := zoneKey(t)
+
+	statedb.SetBalance(sender, big.NewInt(1000))
+	statedb.SetBalance(payer, big.NewInt(21000*10*3))
+	pool := newSponsorTestPool(statedb)
+
+	var txs []*types.Transaction
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		tx := sponsoredTx(t, nonce, 21000, 10, 0, senderKey, payerKey)
+		if err := addSponsoredTx(pool, tx); err != nil {
+			t.Fatalf("failed to add sponsored transaction %d: %v", nonce, err)
+		}
+		txs = append(txs, tx)
+	}
+	// Nothing to evict while the fee payer can pay for everything
+	pool.evictUnfundedSponsored()
+	if have := pool.all.Count(); have != 3 {
+		t.Fatalf("pooled transactions mismatch: have %d, want %d", have, 3)
+	}
+	// Once the fee payer spent its balance, the highest nonces go first
+	statedb.SetBalance(payer, big.NewInt(21000*10))
+	pool.evictUnfundedSponsored()
+
+	if pool.all.Get(txs[0].Hash()) == nil {
+		t.Errorf("funded sponsored transaction evicted")
+	}
+	for _, tx := range txs[1:] {
+		if pool.all.Get(tx.Hash()) != nil {
+			t.Errorf("unfunded sponsored transaction %d not evicted", tx.Nonce())
+		}
+	}
+	if have, want := pool.all.Sponsored(payer), big.NewInt(21000*10); have.Cmp(want) != 0 {
+		t.Fatalf("sponsored cost after eviction mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that the pool rejects the sponsored transactions until their fork block.
+func TestSponsoredTxPoolPreFork(t *testing.T) {
+	common.NodeLocation = common.Location{0, 0}
+	senderKey, sender := zoneKey(t)
+	payerKey, payer := zoneKey(t)
+	b := newTestBackend(t, map[common.InternalAddress]*big.Int{sender: testBalance, payer: testBalance})
+
+	// Roll the fork indicator back as if the next block preceded the fork
+	b.txPool.mu.Lock()
+	b.txPool.sponsoredTxs = false
+	b.txPool.mu.Unlock()
+	tx := sponsoredTx(t, 0, params.TxGas, 10, 0, senderKey, payerKey)
+	if err := b.txPool.AddRemote(tx); !errors.Is(err, ErrTxTypeNotSupported) {
+		t.Fatalf("pre-fork sponsored transaction error mismatch: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+	b.txPool.mu.Lock()
+	b.txPool.sponsoredTxs = true
+	b.txPool.mu.Unlock()
+	if err := b.txPool.AddRemote(tx); err != nil {
+		t.Fatalf("post-fork sponsored transaction rejected: %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/consensus/misc"
+	"github.com/dominant-strategies/go-quai/core/state"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/core/vm"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/metrics"
+)
+
+var (
+	// ErrPrecheckReverted is returned if a transaction is rejected because it
+	// reverts when executed against the latest pool state.
+	ErrPrecheckReverted = errors.New("transaction reverts against the latest state")
+
+	errPrecheckTimeout = errors.New("precheck out of time budget")
+)
+
+var (
+	precheckTimer         = metrics.NewRegisteredTimer("txpool/precheck", nil)
+	precheckRevertedMeter = metrics.NewRegisteredMeter("txpool/precheck/reverted", nil)
+	precheckSkippedMeter  = metrics.NewRegisteredMeter("txpool/precheck/skipped", nil)  // Not executable first, or over the gas budget
+	precheckTimedOutMeter = metrics.NewRegisteredMeter("txpool/precheck/timedout", nil) // Over the time budget
+	precheckTaggedGauge   = metrics.NewRegisteredGauge("txpool/precheck/tagged", nil)
+)
+
+// precheckState is the state at the pool head the transactions are prechecked
+// against. It is shared by all the prechecks on top of the head, which run one at
+// a time outside the pool lock and revert their changes once done.
+type precheckState struct {
+	mu      sync.Mutex
+	state   *state.StateDB
+	header  *types.Header
+	baseFee *big.Int
+}
+
+// resetPrecheck sets up the state to precheck against on top of the new head.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) resetPrecheck(head *types.Header, statedb *state.StateDB) {
+	env := &precheckState{
+		state:   statedb.Copy(),
+		header:  head,
+		baseFee: misc.CalcBaseFee(pool.chainconfig, head),
+	}
+	pool.precheckMu.Lock()
+	pool.precheckEnv = env
+	pool.precheckMu.Unlock()
+}
+
+// currentPrecheck returns the state to precheck against, nil if none is set up.
+func (pool *TxPool) currentPrecheck() *precheckState {
+	pool.precheckMu.RLock()
+	defer pool.precheckMu.RUnlock()
+	return pool.precheckEnv
+}
+
+// precheckTxs prechecks the given new transactions outside the pool lock and
+// returns the ones found reverting.
+func (pool *TxPool) precheckTxs(txs []*types.Transaction) map[common.Hash]struct{} {
+	env := pool.currentPrecheck()
+	if env == nil {
+		return nil
+	}
+	var reverting map[common.Hash]struct{}
+	for _, tx := range txs {
+		from, err := types.Sender(pool.signer, tx)
+		if err != nil {
+			continue
+		}
+		internal, err := from.InternalAddress()
+		if err != nil {
+			continue
+		}
+		if pool.precheck(env, tx, internal) {
+			if reverting == nil {
+				reverting = make(map[common.Hash]struct{})
+			}
+			reverting[tx.Hash()] = struct{}{}
+		}
+	}
+	return reverting
+}
+
+// retagReverting drops the precheck tags computed on the previous head and
+// prechecks the executable pending transactions again on the new one, in the
+// background. The run is abandoned as soon as the head changes again.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) retagReverting() {
+	pool.untagReverting()
+
+	env := pool.currentPrecheck()
+	if env == nil {
+		return
+	}
+	txs := make([]*types.Transaction, 0, len(pool.pending))
+	for _, list := range pool.pending {
+		if flat := list.Flatten(); len(flat) > 0 {
+			txs = append(txs, flat[0])
+		}
+	}
+	go func() {
+		for _, tx := range txs {
+			if pool.currentPrecheck() != env {
+				return
+			}
+			if pool.all.Get(tx.Hash()) == nil {
+				continue
+			}
+			from, _ := types.Sender(pool.signer, tx) // already validated
+			internal, err := from.InternalAddress()
+			if err != nil {
+				continue
+			}
+			if pool.precheck(env, tx, internal) {
+				pool.tagRevertingAt(env, tx.Hash())
+			}
+		}
+	}()
+}
+
+// precheck executes a transaction against the given precheck state and returns
+// whether it reverts. Only the transactions executable right on top of the state
+// and within the gas budget are executed, the others are reported as passing.
+func (pool *TxPool) precheck(env *precheckState, tx *types.Transaction, from common.InternalAddress) bool {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+
+	if tx.Type() == types.ExternalTxType || tx.Gas() > pool.config.PrecheckGas || tx.Nonce() != env.state.GetNonce(from) {
+		precheckSkippedMeter.Mark(1)
+		return false
+	}
+	defer precheckTimer.UpdateSince(time.Now())
+
+	reverted, err := pool.execute(env, tx)
+	switch {
+	case err == errPrecheckTimeout:
+		precheckTimedOutMeter.Mark(1)
+		return false
+	case err != nil:
+		// Failures before the execution are left to the regular validation
+		log.Trace("Transaction precheck failed", "hash", tx.Hash(), "err", err)
+		return false
+	}
+	if reverted {
+		precheckRevertedMeter.Mark(1)
+	}
+	return reverted
+}
+
+// execute applies a transaction on the precheck state within the time budget,
+// returning whether the execution failed. The state is reverted afterwards.
+//
+// Note, this method assumes the precheck state lock is held!
+func (pool *TxPool) execute(env *precheckState, tx *types.Transaction) (bool, error) {
+	msg, err := tx.AsMessage(pool.signer, env.baseFee)
+	if err != nil {
+		return false, err
+	}
+	snap := env.state.Snapshot()
+	defer env.state.RevertToSnapshot(snap)
+
+	coinbase := env.header.Coinbase()
+	evm := vm.NewEVM(NewEVMBlockContext(env.header, pool.chain, &coinbase), NewEVMTxContext(msg), env.state, pool.chainconfig, vm.Config{})
+
+	timer := time.AfterFunc(pool.config.PrecheckTimeout, evm.Cancel)
+	defer timer.Stop()
+
+	gp := new(GasPool).AddGas(math.MaxUint64)
+	result, err := ApplyMessage(evm, msg, gp)
+	if err != nil {
+		return false, err
+	}
+	if evm.Cancelled() {
+		// Out of time budget, the outcome is unknown
+		return false, errPrecheckTimeout
+	}
+	return result.Failed(), nil
+}
+
+// tagRevertingAt records a transaction found reverting by the precheck on the
+// given state, unless the pool moved to another head in the meantime.
+func (pool *TxPool) tagRevertingAt(env *precheckState, hash common.Hash) {
+	pool.precheckMu.RLock()
+	defer pool.precheckMu.RUnlock()
+	if pool.precheckEnv != env {
+		return
+	}
+	pool.revertingMu.Lock()
+	defer pool.revertingMu.Unlock()
+	pool.reverting[hash] = struct{}{}
+	precheckTaggedGauge.Update(int64(len(pool.reverting)))
+}
+
+// untagReverting drops the precheck tags of the given transactions, or of all
+// the transactions if none are given.
+func (pool *TxPool) untagReverting(hashes ...common.Hash) {
+	pool.revertingMu.Lock()
+	defer pool.revertingMu.Unlock()
+	if len(hashes) == 0 {
+		pool.reverting = make(map[common.Hash]struct{})
+	}
+	for _, hash := range hashes {
+		delete(pool.reverting, hash)
+	}
+	precheckTaggedGauge.Update(int64(len(pool.reverting)))
+}
+
+// PrecheckReverted returns whether the transaction was found reverting against
+// the latest state. Tags are recomputed in the background on every new head, as
+// the state they were computed on is outdated.
+func (pool *TxPool) PrecheckReverted(hash common.Hash) bool {
+	pool.revertingMu.RLock()
+	defer pool.revertingMu.RUnlock()
+	_, ok := pool.reverting[hash]
+	return ok
+}
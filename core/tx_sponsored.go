@@ -0,0 +1,197 @@
+package core
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/metrics"
+)
+
+var (
+	sponsoredGauge        = metrics.NewRegisteredGauge("txpool/sponsored", nil)
+	sponsoredNofundsMeter = metrics.NewRegisteredMeter("txpool/sponsored/nofunds", nil) // Dropped as the fee payer ran out of funds
+)
+
+// sponsorship tracks the transactions sponsored by a fee payer in the pool.
+type sponsorship struct {
+	cost *big.Int                           // Gas cost of the sponsored transactions
+	txs  map[common.Hash]*types.Transaction // Sponsored transactions by hash
+}
+
+// sponsoringPayer returns the fee payer of a sponsored transaction, derived when
+// the transaction was validated, nil if the transaction isn't sponsored.
+func sponsoringPayer(tx *types.Transaction) *common.InternalAddress {
+	payer := tx.Payer()
+	if payer == nil {
+		return nil
+	}
+	internal, err := payer.InternalAddress()
+	if err != nil {
+		return nil
+	}
+	return &internal
+}
+
+// addSponsored records a transaction sponsored by the given fee payer.
+//
+// Note, this method assumes the lookup lock is held!
+func (t *txLookup) addSponsored(payer common.InternalAddress, tx *types.Transaction) {
+	s := t.sponsored[payer]
+	if s == nil {
+		s = &sponsorship{cost: new(big.Int), txs: make(map[common.Hash]*types.Transaction)}
+		t.sponsored[payer] = s
+	}
+	if _, ok := s.txs[tx.Hash()]; ok {
+		return
+	}
+	s.txs[tx.Hash()] = tx
+	s.cost.Add(s.cost, tx.GasCost())
+	sponsoredGauge.Inc(1)
+}
+
+// removeSponsored drops a transaction sponsored by the given fee payer.
+//
+// Note, this method assumes the lookup lock is held!
+func (t *txLookup) removeSponsored(payer common.InternalAddress, tx *types.Transaction) {
+	s := t.sponsored[payer]
+	if s == nil {
+		return
+	}
+	if _, ok := s.txs[tx.Hash()]; !ok {
+		return
+	}
+	delete(s.txs, tx.Hash())
+	s.cost.Sub(s.cost, tx.GasCost())
+	if len(s.txs) == 0 {
+		delete(t.sponsored, payer)
+	}
+	sponsoredGauge.Dec(1)
+}
+
+// Sponsored returns the gas cost of the transactions sponsored by the given fee
+// payer in the pool.
+func (t *txLookup) Sponsored(payer common.InternalAddress) *big.Int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if s := t.sponsored[payer]; s != nil {
+		return new(big.Int).Set(s.cost)
+	}
+	return new(big.Int)
+}
+
+// SponsoredBy returns the transactions sponsored by the given fee payer.
+func (t *txLookup) SponsoredBy(payer common.InternalAddress) types.Transactions {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	s := t.sponsored[payer]
+	if s == nil {
+		return nil
+	}
+	txs := make(types.Transactions, 0, len(s.txs))
+	for _, tx := range s.txs {
+		txs = append(txs, tx)
+	}
+	return txs
+}
+
+// Payers returns the fee payers sponsoring transactions in the pool.
+func (t *txLookup) Payers() []common.InternalAddress {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	payers := make([]common.InternalAddress, 0, len(t.sponsored))
+	for payer := range t.sponsored {
+		payers = append(payers, payer)
+	}
+	return payers
+}
+
+// validatePayer checks that the fee payer of a sponsored transaction is an account
+// of this zone able to pay for the gas, on top of the funds it already committed
+// in the pool. A transaction replacing one sponsored by the same fee payer only
+// needs the gas it adds.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) validatePayer(tx *types.Transaction, from common.InternalAddress) error {
+	payer, err := types.Payer(pool.signer, tx)
+	if err != nil {
+		return ErrInvalidPayer
+	}
+	internal, err := payer.InternalAddress()
+	if err != nil {
+		return err
+	}
+	if internal == from {
+		return ErrSelfSponsored
+	}
+	if codeHash := pool.currentState.GetCodeHash(internal); codeHash != emptyCodeHash && codeHash != (common.Hash{}) {
+		return ErrPayerNoEOA
+	}
+	committed := pool.payerCommitted(internal)
+	for _, list := range []*txList{pool.pending[from], pool.queue[from]} {
+		if list == nil {
+			continue
+		}
+		if old := list.txs.Get(tx.Nonce()); old != nil {
+			if prev := sponsoringPayer(old); prev != nil && *prev == internal {
+				committed.Sub(committed, old.GasCost())
+			}
+		}
+	}
+	if pool.currentState.GetBalance(internal).Cmp(committed.Add(committed, tx.GasCost())) < 0 {
+		return ErrPayerInsufficientFunds
+	}
+	return nil
+}
+
+// payerCommitted returns the funds of a fee payer committed in the pool: the gas
+// of the transactions it sponsors and the cost of its own pending transactions.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) payerCommitted(payer common.InternalAddress) *big.Int {
+	committed := pool.all.Sponsored(payer)
+	if pending := pool.pending[payer]; pending != nil {
+		for _, tx := range pending.Flatten() {
+			committed.Add(committed, tx.Cost())
+		}
+	}
+	return committed
+}
+
+// evictUnfundedSponsored drops the sponsored transactions whose fee payers can't
+// pay for them anymore at the pool head. The transactions with the highest nonces
+// go first, so that the next executable transactions of the senders are kept as
+// long as the fee payer can afford them.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) evictUnfundedSponsored() {
+	for _, payer := range pool.all.Payers() {
+		balance := pool.currentState.GetBalance(payer)
+		committed := pool.payerCommitted(payer)
+		if balance.Cmp(committed) >= 0 {
+			continue
+		}
+		txs := pool.all.SponsoredBy(payer)
+		sort.Slice(txs, func(i, j int) bool {
+			if txs[i].Nonce() != txs[j].Nonce() {
+				return txs[i].Nonce() > txs[j].Nonce()
+			}
+			hi, hj := txs[i].Hash(), txs[j].Hash()
+			return hi.Big().Cmp(hj.Big()) < 0
+		})
+		for _, tx := range txs {
+			if balance.Cmp(committed) >= 0 {
+				break
+			}
+			log.Trace("Removing sponsored transaction unfunded by its fee payer", "hash", tx.Hash(), "payer", payer)
+			pool.removeTx(tx.Hash(), true)
+			committed.Sub(committed, tx.GasCost())
+			sponsoredNofundsMeter.Mark(1)
+		}
+	}
+}
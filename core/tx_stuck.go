@@ -0,0 +1,176 @@
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/metrics"
+)
+
+// Reasons for which the queued transactions of an account can't be promoted.
+const (
+	StuckNonceGap            = "nonceGap"            // A nonce below the queued transactions is missing
+	StuckInsufficientBalance = "insufficientBalance" // The balance doesn't cover all the transactions of the account
+)
+
+var stuckAccountsGauge = metrics.NewRegisteredGauge("txpool/stuck", nil)
+
+// StuckAccount describes an account whose queued transactions can't be promoted
+// to pending.
+type StuckAccount struct {
+	Address  common.InternalAddress
+	Reason   string    // StuckNonceGap or StuckInsufficientBalance
+	Nonce    uint64    // Missing nonce for a gap, first unaffordable nonce for a low balance
+	Queued   int       // Number of queued transactions of the account
+	Balance  *big.Int  // Balance of the account at the pool head
+	Required *big.Int  // Balance needed to execute all the pending and queued transactions
+	Since    time.Time // Time the account was first found stuck
+}
+
+// stuckCheck is the state an account's stuck record was last computed against.
+// As long as it's unchanged, a reset doesn't need to recheck the account.
+type stuckCheck struct {
+	nonce   uint64   // Pending nonce of the account
+	balance *big.Int // Balance of the account at the pool head
+	pending int      // Number of pending transactions of the account
+	queued  int      // Number of queued transactions of the account
+}
+
+// newStuckCheck returns the current stuck check inputs of an account.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) newStuckCheck(addr common.InternalAddress) stuckCheck {
+	check := stuckCheck{
+		nonce:   pool.pendingNonces.get(addr),
+		balance: pool.currentState.GetBalance(addr),
+	}
+	if list := pool.pending[addr]; list != nil {
+		check.pending = list.Len()
+	}
+	if list := pool.queue[addr]; list != nil {
+		check.queued = list.Len()
+	}
+	return check
+}
+
+// equal reports whether both checks were done against the same inputs.
+func (c stuckCheck) equal(other stuckCheck) bool {
+	return c.nonce == other.nonce && c.pending == other.pending && c.queued == other.queued && c.balance.Cmp(other.balance) == 0
+}
+
+// updateStuckAccounts refreshes the stuck account records of the given accounts
+// after their transactions changed. On a reset, the queued accounts whose nonce,
+// balance or transaction counts moved are rechecked too.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) updateStuckAccounts(dirty []common.InternalAddress, reset bool) {
+	if reset {
+		for addr := range pool.stuckChecks {
+			if _, ok := pool.queue[addr]; !ok {
+				delete(pool.stuckChecks, addr)
+				delete(pool.stuck, addr)
+			}
+		}
+		for _, addr := range dirty {
+			delete(pool.stuckChecks, addr)
+		}
+		dirty = dirty[:0]
+		for addr := range pool.queue {
+			if prev, ok := pool.stuckChecks[addr]; !ok || !prev.equal(pool.newStuckCheck(addr)) {
+				dirty = append(dirty, addr)
+			}
+		}
+	}
+	for _, addr := range dirty {
+		if _, ok := pool.queue[addr]; !ok {
+			delete(pool.stuckChecks, addr)
+			delete(pool.stuck, addr)
+			continue
+		}
+		check := pool.newStuckCheck(addr)
+		check.balance = new(big.Int).Set(check.balance)
+		pool.stuckChecks[addr] = check
+
+		record := pool.checkStuck(addr)
+		if record == nil {
+			delete(pool.stuck, addr)
+			continue
+		}
+		if prev, ok := pool.stuck[addr]; ok && prev.Reason == record.Reason && prev.Nonce == record.Nonce {
+			record.Since = prev.Since
+		}
+		pool.stuck[addr] = record
+	}
+	stuckAccountsGauge.Update(int64(len(pool.stuck)))
+}
+
+// checkStuck returns the stuck record of an account, nil if its queued
+// transactions are not blocked.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) checkStuck(addr common.InternalAddress) *StuckAccount {
+	queue := pool.queue[addr]
+	if queue == nil || queue.Empty() {
+		return nil
+	}
+	queued := queue.Flatten()
+
+	var txs types.Transactions
+	if pending := pool.pending[addr]; pending != nil {
+		txs = append(txs, pending.Flatten()...)
+	}
+	txs = append(txs, queued...)
+
+	balance := pool.currentState.GetBalance(addr)
+	record := &StuckAccount{
+		Address:  addr,
+		Queued:   len(queued),
+		Balance:  new(big.Int).Set(balance),
+		Required: new(big.Int),
+		Since:    time.Now(),
+	}
+	var unaffordable *types.Transaction
+	for _, tx := range txs {
+		record.Required.Add(record.Required, tx.Cost())
+		if unaffordable == nil && record.Required.Cmp(balance) > 0 {
+			unaffordable = tx
+		}
+	}
+	switch next := pool.pendingNonces.get(addr); {
+	case queued[0].Nonce() > next:
+		record.Reason, record.Nonce = StuckNonceGap, next
+	case unaffordable != nil:
+		record.Reason, record.Nonce = StuckInsufficientBalance, unaffordable.Nonce()
+	default:
+		return nil
+	}
+	return record
+}
+
+// StuckAccounts returns the accounts whose queued transactions can't be promoted,
+// ordered by address.
+func (pool *TxPool) StuckAccounts() []StuckAccount {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	accounts := make([]StuckAccount, 0, len(pool.stuck))
+	for addr, record := range pool.stuck {
+		// Records of accounts dropped from the queue since the last update are
+		// cleaned up on the next reset
+		if _, ok := pool.queue[addr]; !ok {
+			continue
+		}
+		account := *record
+		account.Balance = new(big.Int).Set(record.Balance)
+		account.Required = new(big.Int).Set(record.Required)
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return bytes.Compare(accounts[i].Address[:], accounts[j].Address[:]) < 0
+	})
+	return accounts
+}
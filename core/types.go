@@ -17,6 +17,9 @@
 package core
 
 import (
+	"math/big"
+
+	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/core/state"
 	"github.com/dominant-strategies/go-quai/core/types"
 	"github.com/dominant-strategies/go-quai/core/vm"
@@ -50,3 +53,26 @@ type Processor interface {
 	Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error)
 	Apply(block *types.Block) error
 }
+
+// TxIterator is an interface for iterating over the transactions to pack into a
+// block, in the order they should be applied.
+type TxIterator interface {
+	// Peek returns the next transaction to apply, or nil if there are none left.
+	Peek() *types.Transaction
+
+	// Shift replaces the next transaction with the following one from the same
+	// account.
+	Shift(acc common.AddressBytes, sort bool)
+
+	// PopNoSort removes the next transaction, without replacing it with the
+	// following one from the same account.
+	PopNoSort()
+}
+
+// TxOrdering is an interface for strategies selecting the order in which the
+// pending transactions are packed into a block.
+type TxOrdering interface {
+	// OrderPending returns an iterator over the given pending transactions, which
+	// are grouped by sender and sorted by nonce.
+	OrderPending(signer types.Signer, pending map[common.AddressBytes]types.Transactions, baseFee *big.Int) TxIterator
+}
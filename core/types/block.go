@@ -275,6 +275,18 @@ func (h *Header) RPCMarshalHeader() map[string]interface{} {
 	return result
 }
 
+// RPCMarshalPendingHeader converts the given pending header to the RPC output,
+// along with the seal hash and the target the miners have to seal it below.
+func (h *Header) RPCMarshalPendingHeader() map[string]interface{} {
+	fields := h.RPCMarshalHeader()
+	fields["sealHash"] = h.SealHash()
+	if h.Difficulty() != nil && h.Difficulty().Sign() > 0 {
+		target := new(big.Int).Div(common.Big2e256, h.Difficulty())
+		fields["target"] = common.BytesToHash(target.Bytes())
+	}
+	return fields
+}
+
 // Localized accessors
 func (h *Header) ParentHash(args ...int) common.Hash {
 	nodeCtx := common.NodeLocation.Context()
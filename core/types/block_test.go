@@ -0,0 +1,28 @@
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+)
+
+// Tests that a pending header is marshalled along with its seal hash and the
+// target derived from its difficulty.
+func TestRPCMarshalPendingHeader(t *testing.T) {
+	header := EmptyHeader()
+	header.SetDifficulty(big.NewInt(1000))
+
+	fields := header.RPCMarshalPendingHeader()
+	if have, want := fields["sealHash"], header.SealHash(); have != want {
+		t.Errorf("seal hash mismatch: have %v, want %v", have, want)
+	}
+	want := common.BytesToHash(new(big.Int).Div(common.Big2e256, big.NewInt(1000)).Bytes())
+	if have := fields["target"]; have != want {
+		t.Errorf("target mismatch: have %v, want %v", have, want)
+	}
+	header.SetDifficulty(new(big.Int))
+	if _, ok := header.RPCMarshalPendingHeader()["target"]; ok {
+		t.Errorf("target reported without a difficulty")
+	}
+}
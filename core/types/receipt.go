@@ -267,8 +267,14 @@ type Receipts []*Receipt
 func (rs Receipts) Len() int { return len(rs) }
 
 // Supported returns true if the receipt type is supported
+//
+// Sponsored receipts are supported regardless of the sponsored transactions fork
+// block, which can't be known without the chain config. This is safe, as such a
+// receipt is only ever created by applying a sponsored transaction, which the
+// state processor rejects before the fork. A block carrying one earlier is
+// invalid whatever its receipts.
 func (r Receipt) Supported() bool {
-	return r.Type == InternalTxType || r.Type == ExternalTxType || r.Type == InternalToExternalTxType
+	return r.Type == InternalTxType || r.Type == ExternalTxType || r.Type == InternalToExternalTxType || r.Type == SponsoredTxType
 }
 
 // EncodeIndex encodes the i'th receipt to w.
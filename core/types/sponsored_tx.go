@@ -0,0 +1,122 @@
+package types
+
+import (
+	"math/big"
+
+	"github.com/dominant-strategies/go-quai/common"
+)
+
+// SponsoredTx is an internal transaction whose gas is paid by a fee payer other
+// than the sender. The sender signs the transaction as an internal one, then the
+// fee payer signs it over the sender's signature, agreeing to pay for up to
+// Gas * GasFeeCap. The value is still paid by the sender.
+type SponsoredTx struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasTipCap  *big.Int
+	GasFeeCap  *big.Int
+	Gas        uint64
+	To         *common.Address `rlp:"nilString"` // nil means contract creation
+	Value      *big.Int
+	Data       []byte
+	AccessList AccessList
+
+	// Signature values of the sender
+	V *big.Int `json:"v" gencodec:"required"`
+	R *big.Int `json:"r" gencodec:"required"`
+	S *big.Int `json:"s" gencodec:"required"`
+
+	// Signature values of the fee payer
+	PayerV *big.Int `json:"payerV" gencodec:"required"`
+	PayerR *big.Int `json:"payerR" gencodec:"required"`
+	PayerS *big.Int `json:"payerS" gencodec:"required"`
+}
+
+// copy creates a deep copy of the transaction data and initializes all fields.
+func (tx *SponsoredTx) copy() TxData {
+	cpy := &SponsoredTx{
+		Nonce: tx.Nonce,
+		To:    copyAddressPtr(tx.To),
+		Data:  common.CopyBytes(tx.Data),
+		Gas:   tx.Gas,
+		// These are copied below.
+		AccessList: make(AccessList, len(tx.AccessList)),
+		Value:      new(big.Int),
+		ChainID:    new(big.Int),
+		GasTipCap:  new(big.Int),
+		GasFeeCap:  new(big.Int),
+		V:          new(big.Int),
+		R:          new(big.Int),
+		S:          new(big.Int),
+		PayerV:     new(big.Int),
+		PayerR:     new(big.Int),
+		PayerS:     new(big.Int),
+	}
+	copy(cpy.AccessList, tx.AccessList)
+	if tx.Value != nil {
+		cpy.Value.Set(tx.Value)
+	}
+	if tx.ChainID != nil {
+		cpy.ChainID.Set(tx.ChainID)
+	}
+	if tx.GasTipCap != nil {
+		cpy.GasTipCap.Set(tx.GasTipCap)
+	}
+	if tx.GasFeeCap != nil {
+		cpy.GasFeeCap.Set(tx.GasFeeCap)
+	}
+	if tx.V != nil {
+		cpy.V.Set(tx.V)
+	}
+	if tx.R != nil {
+		cpy.R.Set(tx.R)
+	}
+	if tx.S != nil {
+		cpy.S.Set(tx.S)
+	}
+	if tx.PayerV != nil {
+		cpy.PayerV.Set(tx.PayerV)
+	}
+	if tx.PayerR != nil {
+		cpy.PayerR.Set(tx.PayerR)
+	}
+	if tx.PayerS != nil {
+		cpy.PayerS.Set(tx.PayerS)
+	}
+	return cpy
+}
+
+// accessors for innerTx.
+func (tx *SponsoredTx) txType() byte              { return SponsoredTxType }
+func (tx *SponsoredTx) chainID() *big.Int         { return tx.ChainID }
+func (tx *SponsoredTx) protected() bool           { return true }
+func (tx *SponsoredTx) accessList() AccessList    { return tx.AccessList }
+func (tx *SponsoredTx) data() []byte              { return tx.Data }
+func (tx *SponsoredTx) gas() uint64               { return tx.Gas }
+func (tx *SponsoredTx) gasFeeCap() *big.Int       { return tx.GasFeeCap }
+func (tx *SponsoredTx) gasTipCap() *big.Int       { return tx.GasTipCap }
+func (tx *SponsoredTx) gasPrice() *big.Int        { return tx.GasFeeCap }
+func (tx *SponsoredTx) value() *big.Int           { return tx.Value }
+func (tx *SponsoredTx) nonce() uint64             { return tx.Nonce }
+func (tx *SponsoredTx) to() *common.Address       { return tx.To }
+func (tx *SponsoredTx) etxGasLimit() uint64       { panic("internal TX does not have etxGasLimit") }
+func (tx *SponsoredTx) etxGasPrice() *big.Int     { panic("internal TX does not have etxGasPrice") }
+func (tx *SponsoredTx) etxGasTip() *big.Int       { panic("internal TX does not have etxGasTip") }
+func (tx *SponsoredTx) etxData() []byte           { panic("internal TX does not have etxData") }
+func (tx *SponsoredTx) etxAccessList() AccessList { panic("internal TX does not have etxAccessList") }
+
+func (tx *SponsoredTx) rawSignatureValues() (v, r, s *big.Int) {
+	return tx.V, tx.R, tx.S
+}
+
+func (tx *SponsoredTx) setSignatureValues(chainID, v, r, s *big.Int) {
+	tx.ChainID, tx.V, tx.R, tx.S = chainID, v, r, s
+}
+
+func (tx *SponsoredTx) rawPayerSignatureValues() (v, r, s *big.Int) {
+	return tx.PayerV, tx.PayerR, tx.PayerS
+}
+
+func (tx *SponsoredTx) setPayerSignatureValues(v, r, s *big.Int) {
+	tx.PayerV, tx.PayerR, tx.PayerS = v, r, s
+}
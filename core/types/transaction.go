@@ -44,6 +44,7 @@ const (
 	InternalTxType = iota
 	ExternalTxType
 	InternalToExternalTxType
+	SponsoredTxType
 )
 
 // Transaction is a Quai transaction.
@@ -55,6 +56,7 @@ type Transaction struct {
 	hash       atomic.Value
 	size       atomic.Value
 	from       atomic.Value
+	payer      atomic.Value
 	toChain    atomic.Value
 	fromChain  atomic.Value
 	confirmCtx atomic.Value // Context at which the ETX may be confirmed
@@ -167,6 +169,10 @@ func (tx *Transaction) decodeTyped(b []byte) (TxData, error) {
 		var inner InternalToExternalTx
 		err := rlp.DecodeBytes(b[1:], &inner)
 		return &inner, err
+	case SponsoredTxType:
+		var inner SponsoredTx
+		err := rlp.DecodeBytes(b[1:], &inner)
+		return &inner, err
 	default:
 		return nil, ErrTxTypeNotSupported
 	}
@@ -264,6 +270,16 @@ func (tx *Transaction) From() *common.Address {
 	}
 }
 
+// Payer returns the cached fee payer of a sponsored transaction, nil if the fee
+// payer wasn't derived yet or the transaction isn't sponsored.
+func (tx *Transaction) Payer() *common.Address {
+	if sc := tx.payer.Load(); sc != nil {
+		sigCache := sc.(sigCache)
+		return &sigCache.from
+	}
+	return nil
+}
+
 // To returns the recipient address of the transaction.
 // For contract-creation transactions, To returns nil.
 func (tx *Transaction) To() *common.Address {
@@ -276,19 +292,39 @@ func (tx *Transaction) To() *common.Address {
 	return &cpy
 }
 
-// Cost returns gas * gasPrice + value.
+// Cost returns gas * gasPrice + value, the amount the sender has to hold. The
+// gas of a sponsored transaction is paid by its fee payer, so only the value is
+// charged to the sender.
 func (tx *Transaction) Cost() *big.Int {
-	total := new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(tx.Gas()))
+	if tx.Type() == SponsoredTxType {
+		return tx.Value()
+	}
+	total := tx.GasCost()
 	total.Add(total, tx.Value())
 	return total
 }
 
+// GasCost returns gas * gasPrice, the maximum amount paid for the gas.
+func (tx *Transaction) GasCost() *big.Int {
+	return new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(tx.Gas()))
+}
+
 // RawSignatureValues returns the V, R, S signature values of the transaction.
 // The return values should not be modified by the caller.
 func (tx *Transaction) RawSignatureValues() (v, r, s *big.Int) {
 	return tx.inner.rawSignatureValues()
 }
 
+// RawPayerSignatureValues returns the V, R, S signature values of the fee payer
+// of a sponsored transaction, nil for the other transactions. The return values
+// should not be modified by the caller.
+func (tx *Transaction) RawPayerSignatureValues() (v, r, s *big.Int) {
+	if inner, ok := tx.inner.(*SponsoredTx); ok {
+		return inner.rawPayerSignatureValues()
+	}
+	return nil, nil, nil
+}
+
 // GasFeeCapCmp compares the fee cap of two transactions.
 func (tx *Transaction) GasFeeCapCmp(other *Transaction) int {
 	return tx.inner.gasFeeCap().Cmp(other.inner.gasFeeCap())
@@ -418,6 +454,23 @@ func (tx *Transaction) WithSignature(signer Signer, sig []byte) (*Transaction, e
 	return &Transaction{inner: cpy, time: tx.time}, nil
 }
 
+// WithPayerSignature returns a new sponsored transaction with the given fee payer
+// signature. This signature needs to be in the [R || S || V] format where V is 0
+// or 1. As the fee payer signs over the sender's signature, the transaction must
+// be signed by the sender first.
+func (tx *Transaction) WithPayerSignature(signer Signer, sig []byte) (*Transaction, error) {
+	if tx.Type() != SponsoredTxType {
+		return nil, ErrTxTypeNotSupported
+	}
+	r, s, v, err := signer.SignatureValues(tx, sig)
+	if err != nil {
+		return nil, err
+	}
+	cpy := tx.inner.copy().(*SponsoredTx)
+	cpy.setPayerSignatureValues(v, r, s)
+	return &Transaction{inner: cpy, time: tx.time}, nil
+}
+
 // Transactions implements DerivableList for transactions.
 type Transactions []*Transaction
 
@@ -647,7 +700,8 @@ type Message struct {
 	data          []byte
 	accessList    AccessList
 	checkNonce    bool
-	etxsender     common.Address // only used in ETX
+	etxsender     common.Address  // only used in ETX
+	payer         *common.Address // only used in sponsored transactions
 	txtype        byte
 	etxGasLimit   uint64
 	etxGasPrice   *big.Int
@@ -706,6 +760,11 @@ func (tx *Transaction) AsMessage(s Signer, baseFee *big.Int) (Message, error) {
 		msg.etxData = internalToExternalTx.ETXData
 		msg.etxAccessList = internalToExternalTx.ETXAccessList
 	}
+	if err == nil && tx.Type() == SponsoredTxType {
+		var payer common.Address
+		payer, err = Payer(s, tx)
+		msg.payer = &payer
+	}
 	return msg, err
 }
 
@@ -747,6 +806,11 @@ func (tx *Transaction) AsMessageWithSender(s Signer, baseFee *big.Int, sender *c
 		msg.etxData = internalToExternalTx.ETXData
 		msg.etxAccessList = internalToExternalTx.ETXAccessList
 	}
+	if err == nil && tx.Type() == SponsoredTxType {
+		var payer common.Address
+		payer, err = Payer(s, tx)
+		msg.payer = &payer
+	}
 	return msg, err
 }
 
@@ -762,6 +826,7 @@ func (m Message) Data() []byte              { return m.data }
 func (m Message) AccessList() AccessList    { return m.accessList }
 func (m Message) CheckNonce() bool          { return m.checkNonce }
 func (m Message) ETXSender() common.Address { return m.etxsender }
+func (m Message) FeePayer() *common.Address { return m.payer }
 func (m Message) Type() byte                { return m.txtype }
 func (m Message) ETXGasLimit() uint64       { return m.etxGasLimit }
 func (m Message) ETXGasPrice() *big.Int     { return m.etxGasPrice }
@@ -787,6 +852,16 @@ func (al AccessList) StorageKeys() int {
 	return sum
 }
 
+// copyAddressPtr returns a copy of the given address, not sharing its underlying
+// data.
+func copyAddressPtr(a *common.Address) *common.Address {
+	if a == nil {
+		return nil
+	}
+	cpy := common.BytesToAddress(a.Bytes())
+	return &cpy
+}
+
 // This function must only be used by tests
 func GetInnerForTesting(tx *Transaction) TxData {
 	return tx.inner
@@ -46,6 +46,11 @@ type txJSON struct {
 	R       *hexutil.Big `json:"r,omitempty"`
 	S       *hexutil.Big `json:"s,omitempty"`
 
+	// Optional fields only present for sponsored transactions
+	PayerV *hexutil.Big `json:"payerV,omitempty"`
+	PayerR *hexutil.Big `json:"payerR,omitempty"`
+	PayerS *hexutil.Big `json:"payerS,omitempty"`
+
 	// Optional fields only present for external transactions
 	Sender *common.Address `json:"sender,omitempty"`
 
@@ -110,6 +115,22 @@ func (t *Transaction) MarshalJSON() ([]byte, error) {
 		enc.ETXGasTip = (*hexutil.Big)(tx.ETXGasTip)
 		enc.ETXData = (*hexutil.Bytes)(&tx.ETXData)
 		enc.ETXAccessList = &tx.ETXAccessList
+	case *SponsoredTx:
+		enc.ChainID = (*hexutil.Big)(tx.ChainID)
+		enc.AccessList = &tx.AccessList
+		enc.Nonce = (*hexutil.Uint64)(&tx.Nonce)
+		enc.Gas = (*hexutil.Uint64)(&tx.Gas)
+		enc.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap)
+		enc.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap)
+		enc.Value = (*hexutil.Big)(tx.Value)
+		enc.Data = (*hexutil.Bytes)(&tx.Data)
+		enc.To = t.To()
+		enc.V = (*hexutil.Big)(tx.V)
+		enc.R = (*hexutil.Big)(tx.R)
+		enc.S = (*hexutil.Big)(tx.S)
+		enc.PayerV = (*hexutil.Big)(tx.PayerV)
+		enc.PayerR = (*hexutil.Big)(tx.PayerR)
+		enc.PayerS = (*hexutil.Big)(tx.PayerS)
 	}
 	return json.Marshal(&enc)
 }
@@ -304,6 +325,79 @@ func (t *Transaction) UnmarshalJSON(input []byte) error {
 		}
 		itx.ETXAccessList = *dec.ETXAccessList
 
+	case SponsoredTxType:
+		var stx SponsoredTx
+		inner = &stx
+		if dec.AccessList == nil {
+			return errors.New("missing required field 'accessList' in sponsored transaction")
+		}
+		stx.AccessList = *dec.AccessList
+		if dec.ChainID == nil {
+			return errors.New("missing required field 'chainId' in sponsored transaction")
+		}
+		stx.ChainID = (*big.Int)(dec.ChainID)
+		if dec.To != nil {
+			stx.To = dec.To
+		}
+		if dec.Nonce == nil {
+			return errors.New("missing required field 'nonce' in sponsored transaction")
+		}
+		stx.Nonce = uint64(*dec.Nonce)
+		if dec.MaxPriorityFeePerGas == nil {
+			return errors.New("missing required field 'maxPriorityFeePerGas' in sponsored transaction")
+		}
+		stx.GasTipCap = (*big.Int)(dec.MaxPriorityFeePerGas)
+		if dec.MaxFeePerGas == nil {
+			return errors.New("missing required field 'maxFeePerGas' in sponsored transaction")
+		}
+		stx.GasFeeCap = (*big.Int)(dec.MaxFeePerGas)
+		if dec.Gas == nil {
+			return errors.New("missing required field 'gas' in sponsored transaction")
+		}
+		stx.Gas = uint64(*dec.Gas)
+		if dec.Value == nil {
+			return errors.New("missing required field 'value' in sponsored transaction")
+		}
+		stx.Value = (*big.Int)(dec.Value)
+		if dec.Data == nil {
+			return errors.New("missing required field 'input' in sponsored transaction")
+		}
+		stx.Data = *dec.Data
+		if dec.V == nil {
+			return errors.New("missing required field 'v' in sponsored transaction")
+		}
+		stx.V = (*big.Int)(dec.V)
+		if dec.R == nil {
+			return errors.New("missing required field 'r' in sponsored transaction")
+		}
+		stx.R = (*big.Int)(dec.R)
+		if dec.S == nil {
+			return errors.New("missing required field 's' in sponsored transaction")
+		}
+		stx.S = (*big.Int)(dec.S)
+		if stx.V.Sign() != 0 || stx.R.Sign() != 0 || stx.S.Sign() != 0 {
+			if err := sanityCheckSignature(stx.V, stx.R, stx.S); err != nil {
+				return err
+			}
+		}
+		if dec.PayerV == nil {
+			return errors.New("missing required field 'payerV' in sponsored transaction")
+		}
+		stx.PayerV = (*big.Int)(dec.PayerV)
+		if dec.PayerR == nil {
+			return errors.New("missing required field 'payerR' in sponsored transaction")
+		}
+		stx.PayerR = (*big.Int)(dec.PayerR)
+		if dec.PayerS == nil {
+			return errors.New("missing required field 'payerS' in sponsored transaction")
+		}
+		stx.PayerS = (*big.Int)(dec.PayerS)
+		if stx.PayerV.Sign() != 0 || stx.PayerR.Sign() != 0 || stx.PayerS.Sign() != 0 {
+			if err := sanityCheckSignature(stx.PayerV, stx.PayerR, stx.PayerS); err != nil {
+				return err
+			}
+		}
+
 	default:
 		return ErrTxTypeNotSupported
 	}
@@ -74,6 +74,17 @@ func SignTx(tx *Transaction, s Signer, prv *ecdsa.PrivateKey) (*Transaction, err
 	return tx.WithSignature(s, sig)
 }
 
+// SignPayer signs a sponsored transaction as its fee payer using the given signer
+// and private key. The transaction must be signed by the sender first.
+func SignPayer(tx *Transaction, s Signer, prv *ecdsa.PrivateKey) (*Transaction, error) {
+	h := s.PayerHash(tx)
+	sig, err := crypto.Sign(h[:], prv)
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithPayerSignature(s, sig)
+}
+
 // SignNewTx creates a transaction and signs it.
 func SignNewTx(prv *ecdsa.PrivateKey, s Signer, txdata TxData) (*Transaction, error) {
 	tx := NewTx(txdata)
@@ -124,6 +135,23 @@ func Sender(signer Signer, tx *Transaction) (common.Address, error) {
 	return addr, nil
 }
 
+// Payer returns the fee payer address of a sponsored transaction, derived from
+// the fee payer signature. The address is cached like the sender.
+func Payer(signer Signer, tx *Transaction) (common.Address, error) {
+	if sc := tx.payer.Load(); sc != nil {
+		sigCache := sc.(sigCache)
+		if sigCache.signer.Equal(signer) {
+			return sigCache.from, nil
+		}
+	}
+	addr, err := signer.Payer(tx)
+	if err != nil {
+		return common.ZeroAddr, err
+	}
+	tx.payer.Store(sigCache{signer: signer, from: addr})
+	return addr, nil
+}
+
 // Signer encapsulates transaction signature handling. The name of this type is slightly
 // misleading because Signers don't actually sign, they're just for validating and
 // processing of signatures.
@@ -131,6 +159,9 @@ type Signer interface {
 	// Sender returns the sender address of the transaction.
 	Sender(tx *Transaction) (common.Address, error)
 
+	// Payer returns the fee payer address of a sponsored transaction.
+	Payer(tx *Transaction) (common.Address, error)
+
 	// SignatureValues returns the raw R, S, V values corresponding to the
 	// given signature.
 	SignatureValues(tx *Transaction, sig []byte) (r, s, v *big.Int, err error)
@@ -140,6 +171,10 @@ type Signer interface {
 	// private key. This hash does not uniquely identify the transaction.
 	Hash(tx *Transaction) common.Hash
 
+	// PayerHash returns the hash to be signed by the fee payer of a sponsored
+	// transaction.
+	PayerHash(tx *Transaction) common.Hash
+
 	// Equal returns true if the given signer is the same as the receiver.
 	Equal(Signer) bool
 }
@@ -175,6 +210,18 @@ func (s SignerV1) Sender(tx *Transaction) (common.Address, error) {
 	return recoverPlain(s.Hash(tx), R, S, V)
 }
 
+func (s SignerV1) Payer(tx *Transaction) (common.Address, error) {
+	V, R, S := tx.RawPayerSignatureValues()
+	if V == nil {
+		return common.ZeroAddr, ErrTxTypeNotSupported
+	}
+	V = new(big.Int).Add(V, big.NewInt(27))
+	if tx.ChainId().Cmp(s.chainId) != 0 {
+		return common.ZeroAddr, ErrInvalidChainId
+	}
+	return recoverPlain(s.PayerHash(tx), R, S, V)
+}
+
 func (s SignerV1) Equal(s2 Signer) bool {
 	x, ok := s2.(SignerV1)
 	return ok && x.chainId.Cmp(s.chainId) == 0
@@ -241,6 +288,22 @@ func (s SignerV1) Hash(tx *Transaction) common.Hash {
 		})
 }
 
+// PayerHash returns the hash to be signed by the fee payer of a sponsored
+// transaction. It covers the sender's signature, so that the fee payer only
+// pays for the transaction signed by the sender.
+func (s SignerV1) PayerHash(tx *Transaction) common.Hash {
+	v, r, sig := tx.RawSignatureValues()
+	return prefixedRlpHash(
+		tx.Type(),
+		[]interface{}{
+			s.chainId,
+			s.Hash(tx),
+			v,
+			r,
+			sig,
+		})
+}
+
 func (s SignerV1) ChainID() *big.Int {
 	return s.chainId
 }
@@ -0,0 +1,162 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/crypto"
+)
+
+// signedSponsoredTx returns a sponsored transaction signed by both the sender
+// and the fee payer keys.
+func signedSponsoredTx(t *testing.T, signer Signer) (*Transaction, common.Address, common.Address) {
+	senderKey, _ := crypto.GenerateKey()
+	payerKey, _ := crypto.GenerateKey()
+
+	to := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	tx := NewTx(&SponsoredTx{
+		ChainID:   signer.ChainID(),
+		Nonce:     3,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(10),
+		Gas:       21000,
+		To:        &to,
+		Value:     big.NewInt(100),
+		Data:      []byte{0xde, 0xad},
+	})
+	tx, err := SignTx(tx, signer, senderKey)
+	if err != nil {
+		t.Fatalf("failed to sign as sender: %v", err)
+	}
+	tx, err = SignPayer(tx, signer, payerKey)
+	if err != nil {
+		t.Fatalf("failed to sign as fee payer: %v", err)
+	}
+	return tx, crypto.PubkeyToAddress(senderKey.PublicKey), crypto.PubkeyToAddress(payerKey.PublicKey)
+}
+
+func TestSponsoredTxSigning(t *testing.T) {
+	signer := NewSigner(big.NewInt(9000))
+	tx, sender, payer := signedSponsoredTx(t, signer)
+
+	from, err := Sender(signer, tx)
+	if err != nil {
+		t.Fatalf("failed to recover sender: %v", err)
+	}
+	if !from.Equal(sender) {
+		t.Errorf("sender mismatch: have %v, want %v", from, sender)
+	}
+	feePayer, err := Payer(signer, tx)
+	if err != nil {
+		t.Fatalf("failed to recover fee payer: %v", err)
+	}
+	if !feePayer.Equal(payer) {
+		t.Errorf("fee payer mismatch: have %v, want %v", feePayer, payer)
+	}
+	if cached := tx.Payer(); cached == nil || !cached.Equal(payer) {
+		t.Errorf("fee payer not cached: have %v, want %v", cached, payer)
+	}
+	// The fee payer signature doesn't carry over to another chain
+	if _, err := Payer(NewSigner(big.NewInt(9001)), tx); err != ErrInvalidChainId {
+		t.Errorf("payer recovered on another chain: have %v, want %v", err, ErrInvalidChainId)
+	}
+}
+
+func TestSponsoredTxPayerCoversSender(t *testing.T) {
+	signer := NewSigner(big.NewInt(9000))
+	tx, _, payer := signedSponsoredTx(t, signer)
+
+	// Re-signing as another sender invalidates the fee payer signature
+	otherKey, _ := crypto.GenerateKey()
+	resigned, err := SignTx(tx, signer, otherKey)
+	if err != nil {
+		t.Fatalf("failed to re-sign: %v", err)
+	}
+	if feePayer, err := Payer(signer, resigned); err == nil && feePayer.Equal(payer) {
+		t.Errorf("fee payer signature still valid after re-signing by another sender")
+	}
+}
+
+func TestSponsoredTxGasCost(t *testing.T) {
+	tx, _, _ := signedSponsoredTx(t, NewSigner(big.NewInt(9000)))
+
+	if tx.Cost().Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("sender cost mismatch: have %v, want %v", tx.Cost(), 100)
+	}
+	if tx.GasCost().Cmp(big.NewInt(210000)) != 0 {
+		t.Errorf("gas cost mismatch: have %v, want %v", tx.GasCost(), 210000)
+	}
+}
+
+func TestSponsoredTxEncoding(t *testing.T) {
+	signer := NewSigner(big.NewInt(9000))
+	tx, sender, payer := signedSponsoredTx(t, signer)
+
+	blob, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	if blob[0] != SponsoredTxType {
+		t.Fatalf("type prefix mismatch: have %d, want %d", blob[0], SponsoredTxType)
+	}
+	var decoded Transaction
+	if err := decoded.UnmarshalBinary(blob); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	assertSponsoredEqual(t, "rlp", signer, &decoded, tx, sender, payer)
+
+	enc, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatalf("failed to marshal json: %v", err)
+	}
+	var parsed Transaction
+	if err := json.Unmarshal(enc, &parsed); err != nil {
+		t.Fatalf("failed to unmarshal json: %v", err)
+	}
+	assertSponsoredEqual(t, "json", signer, &parsed, tx, sender, payer)
+}
+
+func TestSponsoredTxCopy(t *testing.T) {
+	signer := NewSigner(big.NewInt(9000))
+	tx, _, _ := signedSponsoredTx(t, signer)
+
+	inner := tx.inner.(*SponsoredTx)
+	cpy := inner.copy().(*SponsoredTx)
+	if cpy.To == inner.To {
+		t.Fatalf("recipient shared with the copy")
+	}
+	if !cpy.To.Equal(*inner.To) {
+		t.Errorf("recipient mismatch: have %v, want %v", cpy.To, inner.To)
+	}
+	if NewTx(cpy).Hash() != tx.Hash() {
+		t.Errorf("hash mismatch: have %x, want %x", NewTx(cpy).Hash(), tx.Hash())
+	}
+}
+
+func assertSponsoredEqual(t *testing.T, name string, signer Signer, have, want *Transaction, sender, payer common.Address) {
+	t.Helper()
+
+	if have.Hash() != want.Hash() {
+		t.Errorf("%s: hash mismatch: have %x, want %x", name, have.Hash(), want.Hash())
+	}
+	if have.Type() != SponsoredTxType {
+		t.Errorf("%s: type mismatch: have %d, want %d", name, have.Type(), SponsoredTxType)
+	}
+	if !bytes.Equal(have.Data(), want.Data()) {
+		t.Errorf("%s: data mismatch: have %x, want %x", name, have.Data(), want.Data())
+	}
+	haveV, haveR, haveS := have.RawPayerSignatureValues()
+	wantV, wantR, wantS := want.RawPayerSignatureValues()
+	if haveV.Cmp(wantV) != 0 || haveR.Cmp(wantR) != 0 || haveS.Cmp(wantS) != 0 {
+		t.Errorf("%s: fee payer signature mismatch", name)
+	}
+	if from, err := Sender(signer, have); err != nil || !from.Equal(sender) {
+		t.Errorf("%s: sender mismatch: have %v (%v), want %v", name, from, err, sender)
+	}
+	if feePayer, err := Payer(signer, have); err != nil || !feePayer.Equal(payer) {
+		t.Errorf("%s: fee payer mismatch: have %v (%v), want %v", name, feePayer, err, payer)
+	}
+}
@@ -0,0 +1,160 @@
+package core
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/consensus/misc"
+	"github.com/dominant-strategies/go-quai/core/types"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// blockPeersLimit is the number of received blocks whose sending peer is kept
+// for the provenance of the uncle candidates.
+const blockPeersLimit = 1024
+
+// uncleInclusionRewardDivisor is the share of the block reward credited to the
+// miner for including an uncle.
+var uncleInclusionRewardDivisor = big.NewInt(32)
+
+// UncleCandidate describes a side block kept as a possible uncle.
+type UncleCandidate struct {
+	Hash           common.Hash
+	ParentHash     common.Hash
+	Number         uint64
+	Local          bool      // Whether the block was mined locally
+	Peer           string    // Peer the block was received from, empty if local or unknown
+	ArrivedAt      time.Time // Time the block was added as a candidate
+	RewardEstimate *big.Int  // Reward credited to the coinbase for including the uncle
+}
+
+// uncleCandidate is a possible uncle along with its provenance.
+type uncleCandidate struct {
+	block *types.Block
+	info  UncleCandidate
+}
+
+// uncleTracker keeps the side blocks which can be included as uncles in the
+// sealing blocks, locally mined ones apart from the remote ones.
+type uncleTracker struct {
+	mu     sync.RWMutex
+	local  map[common.Hash]*uncleCandidate
+	remote map[common.Hash]*uncleCandidate
+	peers  *lru.Cache // Peers the recent blocks were received from, by block hash
+}
+
+func newUncleTracker() *uncleTracker {
+	peers, _ := lru.New(blockPeersLimit)
+	return &uncleTracker{
+		local:  make(map[common.Hash]*uncleCandidate),
+		remote: make(map[common.Hash]*uncleCandidate),
+		peers:  peers,
+	}
+}
+
+// recordPeer records the peer a block was received from, in case it ends up on
+// a side chain.
+func (t *uncleTracker) recordPeer(hash common.Hash, peer string) {
+	t.peers.Add(hash, peer)
+}
+
+// add records a side block as a possible uncle, returning false if it is
+// already known. If no peer is given, the one recorded for the block is used.
+func (t *uncleTracker) add(block *types.Block, local bool, peer string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	hash := block.Hash()
+	if _, ok := t.local[hash]; ok {
+		return false
+	}
+	if _, ok := t.remote[hash]; ok {
+		return false
+	}
+	if recorded, ok := t.peers.Get(hash); ok && peer == "" {
+		peer = recorded.(string)
+	}
+	candidate := &uncleCandidate{
+		block: block,
+		info: UncleCandidate{
+			Hash:           hash,
+			ParentHash:     block.ParentHash(),
+			Number:         block.NumberU64(),
+			Local:          local,
+			Peer:           peer,
+			ArrivedAt:      time.Now(),
+			RewardEstimate: new(big.Int).Div(misc.CalculateReward(block.Header()), uncleInclusionRewardDivisor),
+		},
+	}
+	if local {
+		t.local[hash] = candidate
+	} else {
+		t.remote[hash] = candidate
+	}
+	return true
+}
+
+// prune removes the candidates which are too deep below the chain head number
+// to be included anymore, locally mined and remote ones retained for their
+// respective depths.
+func (t *uncleTracker) prune(head uint64, localRetention, remoteRetention uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for hash, candidate := range t.local {
+		if candidate.info.Number+localRetention <= head {
+			delete(t.local, hash)
+		}
+	}
+	for hash, candidate := range t.remote {
+		if candidate.info.Number+remoteRetention <= head {
+			delete(t.remote, hash)
+		}
+	}
+}
+
+// blocks returns the candidate blocks, the locally mined ones first and then
+// each group from the earliest arrival.
+func (t *uncleTracker) blocks() []*types.Block {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	blocks := make([]*types.Block, 0, len(t.local)+len(t.remote))
+	for _, candidate := range t.sorted() {
+		blocks = append(blocks, candidate.block)
+	}
+	return blocks
+}
+
+// candidates returns the description of all the candidates, in the order they
+// are considered for inclusion.
+func (t *uncleTracker) candidates() []UncleCandidate {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	infos := make([]UncleCandidate, 0, len(t.local)+len(t.remote))
+	for _, candidate := range t.sorted() {
+		info := candidate.info
+		info.RewardEstimate = new(big.Int).Set(info.RewardEstimate)
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// sorted returns the candidates in the order they are considered for inclusion.
+// It assumes the lock is held.
+func (t *uncleTracker) sorted() []*uncleCandidate {
+	sorted := make([]*uncleCandidate, 0, len(t.local)+len(t.remote))
+	for _, candidate := range t.local {
+		sorted = append(sorted, candidate)
+	}
+	for _, candidate := range t.remote {
+		sorted = append(sorted, candidate)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].info.Local != sorted[j].info.Local {
+			return sorted[i].info.Local
+		}
+		return sorted[i].info.ArrivedAt.Before(sorted[j].info.ArrivedAt)
+	})
+	return sorted
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+func uncleTestBlock(number int64, extra byte) *types.Block {
+	header := types.EmptyHeader()
+	header.SetNumber(big.NewInt(number))
+	header.SetExtra([]byte{extra})
+	return types.NewBlockWithHeader(header)
+}
+
+func TestUncleTrackerProvenance(t *testing.T) {
+	tracker := newUncleTracker()
+	fetched, mined, unknown := uncleTestBlock(1, 1), uncleTestBlock(1, 2), uncleTestBlock(1, 3)
+
+	// The peer noted when the block was received is kept once it is a side block
+	tracker.recordPeer(fetched.Hash(), "peer-1")
+	if !tracker.add(fetched, false, "") {
+		t.Fatalf("fetched block not added")
+	}
+	if !tracker.add(mined, true, "") || !tracker.add(unknown, false, "") {
+		t.Fatalf("side blocks not added")
+	}
+	if tracker.add(fetched, false, "") {
+		t.Errorf("known block added again")
+	}
+	candidates := tracker.candidates()
+	if len(candidates) != 3 {
+		t.Fatalf("candidates mismatch: have %d, want %d", len(candidates), 3)
+	}
+	// Locally mined blocks are considered first
+	if candidates[0].Hash != mined.Hash() || !candidates[0].Local {
+		t.Errorf("first candidate mismatch: have %x, want local %x", candidates[0].Hash, mined.Hash())
+	}
+	for _, candidate := range candidates {
+		var want string
+		if candidate.Hash == fetched.Hash() {
+			want = "peer-1"
+		}
+		if candidate.Peer != want {
+			t.Errorf("candidate %x peer mismatch: have %q, want %q", candidate.Hash, candidate.Peer, want)
+		}
+	}
+}
+
+func TestUncleTrackerPrune(t *testing.T) {
+	tracker := newUncleTracker()
+	tracker.add(uncleTestBlock(1, 0), true, "")
+	tracker.add(uncleTestBlock(1, 1), false, "")
+	tracker.add(uncleTestBlock(4, 2), false, "")
+
+	// Remote candidates are retained for a shorter depth than local ones
+	tracker.prune(5, 7, 3)
+	if have := len(tracker.blocks()); have != 2 {
+		t.Fatalf("retained candidates mismatch: have %d, want %d", have, 2)
+	}
+	tracker.prune(8, 7, 3)
+	if have := len(tracker.blocks()); have != 0 {
+		t.Fatalf("retained candidates mismatch: have %d, want %d", have, 0)
+	}
+}
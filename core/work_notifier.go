@@ -0,0 +1,221 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/common/hexutil"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/metrics"
+)
+
+const (
+	// notifyTimeout is the timeout of a single work notification request.
+	notifyTimeout = 1 * time.Second
+
+	// notifyAttempts is the maximum number of attempts to deliver a work
+	// notification to an endpoint.
+	notifyAttempts = 4
+
+	// notifyBaseBackoff is the delay before the first retry of a failed work
+	// notification, doubled on every following retry.
+	notifyBaseBackoff = 250 * time.Millisecond
+)
+
+// NotifyEndpointHealth is the delivery record of a work notification endpoint.
+type NotifyEndpointHealth struct {
+	URL                 string
+	Delivered           uint64    // Work packages delivered to the endpoint
+	Failed              uint64    // Work packages which could not be delivered after all retries
+	ConsecutiveFailures uint64    // Failed deliveries since the last successful one
+	LastDelivered       time.Time // Time of the last successful delivery, zero if none
+	LastError           string    // Error of the last failed attempt, empty if none
+}
+
+// notifyEndpoint is a remote miner notified of new work packages.
+type notifyEndpoint struct {
+	url string
+
+	mu     sync.Mutex
+	health NotifyEndpointHealth
+
+	deliveredMeter metrics.Meter
+	failedMeter    metrics.Meter
+	retryMeter     metrics.Meter
+	latencyTimer   metrics.Timer
+}
+
+// workNotifier pushes the pending headers produced by the worker to the remote
+// miners configured by Config.Notify, either as work packages or as full
+// headers if Config.NotifyFull is set. The delivery of a work package is given
+// up as soon as a newer one is available.
+type workNotifier struct {
+	full      bool
+	endpoints []*notifyEndpoint
+	client    *http.Client
+	backoff   time.Duration // Delay before the first retry of a failed delivery
+
+	cancel context.CancelFunc // Cancels the deliveries of the previous work package
+	wg     sync.WaitGroup     // Tracks the delivery goroutines
+}
+
+func newWorkNotifier(urls []string, full bool) *workNotifier {
+	n := &workNotifier{
+		full:    full,
+		client:  &http.Client{Timeout: notifyTimeout},
+		backoff: notifyBaseBackoff,
+		cancel:  func() {},
+	}
+	for i, url := range urls {
+		prefix := fmt.Sprintf("miner/notify/%d/", i)
+		n.endpoints = append(n.endpoints, &notifyEndpoint{
+			url:            url,
+			health:         NotifyEndpointHealth{URL: url},
+			deliveredMeter: metrics.NewRegisteredMeter(prefix+"delivered", nil),
+			failedMeter:    metrics.NewRegisteredMeter(prefix+"failed", nil),
+			retryMeter:     metrics.NewRegisteredMeter(prefix+"retry", nil),
+			latencyTimer:   metrics.NewRegisteredTimer(prefix+"latency", nil),
+		})
+	}
+	return n
+}
+
+// makeWorkPackage creates the work package of a header, in the format served to
+// remote miners by the consensus engines:
+//
+//	result[0], 32 bytes hex encoded header seal hash
+//	result[1], hex encoded header number
+//	result[2], 32 bytes hex encoded boundary condition ("target"), 2^256/difficulty
+func makeWorkPackage(header *types.Header) [4]string {
+	var work [4]string
+	work[0] = header.SealHash().Hex()
+	work[1] = hexutil.EncodeBig(header.Number())
+	work[2] = common.BytesToHash(new(big.Int).Div(common.Big2e256, header.Difficulty()).Bytes()).Hex()
+	return work
+}
+
+// notify sends the given pending header to all the endpoints, abandoning the
+// deliveries of the previous one still in flight.
+func (n *workNotifier) notify(header *types.Header) {
+	if header.Difficulty() == nil || header.Difficulty().Sign() <= 0 {
+		return
+	}
+	work := makeWorkPackage(header)
+
+	var (
+		blob []byte
+		err  error
+	)
+	if n.full {
+		blob, err = json.Marshal(header)
+	} else {
+		blob, err = json.Marshal(work)
+	}
+	if err != nil {
+		log.Warn("Failed to encode remote miner notification", "err", err)
+		return
+	}
+	n.cancel()
+	ctx, cancel := context.WithCancel(context.Background())
+	n.cancel = cancel
+
+	n.wg.Add(len(n.endpoints))
+	for _, endpoint := range n.endpoints {
+		go n.deliver(ctx, endpoint, blob, work)
+	}
+}
+
+// deliver posts a notification to an endpoint, retrying with exponential
+// backoff until it is accepted, the attempts are exhausted or the context is
+// cancelled by newer work.
+func (n *workNotifier) deliver(ctx context.Context, endpoint *notifyEndpoint, blob []byte, work [4]string) {
+	defer n.wg.Done()
+
+	backoff := n.backoff
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := n.post(ctx, endpoint.url, blob)
+		if err == nil {
+			endpoint.latencyTimer.UpdateSince(start)
+			endpoint.deliveredMeter.Mark(1)
+			endpoint.mu.Lock()
+			endpoint.health.Delivered++
+			endpoint.health.ConsecutiveFailures = 0
+			endpoint.health.LastDelivered = time.Now()
+			endpoint.mu.Unlock()
+			log.Trace("Notified remote miner", "miner", endpoint.url, "hash", work[0], "target", work[2], "attempt", attempt)
+			return
+		}
+		if ctx.Err() != nil {
+			// Superseded by newer work, the endpoint is not to blame
+			return
+		}
+		endpoint.mu.Lock()
+		endpoint.health.LastError = err.Error()
+		endpoint.mu.Unlock()
+
+		if attempt == notifyAttempts {
+			endpoint.failedMeter.Mark(1)
+			endpoint.mu.Lock()
+			endpoint.health.Failed++
+			endpoint.health.ConsecutiveFailures++
+			endpoint.mu.Unlock()
+			log.Warn("Failed to notify remote miner", "miner", endpoint.url, "hash", work[0], "attempts", attempt, "err", err)
+			return
+		}
+		endpoint.retryMeter.Mark(1)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+		backoff *= 2
+	}
+}
+
+// post sends a single notification request to the given url.
+func (n *workNotifier) post(ctx context.Context, url string, blob []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(blob))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// health returns the delivery records of all the endpoints.
+func (n *workNotifier) health() []NotifyEndpointHealth {
+	health := make([]NotifyEndpointHealth, 0, len(n.endpoints))
+	for _, endpoint := range n.endpoints {
+		endpoint.mu.Lock()
+		health = append(health, endpoint.health)
+		endpoint.mu.Unlock()
+	}
+	return health
+}
+
+// stop abandons the deliveries in flight and waits for them to return.
+func (n *workNotifier) stop() {
+	n.cancel()
+	n.wg.Wait()
+}
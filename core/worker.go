@@ -4,7 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
-	"strings"
+	"runtime"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,9 +18,12 @@ import (
 	"github.com/dominant-strategies/go-quai/core/rawdb"
 	"github.com/dominant-strategies/go-quai/core/state"
 	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/core/vm"
+	"github.com/dominant-strategies/go-quai/crypto"
 	"github.com/dominant-strategies/go-quai/ethdb"
 	"github.com/dominant-strategies/go-quai/event"
 	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/metrics"
 	"github.com/dominant-strategies/go-quai/params"
 	"github.com/dominant-strategies/go-quai/trie"
 	lru "github.com/hashicorp/golang-lru"
@@ -33,24 +37,163 @@ const (
 	// resubmitAdjustChanSize is the size of resubmitting interval adjustment channel.
 	resubmitAdjustChanSize = 10
 
-	// sealingLogAtDepth is the number of confirmations before logging successful sealing.
+	// chainSideChanSize is the size of channel listening to ChainSideEvent.
+	chainSideChanSize = 10
+
+	// txChanSize is the size of channel listening to NewTxsEvent.
+	txChanSize = 4096
+
+	// defaultTxBatchSize is the default maximum number of transaction events
+	// coalesced into a single pending state update.
+	defaultTxBatchSize = 64
+
+	// minSnapshotRebuildInterval is the minimal time interval between two rebuilds
+	// of the pending state with the new transactions while not sealing.
+	minSnapshotRebuildInterval = 100 * time.Millisecond
+
+	// sealingLogAtDepth is the default number of confirmations before logging successful sealing.
 	sealingLogAtDepth = 7
 
 	// minRecommitInterval is the minimal time interval to recreate the sealing block with
 	// any newly arrived transactions.
 	minRecommitInterval = 1 * time.Second
 
+	// intervalAdjustRatio is the impact a single interval adjustment has on sealing work
+	// resubmitting interval.
+	intervalAdjustRatio = 0.1
+
+	// intervalAdjustBias is applied during the new resubmit interval calculation in favor of
+	// increasing upper limit or decreasing lower limit so that the limit can be reachable.
+	intervalAdjustBias = 200 * 1000.0 * 1000.0
+
 	// staleThreshold is the maximum depth of the acceptable stale block.
 	staleThreshold = 7
 
 	// pendingBlockBodyLimit is maximum number of pending block bodies to be kept in cache.
 	pendingBlockBodyLimit = 320
 
+	// pendingBodyStoreChanSize is the size of channel queuing the pending block body
+	// writes and deletions to the db.
+	pendingBodyStoreChanSize = 64
+
+	// maxPendingBundles is the maximum number of transaction bundles kept for inclusion.
+	maxPendingBundles = 256
+
+	// maxBundleLookahead is how many blocks ahead of the next one a bundle may target.
+	maxBundleLookahead = 25
+
+	// maxSimulationTime is the time budget for packing the transactions of a block
+	// simulation, unless the configured fill deadline is shorter.
+	maxSimulationTime = time.Second
+
 	// c_headerPrintsExpiryTime is how long a header hash is kept in the cache, so that currentInfo
 	// is not printed on a Proc frequency
 	c_headerPrintsExpiryTime = 2 * time.Minute
+
+	// pendingBodyMissLogInterval is the minimum time between two warnings about
+	// pending block bodies missing from the cache.
+	pendingBodyMissLogInterval = 30 * time.Second
+
+	// snapshotCheckInterval is the time interval to check the consistency of the
+	// pending snapshot, if enabled.
+	snapshotCheckInterval = 1 * time.Minute
+
+	// stateRecoveryReexec is the maximum number of blocks reexecuted to recover
+	// the pruned state of the sealing parent.
+	stateRecoveryReexec = 1024
+
+	// speculativeStateRecoveryReexec is the maximum number of blocks reexecuted to
+	// recover the state of a speculative sealing parent, which may be far off the
+	// canonical chain.
+	speculativeStateRecoveryReexec = 8192
+
+	// commitAttemptsFactor bounds the number of iterations of commitTransactions
+	// relative to the number of pending transactions. Every iteration consumes a
+	// transaction, so exceeding it means the transaction source never advances.
+	commitAttemptsFactor = 2
+
+	// defaultSealingHistorySize is the default number of recent sealing results kept.
+	defaultSealingHistorySize = 64
+
+	// defaultReorgWindow is the default depth below the chain head of the ancestors
+	// pending headers can be requested on.
+	defaultReorgWindow = 64
+)
+
+var (
+	gasLimitTargetGauge = metrics.NewRegisteredGauge("miner/gaslimit/target", nil)
+
+	pendingBodyHitMeter     = metrics.NewRegisteredMeter("miner/pendingbody/hit", nil)
+	pendingBodyMissMeter    = metrics.NewRegisteredMeter("miner/pendingbody/miss", nil)
+	pendingBodyHitRateGauge = metrics.NewRegisteredGauge("miner/pendingbody/hitrate", nil) // Percentage of lookups served from the cache
+	pendingBodyCorruptMeter = metrics.NewRegisteredMeter("miner/pendingbody/corrupt", nil)
+	pendingBodyPersistMeter = metrics.NewRegisteredMeter("miner/pendingbody/persist_mismatch", nil)
+	pendingBodyEvictMeter   = metrics.NewRegisteredMeter("miner/pendingbody/evict", nil)
+	pendingBodyBytesGauge   = metrics.NewRegisteredGauge("miner/pendingbody/bytes", nil)
+
+	pendingBodyRecoveredCounter = metrics.NewRegisteredCounter("miner/pendingbody/recovered", nil)
+	pendingBodyDroppedCounter   = metrics.NewRegisteredCounter("miner/pendingbody/recovery_dropped", nil)
+
+	uncleFamilySizeGauge = metrics.NewRegisteredGauge("miner/uncle/familysize", nil)
+	uncleIncludedHist    = metrics.NewRegisteredHistogram("miner/uncle/included", nil, metrics.NewExpDecaySample(1028, 0.015))
+	uncleRejectedCounter = metrics.NewRegisteredCounter("miner/uncle/rejected", nil)
+
+	snapshotInconsistentMeter = metrics.NewRegisteredMeter("miner/snapshot/inconsistent", nil)
+
+	stateRecoveredCounter      = metrics.NewRegisteredCounter("miner/state/recovered", nil)
+	stateRecoveryFailedCounter = metrics.NewRegisteredCounter("miner/state/recovery_failed", nil)
+
+	unsupportedTxTypeCounter = metrics.NewRegisteredCounter("miner/tx/unsupported", nil)
+
+	txCommittedCounter           = metrics.NewRegisteredCounter("miner/tx/committed", nil)
+	txSponsoredCounter           = metrics.NewRegisteredCounter("miner/tx/sponsored", nil)
+	txRejectedGasLimitCounter    = metrics.NewRegisteredCounter("miner/tx/rejected/gaslimit", nil)
+	txRejectedEtxLimitCounter    = metrics.NewRegisteredCounter("miner/tx/rejected/etxlimit", nil)
+	txRejectedNonceLowCounter    = metrics.NewRegisteredCounter("miner/tx/rejected/noncelow", nil)
+	txRejectedNonceHiCounter     = metrics.NewRegisteredCounter("miner/tx/rejected/noncehigh", nil)
+	txRejectedZeroGasCounter     = metrics.NewRegisteredCounter("miner/tx/rejected/zerogas", nil)
+	txRejectedUnderpricedCounter = metrics.NewRegisteredCounter("miner/tx/rejected/underpriced", nil)
+	txRejectedRevertingCounter   = metrics.NewRegisteredCounter("miner/tx/rejected/reverting", nil)
+	txRejectedPayerCounter       = metrics.NewRegisteredCounter("miner/tx/rejected/payer", nil)
+	txRejectedBlockBytesCounter  = metrics.NewRegisteredCounter("miner/tx/rejected/blockbytes", nil)
+	txRejectedUnsupportedCounter = metrics.NewRegisteredCounter("miner/tx/rejected/unsupported", nil)
+	txRejectedOtherCounter       = metrics.NewRegisteredCounter("miner/tx/rejected/other", nil)
+
+	etxDroppedOnFailedTxCounter = metrics.NewRegisteredCounter("miner/etx/dropped_on_failed_tx", nil)
+	etxEmittedHist              = metrics.NewRegisteredHistogram("miner/etx/emitted", nil, metrics.NewExpDecaySample(1028, 0.015))
+
+	prepareWorkTimer      = metrics.NewRegisteredTimer("miner/prepare", nil)
+	fillTransactionsTimer = metrics.NewRegisteredTimer("miner/fill", nil)
+	finalizeAssembleTimer = metrics.NewRegisteredTimer("miner/finalize", nil)
+
+	preexecHitMeter    = metrics.NewRegisteredMeter("miner/preexec/hit", nil)
+	preexecPrunedMeter = metrics.NewRegisteredMeter("miner/preexec/pruned", nil)
+
+	parallelFallbackMeter = metrics.NewRegisteredMeter("miner/parallel/fallback", nil)
+	parallelDroppedMeter  = metrics.NewRegisteredMeter("miner/parallel/dropped", nil)
+	parallelMergedMeter   = metrics.NewRegisteredMeter("miner/parallel/merged", nil) // Transactions applied from their speculative execution
+	parallelStaleMeter    = metrics.NewRegisteredMeter("miner/parallel/stale", nil)  // Transactions executed again as the state they read changed
+
+	bundleIncludedCounter  = metrics.NewRegisteredCounter("miner/bundle/included", nil)
+	bundleDiscardedCounter = metrics.NewRegisteredCounter("miner/bundle/discarded", nil)
 )
 
+// errZeroGasUsed is returned by commitTransaction when a transaction which used
+// no gas is excluded from the sealing block.
+var errZeroGasUsed = errors.New("transaction used no gas")
+
+// errBlockBytesReached is returned by commitTransaction when the ETXs emitted by
+// a transaction don't fit in the block size limit along with it.
+var errBlockBytesReached = errors.New("block size limit reached")
+
+// errNilBlock is returned when the consensus engine assembles no block without
+// reporting an error.
+var errNilBlock = errors.New("engine returned nil block")
+
+// errDryRun is returned when sealing work is requested or submitted while the
+// worker withholds it in dry-run mode.
+var errDryRun = errors.New("sealing work withheld in dry-run mode")
+
 // environment is the worker's current environment and holds all
 // information of the sealing block generation.
 type environment struct {
@@ -60,20 +203,48 @@ type environment struct {
 	ancestors mapset.Set     // ancestor set (used for checking uncle parent validity)
 	family    mapset.Set     // family set (used for checking uncle invalidity)
 	tcount    int            // tx count in cycle
+	reverted  int            // count of transactions reverted in cycle
 	gasPool   *GasPool       // available gas used to pack transactions
 	coinbase  common.Address
 	etxRLimit int // Remaining number of cross-region ETXs that can be included
 	etxPLimit int // Remaining number of cross-prime ETXs that can be included
 
+	externalGasUsed uint64             // gas used by the external transactions included in the block
+	etxGas          uint64             // total gas of the ETXs emitted by the transactions included in the block
+	size            common.StorageSize // size of the transactions included in the block and of the ETXs they emitted
+	localGasUsed    uint64             // gas used by the local transactions included in the block
+
+	vmConfig *vm.Config // vm config used to apply transactions, the processor's if nil
+
+	blockContext func() vm.BlockContext // creates the block context of speculative execution, derived from the header if nil
+
+	speculated map[common.Hash]*speculatedTx // outcome of the parallel speculative execution, applied in place of executing the transactions
+
+	simulation bool // whether the block is only simulated, its failures are then neither retried nor reported
+
+	fillDeadline time.Time // wall-clock time after which no more transactions are packed, none if zero
+
 	header      *types.Header
 	txs         []*types.Transaction
 	etxs        []*types.Transaction
 	subManifest types.BlockManifest
 	receipts    []*types.Receipt
+	deferred    []*types.Transaction // transactions deferred to a later block by the inclusion policy
 	uncleMu     sync.RWMutex
 	uncles      map[common.Hash]*types.Header
+
+	refs int32 // References held on an environment taken from the pool, accessed atomically
 }
 
+// InclusionDecision is the verdict of an inclusion policy on a transaction.
+type InclusionDecision int
+
+const (
+	InclusionInclude InclusionDecision = iota // Apply the transaction to the block
+	InclusionSkip                             // Drop the transaction from the block
+	InclusionDefer                            // Drop the transaction from the block and record it for a later one
+)
+
 // copy creates a deep copy of environment.
 func (env *environment) copy(processingState bool) *environment {
 	nodeCtx := common.NodeLocation.Context()
@@ -84,11 +255,18 @@ func (env *environment) copy(processingState bool) *environment {
 			ancestors: env.ancestors.Clone(),
 			family:    env.family.Clone(),
 			tcount:    env.tcount,
+			reverted:  env.reverted,
 			coinbase:  env.coinbase,
 			etxRLimit: env.etxRLimit,
 			etxPLimit: env.etxPLimit,
+			etxGas:    env.etxGas,
+			size:      env.size,
 			header:    types.CopyHeader(env.header),
 			receipts:  copyReceipts(env.receipts),
+
+			externalGasUsed: env.externalGasUsed,
+			localGasUsed:    env.localGasUsed,
+			vmConfig:        env.vmConfig,
 		}
 		if env.gasPool != nil {
 			gasPool := *env.gasPool
@@ -100,6 +278,8 @@ func (env *environment) copy(processingState bool) *environment {
 		copy(cpy.txs, env.txs)
 		cpy.etxs = make([]*types.Transaction, len(env.etxs))
 		copy(cpy.etxs, env.etxs)
+		cpy.deferred = make([]*types.Transaction, len(env.deferred))
+		copy(cpy.deferred, env.deferred)
 
 		env.uncleMu.Lock()
 		cpy.uncles = make(map[common.Hash]*types.Header)
@@ -134,6 +314,53 @@ func (env *environment) discard() {
 	env.state.StopPrefetcher()
 }
 
+// Bundle is a list of transactions to be included atomically and in order into
+// the block of the given number.
+type Bundle struct {
+	Txs         types.Transactions
+	BlockNumber uint64
+}
+
+// pendingBodyEntry is an entry of the pending block body cache, holding the body
+// along with the number of the header it was assembled for.
+type pendingBodyEntry struct {
+	body   *types.Body
+	number uint64
+	size   common.StorageSize // Approximate size of the body
+}
+
+// newPendingBodyEntry creates a pending block body cache entry for the given body.
+func newPendingBodyEntry(body *types.Body, number uint64) *pendingBodyEntry {
+	var size common.StorageSize
+	if body != nil {
+		for _, tx := range body.Transactions {
+			size += tx.Size()
+		}
+		for _, etx := range body.ExtTransactions {
+			size += etx.Size()
+		}
+		for _, uncle := range body.Uncles {
+			size += uncle.Size()
+		}
+		size += common.StorageSize(len(body.SubManifest) * common.HashLength)
+	}
+	return &pendingBodyEntry{body: body, number: number, size: size}
+}
+
+// meta returns the metadata persisted along with the body of the entry.
+func (e *pendingBodyEntry) meta() *rawdb.PendingBodyMeta {
+	return &rawdb.PendingBodyMeta{Number: e.number, Size: uint64(e.size)}
+}
+
+// pendingBodyOp is a write of a pending block body into the db, or its deletion
+// if the body is nil. A flush op only signals once the previous ones are done.
+type pendingBodyOp struct {
+	key   common.Hash
+	body  *types.Body
+	meta  *rawdb.PendingBodyMeta
+	flush chan struct{}
+}
+
 // task contains all information for consensus engine sealing and result submitting.
 type task struct {
 	receipts  []*types.Receipt
@@ -148,6 +375,88 @@ const (
 	commitInterruptResubmit
 )
 
+// SealingResult summarizes a block assembled and sealed locally.
+type SealingResult struct {
+	Number    uint64      `json:"number"`
+	SealHash  common.Hash `json:"sealHash"`
+	Txs       int         `json:"txs"`
+	Etxs      int         `json:"etxs"`
+	Uncles    int         `json:"uncles"`
+	GasUsed   uint64      `json:"gasUsed"`
+	Fees      *big.Float  `json:"fees"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// SimulatedBlock is the block the worker would build on top of the chain head
+// with a set of additional transactions in the pool.
+type SimulatedBlock struct {
+	Block    *types.Block
+	Receipts types.Receipts
+	Fees     *big.Int // Fees paid to the coinbase, in wei
+}
+
+// WorkerStats is a summary of the runtime state of the worker.
+type WorkerStats struct {
+	Running    bool
+	DryRun     bool           // Whether assembled blocks are withheld from sealing
+	Recommit   time.Duration  // Current interval for recommitting the sealing work
+	LastSealed *SealingResult // Most recent locally sealed block, nil if none
+}
+
+// FeeSummary is the aggregate fee statistics of a block, all amounts in wei.
+type FeeSummary struct {
+	TotalFees     *big.Int // Fees paid to the coinbase on top of the base fee
+	BaseFeeBurned *big.Int // Base fee paid for the gas used by the transactions
+	TxCount       int
+}
+
+// EnvSnapshot is a serializable summary of a sealing environment, meant to be
+// attached to bug reports to reproduce the sealing of a block.
+type EnvSnapshot struct {
+	Header       *types.Header  `json:"header"`
+	TxHashes     []common.Hash  `json:"txHashes"`
+	EtxHashes    []common.Hash  `json:"etxHashes"`
+	UncleHashes  []common.Hash  `json:"uncleHashes"`
+	Coinbase     common.Address `json:"coinbase"`
+	GasRemaining uint64         `json:"gasRemaining"`
+	StateRoot    common.Hash    `json:"stateRoot"`
+}
+
+// sealingHistory is a fixed size ring buffer of the most recent sealing results.
+type sealingHistory struct {
+	mu      sync.RWMutex
+	results []SealingResult
+	next    int  // index of the slot to be written next
+	full    bool // whether all the slots have been written
+}
+
+func newSealingHistory(size int) *sealingHistory {
+	return &sealingHistory{results: make([]SealingResult, size)}
+}
+
+// add records the given result, overwriting the oldest one if the buffer is full.
+func (h *sealingHistory) add(result SealingResult) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.results[h.next] = result
+	h.next = (h.next + 1) % len(h.results)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns a copy of the recorded results, from the oldest to the newest.
+func (h *sealingHistory) list() []SealingResult {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if !h.full {
+		return append([]SealingResult(nil), h.results[:h.next]...)
+	}
+	results := make([]SealingResult, 0, len(h.results))
+	results = append(results, h.results[h.next:]...)
+	return append(results, h.results[:h.next]...)
+}
+
 // intervalAdjust represents a resubmitting interval adjustment.
 type intervalAdjust struct {
 	ratio float64
@@ -156,15 +465,111 @@ type intervalAdjust struct {
 
 // Config is the configuration parameters of mining.
 type Config struct {
-	Etherbase  common.Address `toml:",omitempty"` // Public address for block mining rewards (default = first account)
-	Notify     []string       `toml:",omitempty"` // HTTP URL list to be notified of new work packages (only useful in ethash).
-	NotifyFull bool           `toml:",omitempty"` // Notify with pending block headers instead of work packages
-	ExtraData  hexutil.Bytes  `toml:",omitempty"` // Block extra data set by the miner
-	GasFloor   uint64         // Target gas floor for mined blocks.
-	GasCeil    uint64         // Target gas ceiling for mined blocks.
-	GasPrice   *big.Int       // Minimum gas price for mining a transaction
-	Recommit   time.Duration  // The time interval for miner to re-create mining work.
-	Noverify   bool           // Disable remote mining solution verification(only useful in ethash).
+	Etherbase        common.Address   `toml:",omitempty"` // Public address for block mining rewards (default = first account)
+	Etherbases       []common.Address `toml:",omitempty"` // Addresses the block rewards rotate between, overriding the etherbase
+	EtherbaseWeights []uint64         `toml:",omitempty"` // Relative number of blocks credited to each of the etherbases (default = round-robin)
+	Notify           []string         `toml:",omitempty"` // HTTP URL list to be notified of new work packages
+	NotifyFull       bool             `toml:",omitempty"` // Notify with pending block headers instead of work packages
+	ExtraData        hexutil.Bytes    `toml:",omitempty"` // Block extra data set by the miner
+	GasFloor         uint64           // Target gas floor for mined blocks.
+	GasCeil          uint64           // Target gas ceiling for mined blocks.
+	GasPrice         *big.Int         // Minimum gas price for mining a transaction
+	Recommit         time.Duration    // The time interval for miner to re-create mining work.
+	Noverify         bool             // Disable remote mining solution verification(only useful in ethash).
+
+	AllowlistMode bool // Only include transactions from senders in the worker allowlist
+
+	LocalUncleRetention  uint64 // Depth after which locally mined uncles are pruned (default = staleThreshold)
+	RemoteUncleRetention uint64 // Depth after which remote uncles are pruned (default = staleThreshold)
+
+	TxBatchSize    int // Maximum number of transaction events coalesced into a single pending state update while not sealing (default = 64)
+	TxCapacityHint int // Expected number of transactions per block, used to preallocate the sealing environment (default = pending transactions, up to the parent gas limit / TxGas)
+
+	// SkipEtxRollup disables the etx rollup computation during block assembly and
+	// leaves the etx rollup hash zeroed. The resulting blocks are invalid, so this
+	// is only meant for isolated sealing benchmarks.
+	SkipEtxRollup bool
+
+	// AllowSiblingUncles lets uncles sharing the parent of the sealing block pass
+	// the worker's uncle selection. Consensus rules decide whether such uncles are
+	// valid, so enabling this on a chain which rejects siblings produces blocks
+	// that will fail verification.
+	AllowSiblingUncles bool
+
+	SnapshotSelfCheck bool // Periodically verify the consistency of the pending snapshot
+
+	MaxBlockBytes int // Maximum size in bytes of the transactions and etxs packed in a block (0 = unlimited)
+
+	MaxRecommitInterval time.Duration // Ceiling of the adjusted recommit interval (0 = unlimited)
+
+	AdaptiveRecommit    bool          // Tune the recommit interval to the recent zone block intervals and ETX load
+	AdaptiveRecommitMin time.Duration // Lower bound of the adaptive recommit interval (default = Recommit)
+	AdaptiveRecommitMax time.Duration // Upper bound of the adaptive recommit interval (default = MaxRecommitInterval, or 4 x Recommit if unset)
+
+	QuietUnsupportedTxType bool // Log skipped transactions of unsupported types at debug instead of error level
+
+	ExcludeZeroGasTxs bool // Exclude transactions which use no gas from the sealing block
+
+	Name string // Identity of the worker in logs (default = node location name)
+
+	// DryRun assembles blocks and keeps the pending snapshot and feeds up to date
+	// without ever handing work to the miners nor accepting sealed headers, for
+	// RPC-only and relay nodes which need accurate pending state but don't mine.
+	DryRun bool
+
+	SealingHistorySize int // Number of recent sealing results kept (default = 64)
+
+	RejectConcurrentGeneration bool // Fail pending header generation while another one is in flight
+
+	FixedBlockInterval time.Duration // Interval to generate a pending header regardless of transactions (0 = disabled)
+
+	VerifyPendingBodies bool // Verify the roots of cached pending block bodies against the requested header
+
+	SealingLogDepth uint64 // Number of confirmations before logging a successfully sealed block (default = 7)
+
+	FeeSummary bool // Compute a fee summary of each pending block
+
+	ParallelPacking        bool // Speculatively execute the transactions of independent senders in parallel (experimental)
+	ParallelPackingThreads int  // Number of threads used for parallel packing (default = number of CPUs)
+
+	RetryTransientFailures bool // Retry the transactions which failed with a transient error first in the next cycle
+
+	AutoRegenerate     bool          // Regenerate the pending header on the new chain heads while running
+	RegenerateDebounce time.Duration // Delay coalescing chain head events before regenerating the pending header (0 = regenerate on every head)
+
+	GasCeilByContext map[int]uint64 // Target gas ceiling per node context, GasCeil is used for contexts not set
+
+	GasPoolReserve uint64 // Gas of each block reserved and unavailable to transactions
+
+	MaxTxPerSender int // Maximum number of transactions included per sender in a block (0 = unlimited)
+
+	PendingBodyMaxBytes int // Approximate size limit of the pending block body cache (0 = entry count limit only)
+
+	VerifyPbBodyPersistence bool // Read back the stored pending block bodies and compare them to the cached ones
+
+	TxOrdering TxOrdering `toml:"-"` // Strategy ordering the pending transactions for packing, set programmatically (default = by price and nonce)
+
+	FillDeadline time.Duration // Time budget for packing transactions into a block (0 = unlimited)
+
+	PriorityGasCap uint64 // Maximum gas of each block used by the transactions of priority senders (0 = unlimited)
+
+	LocalGasReserve uint64 // Percentage of the gas ceiling held back for transactions of local accounts (0 = disabled)
+
+	EtxCountCap uint64 // Maximum number of ETXs emitted by the transactions of a block (0 = unlimited)
+	EtxGasCap   uint64 // Maximum total gas of the ETXs emitted by the transactions of a block (0 = unlimited)
+
+	ReorgWindow uint64 // Depth below the chain head of the ancestors pending headers can be requested on (default = 64)
+
+	SpeculativePreexec bool // Pre-execute the pending transactions on top of each pending block to prune the ones bound to fail from its child
+}
+
+// priceAndNonceOrdering is the default transaction ordering, packing the pending
+// transactions by effective tip while respecting the nonces of each account.
+type priceAndNonceOrdering struct{}
+
+// OrderPending implements TxOrdering.
+func (priceAndNonceOrdering) OrderPending(signer types.Signer, pending map[common.AddressBytes]types.Transactions, baseFee *big.Int) TxIterator {
+	return types.NewTransactionsByPriceAndNonce(signer, pending, baseFee, true)
 }
 
 // worker is the main object which takes care of submitting new work to consensus engine
@@ -177,12 +582,20 @@ type worker struct {
 	txPool      *TxPool
 
 	// Feeds
-	pendingLogsFeed   event.Feed
-	pendingHeaderFeed event.Feed
+	pendingLogsFeed        event.Feed
+	pendingHeaderFeed      event.Feed
+	pendingInvalidatedFeed event.Feed
+	txFailureFeed          event.Feed
 
 	// Subscriptions
 	chainHeadCh  chan ChainHeadEvent
 	chainHeadSub event.Subscription
+	chainSideCh  chan ChainSideEvent
+	chainSideSub event.Subscription
+	domHeaderCh  chan DomHeaderEvent
+	domHeaderSub event.Subscription
+	txsCh        chan NewTxsEvent
+	txsSub       event.Subscription
 
 	// Channels
 	taskCh                         chan *task
@@ -190,35 +603,76 @@ type worker struct {
 	exitCh                         chan struct{}
 	resubmitIntervalCh             chan time.Duration
 	resubmitAdjustCh               chan *intervalAdjust
+	recommitTargetCh               chan time.Duration
+	pendingBodyStoreCh             chan pendingBodyOp
+	snapshotRebuildCh              chan int // Number of transaction events to apply to the pending state
 	fillTransactionsRollingAverage *RollingAverage
 
+	interruptMu sync.Mutex // Protects the async pending header generation interrupt
 	interrupt   chan struct{}
 	asyncPhFeed event.Feed // asyncPhFeed sends an event after each state root update
 	scope       event.SubscriptionScope
 
+	fillInterruptsMu sync.Mutex
+	fillInterrupts   map[*int32]struct{} // Interrupt flags of the background pending header generations in flight
+
+	speculative  *speculativeResults // Results of the pre-execution on top of the recent pending blocks
+	preexecuting int32               // Whether a pre-execution is in flight, accessed atomically
+
 	wg sync.WaitGroup
 
-	current      *environment                 // An environment for current running cycle.
-	localUncles  map[common.Hash]*types.Block // A set of side blocks generated locally as the possible uncle blocks.
-	remoteUncles map[common.Hash]*types.Block // A set of side blocks as the possible uncle blocks.
-	uncleMu      sync.RWMutex
+	currentMu sync.RWMutex  // The lock used to protect the current environment
+	current   *environment  // An environment for current running cycle.
+	uncles    *uncleTracker // Side blocks kept as the possible uncle blocks, with their provenance.
 
-	mu       sync.RWMutex // The lock used to protect the coinbase and extra fields
+	mu       sync.RWMutex // The lock used to protect the engine, coinbase and extra fields
+	engineMu sync.RWMutex // Held for reading by the work generations, so the engine is only swapped in between them
 	coinbase common.Address
 	extra    []byte
 
+	etherbases       []common.Address // Addresses the coinbase rotates between, the coinbase is used alone if empty
+	etherbaseWeights []uint64         // Relative number of blocks credited to each etherbase, round-robin if empty
+
+	allowedSenders  map[common.AddressBytes]struct{} // Senders whose transactions are included in allowlist mode
+	prioritySenders map[common.AddressBytes]struct{} // Senders whose transactions are committed ahead of the others
+
 	workerDb ethdb.Database
 
-	pendingBlockBody *lru.Cache
+	pendingBlockBody *pendingBodyCache
+	pendingBodyBytes int64 // Approximate size of the cached pending block bodies, accessed atomically
+
+	retryMu  sync.Mutex                         // The lock used to protect the retry set
+	retryTxs map[common.Hash]*types.Transaction // Transactions which failed transiently, retried first in the next cycle
+
+	bundleMu sync.Mutex // The lock used to protect the bundle pool
+	bundles  []*Bundle  // Transaction bundles submitted for inclusion into upcoming blocks
 
-	snapshotMu    sync.RWMutex // The lock used to protect the snapshots below
-	snapshotBlock *types.Block
+	snapshotMu       sync.RWMutex // The lock used to protect the snapshots below
+	snapshotBlock    *types.Block
+	snapshotReceipts types.Receipts
+	snapshotFees     *FeeSummary
 
 	headerPrints *expireLru.Cache
 
+	sealingHistory *sealingHistory // Recent sealing results
+	assembled      *lru.Cache      // Summaries of the recently assembled blocks by seal hash, recorded once sealed
+	sealedJournal  *sealedJournal  // Sealed blocks persisted until they are imported
+
+	notifier *workNotifier // Pushes new work to the remote miners, nil if none are configured
+
+	recommitCtl *recommitController // Derives the recommit interval from the recent zone blocks, nil if not adaptive
+
 	// atomic status counters
-	running int32 // The indicator whether the consensus engine is running or not.
-	newTxs  int32 // New arrival transaction count since last sealing work submitting.
+	running      int32 // The indicator whether the consensus engine is running or not.
+	recommit     int64 // The current interval for miner sealing work recommitting.
+	recommitAt   int64 // Unix nano deadline of the next sealing work recommit, zero if none is scheduled.
+	generating   int32 // Number of pending header generations in flight.
+	newTxs       int32 // New arrival transaction count since last sealing work submitting.
+	lastReverted int32 // Number of transactions reverted while filling the last pending block.
+	dryRun       int32 // The indicator whether assembled blocks are withheld from sealing.
+
+	pendingBodyMisses     uint64 // Pending block body cache misses since the last warning.
+	pendingBodyMissWarned int64  // Unix nano timestamp of the last pending block body miss warning.
 
 	// noempty is the flag used to control whether the feature of pre-seal empty
 	// block is enabled. The default value is false(pre-seal is enabled by default).
@@ -228,7 +682,16 @@ type worker struct {
 	noempty uint32
 
 	// External functions
-	isLocalBlock func(header *types.Header) bool // Function used to determine whether the specified block is mined by local miner.
+	isLocalBlock   func(header *types.Header) bool                                     // Function used to determine whether the specified block is mined by local miner.
+	signerOverride func(config *params.ChainConfig, blockNumber *big.Int) types.Signer // Function used instead of types.MakeSigner to create the sealing signer, if set.
+	commitVeto     func(block *types.Block, receipts types.Receipts) error             // Function used to reject an assembled block before it is handed out for sealing, if set.
+	coinbaseCheck  func(addr common.Address) error                                     // Function used to validate the coinbase before preparing sealing work, if set.
+
+	coinbaseSelector func(blockNumber *big.Int) common.Address // Function used to select the coinbase of a block by its number instead of the etherbase, if set.
+
+	inclusionPolicy func(tx *types.Transaction, env *environment) InclusionDecision // Function consulted before applying each transaction, all are included if not set.
+
+	vmConfigOverride *vm.Config // VM config used for sealing instead of the processor's, if set.
 
 	// Test hooks
 	newTaskHook  func(*task) // Method to call upon receiving a new sealing task.
@@ -267,28 +730,78 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, db ethdb.Databas
 		coinbase:                       config.Etherbase,
 		isLocalBlock:                   isLocalBlock,
 		workerDb:                       db,
-		localUncles:                    make(map[common.Hash]*types.Block),
-		remoteUncles:                   make(map[common.Hash]*types.Block),
+		uncles:                         newUncleTracker(),
 		chainHeadCh:                    make(chan ChainHeadEvent, chainHeadChanSize),
+		chainSideCh:                    make(chan ChainSideEvent, chainSideChanSize),
+		domHeaderCh:                    make(chan DomHeaderEvent, chainHeadChanSize),
+		txsCh:                          make(chan NewTxsEvent, txChanSize),
+		snapshotRebuildCh:              make(chan int, 1),
+		fillInterrupts:                 make(map[*int32]struct{}),
+		speculative:                    newSpeculativeResults(),
 		taskCh:                         make(chan *task),
 		resultCh:                       make(chan *types.Block, resultQueueSize),
 		exitCh:                         make(chan struct{}),
 		interrupt:                      make(chan struct{}),
 		resubmitIntervalCh:             make(chan time.Duration),
 		resubmitAdjustCh:               make(chan *intervalAdjust, resubmitAdjustChanSize),
+		recommitTargetCh:               make(chan time.Duration, resubmitAdjustChanSize),
+		pendingBodyStoreCh:             make(chan pendingBodyOp, pendingBodyStoreChanSize),
 		fillTransactionsRollingAverage: &RollingAverage{windowSize: 100},
 	}
 	// Set the GasFloor of the worker to the minGasLimit
 	worker.config.GasFloor = params.MinGasLimit
 
-	phBodyCache, _ := lru.New(pendingBlockBodyLimit)
-	worker.pendingBlockBody = phBodyCache
+	worker.pendingBlockBody = worker.newPendingBodyCache()
+	worker.wg.Add(1)
+	go worker.pendingBodyStoreLoop()
 
 	// Sanitize recommit interval if the user-specified one is too short.
-	recommit := worker.config.Recommit
-	if recommit < minRecommitInterval {
-		log.Warn("Sanitizing miner recommit interval", "provided", recommit, "updated", minRecommitInterval)
-		recommit = minRecommitInterval
+	recommit := sanitizeRecommit(worker.config.Recommit, worker.config.MaxRecommitInterval)
+	atomic.StoreInt64(&worker.recommit, int64(recommit))
+	if worker.config.AdaptiveRecommit {
+		worker.recommitCtl = newRecommitController(adaptiveRecommitBounds(worker.config, recommit))
+	}
+	worker.wg.Add(1)
+	go worker.recommitLoop(recommit)
+
+	if worker.config.SealingHistorySize <= 0 {
+		worker.config.SealingHistorySize = defaultSealingHistorySize
+	}
+	worker.sealingHistory = newSealingHistory(worker.config.SealingHistorySize)
+	worker.assembled, _ = lru.New(pendingBlockBodyLimit)
+	worker.sealedJournal = newSealedJournal(db)
+
+	if worker.config.Name == "" {
+		worker.config.Name = common.NodeLocation.Name()
+	}
+	if len(worker.config.Etherbases) > 0 {
+		if err := worker.setEtherbases(worker.config.Etherbases, worker.config.EtherbaseWeights); err != nil {
+			log.Warn("Ignoring invalid etherbase rotation", "err", err)
+		}
+	}
+	if worker.config.ParallelPackingThreads <= 0 {
+		worker.config.ParallelPackingThreads = runtime.NumCPU()
+	}
+	if worker.config.LocalGasReserve > 100 {
+		log.Warn("Sanitizing local gas reserve", "provided", worker.config.LocalGasReserve, "updated", 100)
+		worker.config.LocalGasReserve = 100
+	}
+	if worker.config.SealingLogDepth == 0 {
+		worker.config.SealingLogDepth = sealingLogAtDepth
+	}
+	if worker.config.ReorgWindow == 0 {
+		worker.config.ReorgWindow = defaultReorgWindow
+	}
+
+	// Default the uncle retention depths to the stale threshold if not specified.
+	if worker.config.LocalUncleRetention == 0 {
+		worker.config.LocalUncleRetention = staleThreshold
+	}
+	if worker.config.RemoteUncleRetention == 0 {
+		worker.config.RemoteUncleRetention = staleThreshold
+	}
+	if worker.config.TxBatchSize <= 0 {
+		worker.config.TxBatchSize = defaultTxBatchSize
 	}
 
 	headerPrints, _ := expireLru.NewWithExpire(1, c_headerPrintsExpiryTime)
@@ -297,592 +810,3435 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, db ethdb.Databas
 	nodeCtx := common.NodeLocation.Context()
 	if headerchain.ProcessingState() && nodeCtx == common.ZONE_CTX {
 		worker.chainHeadSub = worker.hc.SubscribeChainHeadEvent(worker.chainHeadCh)
-		worker.wg.Add(1)
+		worker.chainSideSub = worker.hc.SubscribeChainSideEvent(worker.chainSideCh)
+		worker.domHeaderSub = worker.hc.SubscribeDomHeaderEvent(worker.domHeaderCh)
+		worker.txsSub = txPool.SubscribeNewTxsEvent(worker.txsCh)
+		worker.wg.Add(3)
 		go worker.asyncStateLoop()
+		go worker.uncleLoop()
+		go worker.snapshotRebuildLoop()
+
+		if worker.config.FixedBlockInterval > 0 {
+			worker.wg.Add(1)
+			go worker.fixedIntervalLoop(worker.config.FixedBlockInterval)
+		}
+	}
+	if worker.config.DryRun {
+		worker.dryRun = 1
+	}
+	if len(worker.config.Notify) > 0 {
+		worker.notifier = newWorkNotifier(worker.config.Notify, worker.config.NotifyFull)
+		worker.wg.Add(1)
+		go worker.notifyLoop()
 	}
 
 	return worker
 }
 
-// setEtherbase sets the etherbase used to initialize the block coinbase field.
+// setEtherbase sets the etherbase used to initialize the block coinbase field,
+// replacing any rotation unless the address is already the primary etherbase.
 func (w *worker) setEtherbase(addr common.Address) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	if addr.Equal(w.coinbase) {
+		return
+	}
 	w.coinbase = addr
+	w.etherbases, w.etherbaseWeights = nil, nil
 }
 
-func (w *worker) setGasCeil(ceil uint64) {
+// setEtherbases sets the etherbases the block coinbase rotates between. Blocks
+// are credited round-robin if no weights are given, otherwise each etherbase is
+// selected for its weight out of every total weight blocks. The first etherbase
+// becomes the primary one, used wherever a single address is needed.
+func (w *worker) setEtherbases(addrs []common.Address, weights []uint64) error {
+	if len(addrs) == 0 {
+		return errors.New("no etherbases provided")
+	}
+	for _, addr := range addrs {
+		if addr.Equal(common.ZeroAddr) {
+			return errors.New("zero etherbase provided")
+		}
+	}
+	if len(weights) > 0 {
+		if len(weights) != len(addrs) {
+			return fmt.Errorf("etherbase weight count mismatch: have %d, want %d", len(weights), len(addrs))
+		}
+		var total uint64
+		for _, weight := range weights {
+			total += weight
+		}
+		if total == 0 {
+			return errors.New("etherbase weights sum to zero")
+		}
+	}
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	w.config.GasCeil = ceil
+	w.coinbase = addrs[0]
+	w.etherbases = append([]common.Address(nil), addrs...)
+	w.etherbaseWeights = append([]uint64(nil), weights...)
+	return nil
 }
 
-// setExtra sets the content used to initialize the block extra field.
-func (w *worker) setExtra(extra []byte) {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-	w.extra = extra
+// etherbase returns the primary etherbase of the worker.
+func (w *worker) etherbase() common.Address {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.coinbase
 }
 
-// setRecommitInterval updates the interval for miner sealing work recommitting.
-func (w *worker) setRecommitInterval(interval time.Duration) {
-	select {
-	case w.resubmitIntervalCh <- interval:
-	case <-w.exitCh:
+// hasEtherbase returns whether the worker has a coinbase to credit the sealing
+// blocks with, either an etherbase or a coinbase selector. An etherbase never set
+// counts as missing, just like the zero address.
+func (w *worker) hasEtherbase() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.coinbaseSelector != nil {
+		return true
 	}
+	return !w.coinbase.Equal(common.Address{}) && !w.coinbase.Equal(common.ZeroAddr)
 }
 
-// disablePreseal disables pre-sealing feature
-func (w *worker) disablePreseal() {
-	atomic.StoreUint32(&w.noempty, 1)
-}
-
-// enablePreseal enables pre-sealing feature
-func (w *worker) enablePreseal() {
-	atomic.StoreUint32(&w.noempty, 0)
+// etherbaseAt returns the etherbase credited with the block of the given number
+// under the configured rotation. It assumes the worker lock is held.
+func (w *worker) etherbaseAt(number *big.Int) common.Address {
+	if len(w.etherbases) == 0 {
+		return w.coinbase
+	}
+	if len(w.etherbaseWeights) == 0 {
+		return w.etherbases[new(big.Int).Mod(number, big.NewInt(int64(len(w.etherbases)))).Uint64()]
+	}
+	var total uint64
+	for _, weight := range w.etherbaseWeights {
+		total += weight
+	}
+	slot := new(big.Int).Mod(number, new(big.Int).SetUint64(total)).Uint64()
+	for i, weight := range w.etherbaseWeights {
+		if slot < weight {
+			return w.etherbases[i]
+		}
+		slot -= weight
+	}
+	return w.coinbase
 }
 
-// pending returns the pending state and corresponding block.
-func (w *worker) pending() *types.Block {
-	// return a snapshot to avoid contention on currentMu mutex
-	w.snapshotMu.RLock()
-	defer w.snapshotMu.RUnlock()
-	return w.snapshotBlock
-}
+func (w *worker) setGasCeil(ceil uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.config.GasCeil = ceil
 
-// pendingBlock returns pending block.
-func (w *worker) pendingBlock() *types.Block {
-	// return a snapshot to avoid contention on currentMu mutex
-	w.snapshotMu.RLock()
-	defer w.snapshotMu.RUnlock()
-	return w.snapshotBlock
+	// Override the ceiling of the running context too, copying the map as it may
+	// be shared with the config the worker was created with
+	nodeCtx := common.NodeLocation.Context()
+	if _, ok := w.config.GasCeilByContext[nodeCtx]; ok {
+		ceils := make(map[int]uint64, len(w.config.GasCeilByContext))
+		for ctx, ctxCeil := range w.config.GasCeilByContext {
+			ceils[ctx] = ctxCeil
+		}
+		ceils[nodeCtx] = ceil
+		w.config.GasCeilByContext = ceils
+	}
 }
 
-// pendingBlockAndReceipts returns pending block and corresponding receipts.
-func (w *worker) pendingBlockAndReceipts() (*types.Block, types.Receipts) {
-	// return a snapshot to avoid contention on currentMu mutex
-	w.snapshotMu.RLock()
-	defer w.snapshotMu.RUnlock()
-	// snapshot receipts are not stored in the worker anymore, so pending receipts is nil
-	return w.snapshotBlock, nil
+// availableGas returns the gas available to transactions in a block with the
+// given gas limit, after setting aside the configured reserve.
+func (w *worker) availableGas(gasLimit uint64) uint64 {
+	reserve := w.config.GasPoolReserve
+	if reserve == 0 {
+		return gasLimit
+	}
+	if reserve >= gasLimit {
+		log.Warn("Ignoring gas pool reserve not below the gas limit", "reserve", reserve, "gaslimit", gasLimit)
+		return gasLimit
+	}
+	return gasLimit - reserve
 }
 
-// start sets the running status as 1 and triggers new work submitting.
-func (w *worker) start() {
-	atomic.StoreInt32(&w.running, 1)
+// gasCeil returns the target gas ceiling of the running node context.
+func (w *worker) gasCeil() uint64 {
+	if ceil, ok := w.config.GasCeilByContext[common.NodeLocation.Context()]; ok {
+		return ceil
+	}
+	return w.config.GasCeil
 }
 
-// stop sets the running status as 0.
-func (w *worker) stop() {
-	if w.hc.ProcessingState() && common.NodeLocation.Context() == common.ZONE_CTX {
-		w.chainHeadSub.Unsubscribe()
+// setGasPrice sets the minimum effective tip of the remote transactions included
+// in the sealing block. A nil or zero price disables the floor.
+func (w *worker) setGasPrice(price *big.Int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if price == nil {
+		w.config.GasPrice = nil
+		return
 	}
-	atomic.StoreInt32(&w.running, 0)
+	w.config.GasPrice = new(big.Int).Set(price)
 }
 
-// isRunning returns an indicator whether worker is running or not.
-func (w *worker) isRunning() bool {
-	return atomic.LoadInt32(&w.running) == 1
+// EffectiveConfig returns a copy of the configuration currently applied by the
+// worker, including the etherbase and extra data set at runtime.
+func (w *worker) EffectiveConfig() Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	config := *w.config
+	config.Etherbase = w.coinbase
+	config.Etherbases = append([]common.Address(nil), w.etherbases...)
+	config.EtherbaseWeights = append([]uint64(nil), w.etherbaseWeights...)
+	config.ExtraData = common.CopyBytes(w.extra)
+	config.Notify = append([]string(nil), w.config.Notify...)
+	if w.config.GasPrice != nil {
+		config.GasPrice = new(big.Int).Set(w.config.GasPrice)
+	}
+	if w.config.GasCeilByContext != nil {
+		config.GasCeilByContext = make(map[int]uint64, len(w.config.GasCeilByContext))
+		for ctx, ceil := range w.config.GasCeilByContext {
+			config.GasCeilByContext[ctx] = ceil
+		}
+	}
+	return config
 }
 
-// close terminates all background threads maintained by the worker.
-// Note the worker does not support being closed multiple times.
-func (w *worker) close() {
-	atomic.StoreInt32(&w.running, 0)
-	close(w.exitCh)
-	w.scope.Close()
-	w.wg.Wait()
+// setExtra sets the content used to initialize the block extra field.
+func (w *worker) setExtra(extra []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.extra = extra
 }
 
-func (w *worker) LoadPendingBlockBody() {
-	pendingBlockBodykeys := rawdb.ReadPbBodyKeys(w.workerDb)
-	for _, key := range pendingBlockBodykeys {
-		if key == types.EmptyBodyHash {
-			w.pendingBlockBody.Add(key, &types.Body{})
-		} else {
-			w.pendingBlockBody.Add(key, rawdb.ReadPbCacheBody(w.workerDb, key))
-		}
-		// Remove the entry from the database so that body is not accumulated over multiple stops
-		rawdb.DeletePbCacheBody(w.workerDb, key)
+// setAllowedSenders sets the senders whose transactions are included in the
+// sealing block when allowlist mode is enabled.
+func (w *worker) setAllowedSenders(addrs []common.Address) {
+	allowed := make(map[common.AddressBytes]struct{}, len(addrs))
+	for _, addr := range addrs {
+		allowed[addr.Bytes20()] = struct{}{}
 	}
-	rawdb.DeleteAllPbBodyKeys(w.workerDb)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.allowedSenders = allowed
 }
 
-// StorePendingBlockBody stores the pending block body cache into the db
+// setPrioritySenders sets the senders whose pending transactions are committed
+// to the sealing block ahead of the price ordered ones.
+func (w *worker) setPrioritySenders(addrs []common.Address) {
+	priority := make(map[common.AddressBytes]struct{}, len(addrs))
+	for _, addr := range addrs {
+		priority[addr.Bytes20()] = struct{}{}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.prioritySenders = priority
+}
+
+// PrioritySenders returns the senders whose transactions are committed first.
+func (w *worker) PrioritySenders() []common.Address {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	addrs := make([]common.Address, 0, len(w.prioritySenders))
+	for addr := range w.prioritySenders {
+		addrs = append(addrs, common.Bytes20ToAddress(addr))
+	}
+	return addrs
+}
+
+// TimeToNextRecommit returns the remaining time until the sealing work is due to
+// be recommitted, or zero if no recommit is scheduled.
+func (w *worker) TimeToNextRecommit() time.Duration {
+	deadline := atomic.LoadInt64(&w.recommitAt)
+	if deadline == 0 {
+		return 0
+	}
+	if remaining := time.Until(time.Unix(0, deadline)); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// setSignerOverride sets the function used to create the signer of new sealing
+// environments. A nil function restores the default signer.
+func (w *worker) setSignerOverride(makeSigner func(config *params.ChainConfig, blockNumber *big.Int) types.Signer) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.signerOverride = makeSigner
+}
+
+// setEngine swaps the consensus engine used for preparing and assembling the
+// sealing blocks. The generations in flight are aborted if interruptible and
+// waited for, so that no block is prepared by one engine and assembled by the
+// other. The current environment was prepared by the previous engine, so it is
+// discarded.
+func (w *worker) setEngine(engine consensus.Engine) error {
+	if engine == nil {
+		return errors.New("consensus engine not provided")
+	}
+	w.interruptFilling()
+	w.engineMu.Lock()
+	defer w.engineMu.Unlock()
+
+	w.mu.Lock()
+	w.engine = engine
+	w.mu.Unlock()
+
+	w.setCurrent(nil)
+	return nil
+}
+
+// getEngine returns the consensus engine used for sealing.
+func (w *worker) getEngine() consensus.Engine {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.engine
+}
+
+// setVMConfigOverride sets the vm config used to apply the transactions of new
+// sealing environments. A nil config restores the processor's vm config.
+func (w *worker) setVMConfigOverride(config *vm.Config) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.vmConfigOverride = config
+}
+
+// setCoinbaseValidator sets the function used to validate the coinbase before
+// preparing sealing work, e.g. against a registry of approved addresses.
+func (w *worker) setCoinbaseValidator(validator func(addr common.Address) error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.coinbaseCheck = validator
+}
+
+// setInclusionPolicy sets the function consulted before applying each transaction
+// to the sealing block. A nil function includes every transaction.
+func (w *worker) setInclusionPolicy(policy func(tx *types.Transaction, env *environment) InclusionDecision) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.inclusionPolicy = policy
+}
+
+// setCoinbaseSelector sets the function used to select the coinbase of each
+// sealing block by its number. A nil function restores the etherbase.
+func (w *worker) setCoinbaseSelector(selector func(blockNumber *big.Int) common.Address) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.coinbaseSelector = selector
+}
+
+// setCommitVeto sets the function consulted before handing out an assembled block
+// for sealing. A block is not handed out if the function returns an error.
+func (w *worker) setCommitVeto(veto func(block *types.Block, receipts types.Receipts) error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.commitVeto = veto
+}
+
+// checkCommitVeto consults the commit veto, if set, on an assembled block before
+// it is handed out for sealing.
+func (w *worker) checkCommitVeto(block *types.Block, receipts types.Receipts) error {
+	w.mu.RLock()
+	veto := w.commitVeto
+	w.mu.RUnlock()
+	if veto == nil {
+		return nil
+	}
+	if err := veto(block, receipts); err != nil {
+		log.Info("Sealing work vetoed", "worker", w.config.Name, "number", block.Number(), "sealhash", block.Header().SealHash(), "err", err)
+		return fmt.Errorf("%w: %v", ErrSealingWorkVetoed, err)
+	}
+	return nil
+}
+
+// setRecommitInterval updates the interval for miner sealing work recommitting.
+func (w *worker) setRecommitInterval(interval time.Duration) {
+	select {
+	case w.resubmitIntervalCh <- interval:
+	case <-w.exitCh:
+	}
+}
+
+// disablePreseal disables pre-sealing feature
+func (w *worker) disablePreseal() {
+	atomic.StoreUint32(&w.noempty, 1)
+}
+
+// enablePreseal enables pre-sealing feature
+func (w *worker) enablePreseal() {
+	atomic.StoreUint32(&w.noempty, 0)
+}
+
+// setDryRun toggles the dry-run mode, in which assembled blocks are never handed
+// to the miners for sealing.
+func (w *worker) setDryRun(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&w.dryRun, 1)
+	} else {
+		atomic.StoreInt32(&w.dryRun, 0)
+	}
+}
+
+// isDryRun returns whether assembled blocks are withheld from sealing.
+func (w *worker) isDryRun() bool {
+	return atomic.LoadInt32(&w.dryRun) == 1
+}
+
+// sendPendingHeader hands a new pending header to the miners, unless sealing
+// work is withheld in dry-run mode.
+func (w *worker) sendPendingHeader(header *types.Header) {
+	if w.isDryRun() {
+		log.Trace("Withheld pending header in dry-run mode", "worker", w.config.Name, "number", header.Number())
+		return
+	}
+	w.pendingHeaderFeed.Send(header)
+}
+
+// RecentSealingResults returns the summaries of the most recent blocks sealed
+// locally, from the oldest to the newest.
+func (w *worker) RecentSealingResults() []SealingResult {
+	return w.sealingHistory.list()
+}
+
+// LastRevertedCount returns the number of transactions which were attempted and
+// reverted while filling the last pending block.
+func (w *worker) LastRevertedCount() int {
+	return int(atomic.LoadInt32(&w.lastReverted))
+}
+
+// NewTxCount returns the number of transactions arrived since the last sealing
+// work was generated.
+func (w *worker) NewTxCount() int32 {
+	return atomic.LoadInt32(&w.newTxs)
+}
+
+// ResetNewTxCount resets the number of transactions arrived since the last
+// sealing work was generated.
+func (w *worker) ResetNewTxCount() {
+	atomic.StoreInt32(&w.newTxs, 0)
+}
+
+// DeferredTransactions returns the transactions the inclusion policy deferred to
+// a later block while filling the current sealing block.
+func (w *worker) DeferredTransactions() types.Transactions {
+	w.currentMu.RLock()
+	defer w.currentMu.RUnlock()
+	if w.current == nil {
+		return nil
+	}
+	return append(types.Transactions(nil), w.current.deferred...)
+}
+
+// PendingFeeSummary returns the fee summary of the pending block, or nil if there
+// is no pending block or fee summaries are disabled.
+func (w *worker) PendingFeeSummary() *FeeSummary {
+	w.snapshotMu.RLock()
+	defer w.snapshotMu.RUnlock()
+	if w.snapshotFees == nil {
+		return nil
+	}
+	return &FeeSummary{
+		TotalFees:     new(big.Int).Set(w.snapshotFees.TotalFees),
+		BaseFeeBurned: new(big.Int).Set(w.snapshotFees.BaseFeeBurned),
+		TxCount:       w.snapshotFees.TxCount,
+	}
+}
+
+// PendingGasPool returns the gas remaining for transactions in the current
+// sealing environment along with its gas limit, and whether one exists.
+func (w *worker) PendingGasPool() (remaining uint64, limit uint64, ok bool) {
+	w.currentMu.RLock()
+	defer w.currentMu.RUnlock()
+	if w.current == nil {
+		return 0, 0, false
+	}
+	limit = w.current.header.GasLimit()
+	if w.current.gasPool == nil {
+		return limit, limit, true
+	}
+	return w.current.gasPool.Gas(), limit, true
+}
+
+// prefetchReporter is implemented by the states reporting the statistics of
+// their trie prefetcher, like state.StateDB.
+type prefetchReporter interface {
+	PrefetcherStats() (pending int, hits, misses uint64, ok bool)
+}
+
+// PrefetcherStats returns the statistics of the trie prefetcher of the current
+// sealing environment, and whether one is running.
+func (w *worker) PrefetcherStats() (pending int, hits, misses uint64, ok bool) {
+	w.currentMu.RLock()
+	defer w.currentMu.RUnlock()
+	if w.current == nil || w.current.state == nil {
+		return 0, 0, 0, false
+	}
+	return prefetcherStats(w.current.state)
+}
+
+// prefetcherStats returns the trie prefetcher statistics of the given state, if
+// it reports any.
+func prefetcherStats(state interface{}) (pending int, hits, misses uint64, ok bool) {
+	reporter, ok := state.(prefetchReporter)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	return reporter.PrefetcherStats()
+}
+
+// LocalAccounts returns the accounts the transaction pool considers local.
+func (w *worker) LocalAccounts() []common.Address {
+	locals := w.txPool.Locals()
+	accounts := make([]common.Address, 0, len(locals))
+	for i := range locals {
+		internal := locals[i]
+		accounts = append(accounts, common.NewAddressFromData(&internal))
+	}
+	return accounts
+}
+
+// pending returns the pending state and corresponding block.
+func (w *worker) pending() *types.Block {
+	// return a snapshot to avoid contention on currentMu mutex
+	w.snapshotMu.RLock()
+	defer w.snapshotMu.RUnlock()
+	return w.snapshotBlock
+}
+
+// pendingBlock returns pending block.
+func (w *worker) pendingBlock() *types.Block {
+	// return a snapshot to avoid contention on currentMu mutex
+	w.snapshotMu.RLock()
+	defer w.snapshotMu.RUnlock()
+	return w.snapshotBlock
+}
+
+// PendingManifestHash returns the manifest hash of the pending block, or the
+// empty hash if there is no pending block yet.
+func (w *worker) PendingManifestHash() common.Hash {
+	w.snapshotMu.RLock()
+	defer w.snapshotMu.RUnlock()
+	if w.snapshotBlock == nil {
+		return common.Hash{}
+	}
+	return w.snapshotBlock.Header().ManifestHash()
+}
+
+// PendingStateRoot returns the state root of the pending block, or the empty
+// hash if there is no pending block yet.
+func (w *worker) PendingStateRoot() common.Hash {
+	w.snapshotMu.RLock()
+	defer w.snapshotMu.RUnlock()
+	if w.snapshotBlock == nil {
+		return common.Hash{}
+	}
+	return w.snapshotBlock.Root()
+}
+
+// PendingSealHash returns the seal hash of the pending block, or the empty hash
+// if there is no pending block yet.
+func (w *worker) PendingSealHash() common.Hash {
+	w.snapshotMu.RLock()
+	defer w.snapshotMu.RUnlock()
+	if w.snapshotBlock == nil {
+		return common.Hash{}
+	}
+	return w.snapshotBlock.Header().SealHash()
+}
+
+// PendingEntropyInfo returns the order of the parent of the pending block along
+// with the parent entropy and parent delta S set on the pending header.
+func (w *worker) PendingEntropyInfo() (order int, parentEntropy *big.Int, parentDeltaS *big.Int, err error) {
+	w.snapshotMu.RLock()
+	block := w.snapshotBlock
+	w.snapshotMu.RUnlock()
+	if block == nil {
+		return 0, nil, nil, errors.New("no pending block")
+	}
+	parent := w.hc.GetHeaderByHash(block.ParentHash())
+	if parent == nil {
+		return 0, nil, nil, errors.New("parent of the pending block not found")
+	}
+	if parent.Hash() == w.hc.config.GenesisHash {
+		return 0, nil, nil, errors.New("pending block is built on the genesis block")
+	}
+	engine := w.getEngine()
+	_, order, err = engine.CalcOrder(parent)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	parentEntropy = engine.TotalLogS(parent)
+
+	// Mirror the parent delta S set on the header in prepareWork
+	if nodeCtx := common.NodeLocation.Context(); nodeCtx != common.PRIME_CTX {
+		if order < nodeCtx {
+			parentDeltaS = big.NewInt(0)
+		} else {
+			parentDeltaS = engine.DeltaLogS(parent)
+		}
+	}
+	return order, parentEntropy, parentDeltaS, nil
+}
+
+// PendingTimestamp returns the timestamp the pending block was prepared with,
+// or 0 if no pending block exists.
+func (w *worker) PendingTimestamp() uint64 {
+	w.snapshotMu.RLock()
+	defer w.snapshotMu.RUnlock()
+	if w.snapshotBlock == nil {
+		return 0
+	}
+	return w.snapshotBlock.Time()
+}
+
+// PendingLogs returns copies of the logs of the pending block matching the given
+// addresses and topics. An empty address list or topic set matches any value.
+func (w *worker) PendingLogs(addresses []common.Address, topics [][]common.Hash) []*types.Log {
+	w.snapshotMu.RLock()
+	defer w.snapshotMu.RUnlock()
+
+	var logs []*types.Log
+	for _, receipt := range w.snapshotReceipts {
+		for _, l := range types.FilterLogs(receipt.Logs, nil, nil, addresses, topics) {
+			cpy := *l
+			logs = append(logs, &cpy)
+		}
+	}
+	return logs
+}
+
+// LastTxGasUsed returns the gas used by each transaction of the pending block,
+// keyed by the transaction hash.
+func (w *worker) LastTxGasUsed() map[common.Hash]uint64 {
+	w.snapshotMu.RLock()
+	defer w.snapshotMu.RUnlock()
+	gasUsed := make(map[common.Hash]uint64, len(w.snapshotReceipts))
+	for _, receipt := range w.snapshotReceipts {
+		gasUsed[receipt.TxHash] = receipt.GasUsed
+	}
+	return gasUsed
+}
+
+// pendingBlockAndReceipts returns pending block and corresponding receipts.
+func (w *worker) pendingBlockAndReceipts() (*types.Block, types.Receipts) {
+	// return a snapshot to avoid contention on currentMu mutex
+	w.snapshotMu.RLock()
+	defer w.snapshotMu.RUnlock()
+	return w.snapshotBlock, w.snapshotReceipts
+}
+
+// start sets the running status as 1 and triggers new work submitting.
+func (w *worker) start() {
+	atomic.StoreInt32(&w.running, 1)
+}
+
+// pause sets the running status as 0, keeping the worker subscribed to chain
+// events so that it can be started again.
+func (w *worker) pause() {
+	atomic.StoreInt32(&w.running, 0)
+}
+
+// Stats returns a summary of the runtime state of the worker.
+func (w *worker) Stats() WorkerStats {
+	stats := WorkerStats{
+		Running:  w.isRunning(),
+		DryRun:   w.isDryRun(),
+		Recommit: time.Duration(atomic.LoadInt64(&w.recommit)),
+	}
+	if results := w.sealingHistory.list(); len(results) > 0 {
+		last := results[len(results)-1]
+		stats.LastSealed = &last
+	}
+	return stats
+}
+
+// stop sets the running status as 0.
+func (w *worker) stop() {
+	if w.hc.ProcessingState() && common.NodeLocation.Context() == common.ZONE_CTX {
+		w.chainHeadSub.Unsubscribe()
+		w.chainSideSub.Unsubscribe()
+		w.domHeaderSub.Unsubscribe()
+		w.txsSub.Unsubscribe()
+	}
+	atomic.StoreInt32(&w.running, 0)
+}
+
+// IsGenerating returns whether a pending header generation is in flight.
+func (w *worker) IsGenerating() bool {
+	return atomic.LoadInt32(&w.generating) > 0
+}
+
+// isRunning returns an indicator whether worker is running or not.
+func (w *worker) isRunning() bool {
+	return atomic.LoadInt32(&w.running) == 1
+}
+
+// close terminates all background threads maintained by the worker.
+// Note the worker does not support being closed multiple times.
+func (w *worker) close() {
+	atomic.StoreInt32(&w.running, 0)
+	close(w.exitCh)
+	w.scope.Close()
+	w.wg.Wait()
+}
+
+// LoadPendingBlockBody restores the pending block body cache from the bodies
+// persisted in the db. Bodies left incomplete by a crash are discarded, and
+// bodies stored by the shutdown-only format are migrated.
+func (w *worker) LoadPendingBlockBody() {
+	var recovered, dropped int
+	for _, key := range rawdb.ReadAllPendingBodyHashes(w.workerDb) {
+		meta, body := rawdb.ReadPendingBodyMeta(w.workerDb, key), rawdb.ReadPendingBody(w.workerDb, key)
+		if meta == nil || body == nil {
+			rawdb.DeletePendingBody(w.workerDb, key)
+			dropped++
+			continue
+		}
+		w.addPendingBodyEntry(key, newPendingBodyEntry(body, meta.Number), false)
+		recovered++
+	}
+	pendingBodyRecoveredCounter.Inc(int64(recovered))
+	pendingBodyDroppedCounter.Inc(int64(dropped))
+
+	// The header numbers were not stored along with the bodies by the previous
+	// format, so the bodies are regarded as assembled on top of the current head.
+	legacyKeys := rawdb.ReadPbBodyKeys(w.workerDb)
+	var number uint64
+	if len(legacyKeys) > 0 {
+		number = w.hc.CurrentHeader().NumberU64() + 1
+	}
+	for _, key := range legacyKeys {
+		if key == types.EmptyBodyHash {
+			w.addPendingBodyEntry(key, newPendingBodyEntry(&types.Body{}, number), true)
+		} else if body := rawdb.ReadPbCacheBody(w.workerDb, key); body != nil {
+			w.addPendingBodyEntry(key, newPendingBodyEntry(body, number), true)
+		}
+		rawdb.DeletePbCacheBody(w.workerDb, key)
+	}
+	if legacyKeys != nil {
+		rawdb.DeleteAllPbBodyKeys(w.workerDb)
+	}
+	log.Info("Loaded pending block bodies", "recovered", recovered, "dropped", dropped, "migrated", len(legacyKeys))
+}
+
+// StorePendingBlockBody makes sure the pending block body cache is persisted in
+// the db. Bodies are written in the background as they are added to the cache,
+// so this only waits for the queued writes and verifies them if requested.
 func (w *worker) StorePendingBlockBody() {
-	// store the pendingBodyCache body
-	var pendingBlockBodyKeys []common.Hash
-	pendingBlockBody := w.pendingBlockBody
-	for _, key := range pendingBlockBody.Keys() {
-		if value, exist := pendingBlockBody.Peek(key); exist {
-			pendingBlockBodyKeys = append(pendingBlockBodyKeys, key.(common.Hash))
-			if key.(common.Hash) != types.EmptyBodyHash {
-				rawdb.WritePbCacheBody(w.workerDb, key.(common.Hash), value.(*types.Body))
+	w.flushPendingBodyStore()
+	if w.config.VerifyPbBodyPersistence {
+		keys := make([]common.Hash, 0, w.pendingBlockBody.Len())
+		for _, key := range w.pendingBlockBody.Keys() {
+			keys = append(keys, key.(common.Hash))
+		}
+		w.verifyStoredPendingBlockBodies(keys)
+	}
+}
+
+// newPendingBodyCache creates the pending block body cache. The persisted pending
+// block bodies mirror the cache, so every body leaving the cache is removed from
+// the database as well.
+func (w *worker) newPendingBodyCache() *pendingBodyCache {
+	return newPendingBodyCacheWithEvict(pendingBlockBodyLimit, func(key, value interface{}) {
+		pendingBodyEvictMeter.Mark(1)
+		pendingBodyBytesGauge.Update(atomic.AddInt64(&w.pendingBodyBytes, -int64(value.(*pendingBodyEntry).size)))
+		w.queuePendingBodyOp(pendingBodyOp{key: key.(common.Hash)})
+	})
+}
+
+// persistPendingBodyEntry queues the write of the given pending block body cache
+// entry into the db, atomically with its metadata.
+func (w *worker) persistPendingBodyEntry(key common.Hash, entry *pendingBodyEntry) {
+	if entry.body == nil {
+		return
+	}
+	w.queuePendingBodyOp(pendingBodyOp{key: key, body: entry.body, meta: entry.meta()})
+}
+
+// queuePendingBodyOp hands a pending block body write or deletion to the store
+// loop. Ops queued once the worker is closed are dropped, the bodies left behind
+// being pruned after the next restart.
+func (w *worker) queuePendingBodyOp(op pendingBodyOp) {
+	select {
+	case w.pendingBodyStoreCh <- op:
+	case <-w.exitCh:
+	}
+}
+
+// flushPendingBodyStore waits until the pending block body ops queued so far are
+// applied to the db.
+func (w *worker) flushPendingBodyStore() {
+	done := make(chan struct{})
+	w.queuePendingBodyOp(pendingBodyOp{flush: done})
+	select {
+	case <-done:
+	case <-w.exitCh:
+	}
+}
+
+// pendingBodyStoreLoop is a standalone goroutine applying the pending block body
+// writes and deletions to the db in the order they were queued, so that the
+// cache is not held up by the db.
+func (w *worker) pendingBodyStoreLoop() {
+	defer w.wg.Done()
+
+	for {
+		select {
+		case op := <-w.pendingBodyStoreCh:
+			w.applyPendingBodyOp(op)
+		case <-w.exitCh:
+			// Apply the ops queued before the shutdown
+			for {
+				select {
+				case op := <-w.pendingBodyStoreCh:
+					w.applyPendingBodyOp(op)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// applyPendingBodyOp applies a pending block body op to the db.
+func (w *worker) applyPendingBodyOp(op pendingBodyOp) {
+	switch {
+	case op.flush != nil:
+		close(op.flush)
+	case op.body == nil:
+		rawdb.DeletePendingBody(w.workerDb, op.key)
+	default:
+		batch := w.workerDb.NewBatch()
+		rawdb.WritePendingBody(batch, op.key, op.body, op.meta)
+		if err := batch.Write(); err != nil {
+			log.Error("Failed to persist pending block body", "key", op.key, "err", err)
+		}
+	}
+}
+
+// verifyStoredPendingBlockBodies reads back the stored pending block bodies of
+// the given keys and compares their roots to the cached ones, reporting the
+// number of mismatches.
+func (w *worker) verifyStoredPendingBlockBodies(keys []common.Hash) int {
+	var mismatches int
+	for _, key := range keys {
+		value, exist := w.pendingBlockBody.Peek(key)
+		if !exist || value.(*pendingBodyEntry).body == nil {
+			continue
+		}
+		stored := rawdb.ReadPendingBody(w.workerDb, key)
+		if stored == nil {
+			pendingBodyPersistMeter.Mark(1)
+			log.Error("Stored pending block body missing", "key", key)
+			mismatches++
+			continue
+		}
+		haveUncle, haveTx, haveEtx := pendingBodyRoots(stored)
+		wantUncle, wantTx, wantEtx := pendingBodyRoots(value.(*pendingBodyEntry).body)
+		if haveUncle != wantUncle || haveTx != wantTx || haveEtx != wantEtx {
+			pendingBodyPersistMeter.Mark(1)
+			log.Error("Stored pending block body does not match the cached one", "key", key,
+				"txhash", wantTx, "storedtxhash", haveTx, "unclehash", wantUncle, "storedunclehash", haveUncle, "etxhash", wantEtx, "storedetxhash", haveEtx)
+			mismatches++
+		}
+	}
+	return mismatches
+}
+
+// recommitLoop is a standalone goroutine to maintain the interval for miner sealing
+// work recommitting, applying the interval updates and adjustments, and to
+// regenerate the pending header once no new one was generated for an interval.
+func (w *worker) recommitLoop(recommit time.Duration) {
+	defer w.wg.Done()
+
+	minRecommit := recommit // minimal resubmit interval specified by user.
+
+	// Sealing work is only resubmitted by the zone workers processing state, and
+	// not when the pending header is already regenerated at a fixed interval
+	var (
+		timer    *time.Timer
+		resubmit <-chan time.Time
+	)
+	if w.hc.ProcessingState() && common.NodeLocation.Context() == common.ZONE_CTX && w.config.FixedBlockInterval <= 0 {
+		timer = time.NewTimer(recommit)
+		defer timer.Stop()
+		resubmit = timer.C
+	}
+	for {
+		select {
+		case <-resubmit:
+			timer.Reset(w.resubmit(recommit))
+			continue
+
+		case interval := <-w.resubmitIntervalCh:
+			// Adjust resubmit interval explicitly by user.
+			interval = sanitizeRecommit(interval, w.config.MaxRecommitInterval)
+			log.Info("Miner recommit interval update", "from", minRecommit, "to", interval)
+			minRecommit, recommit = interval, interval
+
+		case target := <-w.recommitTargetCh:
+			// Move the interval towards the target of the adaptive controller,
+			// smoothing out the swings of single block intervals.
+			before := recommit
+			next := float64(recommit.Nanoseconds())*(1-intervalAdjustRatio) + intervalAdjustRatio*float64(target.Nanoseconds())
+			recommit = time.Duration(int64(next))
+			if recommit < w.recommitCtl.min {
+				recommit = w.recommitCtl.min
+			}
+			if recommit > w.recommitCtl.max {
+				recommit = w.recommitCtl.max
+			}
+			log.Trace("Adapt miner recommit interval", "from", before, "to", recommit, "target", target)
+
+		case adjust := <-w.resubmitAdjustCh:
+			// Adjust resubmit interval by feedback.
+			before := recommit
+			if adjust.inc {
+				target := float64(recommit.Nanoseconds()) / adjust.ratio
+				recommit = recalcRecommit(minRecommit, w.config.MaxRecommitInterval, recommit, target, true)
+				log.Trace("Increase miner recommit interval", "from", before, "to", recommit)
+			} else {
+				recommit = recalcRecommit(minRecommit, w.config.MaxRecommitInterval, recommit, float64(minRecommit.Nanoseconds()), false)
+				log.Trace("Decrease miner recommit interval", "from", before, "to", recommit)
+			}
+
+		case <-w.exitCh:
+			return
+		}
+		atomic.StoreInt64(&w.recommit, int64(recommit))
+	}
+}
+
+// adaptiveRecommitBounds returns the bounds of the adaptive recommit interval,
+// defaulting to the sanitized recommit interval given.
+func adaptiveRecommitBounds(config *Config, recommit time.Duration) (time.Duration, time.Duration) {
+	min, max := config.AdaptiveRecommitMin, config.AdaptiveRecommitMax
+	if min == 0 {
+		min = recommit
+	}
+	if min < minRecommitInterval {
+		log.Warn("Sanitizing adaptive recommit interval floor", "provided", min, "updated", minRecommitInterval)
+		min = minRecommitInterval
+	}
+	if max == 0 {
+		max = config.MaxRecommitInterval
+	}
+	if max == 0 {
+		max = 4 * recommit
+	}
+	if max < min {
+		log.Warn("Sanitizing adaptive recommit interval ceiling", "provided", max, "updated", min)
+		max = min
+	}
+	return min, max
+}
+
+// sanitizeRecommit clamps a recommit interval to the given ceiling, without ever
+// going below minRecommitInterval, even if the ceiling is lower.
+func sanitizeRecommit(interval, max time.Duration) time.Duration {
+	if max > 0 && interval > max {
+		log.Warn("Sanitizing miner recommit interval", "provided", interval, "updated", max)
+		interval = max
+	}
+	if interval < minRecommitInterval {
+		log.Warn("Sanitizing miner recommit interval", "provided", interval, "updated", minRecommitInterval)
+		interval = minRecommitInterval
+	}
+	return interval
+}
+
+// resubmit regenerates the pending header on top of the current head if none was
+// generated since the recommit deadline, and returns the time until the next one.
+func (w *worker) resubmit(recommit time.Duration) time.Duration {
+	// A pending header generated since the timer was armed pushed the deadline back
+	if remaining := w.TimeToNextRecommit(); remaining > 0 {
+		return remaining
+	}
+	atomic.StoreInt64(&w.recommitAt, time.Now().Add(recommit).UnixNano())
+	if !w.isRunning() {
+		return recommit
+	}
+	if head := w.hc.CurrentBlock(); head != nil {
+		log.Debug("Resubmitting sealing work", "worker", w.config.Name, "number", head.Number(), "recommit", recommit)
+		w.asyncGeneratePendingHeader(head)
+	}
+	return recommit
+}
+
+// adaptRecommit feeds a new zone block to the adaptive recommit controller and
+// moves the recommit interval towards its new target.
+func (w *worker) adaptRecommit(block *types.Block) {
+	if w.recommitCtl == nil || block.NumberU64() == 0 {
+		return
+	}
+	parent := w.hc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return
+	}
+	w.pushRecommitTarget(w.recommitCtl.observe(block, parent))
+}
+
+// pushRecommitTarget hands a new target interval to the recommit loop without
+// blocking the caller. Targets are dropped while the loop is lagging behind, the
+// following blocks superseding them anyway.
+func (w *worker) pushRecommitTarget(target time.Duration) {
+	select {
+	case w.recommitTargetCh <- target:
+	default:
+		log.Debug("Dropping recommit interval target", "target", target)
+	}
+}
+
+// RecommitStats returns the state of the recommit interval controller.
+func (w *worker) RecommitStats() RecommitStats {
+	stats := RecommitStats{
+		Adaptive: w.recommitCtl != nil,
+		Recommit: time.Duration(atomic.LoadInt64(&w.recommit)),
+	}
+	if w.recommitCtl != nil {
+		w.recommitCtl.stats(&stats)
+	}
+	return stats
+}
+
+// recalcRecommit recalculates the resubmitting interval upon feedback. The
+// increased interval is capped to maxRecommit, unless it is zero.
+func recalcRecommit(minRecommit, maxRecommit, prev time.Duration, target float64, inc bool) time.Duration {
+	var (
+		prevF = float64(prev.Nanoseconds())
+		next  float64
+	)
+	if inc {
+		next = prevF*(1-intervalAdjustRatio) + intervalAdjustRatio*(target+intervalAdjustBias)
+		if max := float64(maxRecommit.Nanoseconds()); maxRecommit > 0 && next > max {
+			next = max
+		}
+	} else {
+		next = prevF*(1-intervalAdjustRatio) + intervalAdjustRatio*(target-intervalAdjustBias)
+	}
+	// The floor wins over a ceiling configured below it
+	if min := float64(minRecommit.Nanoseconds()); next < min {
+		next = min
+	}
+	return time.Duration(int64(next))
+}
+
+// asyncStateLoop updates the state root for a block and returns the state udpate in a channel
+func (w *worker) asyncStateLoop() {
+	defer w.wg.Done() // decrement the wait group after the close of the loop
+
+	var snapshotCheck <-chan time.Time
+	if w.config.SnapshotSelfCheck {
+		ticker := time.NewTicker(snapshotCheckInterval)
+		defer ticker.Stop()
+		snapshotCheck = ticker.C
+	}
+	// Heads arriving within the debounce delay of the first unprocessed one are
+	// coalesced, and the pending header is only regenerated on the latest
+	var (
+		regenerate <-chan time.Time
+		latest     *types.Block
+	)
+	for {
+		select {
+		case <-snapshotCheck:
+			w.checkSnapshot()
+		case head := <-w.chainHeadCh:
+
+			w.interruptAsyncPhGen()
+			w.speculative.retain(head.Block.Header().SealHash())
+			w.pruneStaleUncles(head.Block)
+			w.invalidatePending(head.Block)
+			w.logSealedConfirmations(head.Block)
+			w.sealedJournal.prune(w.hc, head.Block)
+			w.adaptRecommit(head.Block)
+
+			if w.config.RegenerateDebounce <= 0 {
+				w.regenerateOnHead(head.Block)
+				continue
+			}
+			latest = head.Block
+			if regenerate == nil {
+				regenerate = time.After(w.config.RegenerateDebounce)
+			}
+		case <-regenerate:
+			regenerate = nil
+			w.regenerateOnHead(latest)
+		case ev := <-w.txsCh:
+			// The sealing work picks the new transactions up on its next cycle, otherwise
+			// apply a whole burst of them to the pending state at once
+			atomic.AddInt32(&w.newTxs, int32(len(ev.Txs)))
+			batch := w.coalesceTxs()
+			if w.isRunning() {
+				continue
+			}
+			w.requestSnapshotRebuild(batch)
+		case ev := <-w.domHeaderCh:
+			// A new dominant header changes the expected parent and manifest, so any
+			// background work in flight is stale and restarts once aborted
+			if w.interruptFilling() > 0 {
+				log.Debug("Restarting sealing work on new dom header", "number", ev.Header.NumberArray(), "order", ev.Order)
+			}
+		case <-w.exitCh:
+			return
+		case <-w.chainHeadSub.Err():
+			return
+		case <-w.domHeaderSub.Err():
+			return
+		case <-w.txsSub.Err():
+			return
+		}
+	}
+}
+
+// coalesceTxs drains the transaction events queued behind a received one, up to
+// the configured batch size, and returns the number of events coalesced. The
+// pending state is rebuilt from the pool, so the events themselves are dropped
+// once their transactions are counted as new.
+func (w *worker) coalesceTxs() int {
+	batch := 1
+	for batch < w.config.TxBatchSize {
+		select {
+		case ev := <-w.txsCh:
+			atomic.AddInt32(&w.newTxs, int32(len(ev.Txs)))
+			batch++
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// requestSnapshotRebuild hands the given number of transaction events over to the
+// snapshot rebuild loop without blocking. If a rebuild is already queued, the
+// events are merged into it.
+func (w *worker) requestSnapshotRebuild(batch int) {
+	for {
+		select {
+		case w.snapshotRebuildCh <- batch:
+			return
+		default:
+		}
+		select {
+		case queued := <-w.snapshotRebuildCh:
+			batch += queued
+		default:
+		}
+	}
+}
+
+// snapshotRebuildLoop applies the new transactions to the pending state while not
+// sealing, apart from the state loop which isn't held up. Rebuilds are at least
+// minSnapshotRebuildInterval apart, the requests arriving in between are merged.
+func (w *worker) snapshotRebuildLoop() {
+	defer w.wg.Done()
+
+	for {
+		select {
+		case batch := <-w.snapshotRebuildCh:
+			if !w.isRunning() {
+				if err := w.RebuildSnapshot(w.hc.CurrentBlock()); err != nil {
+					log.Debug("Failed to apply new transactions to the pending state", "events", batch, "err", err)
+				}
+			}
+			select {
+			case <-time.After(minSnapshotRebuildInterval):
+			case <-w.exitCh:
+				return
+			}
+		case <-w.exitCh:
+			return
+		}
+	}
+}
+
+// uncleLoop keeps the side blocks imported into the chain as possible uncles.
+// Their headers are verified apart from the state loop, which isn't held up.
+func (w *worker) uncleLoop() {
+	defer w.wg.Done()
+
+	for {
+		select {
+		case ev := <-w.chainSideCh:
+			w.AddUncleCandidates([]*types.Block{ev.Block}, "")
+		case <-w.exitCh:
+			return
+		case <-w.chainSideSub.Err():
+			return
+		}
+	}
+}
+
+// interruptFilling interrupts the transaction filling of the background pending
+// header generations in flight, returning their number. Generations requested
+// synchronously, like the one of a block being appended, are never interrupted.
+func (w *worker) interruptFilling() int {
+	w.fillInterruptsMu.Lock()
+	defer w.fillInterruptsMu.Unlock()
+	for interrupt := range w.fillInterrupts {
+		atomic.StoreInt32(interrupt, commitInterruptNewHead)
+	}
+	return len(w.fillInterrupts)
+}
+
+// regenerateOnHead generates the pending header on top of a new chain head in
+// the background, if automatic regeneration is enabled and the worker is running.
+func (w *worker) regenerateOnHead(block *types.Block) {
+	if !w.config.AutoRegenerate || !w.isRunning() {
+		return
+	}
+	w.asyncGeneratePendingHeader(block)
+}
+
+// asyncGeneratePendingHeader generates the pending header on top of the given
+// block in the background, and sends it in the asyncPhFeed unless interrupted.
+// If a new dom header aborts the filling, the generation is restarted on the
+// current head once the aborted one is done, so that it doesn't collide with it.
+func (w *worker) asyncGeneratePendingHeader(block *types.Block) {
+	go func() {
+		if w.consumeAsyncPhGenInterrupt() {
+			return
+		}
+		for {
+			header, err := w.generatePendingHeader(block, true, &generateParams{interruptible: true})
+			if errors.Is(err, ErrGenerationInterrupted) {
+				log.Debug("Pending header generation interrupted", "number", block.Number())
+				select {
+				case <-w.exitCh:
+					return
+				default:
+				}
+				if block = w.hc.CurrentBlock(); block == nil {
+					return
+				}
+				continue
+			} else if err != nil {
+				log.Error("Error generating pending header with state", "err", err)
+				return
+			}
+			// Send the updated pendingHeader in the asyncPhFeed
+			w.asyncPhFeed.Send(header)
+			return
+		}
+	}()
+}
+
+// shouldLogSealed reports whether a block sealed at the given number has reached
+// the configured confirmation depth below the given head.
+func (w *worker) shouldLogSealed(sealed, head uint64) bool {
+	return head == sealed+w.config.SealingLogDepth
+}
+
+// sealedAtDepth returns the blocks of the sealing history which reached the
+// configured confirmation depth below the given head number.
+func (w *worker) sealedAtDepth(head uint64) []SealingResult {
+	var results []SealingResult
+	for _, result := range w.sealingHistory.list() {
+		if w.shouldLogSealed(result.Number, head) {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// logSealedConfirmations logs the recently sealed blocks which reached the
+// configured confirmation depth on the canonical chain with the given head.
+func (w *worker) logSealedConfirmations(head *types.Block) {
+	if head == nil {
+		return
+	}
+	for _, result := range w.sealedAtDepth(head.NumberU64()) {
+		header := w.hc.GetHeaderByNumber(result.Number)
+		if header == nil || header.SealHash() != result.SealHash {
+			continue
+		}
+		log.Info("🔗 block reached canonical chain", "worker", w.config.Name, "number", result.Number, "hash", header.Hash(), "depth", w.config.SealingLogDepth)
+	}
+}
+
+// JournalSealedBlock persists a locally sealed block until it is imported into
+// the chain, so that it can be replayed if the node restarts in the meantime.
+// The journal is pruned on every new chain head. The block is recorded in the
+// sealing history as well.
+func (w *worker) JournalSealedBlock(block *types.Block) {
+	w.recordSealed(block.Header())
+	w.sealedJournal.add(block)
+}
+
+// recordSealed adds the summary of the assembled block sealed with the given
+// header to the sealing history, and its uncles to the inclusion metrics.
+func (w *worker) recordSealed(header *types.Header) {
+	value, ok := w.assembled.Get(header.SealHash())
+	if !ok {
+		return
+	}
+	// A block is only recorded once, even if submitted again
+	w.assembled.Remove(header.SealHash())
+	result := value.(SealingResult)
+	w.sealingHistory.add(result)
+	uncleIncludedHist.Update(int64(result.Uncles))
+}
+
+// ReplaySealedBlocks passes the sealed blocks journaled before the last shutdown
+// and not yet imported to write, unless they are too deep below the chain head.
+func (w *worker) ReplaySealedBlocks(write func(block *types.Block)) {
+	head := w.hc.CurrentBlock()
+	if head == nil {
+		return
+	}
+	w.sealedJournal.replay(w.hc, head, write)
+}
+
+// pruneStaleUncles removes the possible uncles which are too deep below the given
+// chain head to be included anymore. Locally mined uncles and remote uncles are
+// retained for their respective configured depths.
+func (w *worker) pruneStaleUncles(head *types.Block) {
+	if head == nil {
+		return
+	}
+	w.uncles.prune(head.NumberU64(), w.config.LocalUncleRetention, w.config.RemoteUncleRetention)
+}
+
+// AddUncleCandidates verifies the headers of the given side blocks against the
+// consensus engine concurrently and keeps the valid ones as possible uncles. The
+// peer the blocks were received from is recorded, falling back to the one noted
+// by RecordBlockPeer if empty. It returns the number of blocks added.
+func (w *worker) AddUncleCandidates(blocks []*types.Block, peer string) int {
+	if len(blocks) == 0 {
+		return 0
+	}
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	abort, results := w.getEngine().VerifyHeaders(w.hc, headers)
+	defer close(abort)
+
+	var added int
+	for i, block := range blocks {
+		if err := <-results; err != nil {
+			uncleRejectedCounter.Inc(1)
+			log.Debug("Invalid uncle candidate", "hash", block.Hash(), "number", block.NumberU64(), "peer", peer, "err", err)
+			continue
+		}
+		local := w.isLocalBlock != nil && w.isLocalBlock(headers[i])
+		if w.uncles.add(block, local, peer) {
+			added++
+		}
+	}
+	return added
+}
+
+// RecordBlockPeer notes the peer a block was received from, recorded as its
+// provenance if it is later imported as a side block.
+func (w *worker) RecordBlockPeer(hash common.Hash, peer string) {
+	w.uncles.recordPeer(hash, peer)
+}
+
+// UncleCandidates returns the side blocks kept as possible uncles, in the order
+// they are considered for inclusion.
+func (w *worker) UncleCandidates() []UncleCandidate {
+	return w.uncles.candidates()
+}
+
+// fixedIntervalLoop generates a pending header on top of the current head at a
+// fixed interval while the worker is running, whether or not there are new
+// transactions to include.
+func (w *worker) fixedIntervalLoop(interval time.Duration) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !w.isRunning() {
+				continue
+			}
+			header, err := w.GeneratePendingHeader(w.hc.CurrentBlock(), true)
+			if err != nil {
+				log.Error("Error generating pending header on fixed interval", "err", err)
+				continue
+			}
+			w.asyncPhFeed.Send(header)
+		case <-w.exitCh:
+			return
+		}
+	}
+}
+
+// notifyLoop pushes every new pending header to the remote miners.
+func (w *worker) notifyLoop() {
+	defer w.wg.Done()
+	defer w.notifier.stop()
+
+	headerCh := make(chan *types.Header, 1)
+	sub := w.pendingHeaderFeed.Subscribe(headerCh)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case header := <-headerCh:
+			w.notifier.notify(header)
+		case <-sub.Err():
+			return
+		case <-w.exitCh:
+			return
+		}
+	}
+}
+
+// NotifyHealth returns the delivery records of the endpoints notified of new
+// work, nil if none are configured.
+func (w *worker) NotifyHealth() []NotifyEndpointHealth {
+	if w.notifier == nil {
+		return nil
+	}
+	return w.notifier.health()
+}
+
+// GeneratePendingBlock generates pending block given a commited block.
+func (w *worker) GeneratePendingHeader(block *types.Block, fill bool) (*types.Header, error) {
+	return w.generatePendingHeader(block, fill, &generateParams{})
+}
+
+// GeneratePendingHeaderAt generates the pending header given a commited block and
+// the timestamp to seal it with. The timestamp is recapped to parent+1 if it is not
+// after the parent's, unless forceTime is set in which case an error is returned.
+func (w *worker) GeneratePendingHeaderAt(block *types.Block, fill bool, timestamp uint64, forceTime bool) (*types.Header, error) {
+	return w.generatePendingHeader(block, fill, &generateParams{
+		timestamp: timestamp,
+		forceTime: forceTime,
+	})
+}
+
+// GeneratePendingHeaderWithGasLimit generates the pending header given a commited
+// block, forcing the given gas limit instead of computing it from the parent.
+func (w *worker) GeneratePendingHeaderWithGasLimit(block *types.Block, fill bool, gasLimit uint64) (*types.Header, error) {
+	if gasLimit == 0 {
+		return nil, errors.New("gas limit override not provided")
+	}
+	return w.generatePendingHeader(block, fill, &generateParams{gasLimitOverride: gasLimit})
+}
+
+// generatePendingHeader generates the pending header given a commited block and
+// the sealing parameters. The coinbase of the parameters is set by the worker.
+func (w *worker) generatePendingHeader(block *types.Block, fill bool, genParams *generateParams) (*types.Header, error) {
+	nodeCtx := common.NodeLocation.Context()
+
+	if w.config.RejectConcurrentGeneration {
+		if !atomic.CompareAndSwapInt32(&w.generating, 0, 1) {
+			return nil, ErrGenerationInProgress
+		}
+	} else {
+		atomic.AddInt32(&w.generating, 1)
+	}
+	defer atomic.AddInt32(&w.generating, -1)
+
+	w.engineMu.RLock()
+	defer w.engineMu.RUnlock()
+
+	w.interruptAsyncPhGen()
+
+	var (
+		interrupt *int32
+	)
+
+	if interrupt != nil {
+		atomic.StoreInt32(interrupt, commitInterruptNewHead)
+	}
+	interrupt = new(int32)
+	atomic.StoreInt32(&w.newTxs, 0)
+
+	// Expose the interrupt so the background filling can be aborted by a new dom
+	// header
+	if genParams.interruptible {
+		w.fillInterruptsMu.Lock()
+		w.fillInterrupts[interrupt] = struct{}{}
+		w.fillInterruptsMu.Unlock()
+		defer func() {
+			w.fillInterruptsMu.Lock()
+			delete(w.fillInterrupts, interrupt)
+			w.fillInterruptsMu.Unlock()
+		}()
+	}
+
+	start := time.Now()
+	// Set the coinbase if the worker is running or it's required
+	coinbase := w.etherbase() // Use the preset address as the fee recipient
+	if !w.hasEtherbase() {
+		log.Error("Refusing to mine without etherbase")
+		return nil, errors.New("etherbase not found")
+	}
+	genParams.coinbase = coinbase
+
+	work, err := w.prepareWork(genParams, block)
+	if err != nil {
+		return nil, err
+	}
+	defer work.release()
+
+	if nodeCtx == common.ZONE_CTX && w.hc.ProcessingState() {
+		// Fill pending transactions from the txpool
+		if genParams.gasLimitOverride == 0 {
+			w.adjustGasLimit(nil, work, block)
+		}
+		if fill {
+			start := time.Now()
+			w.fillTransactions(interrupt, work, block)
+			if atomic.LoadInt32(interrupt) == commitInterruptNewHead {
+				return nil, ErrGenerationInterrupted
+			}
+			w.fillTransactionsRollingAverage.Add(time.Since(start))
+			log.Info("Filled and sorted pending transactions", "worker", w.config.Name, "count", len(work.txs), "elapsed", common.PrettyDuration(time.Since(start)), "average", common.PrettyDuration(w.fillTransactionsRollingAverage.Average()))
+		}
+	}
+
+	newBlock, err := w.finalizePending(work, block)
+	if err != nil {
+		return nil, err
+	}
+	atomic.StoreInt64(&w.recommitAt, time.Now().Add(time.Duration(atomic.LoadInt64(&w.recommit))).UnixNano())
+	etxEmittedHist.Update(int64(len(newBlock.ExtTransactions())))
+	w.printPendingHeaderInfo(work, newBlock, start)
+
+	if w.config.SpeculativePreexec && fill && nodeCtx == common.ZONE_CTX && w.hc.ProcessingState() {
+		w.preexecuteChild(newBlock, work.state, work.coinbase)
+	}
+
+	return work.header, nil
+}
+
+// GenerateSpeculativeHeader generates a pending header on top of the given parent,
+// which may be any known block including side chain blocks. The result is purely
+// speculative, it neither replaces the current environment nor updates the pending
+// snapshot. The block is assembled like the pending one, so its body is cached for
+// the header to be sealed.
+func (w *worker) GenerateSpeculativeHeader(parent *types.Block, fill bool) (*types.Header, error) {
+	if parent == nil {
+		return nil, errors.New("speculative parent not provided")
+	}
+	w.engineMu.RLock()
+	defer w.engineMu.RUnlock()
+
+	coinbase := w.etherbase()
+	if !w.hasEtherbase() {
+		log.Error("Refusing to mine without etherbase")
+		return nil, errors.New("etherbase not found")
+	}
+	work, err := w.prepareWork(&generateParams{coinbase: coinbase, speculative: true}, parent)
+	if err != nil {
+		return nil, err
+	}
+	defer work.release()
+	work.simulation = true
+
+	if common.NodeLocation.Context() == common.ZONE_CTX && w.hc.ProcessingState() {
+		w.adjustGasLimit(nil, work, parent)
+		if fill {
+			w.fillTransactions(new(int32), work, parent)
+		}
+	}
+	block, err := w.FinalizeAssemble(w.hc, work.header, parent, work.state, work.txs, work.unclelist(), work.etxs, work.subManifest, work.receipts)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("Generated speculative pending header", "worker", w.config.Name, "number", block.Number(), "parent", parent.Hash(),
+		"txs", work.tcount, "gas", block.GasUsed())
+	return block.Header(), nil
+}
+
+// GeneratePendingHeaderOnAncestor generates a pending header on top of the
+// canonical ancestor of the chain head with the given hash, so that alternatives
+// can be prebuilt ahead of a reorg. The ancestor has to be within the reorg window
+// of the head, its state is recovered if already pruned. Like speculative headers,
+// the result does not replace the current environment.
+func (w *worker) GeneratePendingHeaderOnAncestor(hash common.Hash, fill bool) (*types.Header, error) {
+	head := w.hc.CurrentBlock()
+	if head == nil {
+		return nil, errors.New("chain head not found")
+	}
+	number := w.hc.GetBlockNumber(hash)
+	if number == nil {
+		return nil, fmt.Errorf("unknown block %s", hash)
+	}
+	if *number > head.NumberU64() || head.NumberU64()-*number > w.config.ReorgWindow || w.hc.GetCanonicalHash(*number) != hash {
+		return nil, ErrAncestorOutsideReorgWindow
+	}
+	ancestor := w.hc.GetBlock(hash, *number)
+	if ancestor == nil {
+		return nil, fmt.Errorf("block %s body not found", hash)
+	}
+	return w.GenerateSpeculativeHeader(ancestor, fill)
+}
+
+// SimulatePendingBlock builds a throwaway block on top of the chain head, as the
+// worker would if the given transactions were in the pool. Neither the pool, the
+// pending snapshot nor the pending body cache are modified.
+func (w *worker) SimulatePendingBlock(txs types.Transactions) (*SimulatedBlock, error) {
+	if common.NodeLocation.Context() != common.ZONE_CTX || !w.hc.ProcessingState() {
+		return nil, errors.New("block simulation is only available on zone chains processing the state")
+	}
+	w.engineMu.RLock()
+	defer w.engineMu.RUnlock()
+
+	parent := w.hc.CurrentBlock()
+	if parent == nil {
+		return nil, errors.New("chain head not found")
+	}
+	work, err := w.prepareWork(&generateParams{coinbase: w.etherbase(), speculative: true}, parent)
+	if err != nil {
+		return nil, err
+	}
+	defer work.release()
+	work.simulation = true
+	work.fillDeadline = time.Now().Add(maxSimulationTime)
+	if w.config.FillDeadline > 0 && w.config.FillDeadline < maxSimulationTime {
+		work.fillDeadline = time.Now().Add(w.config.FillDeadline)
+	}
+	w.adjustGasLimit(nil, work, parent)
+
+	etxSet := rawdb.ReadEtxSet(w.hc.bc.db, parent.Hash(), parent.NumberU64())
+	if etxSet != nil {
+		etxSet.Update(types.Transactions{}, parent.NumberU64()+1) // Prune any expired ETXs
+	}
+	pending, err := w.txPool.SimulationPending(true, etxSet)
+	if err != nil {
+		return nil, err
+	}
+	// Merge the simulated transactions into the pending set, replacing the pending
+	// ones of the same sender and nonce
+	for i, tx := range txs {
+		from, err := types.Sender(work.signer, tx)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transaction %d: %v", i, err)
+		}
+		accTxs := make(types.Transactions, 0, len(pending[from.Bytes20()])+1)
+		for _, pendingTx := range pending[from.Bytes20()] {
+			if pendingTx.Nonce() != tx.Nonce() {
+				accTxs = append(accTxs, pendingTx)
+			}
+		}
+		accTxs = append(accTxs, tx)
+		sort.Sort(types.TxByNonce(accTxs))
+		pending[from.Bytes20()] = accTxs
+	}
+	var count int
+	for _, accTxs := range pending {
+		count += len(accTxs)
+	}
+	ordered := w.txOrdering().OrderPending(work.signer, pending, work.header.BaseFee())
+	w.commitTransactions(work, ordered, count*commitAttemptsFactor, nil)
+
+	block, err := w.assembleBlock(w.hc, work.header, parent, work.state, work.txs, work.unclelist(), work.etxs, work.subManifest, work.receipts)
+	if err != nil {
+		return nil, err
+	}
+	return &SimulatedBlock{Block: block, Receipts: work.receipts, Fees: totalFeesWei(block, work.receipts)}, nil
+}
+
+// RebuildSnapshot regenerates the current environment and the pending snapshot on
+// top of the given block. It is meant for reorg handlers which need to refresh the
+// pending state without publishing a new pending header.
+func (w *worker) RebuildSnapshot(block *types.Block) error {
+	if block == nil {
+		return errors.New("rebuild block not provided")
+	}
+	_, err := w.rebuildPending(block)
+	return err
+}
+
+// rebuildPending fills a new sealing environment on top of the given block and
+// publishes its block as the pending snapshot.
+func (w *worker) rebuildPending(block *types.Block) (*types.Block, error) {
+	w.engineMu.RLock()
+	defer w.engineMu.RUnlock()
+
+	coinbase := w.etherbase()
+	if !w.hasEtherbase() {
+		log.Error("Refusing to mine without etherbase")
+		return nil, errors.New("etherbase not found")
+	}
+	w.interruptAsyncPhGen()
+
+	work, err := w.prepareWork(&generateParams{coinbase: coinbase}, block)
+	if err != nil {
+		return nil, err
+	}
+	defer work.release()
+
+	if common.NodeLocation.Context() == common.ZONE_CTX && w.hc.ProcessingState() {
+		w.adjustGasLimit(nil, work, block)
+		w.fillTransactions(new(int32), work, block)
+	}
+	return w.finalizePending(work, block)
+}
+
+// finalizePending swaps the current environment with the given one, assembles
+// the sealing block of the environment on top of the given parent and publishes
+// it as the pending snapshot.
+func (w *worker) finalizePending(work *environment, parent *types.Block) (*types.Block, error) {
+	// Swap out the old work with the new one, terminating any leftover
+	// prefetcher processes in the mean time and starting a new one.
+	w.setCurrent(work)
+
+	// Create a local environment copy, avoid the data race with snapshot state.
+	block, err := w.FinalizeAssemble(w.hc, work.header, parent, work.state, work.txs, work.unclelist(), work.etxs, work.subManifest, work.receipts)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.checkCommitVeto(block, work.receipts); err != nil {
+		return nil, err
+	}
+	work.header = block.Header()
+	w.assembled.Add(block.Header().SealHash(), SealingResult{
+		Number:    block.NumberU64(),
+		SealHash:  block.Header().SealHash(),
+		Txs:       len(block.Transactions()),
+		Etxs:      len(block.ExtTransactions()),
+		Uncles:    len(block.Uncles()),
+		GasUsed:   block.GasUsed(),
+		Fees:      totalFees(block, work.receipts),
+		CreatedAt: time.Now(),
+	})
+	w.updateSnapshot(block, work.receipts)
+	atomic.StoreInt32(&w.lastReverted, int32(work.reverted))
+	return block, nil
+}
+
+// setCurrent swaps the current environment with the given one, terminating the
+// prefetcher of the replaced environment in the background. Stopping a busy
+// prefetcher can take a while, so the caller doesn't wait for it.
+func (w *worker) setCurrent(env *environment) {
+	w.currentMu.Lock()
+	defer w.currentMu.Unlock()
+	w.setCurrentLocked(env)
+}
+
+// setCurrentLocked swaps the current environment, the caller must hold currentMu.
+// The current environment holds a reference on the environment it points to.
+func (w *worker) setCurrentLocked(env *environment) {
+	if env != nil {
+		env.retain()
+	}
+	if old := w.current; old != nil {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			old.discard()
+			old.release()
+		}()
+	}
+	w.current = env
+	if env != nil && env.family != nil {
+		uncleFamilySizeGauge.Update(int64(env.family.Cardinality()))
+	}
+}
+
+// invalidatePending discards the current environment if it was not built on top
+// of the given chain head, notifying the subscribers of the invalidation.
+func (w *worker) invalidatePending(head *types.Block) {
+	if head == nil {
+		return
+	}
+	w.currentMu.Lock()
+	if w.current == nil || w.current.header.ParentHash() == head.Hash() {
+		w.currentMu.Unlock()
+		return
+	}
+	ev := PendingInvalidatedEvent{
+		OldNumber: w.current.header.NumberU64() - 1,
+		NewNumber: head.NumberU64(),
+	}
+	w.setCurrentLocked(nil)
+	w.currentMu.Unlock()
+
+	log.Debug("Pending block invalidated by new chain head", "old", ev.OldNumber, "new", ev.NewNumber, "hash", head.Hash())
+	w.pendingInvalidatedFeed.Send(ev)
+}
+
+// PendingEtxGasBreakdown returns the gas used by the internal and the external
+// transactions of the current environment.
+func (w *worker) PendingEtxGasBreakdown() (internal uint64, external uint64) {
+	w.currentMu.RLock()
+	defer w.currentMu.RUnlock()
+	if w.current == nil {
+		return 0, 0
+	}
+	gasUsed, external := w.current.header.GasUsed(), w.current.externalGasUsed
+	if external > gasUsed {
+		log.Warn("External gas used exceeds block gas used", "external", external, "gasUsed", gasUsed)
+		return 0, external
+	}
+	return gasUsed - external, external
+}
+
+// ExportCurrentEnv exports a summary of the current environment, which does not
+// include the full state but only its root.
+func (w *worker) ExportCurrentEnv() (*EnvSnapshot, error) {
+	w.currentMu.RLock()
+	defer w.currentMu.RUnlock()
+	env := w.current
+	if env == nil {
+		return nil, errors.New("no current sealing environment")
+	}
+	uncles := env.unclelist()
+	snapshot := &EnvSnapshot{
+		Header:      types.CopyHeader(env.header),
+		TxHashes:    make([]common.Hash, len(env.txs)),
+		EtxHashes:   make([]common.Hash, len(env.etxs)),
+		UncleHashes: make([]common.Hash, len(uncles)),
+		Coinbase:    env.coinbase,
+		StateRoot:   env.header.Root(),
+	}
+	for i, tx := range env.txs {
+		snapshot.TxHashes[i] = tx.Hash()
+	}
+	for i, etx := range env.etxs {
+		snapshot.EtxHashes[i] = etx.Hash()
+	}
+	for i, uncle := range uncles {
+		snapshot.UncleHashes[i] = uncle.Hash()
+	}
+	if env.gasPool != nil {
+		snapshot.GasRemaining = env.gasPool.Gas()
+	}
+	return snapshot, nil
+}
+
+// RecomputeGasLimit regenerates the pending block on top of the parent of the
+// current environment, so that runtime changes of the gas ceiling apply to the
+// pending block immediately. The block is rebuilt like any pending one, so the
+// snapshot and the pending block body cache follow the new gas limit.
+func (w *worker) RecomputeGasLimit() (uint64, error) {
+	w.currentMu.RLock()
+	env := w.current
+	var (
+		parentHash common.Hash
+		number     uint64
+	)
+	if env != nil {
+		parentHash, number = env.header.ParentHash(), env.header.NumberU64()-1
+	}
+	w.currentMu.RUnlock()
+	if env == nil {
+		return 0, errors.New("no current sealing environment")
+	}
+	parent := w.hc.GetBlock(parentHash, number)
+	if parent == nil {
+		return 0, errors.New("parent of the current sealing environment not found")
+	}
+	block, err := w.rebuildPending(parent)
+	if err != nil {
+		return 0, err
+	}
+	return block.GasLimit(), nil
+}
+
+// FamilySetSize returns the size of the family set used to validate the uncles
+// of the current environment.
+func (w *worker) FamilySetSize() int {
+	w.currentMu.RLock()
+	defer w.currentMu.RUnlock()
+	if w.current == nil || w.current.family == nil {
+		return 0
+	}
+	return w.current.family.Cardinality()
+}
+
+// updateSnapshot updates pending snapshot block and receipts.
+func (w *worker) updateSnapshot(block *types.Block, receipts []*types.Receipt) {
+	w.snapshotMu.Lock()
+	defer w.snapshotMu.Unlock()
+	w.snapshotBlock = block
+	w.snapshotReceipts = copyReceipts(receipts)
+	w.snapshotFees = nil
+	if w.config.FeeSummary && block != nil && len(receipts) == len(block.Transactions()) {
+		w.snapshotFees = feeSummary(block, receipts)
+	}
+}
+
+// checkSnapshot verifies that the pending snapshot block and receipts are
+// consistent with each other, reporting any mismatch.
+func (w *worker) checkSnapshot() bool {
+	w.snapshotMu.RLock()
+	defer w.snapshotMu.RUnlock()
+	if w.snapshotBlock == nil {
+		return true
+	}
+	if len(w.snapshotReceipts) != len(w.snapshotBlock.Transactions()) {
+		snapshotInconsistentMeter.Mark(1)
+		log.Warn("Inconsistent pending snapshot", "number", w.snapshotBlock.Number(), "hash", w.snapshotBlock.Hash(),
+			"txs", len(w.snapshotBlock.Transactions()), "receipts", len(w.snapshotReceipts))
+		return false
+	}
+	return true
+}
+
+// ResimulatePending rebuilds the pending block on top of the current head using
+// the given base fee. The work is done on a throwaway environment, so the current
+// environment is not modified, but the block is assembled like the pending one.
+// The resimulated body is not added to the pending body cache.
+func (w *worker) ResimulatePending(baseFee *big.Int) (*types.Block, types.Receipts, error) {
+	if common.NodeLocation.Context() != common.ZONE_CTX || !w.hc.ProcessingState() {
+		return nil, nil, errors.New("pending resimulation requires a zone processing state")
+	}
+	if baseFee == nil {
+		return nil, nil, errors.New("base fee not provided")
+	}
+	w.engineMu.RLock()
+	defer w.engineMu.RUnlock()
+
+	coinbase := w.etherbase()
+	if !w.hasEtherbase() {
+		return nil, nil, errors.New("etherbase not found")
+	}
+	parent := w.hc.CurrentBlock()
+	if parent == nil {
+		return nil, nil, errors.New("current block not found")
+	}
+	work, err := w.prepareWork(&generateParams{coinbase: coinbase}, parent)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer work.release()
+	work.simulation = true
+
+	work.header.SetBaseFee(new(big.Int).Set(baseFee))
+	w.adjustGasLimit(nil, work, parent)
+	w.fillTransactions(new(int32), work, parent)
+
+	block, err := w.assembleBlock(w.hc, work.header, parent, work.state, work.txs, work.unclelist(), work.etxs, work.subManifest, work.receipts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return block, work.receipts, nil
+}
+
+// printPendingHeaderInfo logs the pending header information
+func (w *worker) printPendingHeaderInfo(work *environment, block *types.Block, start time.Time) {
+	work.uncleMu.RLock()
+	if w.CurrentInfo(block.Header()) {
+		log.Info("Commit new sealing work", "worker", w.config.Name, "number", block.Number(), "sealhash", block.Header().SealHash(), "coinbase", block.Coinbase(),
+			"uncles", len(work.uncles), "txs", work.tcount, "reverted", work.reverted, "etxs", len(block.ExtTransactions()),
+			"gas", block.GasUsed(), "fees", totalFees(block, work.receipts),
+			"elapsed", common.PrettyDuration(time.Since(start)))
+	} else {
+		log.Debug("Commit new sealing work", "worker", w.config.Name, "number", block.Number(), "sealhash", block.Header().SealHash(), "coinbase", block.Coinbase(),
+			"uncles", len(work.uncles), "txs", work.tcount, "reverted", work.reverted, "etxs", len(block.ExtTransactions()),
+			"gas", block.GasUsed(), "fees", totalFees(block, work.receipts),
+			"elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	work.uncleMu.RUnlock()
+}
+
+// interruptAsyncPhGen kills any async ph generation running
+func (w *worker) interruptAsyncPhGen() {
+	w.interruptMu.Lock()
+	defer w.interruptMu.Unlock()
+	if w.interrupt != nil {
+		close(w.interrupt)
+		w.interrupt = nil
+	}
+}
+
+// consumeAsyncPhGenInterrupt reports whether the async ph generation has been
+// interrupted, rearming the interrupt if so.
+func (w *worker) consumeAsyncPhGenInterrupt() bool {
+	w.interruptMu.Lock()
+	defer w.interruptMu.Unlock()
+	select {
+	case <-w.interrupt:
+		w.interrupt = make(chan struct{})
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *worker) eventExitLoop() {
+	for {
+		select {
+		case <-w.exitCh:
+			return
+		}
+	}
+}
+
+// parentState retrieves the state of the given parent block to execute the
+// sealing block on top of.
+func (w *worker) parentState(parent *types.Block, reexec uint64) (*state.StateDB, error) {
+	statedb, err := w.hc.bc.processor.StateAt(parent.Root())
+	if err == nil {
+		return statedb, nil
+	}
+	// The sealing block can be created upon an arbitrary parent block whose
+	// state may already be pruned, so recover it by reexecuting the blocks.
+	statedb, err = w.hc.bc.processor.StateAtBlock(parent, reexec, nil, false)
+	if err != nil {
+		stateRecoveryFailedCounter.Inc(1)
+		return nil, err
+	}
+	stateRecoveredCounter.Inc(1)
+	log.Warn("Recovered sealing state", "number", parent.Number(), "root", parent.Root())
+	return statedb, nil
+}
+
+// makeEnv creates a new environment for the sealing block on top of the given
+// parent state.
+func (w *worker) makeEnv(parent *types.Block, header *types.Header, coinbase common.Address, state *state.StateDB) (*environment, error) {
+	etxRLimit := len(parent.Transactions()) / params.ETXRegionMaxFraction
+	if etxRLimit < params.ETXRLimitMin {
+		etxRLimit = params.ETXRLimitMin
+	}
+	etxPLimit := len(parent.Transactions()) / params.ETXPrimeMaxFraction
+	if etxPLimit < params.ETXPLimitMin {
+		etxPLimit = params.ETXPLimitMin
+	}
+	signer := types.MakeSigner
+	if w.signerOverride != nil {
+		signer = w.signerOverride
+	}
+	// Note the passed coinbase may be different with header.Coinbase.
+	env := newEnvironment(w.txCapacity(parent.GasLimit()))
+	env.signer = signer(w.chainConfig, header.Number())
+	env.state = state
+	env.coinbase = coinbase
+	env.header = header
+	env.etxRLimit = etxRLimit
+	env.etxPLimit = etxPLimit
+	env.vmConfig = w.vmConfigOverride
+	// when 08 is processed ancestors contain 07 (quick block)
+	for _, ancestor := range w.hc.GetBlocksFromHash(parent.Hash(), 7) {
+		for _, uncle := range ancestor.Uncles() {
+			env.family.Add(uncle.Hash())
+		}
+		env.family.Add(ancestor.Hash())
+		env.ancestors.Add(ancestor.Hash())
+	}
+	uncleFamilySizeGauge.Update(int64(env.family.Cardinality()))
+	// Keep track of transactions which return errors so they can be removed
+	env.tcount = 0
+	return env, nil
+}
+
+// txCapacity returns the number of transactions to preallocate the lists of a
+// sealing environment for, to avoid growing them while packing. Without a hint,
+// it is the number of pending transactions capped by the number of transactions
+// which can fit in the block.
+func (w *worker) txCapacity(gasLimit uint64) int {
+	if w.config.TxCapacityHint > 0 {
+		return w.config.TxCapacityHint
+	}
+	if w.txPool == nil {
+		return 0
+	}
+	pending, _ := w.txPool.Stats()
+	if limit := gasLimit / params.TxGas; uint64(pending) > limit {
+		return int(limit)
+	}
+	return pending
+}
+
+// commitUncle adds the given block to uncle block set, returns error if failed to add.
+func (w *worker) commitUncle(env *environment, uncle *types.Header) error {
+	env.uncleMu.Lock()
+	defer env.uncleMu.Unlock()
+	hash := uncle.Hash()
+	if _, exist := env.uncles[hash]; exist {
+		return errors.New("uncle not unique")
+	}
+	if !w.config.AllowSiblingUncles && env.header.ParentHash() == uncle.ParentHash() {
+		return errors.New("uncle is sibling")
+	}
+	if !env.ancestors.Contains(uncle.ParentHash()) {
+		return errors.New("uncle's parent unknown")
+	}
+	if env.family.Contains(hash) {
+		return errors.New("uncle already included")
+	}
+	env.uncles[hash] = uncle
+	return nil
+}
+
+func (w *worker) commitTransaction(env *environment, tx *types.Transaction) ([]*types.Log, error) {
+	if tx != nil {
+		snap := env.state.Snapshot()
+		// retrieve the gas used int and pass in the reference to the ApplyTransaction
+		gasUsed := env.header.GasUsed()
+		vmConfig := env.vmConfig
+		if vmConfig == nil {
+			vmConfig = w.hc.bc.processor.GetVMConfig()
+		}
+		etxRLimit, etxPLimit, gasPool := env.etxRLimit, env.etxPLimit, *env.gasPool
+		var (
+			receipt *types.Receipt
+			err     error
+		)
+		// Apply the outcome of the speculative execution if the state it read is
+		// still the same, otherwise execute the transaction
+		spec := env.speculated[tx.Hash()]
+		if spec != nil && spec.access.valid(env.state) {
+			receipt, err = w.applySpeculated(env, tx, spec, &gasUsed)
+			if err == nil {
+				parallelMergedMeter.Mark(1)
+			}
+		} else {
+			if spec != nil {
+				parallelStaleMeter.Mark(1)
+			}
+			check := func(result *ExecutionResult) error {
+				return w.checkOutcome(env, tx, result.UsedGas, result.Failed(), result.Etxs)
+			}
+			receipt, err = applyCheckedTransaction(w.chainConfig, w.hc, &env.coinbase, env.gasPool, env.state, env.header, tx, &gasUsed, *vmConfig, &env.etxRLimit, &env.etxPLimit, check)
+		}
+		if err != nil {
+			// Undo the transaction, handing back the gas and the etx limits it consumed
+			log.Debug("Error playing transaction in worker", "err", err, "tx", tx.Hash().Hex(), "block", env.header.Number, "gasUsed", gasUsed)
+			env.state.RevertToSnapshot(snap)
+			*env.gasPool = gasPool
+			env.etxRLimit, env.etxPLimit = etxRLimit, etxPLimit
+			env.reverted++
+			return nil, err
+		}
+		_, etxGas, etxSize := emittedEtxs(receipt.Etxs, receipt.Status != types.ReceiptStatusSuccessful)
+		// once the gasUsed pointer is updated in the ApplyTransaction it has to be set back to the env.Header.GasUsed
+		// This extra step is needed because previously the GasUsed was a public method and direct update of the value
+		// was possible.
+		env.header.SetGasUsed(gasUsed)
+		if tx.Type() == types.ExternalTxType {
+			env.externalGasUsed += receipt.GasUsed
+		}
+		env.txs = append(env.txs, tx)
+		env.receipts = append(env.receipts, receipt)
+		env.size += tx.Size() + etxSize
+		if receipt.Status == types.ReceiptStatusSuccessful {
+			env.etxs = append(env.etxs, receipt.Etxs...)
+			env.etxGas += etxGas
+		} else if len(receipt.Etxs) > 0 {
+			// A failed transaction must not emit any etxs, they are dropped
+			etxDroppedOnFailedTxCounter.Inc(int64(len(receipt.Etxs)))
+			log.Error("Failed transaction emitted etxs", "tx", tx.Hash(), "etxs", len(receipt.Etxs))
+		}
+		return receipt.Logs, nil
+	}
+	return nil, errors.New("error finding transaction")
+}
+
+func (w *worker) commitTransactions(env *environment, txs TxIterator, maxAttempts int, interrupt *int32) bool {
+	gasLimit := env.header.GasLimit
+	if env.gasPool == nil {
+		env.gasPool = new(GasPool).AddGas(w.availableGas(gasLimit()))
+	}
+	var coalescedLogs []*types.Log
+
+	// Report the transactions which failed to commit once done, without
+	// blocking the packing on slow subscribers
+	var (
+		failures []TxFailureEvent
+		retries  []*types.Transaction
+	)
+	defer func() {
+		w.addRetryTxs(retries)
+		if len(failures) > 0 {
+			go func() {
+				for _, failure := range failures {
+					w.txFailureFeed.Send(failure)
+				}
+			}()
+		}
+	}()
+
+	w.mu.RLock()
+	allowlistMode, allowedSenders := w.config.AllowlistMode, w.allowedSenders
+	maxBlockBytes := common.StorageSize(w.config.MaxBlockBytes)
+	quietUnsupportedTxType := w.config.QuietUnsupportedTxType
+	inclusionPolicy := w.inclusionPolicy
+	retryTransient := w.config.RetryTransientFailures
+	maxTxPerSender := w.config.MaxTxPerSender
+	localReserve := w.config.LocalGasReserve * (w.gasCeil() / 100)
+	var minTip *big.Int
+	if w.config.GasPrice != nil && w.config.GasPrice.Sign() > 0 {
+		minTip = new(big.Int).Set(w.config.GasPrice)
+	}
+	w.mu.RUnlock()
+
+	// Resolve the local accounts if part of the block is reserved for them or
+	// they have to be exempted from the tip floor
+	var locals map[common.AddressBytes]struct{}
+	if localReserve > 0 || minTip != nil {
+		locals = make(map[common.AddressBytes]struct{})
+		for _, addr := range w.txPool.Locals() {
+			locals[common.AddressBytes(addr)] = struct{}{}
+		}
+	}
+
+	// Keep track of the transactions already in the block so duplicates in the
+	// pending set are not applied again
+	included := make(map[common.Hash]struct{}, len(env.txs))
+	for _, tx := range env.txs {
+		included[tx.Hash()] = struct{}{}
+	}
+	// Keep track of the number of transactions included per sender if capped
+	var senderTxs map[common.AddressBytes]int
+	if maxTxPerSender > 0 {
+		senderTxs = make(map[common.AddressBytes]int)
+		for _, tx := range env.txs {
+			if from, err := types.Sender(env.signer, tx); err == nil {
+				senderTxs[from.Bytes20()]++
 			}
 		}
 	}
-	rawdb.WritePbBodyKeys(w.workerDb, pendingBlockBodyKeys)
-}
 
-// asyncStateLoop updates the state root for a block and returns the state udpate in a channel
-func (w *worker) asyncStateLoop() {
-	defer w.wg.Done() // decrement the wait group after the close of the loop
+	for attempts := 0; ; attempts++ {
+		// Guard against a transaction source which never advances
+		if maxAttempts > 0 && attempts >= maxAttempts {
+			log.Error("Aborting transaction commit, too many attempts", "attempts", attempts, "txs", env.tcount)
+			break
+		}
+		// In the following three cases, we will interrupt the execution of the transaction.
+		// (1) new head block event arrival, the interrupt signal is 1
+		// (2) worker start or restart, the interrupt signal is 1
+		// (3) worker recreate the sealing block with any newly arrived transactions, the interrupt signal is 2.
+		// For the first two cases, the semi-finished work will be discarded.
+		// For the third case, the semi-finished work will be submitted to the consensus engine.
+		if interrupt != nil && atomic.LoadInt32(interrupt) != commitInterruptNone {
+			// Notify resubmit loop to increase resubmitting interval due to too frequent commits.
+			if atomic.LoadInt32(interrupt) == commitInterruptResubmit {
+				ratio := float64(gasLimit()-env.gasPool.Gas()) / float64(gasLimit())
+				if ratio < 0.1 {
+					ratio = 0.1
+				}
+				w.resubmitAdjustCh <- &intervalAdjust{
+					ratio: ratio,
+					inc:   true,
+				}
+			}
+			return atomic.LoadInt32(interrupt) == commitInterruptNewHead
+		}
+		// If the time budget for packing transactions is spent then we're done
+		if !env.fillDeadline.IsZero() && time.Now().After(env.fillDeadline) {
+			log.Debug("Fill deadline reached", "txs", env.tcount, "deadline", w.config.FillDeadline)
+			break
+		}
+		// If we don't have enough gas for any further transactions then we're done
+		if env.gasPool.Gas() < params.TxGas {
+			log.Trace("Not enough gas for further transactions", "have", env.gasPool, "want", params.TxGas)
+			break
+		}
+		// Retrieve the next transaction and abort if all done
+		tx := txs.Peek()
+		if tx == nil {
+			break
+		}
+		// Error may be ignored here. The error has already been checked
+		// during transaction acceptance is the transaction pool.
+		//
+		// We use the signer regardless of the current hf.
+		from, _ := types.Sender(env.signer, tx)
+		if allowlistMode {
+			if _, ok := allowedSenders[from.Bytes20()]; !ok {
+				// Pop the transaction of the unlisted sender without shifting in the next from the account
+				log.Trace("Skipping transaction from sender not in allowlist", "sender", from)
+				txs.PopNoSort()
+				continue
+			}
+		}
+		if maxTxPerSender > 0 && senderTxs[from.Bytes20()] >= maxTxPerSender {
+			// Pop the transaction of the capped sender without shifting in the next from the account
+			log.Trace("Skipping transaction from sender at inclusion cap", "sender", from, "cap", maxTxPerSender)
+			txs.PopNoSort()
+			continue
+		}
+		if _, ok := included[tx.Hash()]; ok {
+			// Shift in the next transaction from the account, the duplicate has been applied already
+			log.Trace("Skipping duplicate transaction", "sender", from, "hash", tx.Hash())
+			txs.Shift(from.Bytes20(), false)
+			continue
+		}
+		if inclusionPolicy != nil {
+			switch inclusionPolicy(tx, env) {
+			case InclusionSkip:
+				// Pop the skipped transaction without shifting in the next from the account
+				log.Trace("Skipping transaction by inclusion policy", "sender", from, "hash", tx.Hash())
+				txs.PopNoSort()
+				continue
+			case InclusionDefer:
+				// Pop the deferred transaction and keep it around for a later block
+				log.Trace("Deferring transaction by inclusion policy", "sender", from, "hash", tx.Hash())
+				env.deferred = append(env.deferred, tx)
+				txs.PopNoSort()
+				continue
+			}
+		}
+		// Keep remote transactions out of the gas still reserved for local ones
+		_, local := locals[from.Bytes20()]
+		if localReserve > 0 && !local && env.localGasUsed < localReserve {
+			if env.gasPool.Gas() < localReserve-env.localGasUsed+tx.Gas() {
+				// Pop the remote transaction without shifting in the next from the account
+				log.Trace("Skipping remote transaction intruding on local gas reserve", "sender", from, "hash", tx.Hash())
+				txs.PopNoSort()
+				continue
+			}
+		}
+		// Keep remote transactions tipping less than the configured floor out, the
+		// pool may still hold them if they were accepted before the floor was raised
+		if minTip != nil && !local && tx.Type() != types.ExternalTxType {
+			if tip, err := tx.EffectiveGasTip(env.header.BaseFee()); err != nil || tip.Cmp(minTip) < 0 {
+				// Pop the underpriced transaction without shifting in the next from the account
+				log.Trace("Skipping transaction below the tip floor", "sender", from, "hash", tx.Hash(), "floor", minTip)
+				txRejectedUnderpricedCounter.Inc(1)
+				txs.PopNoSort()
+				continue
+			}
+		}
+		// Skip the transactions the pool found reverting against the latest state
+		if w.txPool != nil && tx.Type() != types.ExternalTxType && w.txPool.PrecheckReverted(tx.Hash()) {
+			log.Trace("Skipping transaction reverting in the pool precheck", "sender", from, "hash", tx.Hash())
+			txRejectedRevertingCounter.Inc(1)
+			txs.PopNoSort()
+			continue
+		}
+		// If the transaction doesn't fit in the block size limit then we're done
+		if maxBlockBytes > 0 && env.size+tx.Size() > maxBlockBytes {
+			log.Trace("Not enough space for further transactions", "have", env.size, "want", tx.Size(), "limit", maxBlockBytes)
+			break
+		}
+		// Start executing the transaction
+		env.state.Prepare(tx.Hash(), env.tcount)
+		gasLeft := env.gasPool.Gas()
 
-	for {
-		select {
-		case head := <-w.chainHeadCh:
+		logs, err := w.commitTransaction(env, tx)
+		switch {
+		case errors.Is(err, ErrGasLimitReached):
+			// Pop the current out-of-gas transaction without shifting in the next from the account
+			log.Trace("Gas limit exceeded for current block", "sender", from)
+			txRejectedGasLimitCounter.Inc(1)
+			txs.PopNoSort()
 
-			w.interruptAsyncPhGen()
+		case errors.Is(err, ErrEtxLimitReached):
+			// Pop the current transaction without shifting in the next from the account
+			log.Trace("Etx limit exceeded for current block", "sender", from, "err", err)
+			txRejectedEtxLimitCounter.Inc(1)
+			txs.PopNoSort()
 
-			go func() {
-				select {
-				case <-w.interrupt:
-					w.interrupt = make(chan struct{})
-					return
-				default:
-					block := head.Block
-					header, err := w.GeneratePendingHeader(block, true)
-					if err != nil {
-						log.Error("Error generating pending header with state", "err", err)
-						return
-					}
-					// Send the updated pendingHeader in the asyncPhFeed
-					w.asyncPhFeed.Send(header)
-					return
+		case errors.Is(err, errBlockBytesReached):
+			// Pop the transaction whose ETXs overflow the block size without shifting in
+			// the next from the account, smaller transactions may still fit
+			log.Trace("Block size limit exceeded by the emitted etxs", "sender", from, "err", err)
+			txRejectedBlockBytesCounter.Inc(1)
+			txs.PopNoSort()
+
+		case errors.Is(err, ErrNonceTooLow):
+			// New head notification data race between the transaction pool and miner, shift
+			log.Trace("Skipping transaction with low nonce", "sender", from, "nonce", tx.Nonce())
+			txRejectedNonceLowCounter.Inc(1)
+			txs.Shift(from.Bytes20(), false)
+
+		case errors.Is(err, ErrNonceTooHigh):
+			// Reorg notification data race between the transaction pool and miner, skip account =
+			log.Debug("Skipping account with high nonce", "sender", from, "nonce", tx.Nonce())
+			txRejectedNonceHiCounter.Inc(1)
+			txs.PopNoSort()
+
+		case tx.Type() == types.SponsoredTxType && (errors.Is(err, ErrInsufficientFunds) || errors.Is(err, ErrPayerNoEOA)):
+			// The fee payer can't pay for the gas, pop the transaction without shifting in
+			// the next from the account as it can't execute before this one
+			log.Trace("Skipping sponsored transaction unpaid by its fee payer", "sender", from, "hash", tx.Hash(), "err", err)
+			txRejectedPayerCounter.Inc(1)
+			txs.PopNoSort()
+
+		case errors.Is(err, nil):
+			// Everything ok, collect the logs and shift in the next transaction from the same account,
+			// which is priced against the other heads
+			coalescedLogs = append(coalescedLogs, logs...)
+			included[tx.Hash()] = struct{}{}
+			env.tcount++
+			if !env.simulation {
+				txCommittedCounter.Inc(1)
+				if tx.Type() == types.SponsoredTxType {
+					txSponsoredCounter.Inc(1)
 				}
-			}()
-		case <-w.exitCh:
-			return
-		case <-w.chainHeadSub.Err():
-			return
+			}
+			if local {
+				env.localGasUsed += gasLeft - env.gasPool.Gas()
+			}
+			if senderTxs != nil {
+				senderTxs[from.Bytes20()]++
+			}
+			txs.Shift(from.Bytes20(), true)
+
+		case errors.Is(err, errZeroGasUsed):
+			// Pop the transaction which used no gas without shifting in the next from the account
+			log.Trace("Skipping transaction which used no gas", "sender", from, "hash", tx.Hash())
+			txRejectedZeroGasCounter.Inc(1)
+			txs.PopNoSort()
+
+		case errors.Is(err, ErrTxTypeNotSupported):
+			// Pop the unsupported transaction without shifting in the next from the account
+			unsupportedTxTypeCounter.Inc(1)
+			txRejectedUnsupportedCounter.Inc(1)
+			if quietUnsupportedTxType {
+				log.Debug("Skipping unsupported transaction type", "sender", from, "type", tx.Type())
+			} else {
+				log.Error("Skipping unsupported transaction type", "sender", from, "type", tx.Type())
+			}
+			txs.PopNoSort()
+
+		default:
+			// Strange error, discard the transaction and get the next in line (note, the
+			// nonce-too-high clause will prevent us from executing in vain).
+			log.Debug("Transaction failed, account skipped", "hash", tx.Hash(), "err", err)
+			txRejectedOtherCounter.Inc(1)
+			txs.Shift(from.Bytes20(), false)
+		}
+		if err != nil && !env.simulation {
+			failures = append(failures, TxFailureEvent{Hash: tx.Hash(), Sender: from, Err: err, Number: env.header.NumberU64()})
+			if retryTransient && isTransientCommitError(err) {
+				retries = append(retries, tx)
+			}
+		}
+	}
+
+	if !env.simulation && !w.isRunning() && len(coalescedLogs) > 0 {
+		// We don't push the pendingLogsEvent while we are sealing. The reason is that
+		// when we are sealing, the worker will regenerate a sealing block every 3 seconds.
+		// In order to avoid pushing the repeated pendingLog, we disable the pending log pushing.
+
+		// make a copy, the state caches the logs and these logs get "upgraded" from pending to mined
+		// logs by filling in the block hash when the block was mined by the local miner. This can
+		// cause a race condition if a log was "upgraded" before the PendingLogsEvent is processed.
+		cpy := make([]*types.Log, len(coalescedLogs))
+		for i, l := range coalescedLogs {
+			cpy[i] = new(types.Log)
+			*cpy[i] = *l
 		}
+		w.pendingLogsFeed.Send(cpy)
 	}
+	return false
 }
 
-// GeneratePendingBlock generates pending block given a commited block.
-func (w *worker) GeneratePendingHeader(block *types.Block, fill bool) (*types.Header, error) {
+// generateParams wraps various of settings for generating sealing task.
+type generateParams struct {
+	timestamp     uint64         // The timstamp for sealing task
+	forceTime     bool           // Flag whether the given timestamp is immutable or not
+	coinbase      common.Address // The fee recipient address for including transaction
+	speculative   bool           // Flag whether the parent may be an arbitrary non-canonical block
+	interruptible bool           // Flag whether a new dom header may abort the transaction filling
+
+	gasLimitOverride uint64 // The gas limit for sealing task, computed from the parent if zero
+}
+
+// prepareWork constructs the sealing task according to the given parameters,
+// either based on the last chain head or specified parent. In this function
+// the pending transactions are not filled yet, only the empty task returned.
+func (w *worker) prepareWork(genParams *generateParams, block *types.Block) (*environment, error) {
+	defer prepareWorkTimer.UpdateSince(time.Now())
 	nodeCtx := common.NodeLocation.Context()
 
-	w.interruptAsyncPhGen()
+	// Resolve the parent state before taking the lock, as recovering it may take
+	// reexecuting thousands of blocks, which would hold up every setter
+	var statedb *state.StateDB
+	if nodeCtx == common.ZONE_CTX && w.hc.ProcessingState() {
+		reexec := uint64(stateRecoveryReexec)
+		if genParams.speculative {
+			reexec = speculativeStateRecoveryReexec
+		}
+		var err error
+		if statedb, err = w.parentState(block, reexec); err != nil {
+			log.Error("Failed to create sealing context", "err", err)
+			return nil, err
+		}
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
 
-	var (
-		interrupt *int32
-		timestamp int64 // timestamp for each round of sealing.
-	)
+	// Find the parent block for sealing task
+	parent := block
+	// Sanity check the timestamp correctness, recap the timestamp
+	// to parent+1 if the mutation is allowed.
+	timestamp := genParams.timestamp
+	if parent.Time() >= timestamp {
+		if genParams.forceTime {
+			return nil, fmt.Errorf("invalid timestamp, parent %d given %d", parent.Time(), timestamp)
+		}
+		timestamp = parent.Time() + 1
+	}
+	// Construct the sealing block header, set the extra field if it's allowed
+	num := parent.Number()
+	header := types.EmptyHeader()
+	header.SetParentHash(block.Header().Hash())
+	header.SetNumber(big.NewInt(int64(num.Uint64()) + 1))
+	header.SetTime(timestamp)
 
-	if interrupt != nil {
-		atomic.StoreInt32(interrupt, commitInterruptNewHead)
+	// Only calculate entropy if the parent is not the genesis block
+	if parent.Hash() != w.hc.config.GenesisHash {
+		_, order, err := w.engine.CalcOrder(parent.Header())
+		if err != nil {
+			return nil, err
+		}
+		// Set the parent delta S prior to sending to sub
+		if nodeCtx != common.PRIME_CTX {
+			if order < nodeCtx {
+				header.SetParentDeltaS(big.NewInt(0), nodeCtx)
+			} else {
+				header.SetParentDeltaS(w.engine.DeltaLogS(parent.Header()), nodeCtx)
+			}
+		}
+		header.SetParentEntropy(w.engine.TotalLogS(parent.Header()))
 	}
-	interrupt = new(int32)
-	atomic.StoreInt32(&w.newTxs, 0)
 
-	start := time.Now()
-	// Set the coinbase if the worker is running or it's required
-	var coinbase common.Address
-	if w.coinbase.Equal(common.ZeroAddr) {
-		log.Error("Refusing to mine without etherbase")
-		return nil, errors.New("etherbase not found")
+	// Only zone should calculate state
+	if nodeCtx == common.ZONE_CTX && w.hc.ProcessingState() {
+		header.SetExtra(w.extra)
+		header.SetBaseFee(misc.CalcBaseFee(w.chainConfig, parent.Header()))
+		if genParams.gasLimitOverride != 0 {
+			header.SetGasLimit(genParams.gasLimitOverride)
+		}
+		coinbase := w.etherbaseAt(header.Number())
+		if w.coinbaseSelector != nil {
+			coinbase = w.coinbaseSelector(new(big.Int).Set(header.Number()))
+		}
+		if w.coinbaseCheck != nil && !coinbase.Equal(common.ZeroAddr) {
+			if err := w.coinbaseCheck(coinbase); err != nil {
+				log.Error("Refusing to mine with invalid etherbase", "etherbase", coinbase, "err", err)
+				return nil, err
+			}
+		}
+		if w.isRunning() {
+			if coinbase.Equal(common.ZeroAddr) {
+				log.Error("Refusing to mine without etherbase")
+				return nil, errors.New("refusing to mine without etherbase")
+			}
+			header.SetCoinbase(coinbase)
+		}
+
+		// Run the consensus preparation with the default or customized consensus engine.
+		if err := w.engine.Prepare(w.hc, header, block.Header()); err != nil {
+			log.Error("Failed to prepare header for sealing", "err", err)
+			return nil, err
+		}
+		if err := validatePreparedHeader(header, parent.Header()); err != nil {
+			log.Error("Consensus engine prepared an invalid header", "err", err)
+			return nil, err
+		}
+		env, err := w.makeEnv(parent, header, coinbase, statedb)
+		if err != nil {
+			log.Error("Failed to create sealing context", "err", err)
+			return nil, err
+		}
+		// Accumulate the uncles for the sealing work, the candidates come locally
+		// generated first.
+		for _, uncle := range w.uncles.blocks() {
+			env.uncleMu.RLock()
+			if len(env.uncles) == 2 {
+				env.uncleMu.RUnlock()
+				break
+			}
+			env.uncleMu.RUnlock()
+			if err := w.commitUncle(env, uncle.Header()); err != nil {
+				uncleRejectedCounter.Inc(1)
+				log.Trace("Possible uncle rejected", "hash", uncle.Hash(), "reason", err)
+			} else {
+				log.Debug("Committing new uncle to block", "hash", uncle.Hash())
+			}
+		}
+		return env, nil
+	} else {
+		return &environment{header: header}, nil
+	}
+
+}
+
+// fillTransactions retrieves the pending transactions from the txpool and fills them
+// into the given sealing block. The transaction selection and ordering strategy can
+// be customized with the plugin in the future.
+func (w *worker) fillTransactions(interrupt *int32, env *environment, block *types.Block) {
+	defer fillTransactionsTimer.UpdateSince(time.Now())
+	if w.config.FillDeadline > 0 {
+		env.fillDeadline = time.Now().Add(w.config.FillDeadline)
+	}
+	// Split the pending transactions into locals and remotes
+	// Fill the block with all available pending transactions.
+	etxSet := rawdb.ReadEtxSet(w.hc.bc.db, block.Hash(), block.NumberU64())
+	if etxSet == nil {
+		return
+	}
+	etxSet.Update(types.Transactions{}, block.NumberU64()+1) // Prune any expired ETXs
+	txPoolPending := w.txPool.TxPoolPending
+	if env.simulation {
+		txPoolPending = w.txPool.SimulationPending
 	}
-	coinbase = w.coinbase // Use the preset address as the fee recipient
-
-	work, err := w.prepareWork(&generateParams{
-		timestamp: uint64(timestamp),
-		coinbase:  coinbase,
-	}, block)
+	pending, err := txPoolPending(true, etxSet)
 	if err != nil {
-		return nil, err
+		return
+	}
+	// Drop the transactions found bound to fail by the pre-execution on the parent
+	if result := w.speculative.get(block.Header().SealHash()); result != nil {
+		preexecHitMeter.Mark(1)
+		preexecPrunedMeter.Mark(int64(result.prune(pending)))
+	}
+	// Commit the most profitable bundles targeting the block ahead of the pool
+	if w.commitBundles(env, interrupt) {
+		return
 	}
 
-	if nodeCtx == common.ZONE_CTX && w.hc.ProcessingState() {
-		// Fill pending transactions from the txpool
-		w.adjustGasLimit(nil, work, block)
-		if fill {
-			start := time.Now()
-			w.fillTransactions(interrupt, work, block)
-			w.fillTransactionsRollingAverage.Add(time.Since(start))
-			log.Info("Filled and sorted pending transactions", "count", len(work.txs), "elapsed", common.PrettyDuration(time.Since(start)), "average", common.PrettyDuration(w.fillTransactionsRollingAverage.Average()))
+	// Commit the transactions of the priority senders ahead of the price ordered set
+	if priority := w.takePriorityTxs(pending); len(priority) > 0 {
+		if w.commitPriorityTransactions(env, priority, interrupt) {
+			return
 		}
 	}
 
-	// Swap out the old work with the new one, terminating any leftover
-	// prefetcher processes in the mean time and starting a new one.
-	if w.current != nil {
-		w.current.discard()
+	// Retry the transactions which failed transiently in the previous cycle first,
+	// simulations leave them to the sealing work
+	if !env.simulation {
+		if retries := w.takeRetryTxs(env.signer); len(retries) > 0 {
+			var count int
+			for _, accTxs := range retries {
+				count += len(accTxs)
+			}
+			txs := w.txOrdering().OrderPending(env.signer, retries, env.header.BaseFee())
+			if w.commitTransactions(env, txs, count*commitAttemptsFactor, interrupt) {
+				return
+			}
+		}
 	}
-	w.current = work
-
-	// Create a local environment copy, avoid the data race with snapshot state.
-	newBlock, err := w.FinalizeAssemble(w.hc, work.header, block, work.state, work.txs, work.unclelist(), work.etxs, work.subManifest, work.receipts)
-	if err != nil {
-		return nil, err
+	if len(pending) > 0 {
+		w.commitPending(env, pending, interrupt)
 	}
+}
 
-	work.header = newBlock.Header()
-	w.printPendingHeaderInfo(work, newBlock, start)
+// commitPending commits the given pending transactions in the configured order.
+// With parallel packing, the transactions are executed speculatively first and
+// the outcome of the ones whose state didn't change since is applied as is.
+func (w *worker) commitPending(env *environment, pending map[common.AddressBytes]types.Transactions, interrupt *int32) bool {
+	if w.config.ParallelPacking {
+		pending, env.speculated = w.speculateTransactions(env, pending)
+		defer func() { env.speculated = nil }()
+	}
+	var count int
+	for _, accTxs := range pending {
+		count += len(accTxs)
+	}
+	txs := w.txOrdering().OrderPending(env.signer, pending, env.header.BaseFee())
+	return w.commitTransactions(env, txs, count*commitAttemptsFactor, interrupt)
+}
 
-	return work.header, nil
+// txOrdering returns the strategy ordering the pending transactions for packing.
+func (w *worker) txOrdering() TxOrdering {
+	if w.config.TxOrdering != nil {
+		return w.config.TxOrdering
+	}
+	return priceAndNonceOrdering{}
 }
 
-// printPendingHeaderInfo logs the pending header information
-func (w *worker) printPendingHeaderInfo(work *environment, block *types.Block, start time.Time) {
-	work.uncleMu.RLock()
-	if w.CurrentInfo(block.Header()) {
-		log.Info("Commit new sealing work", "number", block.Number(), "sealhash", block.Header().SealHash(),
-			"uncles", len(work.uncles), "txs", work.tcount, "etxs", len(block.ExtTransactions()),
-			"gas", block.GasUsed(), "fees", totalFees(block, work.receipts),
-			"elapsed", common.PrettyDuration(time.Since(start)))
-	} else {
-		log.Debug("Commit new sealing work", "number", block.Number(), "sealhash", block.Header().SealHash(),
-			"uncles", len(work.uncles), "txs", work.tcount, "etxs", len(block.ExtTransactions()),
-			"gas", block.GasUsed(), "fees", totalFees(block, work.receipts),
-			"elapsed", common.PrettyDuration(time.Since(start)))
+// validateBundle checks that the bundle isn't empty and targets one of the blocks
+// from the next one to be sealed to the bundle lookahead.
+func validateBundle(bundle *Bundle, next uint64) error {
+	if len(bundle.Txs) == 0 {
+		return errors.New("empty bundle")
 	}
-	work.uncleMu.RUnlock()
+	if bundle.BlockNumber < next {
+		return fmt.Errorf("bundle targets past block %d, next block is %d", bundle.BlockNumber, next)
+	}
+	if bundle.BlockNumber > next+maxBundleLookahead {
+		return fmt.Errorf("bundle targets block %d too far ahead, next block is %d", bundle.BlockNumber, next)
+	}
+	return nil
 }
 
-// interruptAsyncPhGen kills any async ph generation running
-func (w *worker) interruptAsyncPhGen() {
-	if w.interrupt != nil {
-		close(w.interrupt)
-		w.interrupt = nil
+// addBundle adds a transaction bundle to be included into the block it targets.
+func (w *worker) addBundle(bundle *Bundle) error {
+	if err := validateBundle(bundle, w.hc.CurrentHeader().NumberU64()+1); err != nil {
+		return err
 	}
+	w.bundleMu.Lock()
+	defer w.bundleMu.Unlock()
+	if len(w.bundles) >= maxPendingBundles {
+		return errors.New("bundle pool is full")
+	}
+	w.bundles = append(w.bundles, bundle)
+	return nil
 }
 
-func (w *worker) eventExitLoop() {
-	for {
-		select {
-		case <-w.exitCh:
-			return
+// bundlesAt returns the bundles targeting the block of the given number, and
+// drops the ones targeting earlier blocks. The returned bundles are kept, as the
+// block may be regenerated before it is sealed.
+func (w *worker) bundlesAt(number uint64) []*Bundle {
+	w.bundleMu.Lock()
+	defer w.bundleMu.Unlock()
+
+	var (
+		kept    = w.bundles[:0]
+		bundles []*Bundle
+	)
+	for _, bundle := range w.bundles {
+		if bundle.BlockNumber < number {
+			continue
+		}
+		kept = append(kept, bundle)
+		if bundle.BlockNumber == number {
+			bundles = append(bundles, bundle)
 		}
 	}
+	w.bundles = kept
+	return bundles
 }
 
-// makeEnv creates a new environment for the sealing block.
-func (w *worker) makeEnv(parent *types.Block, header *types.Header, coinbase common.Address) (*environment, error) {
-	// Retrieve the parent state to execute on top and start a prefetcher for
-	// the miner to speed block sealing up a bit.
-	state, err := w.hc.bc.processor.StateAt(parent.Root())
-	if err != nil {
-		return nil, err
-	}
+// bundlePolicy holds the packing rules of the miner which apply to the bundle
+// transactions like to the pool ones.
+type bundlePolicy struct {
+	allowlistMode  bool
+	allowedSenders map[common.AddressBytes]struct{}
+	inclusion      func(tx *types.Transaction, env *environment) InclusionDecision
+	maxTxPerSender int
+}
 
-	etxRLimit := len(parent.Transactions()) / params.ETXRegionMaxFraction
-	if etxRLimit < params.ETXRLimitMin {
-		etxRLimit = params.ETXRLimitMin
-	}
-	etxPLimit := len(parent.Transactions()) / params.ETXPrimeMaxFraction
-	if etxPLimit < params.ETXPLimitMin {
-		etxPLimit = params.ETXPLimitMin
+// bundlePolicy returns the packing rules the bundles are currently subject to.
+func (w *worker) bundlePolicy() *bundlePolicy {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return &bundlePolicy{
+		allowlistMode:  w.config.AllowlistMode,
+		allowedSenders: w.allowedSenders,
+		inclusion:      w.inclusionPolicy,
+		maxTxPerSender: w.config.MaxTxPerSender,
 	}
-	// Note the passed coinbase may be different with header.Coinbase.
-	env := &environment{
-		signer:    types.MakeSigner(w.chainConfig, header.Number()),
-		state:     state,
-		coinbase:  coinbase,
-		ancestors: mapset.NewSet(),
-		family:    mapset.NewSet(),
-		header:    header,
-		uncles:    make(map[common.Hash]*types.Header),
-		etxRLimit: etxRLimit,
-		etxPLimit: etxPLimit,
+}
+
+// check returns why the bundle can't be committed to the sealing environment, nil
+// if all of its transactions comply with the packing rules. A bundle is applied
+// atomically, so a single transaction the rules keep out discards it whole.
+func (p *bundlePolicy) check(env *environment, bundle *Bundle) error {
+	var senderTxs map[common.AddressBytes]int
+	if p.maxTxPerSender > 0 {
+		senderTxs = make(map[common.AddressBytes]int)
+		for _, tx := range env.txs {
+			if from, err := types.Sender(env.signer, tx); err == nil {
+				senderTxs[from.Bytes20()]++
+			}
+		}
 	}
-	// when 08 is processed ancestors contain 07 (quick block)
-	for _, ancestor := range w.hc.GetBlocksFromHash(parent.Hash(), 7) {
-		for _, uncle := range ancestor.Uncles() {
-			env.family.Add(uncle.Hash())
+	for _, tx := range bundle.Txs {
+		from, err := types.Sender(env.signer, tx)
+		if err != nil {
+			return fmt.Errorf("bundle transaction %x: %v", tx.Hash(), err)
+		}
+		if p.allowlistMode {
+			if _, ok := p.allowedSenders[from.Bytes20()]; !ok {
+				return fmt.Errorf("bundle transaction %x sender %v not in allowlist", tx.Hash(), from)
+			}
+		}
+		if p.maxTxPerSender > 0 {
+			if senderTxs[from.Bytes20()]++; senderTxs[from.Bytes20()] > p.maxTxPerSender {
+				return fmt.Errorf("bundle transaction %x sender %v over inclusion cap %d", tx.Hash(), from, p.maxTxPerSender)
+			}
+		}
+		if p.inclusion != nil && p.inclusion(tx, env) != InclusionInclude {
+			return fmt.Errorf("bundle transaction %x refused by inclusion policy", tx.Hash())
 		}
-		env.family.Add(ancestor.Hash())
-		env.ancestors.Add(ancestor.Hash())
 	}
-	// Keep track of transactions which return errors so they can be removed
-	env.tcount = 0
-	return env, nil
+	return nil
 }
 
-// commitUncle adds the given block to uncle block set, returns error if failed to add.
-func (w *worker) commitUncle(env *environment, uncle *types.Header) error {
-	env.uncleMu.Lock()
-	defer env.uncleMu.Unlock()
-	hash := uncle.Hash()
-	if _, exist := env.uncles[hash]; exist {
-		return errors.New("uncle not unique")
+// commitBundles simulates the bundles targeting the sealing block on top of its
+// state, and commits the non-conflicting ones paying the most to the coinbase.
+// The bundles are subject to the same packing rules, interrupt and fill deadline
+// as the pool transactions. It returns true if interrupted by a new head.
+func (w *worker) commitBundles(env *environment, interrupt *int32) bool {
+	bundles := w.bundlesAt(env.header.NumberU64())
+	if len(bundles) == 0 {
+		return false
 	}
-	if env.header.ParentHash() == uncle.ParentHash() {
-		return errors.New("uncle is sibling")
+	coinbase, err := env.coinbase.InternalAddress()
+	if err != nil {
+		return false
 	}
-	if !env.ancestors.Contains(uncle.ParentHash()) {
-		return errors.New("uncle's parent unknown")
+	if env.gasPool == nil {
+		env.gasPool = new(GasPool).AddGas(w.availableGas(env.header.GasLimit()))
 	}
-	if env.family.Contains(hash) {
-		return errors.New("uncle already included")
+	// stop reports whether the bundle commit has to end early, and whether due to
+	// a new head
+	stop := func() (bool, bool) {
+		if interrupt != nil && atomic.LoadInt32(interrupt) != commitInterruptNone {
+			return true, atomic.LoadInt32(interrupt) == commitInterruptNewHead
+		}
+		if !env.fillDeadline.IsZero() && time.Now().After(env.fillDeadline) {
+			log.Debug("Fill deadline reached committing bundles", "txs", env.tcount, "deadline", w.config.FillDeadline)
+			return true, false
+		}
+		return false, false
 	}
-	env.uncles[hash] = uncle
-	return nil
-}
+	policy := w.bundlePolicy()
 
-func (w *worker) commitTransaction(env *environment, tx *types.Transaction) ([]*types.Log, error) {
-	if tx != nil {
-		snap := env.state.Snapshot()
-		// retrieve the gas used int and pass in the reference to the ApplyTransaction
-		gasUsed := env.header.GasUsed()
-		receipt, err := ApplyTransaction(w.chainConfig, w.hc, &env.coinbase, env.gasPool, env.state, env.header, tx, &gasUsed, *w.hc.bc.processor.GetVMConfig(), &env.etxRLimit, &env.etxPLimit)
+	type candidate struct {
+		bundle  *Bundle
+		payment *big.Int
+	}
+	candidates := make([]candidate, 0, len(bundles))
+	for _, bundle := range bundles {
+		if done, newHead := stop(); done {
+			return newHead
+		}
+		if err := policy.check(env, bundle); err != nil {
+			log.Debug("Discarding bundle against packing rules", "number", bundle.BlockNumber, "txs", len(bundle.Txs), "err", err)
+			bundleDiscardedCounter.Inc(1)
+			continue
+		}
+		payment, err := w.simulateBundle(env, coinbase, bundle)
 		if err != nil {
-			log.Debug("Error playing transaction in worker", "err", err, "tx", tx.Hash().Hex(), "block", env.header.Number, "gasUsed", gasUsed)
-			env.state.RevertToSnapshot(snap)
-			return nil, err
+			log.Debug("Discarding failing bundle", "number", bundle.BlockNumber, "txs", len(bundle.Txs), "err", err)
+			bundleDiscardedCounter.Inc(1)
+			continue
 		}
-		// once the gasUsed pointer is updated in the ApplyTransaction it has to be set back to the env.Header.GasUsed
-		// This extra step is needed because previously the GasUsed was a public method and direct update of the value
-		// was possible.
-		env.header.SetGasUsed(gasUsed)
-		env.txs = append(env.txs, tx)
-		env.receipts = append(env.receipts, receipt)
-		if receipt.Status == types.ReceiptStatusSuccessful {
-			env.etxs = append(env.etxs, receipt.Etxs...)
+		candidates = append(candidates, candidate{bundle: bundle, payment: payment})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].payment.Cmp(candidates[j].payment) > 0
+	})
+	// Commit the bundles greedily, skipping the ones sharing transactions with an
+	// already committed one or failing on top of it
+	committed := make(map[common.Hash]struct{})
+	for _, c := range candidates {
+		if done, newHead := stop(); done {
+			return newHead
 		}
-		return receipt.Logs, nil
+		conflict := false
+		for _, tx := range c.bundle.Txs {
+			if _, ok := committed[tx.Hash()]; ok {
+				conflict = true
+				break
+			}
+		}
+		if conflict {
+			bundleDiscardedCounter.Inc(1)
+			continue
+		}
+		// The per sender cap also counts the bundles committed before this one
+		if err := policy.check(env, c.bundle); err != nil {
+			log.Debug("Discarding bundle against packing rules", "number", c.bundle.BlockNumber, "txs", len(c.bundle.Txs), "err", err)
+			bundleDiscardedCounter.Inc(1)
+			continue
+		}
+		if err := w.commitBundle(env, c.bundle); err != nil {
+			log.Debug("Discarding conflicting bundle", "number", c.bundle.BlockNumber, "txs", len(c.bundle.Txs), "err", err)
+			bundleDiscardedCounter.Inc(1)
+			continue
+		}
+		for _, tx := range c.bundle.Txs {
+			committed[tx.Hash()] = struct{}{}
+		}
+		bundleIncludedCounter.Inc(1)
+		log.Debug("Committed bundle", "number", c.bundle.BlockNumber, "txs", len(c.bundle.Txs), "payment", c.payment)
 	}
-	return nil, errors.New("error finding transaction")
+	return false
 }
 
-func (w *worker) commitTransactions(env *environment, txs *types.TransactionsByPriceAndNonce, interrupt *int32) bool {
-	gasLimit := env.header.GasLimit
-	if env.gasPool == nil {
-		env.gasPool = new(GasPool).AddGas(gasLimit())
+// simulateBundle applies the bundle on a copy of the sealing environment and
+// returns the payment it makes to the coinbase. The copy is discarded afterwards.
+func (w *worker) simulateBundle(env *environment, coinbase common.InternalAddress, bundle *Bundle) (*big.Int, error) {
+	sim := env.copy(true)
+	sim.simulation = true
+	defer sim.discard()
+
+	before := sim.state.GetBalance(coinbase)
+	if err := w.applyBundle(sim, bundle); err != nil {
+		return nil, err
 	}
-	var coalescedLogs []*types.Log
+	return new(big.Int).Sub(sim.state.GetBalance(coinbase), before), nil
+}
 
-	for {
-		// In the following three cases, we will interrupt the execution of the transaction.
-		// (1) new head block event arrival, the interrupt signal is 1
-		// (2) worker start or restart, the interrupt signal is 1
-		// (3) worker recreate the sealing block with any newly arrived transactions, the interrupt signal is 2.
-		// For the first two cases, the semi-finished work will be discarded.
-		// For the third case, the semi-finished work will be submitted to the consensus engine.
-		if interrupt != nil && atomic.LoadInt32(interrupt) != commitInterruptNone {
-			// Notify resubmit loop to increase resubmitting interval due to too frequent commits.
-			if atomic.LoadInt32(interrupt) == commitInterruptResubmit {
-				ratio := float64(gasLimit()-env.gasPool.Gas()) / float64(gasLimit())
-				if ratio < 0.1 {
-					ratio = 0.1
-				}
-				w.resubmitAdjustCh <- &intervalAdjust{
-					ratio: ratio,
-					inc:   true,
-				}
+// commitBundle applies the transactions of the bundle in order to the sealing
+// environment. If any of them fails or reverts, the environment is rolled back
+// to its state before the bundle.
+func (w *worker) commitBundle(env *environment, bundle *Bundle) error {
+	// The state is finalised after every transaction, so it can't be reverted to
+	// a snapshot across the bundle, keep a copy to roll back to instead
+	var (
+		statedb         = env.state.Copy()
+		gasPool         = *env.gasPool
+		gasUsed         = env.header.GasUsed()
+		txs             = len(env.txs)
+		etxs            = len(env.etxs)
+		receipts        = len(env.receipts)
+		tcount          = env.tcount
+		reverted        = env.reverted
+		externalGasUsed = env.externalGasUsed
+		etxGas          = env.etxGas
+		size            = env.size
+		etxRLimit       = env.etxRLimit
+		etxPLimit       = env.etxPLimit
+	)
+	if err := w.applyBundle(env, bundle); err != nil {
+		env.state.StopPrefetcher()
+		env.state = statedb
+		*env.gasPool = gasPool
+		env.header.SetGasUsed(gasUsed)
+		env.txs, env.etxs, env.receipts = env.txs[:txs], env.etxs[:etxs], env.receipts[:receipts]
+		env.tcount, env.reverted, env.externalGasUsed, env.etxGas, env.size = tcount, reverted, externalGasUsed, etxGas, size
+		env.etxRLimit, env.etxPLimit = etxRLimit, etxPLimit
+		return err
+	}
+	if !env.simulation {
+		for _, tx := range bundle.Txs {
+			txCommittedCounter.Inc(1)
+			if tx.Type() == types.SponsoredTxType {
+				txSponsoredCounter.Inc(1)
 			}
-			return atomic.LoadInt32(interrupt) == commitInterruptNewHead
 		}
-		// If we don't have enough gas for any further transactions then we're done
-		if env.gasPool.Gas() < params.TxGas {
-			log.Trace("Not enough gas for further transactions", "have", env.gasPool, "want", params.TxGas)
-			break
+	}
+	return nil
+}
+
+// applyBundle applies the transactions of the bundle in order to the sealing
+// environment, stopping at the first one failing or reverting. The environment
+// is left as is on failure.
+func (w *worker) applyBundle(env *environment, bundle *Bundle) error {
+	for _, tx := range bundle.Txs {
+		env.state.Prepare(tx.Hash(), env.tcount)
+		_, err := w.commitTransaction(env, tx)
+		if err == nil && env.receipts[len(env.receipts)-1].Status != types.ReceiptStatusSuccessful {
+			err = fmt.Errorf("bundle transaction %x reverted", tx.Hash())
 		}
-		// Retrieve the next transaction and abort if all done
-		tx := txs.Peek()
-		if tx == nil {
-			break
+		if err != nil {
+			return err
 		}
-		// Error may be ignored here. The error has already been checked
-		// during transaction acceptance is the transaction pool.
-		//
-		// We use the signer regardless of the current hf.
-		from, _ := types.Sender(env.signer, tx)
-		// Start executing the transaction
-		env.state.Prepare(tx.Hash(), env.tcount)
-
-		logs, err := w.commitTransaction(env, tx)
-		switch {
-		case errors.Is(err, ErrGasLimitReached):
-			// Pop the current out-of-gas transaction without shifting in the next from the account
-			log.Trace("Gas limit exceeded for current block", "sender", from)
-			txs.PopNoSort()
-
-		case errors.Is(err, ErrEtxLimitReached):
-			// Pop the current transaction without shifting in the next from the account
-			log.Trace("Etx limit exceeded for current block", "sender", from)
-			txs.PopNoSort()
+		env.tcount++
+	}
+	return nil
+}
 
-		case errors.Is(err, ErrNonceTooLow):
-			// New head notification data race between the transaction pool and miner, shift
-			log.Trace("Skipping transaction with low nonce", "sender", from, "nonce", tx.Nonce())
-			txs.Shift(from.Bytes20(), false)
+// takePriorityTxs moves the pending transactions of the priority senders out of
+// the given pending set, returning them.
+func (w *worker) takePriorityTxs(pending map[common.AddressBytes]types.Transactions) map[common.AddressBytes]types.Transactions {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if len(w.prioritySenders) == 0 {
+		return nil
+	}
+	priority := make(map[common.AddressBytes]types.Transactions)
+	for addr := range w.prioritySenders {
+		if txs, ok := pending[addr]; ok {
+			priority[addr] = txs
+			delete(pending, addr)
+		}
+	}
+	return priority
+}
 
-		case errors.Is(err, ErrNonceTooHigh):
-			// Reorg notification data race between the transaction pool and miner, skip account =
-			log.Debug("Skipping account with high nonce", "sender", from, "nonce", tx.Nonce())
-			txs.PopNoSort()
+// commitPriorityTransactions commits the transactions of the priority senders,
+// using at most the configured priority gas cap of the block. It returns true
+// if the commit was interrupted, like commitTransactions.
+func (w *worker) commitPriorityTransactions(env *environment, priority map[common.AddressBytes]types.Transactions, interrupt *int32) bool {
+	var count int
+	for _, accTxs := range priority {
+		count += len(accTxs)
+	}
+	txs := w.txOrdering().OrderPending(env.signer, priority, env.header.BaseFee())
 
-		case errors.Is(err, nil):
-			// Everything ok, collect the logs and shift in the next transaction from the same account
-			coalescedLogs = append(coalescedLogs, logs...)
-			env.tcount++
-			txs.PopNoSort()
+	if env.gasPool == nil {
+		env.gasPool = new(GasPool).AddGas(w.availableGas(env.header.GasLimit()))
+	}
+	gasCap := w.config.PriorityGasCap
+	if gasCap == 0 || gasCap >= env.gasPool.Gas() {
+		return w.commitTransactions(env, txs, count*commitAttemptsFactor, interrupt)
+	}
+	// Restrict the gas pool to the cap, handing back the gas set aside afterwards
+	withheld := env.gasPool.Gas() - gasCap
+	env.gasPool = new(GasPool).AddGas(gasCap)
+	defer env.gasPool.AddGas(withheld)
 
-		case errors.Is(err, ErrTxTypeNotSupported):
-			// Pop the unsupported transaction without shifting in the next from the account
-			log.Error("Skipping unsupported transaction type", "sender", from, "type", tx.Type())
-			txs.PopNoSort()
+	return w.commitTransactions(env, txs, count*commitAttemptsFactor, interrupt)
+}
 
-		case strings.Contains(err.Error(), "emits too many cross"): // This is ErrEtxLimitReached with more info
-			// Pop the unsupported transaction without shifting in the next from the account
-			log.Trace("Etx limit exceeded for current block", "sender", from, "err", err)
-			txs.PopNoSort()
+// isTransientCommitError reports whether a transaction which failed to commit
+// with the given error may succeed in a later cycle as is, because it hit a per
+// block limit or raced with the pool on the sender balance. The transactions left
+// out of a full block or behind a nonce gap are still pending, so the next cycle
+// picks them up anyway.
+func isTransientCommitError(err error) bool {
+	return errors.Is(err, ErrEtxLimitReached) || errors.Is(err, ErrInsufficientFunds)
+}
 
-		default:
-			// Strange error, discard the transaction and get the next in line (note, the
-			// nonce-too-high clause will prevent us from executing in vain).
-			log.Debug("Transaction failed, account skipped", "hash", tx.Hash(), "err", err)
-			txs.Shift(from.Bytes20(), false)
-		}
+// addRetryTxs records the given transactions to be retried in the next cycle.
+func (w *worker) addRetryTxs(txs []*types.Transaction) {
+	if len(txs) == 0 {
+		return
 	}
+	w.retryMu.Lock()
+	defer w.retryMu.Unlock()
+	if w.retryTxs == nil {
+		w.retryTxs = make(map[common.Hash]*types.Transaction)
+	}
+	for _, tx := range txs {
+		w.retryTxs[tx.Hash()] = tx
+	}
+}
 
-	if !w.isRunning() && len(coalescedLogs) > 0 {
-		// We don't push the pendingLogsEvent while we are sealing. The reason is that
-		// when we are sealing, the worker will regenerate a sealing block every 3 seconds.
-		// In order to avoid pushing the repeated pendingLog, we disable the pending log pushing.
-
-		// make a copy, the state caches the logs and these logs get "upgraded" from pending to mined
-		// logs by filling in the block hash when the block was mined by the local miner. This can
-		// cause a race condition if a log was "upgraded" before the PendingLogsEvent is processed.
-		cpy := make([]*types.Log, len(coalescedLogs))
-		for i, l := range coalescedLogs {
-			cpy[i] = new(types.Log)
-			*cpy[i] = *l
+// takeRetryTxs empties the retry set, returning its transactions still in the
+// pool grouped by sender and sorted by nonce.
+func (w *worker) takeRetryTxs(signer types.Signer) map[common.AddressBytes]types.Transactions {
+	w.retryMu.Lock()
+	retryTxs := w.retryTxs
+	w.retryTxs = nil
+	w.retryMu.Unlock()
+
+	if len(retryTxs) == 0 {
+		return nil
+	}
+	retries := make(map[common.AddressBytes]types.Transactions)
+	for hash, tx := range retryTxs {
+		// Drop the transactions which left the pool since, included or evicted
+		if w.txPool != nil && w.txPool.Get(hash) == nil {
+			continue
 		}
-		w.pendingLogsFeed.Send(cpy)
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			continue
+		}
+		retries[from.Bytes20()] = append(retries[from.Bytes20()], tx)
 	}
-	return false
+	for _, txs := range retries {
+		sort.Sort(types.TxByNonce(txs))
+	}
+	return retries
 }
 
-// generateParams wraps various of settings for generating sealing task.
-type generateParams struct {
-	timestamp uint64         // The timstamp for sealing task
-	forceTime bool           // Flag whether the given timestamp is immutable or not
-	coinbase  common.Address // The fee recipient address for including transaction
+// speculatedTx is the outcome of the speculative execution of a transaction,
+// applied to the sealing state in place of executing the transaction again as
+// long as the state it read didn't change in the meantime.
+type speculatedTx struct {
+	access   *txAccess
+	gasUsed  uint64
+	failed   bool
+	etxs     types.Transactions
+	logs     []*types.Log
+	contract *common.Address // Address of the contract created by the transaction, if any
 }
 
-// prepareWork constructs the sealing task according to the given parameters,
-// either based on the last chain head or specified parent. In this function
-// the pending transactions are not filled yet, only the empty task returned.
-func (w *worker) prepareWork(genParams *generateParams, block *types.Block) (*environment, error) {
-	w.mu.RLock()
-	defer w.mu.RUnlock()
-	nodeCtx := common.NodeLocation.Context()
+// speculateTransactions executes the pending transactions of each sender in
+// parallel on copies of the sealing state, and drops the ones bound to fail from
+// the pending set so the sequential pass doesn't have to execute them. The state
+// accessed by each sender is tracked, and the speculation of senders touching
+// state written by another one is discarded, leaving them to the sequential pass.
+// The outcome of the transactions executed is returned along with the pending
+// set, for the sequential pass to apply them rather than executing them again.
+func (w *worker) speculateTransactions(env *environment, pending map[common.AddressBytes]types.Transactions) (map[common.AddressBytes]types.Transactions, map[common.Hash]*speculatedTx) {
+	coinbase, err := env.coinbase.InternalAddress()
+	if err != nil {
+		parallelFallbackMeter.Mark(int64(len(pending)))
+		return pending, nil
+	}
+	vmConfig := env.vmConfig
+	if vmConfig == nil {
+		vmConfig = w.hc.bc.processor.GetVMConfig()
+	}
+	gas := w.availableGas(env.header.GasLimit())
+	if env.gasPool != nil {
+		gas = env.gasPool.Gas()
+	}
 
-	// Find the parent block for sealing task
-	parent := block
-	// Sanity check the timestamp correctness, recap the timestamp
-	// to parent+1 if the mutation is allowed.
-	timestamp := genParams.timestamp
-	if parent.Time() >= timestamp {
-		if genParams.forceTime {
-			return nil, fmt.Errorf("invalid timestamp, parent %d given %d", parent.Time(), timestamp)
+	type job struct {
+		sender common.AddressBytes
+		txs    types.Transactions
+	}
+	type speculation struct {
+		txs     types.Transactions
+		tracker *accessTracker
+	}
+	result := make(map[common.AddressBytes]types.Transactions, len(pending))
+	jobs := make(chan job, len(pending))
+	for sender, txs := range pending {
+		// External transactions are applied differently, leave them to the sequential pass
+		external := false
+		for _, tx := range txs {
+			if tx.Type() == types.ExternalTxType {
+				external = true
+				break
+			}
 		}
-		timestamp = parent.Time() + 1
+		if external {
+			result[sender] = txs
+			continue
+		}
+		jobs <- job{sender: sender, txs: txs}
 	}
-	// Construct the sealing block header, set the extra field if it's allowed
-	num := parent.Number()
-	header := types.EmptyHeader()
-	header.SetParentHash(block.Header().Hash())
-	header.SetNumber(big.NewInt(int64(num.Uint64()) + 1))
-	header.SetTime(timestamp)
+	close(jobs)
 
-	// Only calculate entropy if the parent is not the genesis block
-	if parent.Hash() != w.hc.config.GenesisHash {
-		_, order, err := w.engine.CalcOrder(parent.Header())
-		if err != nil {
-			return nil, err
+	blockContext := env.blockContext
+	if blockContext == nil {
+		blockContext = func() vm.BlockContext {
+			return NewEVMBlockContext(env.header, w.hc, &env.coinbase)
 		}
-		// Set the parent delta S prior to sending to sub
-		if nodeCtx != common.PRIME_CTX {
-			if order < nodeCtx {
-				header.SetParentDeltaS(big.NewInt(0), nodeCtx)
-			} else {
-				header.SetParentDeltaS(w.engine.DeltaLogS(parent.Header()), nodeCtx)
+	}
+	// Each thread executes its senders one after the other on its own copy of the
+	// sealing state. A sender reading the state written by a previous one is seen
+	// as conflicting, and its outcome found stale when applied.
+	threads := w.config.ParallelPackingThreads
+	if threads > len(jobs) {
+		threads = len(jobs)
+	}
+	states := make([]*state.StateDB, threads)
+	for i := range states {
+		states[i] = env.state.Copy()
+	}
+	var (
+		wg           sync.WaitGroup
+		mu           sync.Mutex // Protects the speculations and their outcome
+		speculations = make(map[common.AddressBytes]*speculation, len(jobs))
+		speculated   = make(map[common.Hash]*speculatedTx)
+	)
+	for _, statedb := range states {
+		wg.Add(1)
+		go func(statedb *state.StateDB) {
+			defer wg.Done()
+			header := types.CopyHeader(env.header)
+			blockCtx := blockContext()
+			for j := range jobs {
+				tracker := newAccessTracker(statedb, coinbase)
+				txs, outcome := w.speculateSender(blockCtx, header, tracker, gas, env.etxRLimit, env.etxPLimit, j.txs, *vmConfig)
+
+				mu.Lock()
+				speculations[j.sender] = &speculation{txs: txs, tracker: tracker}
+				for hash, tx := range outcome {
+					speculated[hash] = tx
+				}
+				mu.Unlock()
 			}
-		}
-		header.SetParentEntropy(w.engine.TotalLogS(parent.Header()))
+		}(statedb)
 	}
+	wg.Wait()
 
-	// Only zone should calculate state
-	if nodeCtx == common.ZONE_CTX && w.hc.ProcessingState() {
-		header.SetExtra(w.extra)
-		header.SetBaseFee(misc.CalcBaseFee(w.chainConfig, parent.Header()))
-		if w.isRunning() {
-			if w.coinbase.Equal(common.ZeroAddr) {
-				log.Error("Refusing to mine without etherbase")
-				return nil, errors.New("refusing to mine without etherbase")
+	// Index the state written by each sender, and the state written by several
+	var (
+		writers = make(map[accessKey]common.AddressBytes)
+		shared  = make(map[accessKey]struct{})
+	)
+	for sender, spec := range speculations {
+		for key := range spec.tracker.writes {
+			if writer, ok := writers[key]; ok && writer != sender {
+				shared[key] = struct{}{}
 			}
-			header.SetCoinbase(w.coinbase)
+			writers[key] = sender
 		}
-
-		// Run the consensus preparation with the default or customized consensus engine.
-		if err := w.engine.Prepare(w.hc, header, block.Header()); err != nil {
-			log.Error("Failed to prepare header for sealing", "err", err)
-			return nil, err
+	}
+	conflicts := func(sender common.AddressBytes, tracker *accessTracker) bool {
+		if tracker.touchedCoinbase && len(speculations) > 1 {
+			return true
 		}
-		env, err := w.makeEnv(parent, header, w.coinbase)
-		if err != nil {
-			log.Error("Failed to create sealing context", "err", err)
-			return nil, err
+		for key := range tracker.writes {
+			if _, ok := shared[key]; ok {
+				return true
+			}
 		}
-		// Accumulate the uncles for the sealing work.
-		commitUncles := func(blocks map[common.Hash]*types.Block) {
-			for hash, uncle := range blocks {
-				env.uncleMu.RLock()
-				if len(env.uncles) == 2 {
-					env.uncleMu.RUnlock()
-					break
-				}
-				env.uncleMu.RUnlock()
-				if err := w.commitUncle(env, uncle.Header()); err != nil {
-					log.Trace("Possible uncle rejected", "hash", hash, "reason", err)
-				} else {
-					log.Debug("Committing new uncle to block", "hash", hash)
+		for key := range tracker.reads {
+			if _, ok := shared[key]; ok {
+				return true
+			}
+			if writer, ok := writers[key]; ok && writer != sender {
+				return true
+			}
+			// Storage also depends on the account being created or destroyed
+			if key.storage {
+				if writer, ok := writers[accessKey{addr: key.addr}]; ok && writer != sender {
+					return true
 				}
 			}
 		}
-		w.uncleMu.RLock()
-		// Prefer to locally generated uncle
-		commitUncles(w.localUncles)
-		commitUncles(w.remoteUncles)
-		w.uncleMu.RUnlock()
-		return env, nil
-	} else {
-		return &environment{header: header}, nil
+		return false
+	}
+	for sender, spec := range speculations {
+		if conflicts(sender, spec.tracker) {
+			parallelFallbackMeter.Mark(1)
+			result[sender] = pending[sender]
+			continue
+		}
+		if dropped := len(pending[sender]) - len(spec.txs); dropped > 0 {
+			parallelDroppedMeter.Mark(int64(dropped))
+		}
+		if len(spec.txs) > 0 {
+			result[sender] = spec.txs
+		}
+	}
+	return result, speculated
+}
+
+// applySpeculated applies the outcome of the speculative execution of a
+// transaction to the sealing state, which must hold the state the transaction
+// read, and returns its receipt. The gas and ETX limits are checked like the
+// transaction was executed.
+func (w *worker) applySpeculated(env *environment, tx *types.Transaction, spec *speculatedTx, usedGas *uint64) (*types.Receipt, error) {
+	coinbase, err := env.coinbase.InternalAddress()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.checkOutcome(env, tx, spec.gasUsed, spec.failed, spec.etxs); err != nil {
+		return nil, err
+	}
+	if err := env.gasPool.SubGas(tx.Gas()); err != nil {
+		return nil, err
+	}
+	env.gasPool.AddGas(tx.Gas() - spec.gasUsed)
+	if err := checkEtxLimits(tx, spec.etxs, &env.etxRLimit, &env.etxPLimit); err != nil {
+		env.gasPool.AddGas(spec.gasUsed)
+		return nil, err
+	}
+	spec.access.apply(env.state, coinbase)
+	for _, l := range spec.logs {
+		cpy := *l
+		env.state.AddLog(&cpy)
 	}
+	env.state.Finalise(true)
+
+	*usedGas += spec.gasUsed
 
+	blockHash := env.header.Hash()
+	receipt := &types.Receipt{Type: tx.Type(), CumulativeGasUsed: *usedGas, Etxs: spec.etxs}
+	if spec.failed {
+		receipt.Status = types.ReceiptStatusFailed
+	} else {
+		receipt.Status = types.ReceiptStatusSuccessful
+	}
+	receipt.TxHash = tx.Hash()
+	receipt.GasUsed = spec.gasUsed
+	if spec.contract != nil {
+		receipt.ContractAddress = *spec.contract
+	}
+	receipt.Logs = env.state.GetLogs(tx.Hash(), blockHash)
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	receipt.BlockHash = blockHash
+	receipt.BlockNumber = env.header.Number()
+	receipt.TransactionIndex = uint(env.state.TxIndex())
+	return receipt, nil
 }
 
-// fillTransactions retrieves the pending transactions from the txpool and fills them
-// into the given sealing block. The transaction selection and ordering strategy can
-// be customized with the plugin in the future.
-func (w *worker) fillTransactions(interrupt *int32, env *environment, block *types.Block) {
-	// Split the pending transactions into locals and remotes
-	// Fill the block with all available pending transactions.
-	etxSet := rawdb.ReadEtxSet(w.hc.bc.db, block.Hash(), block.NumberU64())
-	if etxSet == nil {
+// preexecuteChild speculatively executes the pending transactions in the
+// background on top of the given pending block and its final state. The
+// transactions found bound to fail are recorded, so that they can be pruned
+// when building the child of the block once it is sealed. The state is only
+// copied if no pre-execution is in flight and the block wasn't pre-executed yet.
+// The pre-execution is waited for when the worker is closed, and nothing is
+// started or recorded once it is.
+func (w *worker) preexecuteChild(parent *types.Block, statedb *state.StateDB, coinbase common.Address) {
+	if w.speculative.get(parent.Header().SealHash()) != nil {
 		return
 	}
-	etxSet.Update(types.Transactions{}, block.NumberU64()+1) // Prune any expired ETXs
-	pending, err := w.txPool.TxPoolPending(true, etxSet)
-	if err != nil {
+	select {
+	case <-w.exitCh:
 		return
+	default:
 	}
-	if len(pending) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(env.signer, pending, env.header.BaseFee(), true)
-		if w.commitTransactions(env, txs, interrupt) {
+	if !atomic.CompareAndSwapInt32(&w.preexecuting, 0, 1) {
+		return
+	}
+	statedb = statedb.Copy()
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer atomic.StoreInt32(&w.preexecuting, 0)
+
+		header := types.EmptyHeader()
+		header.SetParentHash(parent.Hash())
+		header.SetNumber(new(big.Int).Add(parent.Number(), common.Big1))
+		header.SetTime(parent.Time() + 1)
+		header.SetBaseFee(misc.CalcBaseFee(w.chainConfig, parent.Header()))
+		header.SetGasLimit(CalcGasLimit(parent.Header(), w.gasCeil()))
+		header.SetCoinbase(coinbase)
+
+		// The parent is not in the chain yet, so it's resolved from the pending
+		// block for the child to get the block context it will be executed with
+		chain := &pendingParentChain{ChainContext: w.hc, parent: parent.Header()}
+		env := &environment{
+			signer:   types.MakeSigner(w.chainConfig, header.Number()),
+			state:    statedb,
+			coinbase: coinbase,
+			header:   header,
+			blockContext: func() vm.BlockContext {
+				return NewEVMBlockContext(header, chain, &coinbase)
+			},
+		}
+		pending, err := w.txPool.TxPoolPending(true, nil)
+		if err != nil || len(pending) == 0 {
+			return
+		}
+		start := time.Now()
+		kept, _ := w.speculateTransactions(env, pending)
+
+		// Drop the outcome if the worker was closed in the mean time
+		select {
+		case <-w.exitCh:
 			return
+		default:
+		}
+
+		result := &speculativeResult{number: header.Number().Uint64(), failed: make(map[common.Hash]struct{})}
+		for sender, txs := range pending {
+			applied := make(map[common.Hash]struct{}, len(kept[sender]))
+			for _, tx := range kept[sender] {
+				applied[tx.Hash()] = struct{}{}
+			}
+			for _, tx := range txs {
+				if _, ok := applied[tx.Hash()]; !ok {
+					result.failed[tx.Hash()] = struct{}{}
+				}
+			}
+		}
+		w.speculative.add(parent.Header().SealHash(), result)
+		log.Debug("Pre-executed pending transactions on pending block", "number", parent.Number(), "sealhash", parent.Header().SealHash(),
+			"senders", len(pending), "failed", len(result.failed), "elapsed", common.PrettyDuration(time.Since(start)))
+	}()
+}
+
+// speculateSender applies the transactions of a single sender in nonce order on
+// the given state, returning the ones which are not bound to fail along with the
+// outcome of the ones whose effects can be applied to the sealing state as is.
+func (w *worker) speculateSender(blockCtx vm.BlockContext, header *types.Header, tracker *accessTracker, gas uint64, etxRLimit, etxPLimit int, txs types.Transactions, vmConfig vm.Config) (types.Transactions, map[common.Hash]*speculatedTx) {
+	var (
+		gasPool = new(GasPool).AddGas(gas)
+		signer  = types.MakeSigner(w.chainConfig, header.Number())
+		vmenv   = vm.NewEVM(blockCtx, vm.TxContext{}, tracker, w.chainConfig, vmConfig)
+		kept    = make(types.Transactions, 0, len(txs))
+		outcome = make(map[common.Hash]*speculatedTx, len(txs))
+	)
+	for i, tx := range txs {
+		tracker.Prepare(tx.Hash(), i)
+		tracker.beginTx()
+		snap := tracker.Snapshot()
+
+		var result *ExecutionResult
+		msg, err := tx.AsMessage(signer, header.BaseFee())
+		if err == nil && tx.Type() == types.SponsoredTxType && !w.chainConfig.IsSponsoredTx(header.Number()) {
+			err = ErrTxTypeNotSupported
+		}
+		if err == nil {
+			vmenv.Reset(NewEVMTxContext(msg), tracker)
+			if result, err = ApplyMessage(vmenv, msg, gasPool); err == nil {
+				if err = checkEtxLimits(tx, result.Etxs, &etxRLimit, &etxPLimit); err == nil {
+					tracker.Finalise(true)
+					if result.UsedGas == 0 && w.config.ExcludeZeroGasTxs {
+						err = errZeroGasUsed
+					}
+				}
+			}
+		}
+		if err != nil {
+			tracker.RevertToSnapshot(snap)
+		}
+		access := tracker.endTx()
+
+		switch {
+		case errors.Is(err, ErrGasLimitReached), errors.Is(err, ErrEtxLimitReached):
+			// The outcome depends on the other senders, leave the rest to the sequential pass
+			return append(kept, txs[i:]...), outcome
+
+		case errors.Is(err, ErrNonceTooLow):
+			// The sequential pass would shift past the stale transaction too
+			continue
+
+		case err != nil:
+			// The transaction and the following ones from the sender are bound to fail
+			return kept, outcome
 		}
+		if access.mergeable {
+			spec := &speculatedTx{
+				access:  access,
+				gasUsed: result.UsedGas,
+				failed:  result.Failed(),
+				etxs:    result.Etxs,
+				logs:    tracker.GetLogs(tx.Hash(), common.Hash{}),
+			}
+			if msg.To() == nil {
+				contract := crypto.CreateAddress(msg.From(), tx.Nonce(), tx.Data())
+				spec.contract = &contract
+			}
+			outcome[tx.Hash()] = spec
+		}
+		kept = append(kept, tx)
 	}
+	return kept, outcome
 }
 
-// fillTransactions retrieves the pending transactions from the txpool and fills them
-// into the given sealing block. The transaction selection and ordering strategy can
-// be customized with the plugin in the future.
+// emittedEtxs returns the number, the total gas and the size of the ETXs emitted
+// by a transaction. The ETXs of a failed transaction are dropped, so they don't
+// count against the ETX budget nor the size of the block.
+func emittedEtxs(etxs types.Transactions, failed bool) (int, uint64, common.StorageSize) {
+	if failed {
+		return 0, 0, 0
+	}
+	var (
+		gas  uint64
+		size common.StorageSize
+	)
+	for _, etx := range etxs {
+		gas += etx.Gas()
+		size += etx.Size()
+	}
+	return len(etxs), gas, size
+}
+
+// checkOutcome checks whether the outcome of a transaction lets it into the
+// block. It runs before the state changes of the transaction are finalised, so
+// that they can still be reverted.
+func (w *worker) checkOutcome(env *environment, tx *types.Transaction, gasUsed uint64, failed bool, etxs types.Transactions) error {
+	if gasUsed == 0 && w.config.ExcludeZeroGasTxs {
+		return errZeroGasUsed
+	}
+	count, gas, size := emittedEtxs(etxs, failed)
+	if err := w.checkEtxCap(env, count, gas); err != nil {
+		return err
+	}
+	if max := common.StorageSize(w.config.MaxBlockBytes); max > 0 && env.size+tx.Size()+size > max {
+		return fmt.Errorf("%w: have %v, want %v, limit %v", errBlockBytesReached, env.size, tx.Size()+size, max)
+	}
+	return nil
+}
+
+// checkEtxCap checks whether a transaction emitting the given number of ETXs of
+// the given total gas fits within the configured ETX budget of the block.
+func (w *worker) checkEtxCap(env *environment, count int, gas uint64) error {
+	if count == 0 {
+		return nil
+	}
+	w.mu.RLock()
+	countCap, gasCap := w.config.EtxCountCap, w.config.EtxGasCap
+	w.mu.RUnlock()
+
+	if countCap > 0 && uint64(len(env.etxs)+count) > countCap {
+		return fmt.Errorf("%w: etx count cap %d, have %d, want %d", ErrEtxLimitReached, countCap, len(env.etxs), count)
+	}
+	if gasCap > 0 && env.etxGas+gas > gasCap {
+		return fmt.Errorf("%w: etx gas cap %d, have %d, want %d", ErrEtxLimitReached, gasCap, env.etxGas, gas)
+	}
+	return nil
+}
+
+// setEtxCap sets the ETX budget of the sealing blocks, zero values lifting the
+// respective limit.
+func (w *worker) setEtxCap(count uint64, gas uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.config.EtxCountCap = count
+	w.config.EtxGasCap = gas
+}
+
+// validatePreparedHeader checks that the fields required for sealing were set on
+// the header after the consensus engine prepared it.
+func validatePreparedHeader(header *types.Header, parent *types.Header) error {
+	if number := header.Number(); number == nil || number.Cmp(new(big.Int).Add(parent.Number(), common.Big1)) != 0 {
+		return fmt.Errorf("invalid prepared header number: have %v, want %v", number, new(big.Int).Add(parent.Number(), common.Big1))
+	}
+	if difficulty := header.Difficulty(); difficulty == nil || difficulty.Sign() <= 0 {
+		return fmt.Errorf("invalid prepared header difficulty: %v", difficulty)
+	}
+	return nil
+}
+
+// adjustGasLimit sets the gas limit of the sealing block to the target computed
+// from the parent block and the configured gas ceiling.
 func (w *worker) adjustGasLimit(interrupt *int32, env *environment, parent *types.Block) {
-	env.header.SetGasLimit(CalcGasLimit(parent.Header(), w.config.GasCeil))
+	gasCeil := w.gasCeil()
+	gasLimit := CalcGasLimit(parent.Header(), gasCeil)
+	log.Debug("Adjusting sealing block gas limit", "number", env.header.Number(), "parentGasUsed", parent.GasUsed(),
+		"parentGasLimit", parent.GasLimit(), "gasCeil", gasCeil, "target", gasLimit)
+	gasLimitTargetGauge.Update(int64(gasLimit))
+	env.header.SetGasLimit(gasLimit)
 }
 
 // ComputeManifestHash given a header computes the manifest hash for the header
@@ -896,7 +4252,7 @@ func (w *worker) ComputeManifestHash(header *types.Header) common.Hash {
 		if nodeCtx == common.PRIME_CTX {
 			// Nothing to do for prime chain
 			manifest = types.BlockManifest{}
-		} else if w.engine.IsDomCoincident(w.hc, header) {
+		} else if w.getEngine().IsDomCoincident(w.hc, header) {
 			manifest = types.BlockManifest{header.Hash()}
 		} else {
 			parentManifest := rawdb.ReadManifest(w.workerDb, header.ParentHash())
@@ -911,20 +4267,41 @@ func (w *worker) ComputeManifestHash(header *types.Header) common.Hash {
 }
 
 func (w *worker) FinalizeAssemble(chain consensus.ChainHeaderReader, header *types.Header, parent *types.Block, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, etxs []*types.Transaction, subManifest types.BlockManifest, receipts []*types.Receipt) (*types.Block, error) {
+	block, err := w.assembleBlock(chain, header, parent, state, txs, uncles, etxs, subManifest, receipts)
+	if err != nil {
+		return nil, err
+	}
+	if w.hc.ProcessingState() {
+		w.AddPendingBlockBody(block.Header(), block.Body())
+	}
+	return block, nil
+}
+
+// assembleBlock finalizes and assembles the block like FinalizeAssemble, but
+// leaves the pending body cache untouched. It is used for simulations, whose
+// bodies must neither evict the real ones nor be persisted.
+func (w *worker) assembleBlock(chain consensus.ChainHeaderReader, header *types.Header, parent *types.Block, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, etxs []*types.Transaction, subManifest types.BlockManifest, receipts []*types.Receipt) (*types.Block, error) {
+	defer finalizeAssembleTimer.UpdateSince(time.Now())
 	nodeCtx := common.NodeLocation.Context()
-	block, err := w.engine.FinalizeAndAssemble(chain, header, state, txs, uncles, etxs, subManifest, receipts)
+	engine := w.getEngine()
+	block, err := engine.FinalizeAndAssemble(chain, header, state, txs, uncles, etxs, subManifest, receipts)
 	if err != nil {
 		return nil, err
 	}
+	if block == nil {
+		return nil, errNilBlock
+	}
 
 	manifestHash := w.ComputeManifestHash(parent.Header())
 
 	if w.hc.ProcessingState() {
 		block.Header().SetManifestHash(manifestHash)
-		if nodeCtx == common.ZONE_CTX {
+		if nodeCtx == common.ZONE_CTX && w.config.SkipEtxRollup {
+			block.Header().SetEtxRollupHash(common.Hash{})
+		} else if nodeCtx == common.ZONE_CTX {
 			// Compute and set etx rollup hash
 			var etxRollup types.Transactions
-			if w.engine.IsDomCoincident(w.hc, parent.Header()) {
+			if engine.IsDomCoincident(w.hc, parent.Header()) {
 				etxRollup = parent.ExtTransactions()
 			} else {
 				etxRollup, err = w.hc.CollectEtxRollup(parent)
@@ -936,8 +4313,6 @@ func (w *worker) FinalizeAssemble(chain consensus.ChainHeaderReader, header *typ
 			etxRollupHash := types.DeriveSha(etxRollup, trie.NewStackTrie(nil))
 			block.Header().SetEtxRollupHash(etxRollupHash)
 		}
-
-		w.AddPendingBlockBody(block.Header(), block.Body())
 	}
 
 	return block, nil
@@ -963,8 +4338,8 @@ func (w *worker) commit(env *environment, interval func(), update bool, start ti
 		select {
 		case w.taskCh <- &task{receipts: env.receipts, state: env.state, block: block, createdAt: time.Now()}:
 			env.uncleMu.RLock()
-			log.Info("Commit new sealing work", "number", block.Number(), "sealhash", block.Header().SealHash(),
-				"uncles", len(env.uncles), "txs", env.tcount, "etxs", len(block.ExtTransactions()),
+			log.Info("Commit new sealing work", "worker", w.config.Name, "number", block.Number(), "sealhash", block.Header().SealHash(), "coinbase", block.Coinbase(),
+				"uncles", len(env.uncles), "txs", env.tcount, "reverted", env.reverted, "etxs", len(block.ExtTransactions()),
 				"gas", block.GasUsed(), "fees", totalFees(block, env.receipts),
 				"elapsed", common.PrettyDuration(time.Since(start)))
 			env.uncleMu.RUnlock()
@@ -990,24 +4365,135 @@ func (w *worker) getPendingBlockBodyKey(header *types.Header) common.Hash {
 // AddPendingBlockBody adds an entry in the lru cache for the given pendingBodyKey
 // maps it to body.
 func (w *worker) AddPendingBlockBody(header *types.Header, body *types.Body) {
-	w.pendingBlockBody.ContainsOrAdd(w.getPendingBlockBodyKey(header), body)
+	key := w.getPendingBlockBodyKey(header)
+	entry := newPendingBodyEntry(body, header.NumberU64())
+	if ok, _ := w.pendingBlockBody.ContainsOrAdd(key, entry); !ok {
+		pendingBodyBytesGauge.Update(atomic.AddInt64(&w.pendingBodyBytes, int64(entry.size)))
+		w.persistPendingBodyEntry(key, entry)
+		w.enforcePendingBodyMaxBytes()
+	}
+}
+
+// addPendingBodyEntry adds the given entry to the pending block body cache,
+// replacing any existing one for the key, and persists it if requested.
+func (w *worker) addPendingBodyEntry(key common.Hash, entry *pendingBodyEntry, persist bool) {
+	w.pendingBlockBody.Remove(key)
+	w.pendingBlockBody.Add(key, entry)
+	pendingBodyBytesGauge.Update(atomic.AddInt64(&w.pendingBodyBytes, int64(entry.size)))
+	if persist {
+		w.persistPendingBodyEntry(key, entry)
+	}
+	w.enforcePendingBodyMaxBytes()
+}
+
+// enforcePendingBodyMaxBytes evicts the oldest pending block bodies until the
+// cache fits into the configured size limit.
+func (w *worker) enforcePendingBodyMaxBytes() {
+	if w.config.PendingBodyMaxBytes <= 0 {
+		return
+	}
+	for atomic.LoadInt64(&w.pendingBodyBytes) > int64(w.config.PendingBodyMaxBytes) {
+		if _, _, ok := w.pendingBlockBody.RemoveOldest(); !ok {
+			return
+		}
+	}
+}
+
+// PruneStalePendingBodies removes the cached pending block bodies assembled for
+// headers below the given number.
+func (w *worker) PruneStalePendingBodies(belowNumber uint64) {
+	var pruned int
+	for _, key := range w.pendingBlockBody.Keys() {
+		if value, exist := w.pendingBlockBody.Peek(key); exist && value.(*pendingBodyEntry).number < belowNumber {
+			w.pendingBlockBody.Remove(key)
+			pruned++
+		}
+	}
+	log.Debug("Pruned stale pending block bodies", "below", belowNumber, "pruned", pruned)
 }
 
 // GetPendingBlockBody gets the block body associated with the given header.
 func (w *worker) GetPendingBlockBody(header *types.Header) *types.Body {
 	key := w.getPendingBlockBodyKey(header)
-	body, ok := w.pendingBlockBody.Get(key)
+	entry, ok := w.pendingBlockBody.Get(key)
 	if ok {
-		return body.(*types.Body)
+		body := entry.(*pendingBodyEntry).body
+		if w.config.VerifyPendingBodies {
+			if err := verifyPendingBlockBody(header, body); err != nil {
+				pendingBodyCorruptMeter.Mark(1)
+				log.Error("Pending block body does not match header", "key", key, "hash", header.Hash(), "err", err)
+				return nil
+			}
+		}
+		pendingBodyHitMeter.Mark(1)
+		updatePendingBodyHitRate()
+		return body
+	}
+	pendingBodyMissMeter.Mark(1)
+	updatePendingBodyHitRate()
+	atomic.AddUint64(&w.pendingBodyMisses, 1)
+	// Only warn once per interval, reporting the misses accumulated in between
+	now, last := time.Now().UnixNano(), atomic.LoadInt64(&w.pendingBodyMissWarned)
+	if now-last >= int64(pendingBodyMissLogInterval) && atomic.CompareAndSwapInt64(&w.pendingBodyMissWarned, last, now) {
+		log.Warn("Pending block body not found", "key", key, "misses", atomic.SwapUint64(&w.pendingBodyMisses, 0))
+	} else {
+		log.Debug("Pending block body not found", "key", key)
+	}
+	return nil
+}
+
+// updatePendingBodyHitRate refreshes the pending block body cache hit rate gauge
+// from the lifetime hit and miss counts.
+func updatePendingBodyHitRate() {
+	hits, misses := pendingBodyHitMeter.Count(), pendingBodyMissMeter.Count()
+	if total := hits + misses; total > 0 {
+		pendingBodyHitRateGauge.Update(hits * 100 / total)
+	}
+}
+
+// verifyPendingBlockBody checks that the roots of the given body match the ones
+// committed to in the header.
+func verifyPendingBlockBody(header *types.Header, body *types.Body) error {
+	if body == nil {
+		return errors.New("body not available")
+	}
+	uncleHash, txHash, etxHash := pendingBodyRoots(body)
+	if uncleHash != header.UncleHash() {
+		return fmt.Errorf("uncle root hash mismatch: have %x, want %x", uncleHash, header.UncleHash())
+	}
+	if txHash != header.TxHash() {
+		return fmt.Errorf("transaction root hash mismatch: have %x, want %x", txHash, header.TxHash())
+	}
+	if etxHash != header.EtxHash() {
+		return fmt.Errorf("etx root hash mismatch: have %x, want %x", etxHash, header.EtxHash())
 	}
-	log.Warn("pending block body not found for header: ", key)
 	return nil
 }
 
+// pendingBodyRoots computes the uncle, transaction and etx roots of the body.
+func pendingBodyRoots(body *types.Body) (uncleHash, txHash, etxHash common.Hash) {
+	uncleHash = types.CalcUncleHash(body.Uncles)
+	txHash = types.DeriveSha(types.Transactions(body.Transactions), trie.NewStackTrie(nil))
+	etxHash = types.DeriveSha(types.Transactions(body.ExtTransactions), trie.NewStackTrie(nil))
+	return uncleHash, txHash, etxHash
+}
+
 func (w *worker) SubscribeAsyncPendingHeader(ch chan *types.Header) event.Subscription {
 	return w.scope.Track(w.asyncPhFeed.Subscribe(ch))
 }
 
+// SubscribeTxFailures registers a subscription for the transactions which failed
+// to be committed to the sealing block.
+func (w *worker) SubscribeTxFailures(ch chan<- TxFailureEvent) event.Subscription {
+	return w.scope.Track(w.txFailureFeed.Subscribe(ch))
+}
+
+// SubscribePendingInvalidated starts delivering an event whenever a new chain head
+// invalidates the pending block.
+func (w *worker) SubscribePendingInvalidated(ch chan<- PendingInvalidatedEvent) event.Subscription {
+	return w.scope.Track(w.pendingInvalidatedFeed.Subscribe(ch))
+}
+
 // copyReceipts makes a deep copy of the given receipts.
 func copyReceipts(receipts []*types.Receipt) []*types.Receipt {
 	result := make([]*types.Receipt, len(receipts))
@@ -1020,12 +4506,35 @@ func copyReceipts(receipts []*types.Receipt) []*types.Receipt {
 
 // totalFees computes total consumed miner fees in ETH. Block transactions and receipts have to have the same order.
 func totalFees(block *types.Block, receipts []*types.Receipt) *big.Float {
+	feesWei := totalFeesWei(block, receipts)
+	return new(big.Float).Quo(new(big.Float).SetInt(feesWei), new(big.Float).SetInt(big.NewInt(params.Ether)))
+}
+
+// totalFeesWei computes total consumed miner fees in wei.
+func totalFeesWei(block *types.Block, receipts []*types.Receipt) *big.Int {
 	feesWei := new(big.Int)
 	for i, tx := range block.Transactions() {
 		minerFee, _ := tx.EffectiveGasTip(block.BaseFee())
 		feesWei.Add(feesWei, new(big.Int).Mul(new(big.Int).SetUint64(receipts[i].GasUsed), minerFee))
 	}
-	return new(big.Float).Quo(new(big.Float).SetInt(feesWei), new(big.Float).SetInt(big.NewInt(params.Ether)))
+	return feesWei
+}
+
+// feeSummary computes the fee summary of the given block and its receipts.
+func feeSummary(block *types.Block, receipts []*types.Receipt) *FeeSummary {
+	burned := new(big.Int)
+	if baseFee := block.BaseFee(); baseFee != nil {
+		var gasUsed uint64
+		for _, receipt := range receipts {
+			gasUsed += receipt.GasUsed
+		}
+		burned.Mul(new(big.Int).SetUint64(gasUsed), baseFee)
+	}
+	return &FeeSummary{
+		TotalFees:     totalFeesWei(block, receipts),
+		BaseFeeBurned: burned,
+		TxCount:       len(block.Transactions()),
+	}
 }
 
 func (w *worker) CurrentInfo(header *types.Header) bool {
@@ -0,0 +1,3792 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mapset "github.com/deckarep/golang-set"
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/consensus"
+	"github.com/dominant-strategies/go-quai/consensus/misc"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+	"github.com/dominant-strategies/go-quai/core/state"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/core/vm"
+	"github.com/dominant-strategies/go-quai/ethdb"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/metrics"
+	"github.com/dominant-strategies/go-quai/params"
+	"github.com/dominant-strategies/go-quai/trie"
+	"github.com/naoina/toml"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// testSigner is the signer of the transactions of the test chain.
+	testSigner = types.LatestSigner(params.TestChainConfig)
+
+	// testBalance is the balance the test accounts are funded with.
+	testBalance = new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))
+
+	// testDifficulty is the difficulty of every block of the test chain.
+	testDifficulty = big.NewInt(1000)
+)
+
+// testEngine is a consensus engine sealing nothing, which orders the blocks of
+// the test chain as zone blocks on top of a prime genesis.
+type testEngine struct {
+	consensus.Engine
+}
+
+func (testEngine) CalcOrder(header *types.Header) (*big.Int, int, error) {
+	if header.NumberU64() == 0 {
+		return new(big.Int), common.PRIME_CTX, nil
+	}
+	return new(big.Int), common.ZONE_CTX, nil
+}
+
+func (testEngine) Author(header *types.Header) (common.Address, error) {
+	return header.Coinbase(), nil
+}
+
+func (testEngine) TotalLogS(header *types.Header) *big.Int { return new(big.Int) }
+
+func (testEngine) DeltaLogS(header *types.Header) *big.Int { return new(big.Int) }
+
+func (e testEngine) IsDomCoincident(chain consensus.ChainHeaderReader, header *types.Header) bool {
+	_, order, _ := e.CalcOrder(header)
+	return order < common.NodeLocation.Context()
+}
+
+func (testEngine) CalcDifficulty(chain consensus.ChainHeaderReader, parent *types.Header) *big.Int {
+	return new(big.Int).Set(parent.Difficulty())
+}
+
+func (e testEngine) Prepare(chain consensus.ChainHeaderReader, header *types.Header, parent *types.Header) error {
+	header.SetDifficulty(e.CalcDifficulty(chain, parent))
+	return nil
+}
+
+func (testEngine) Finalize(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header) {
+	header.SetRoot(state.IntermediateRoot(true))
+}
+
+func (e testEngine) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, etxs []*types.Transaction, manifest types.BlockManifest, receipts []*types.Receipt) (*types.Block, error) {
+	e.Finalize(chain, header, state, txs, uncles)
+	return types.NewBlock(header, txs, uncles, etxs, manifest, receipts, trie.NewStackTrie(nil)), nil
+}
+
+// testBackend is the chain a test worker seals on: a zone header chain processing
+// the state on top of a prime genesis, and a transaction pool following it.
+type testBackend struct {
+	db      ethdb.Database
+	config  *params.ChainConfig
+	chain   *HeaderChain
+	txPool  *TxPool
+	genesis *types.Block
+	head    *types.Block // Block funding the test accounts, the initial chain head
+}
+
+// newTestBackend creates a test chain made of the genesis and a head block which
+// funds the given accounts.
+func newTestBackend(t testing.TB, alloc map[common.InternalAddress]*big.Int) *testBackend {
+	t.Helper()
+	common.NodeLocation = common.Location{0, 0}
+
+	db := rawdb.NewMemoryDatabase()
+	config := *params.TestChainConfig
+	config.Location = common.NodeLocation
+	genesis := (&Genesis{Config: &config, GasLimit: params.MinGasLimit, Difficulty: testDifficulty}).MustCommit(db)
+	config.GenesisHash = genesis.Hash()
+	rawdb.WriteEtxSet(db, genesis.Hash(), 0, types.NewEtxSet())
+
+	statedb, err := state.New(genesis.Root(), state.NewDatabase(db), nil)
+	if err != nil {
+		t.Fatalf("failed to create genesis state: %v", err)
+	}
+	for addr, balance := range alloc {
+		statedb.SetBalance(addr, balance)
+	}
+	head := writeTestBlock(t, db, &config, genesis, statedb, nil)
+	rawdb.WriteCanonicalHash(db, head.Hash(), head.NumberU64())
+	rawdb.WriteHeadBlockHash(db, head.Hash())
+
+	hc, err := NewHeaderChain(db, testEngine{}, nil, nil, &config, &CacheConfig{TrieCleanLimit: 16, TrieDirtyLimit: 16}, nil, vm.Config{}, []common.Location{common.NodeLocation})
+	if err != nil {
+		t.Fatalf("failed to create header chain: %v", err)
+	}
+	txPool := NewTxPool(TxPoolConfig{}, &config, hc)
+	t.Cleanup(txPool.Stop)
+	hc.pool = txPool
+
+	return &testBackend{
+		db:      db,
+		config:  &config,
+		chain:   hc,
+		txPool:  txPool,
+		genesis: genesis,
+		head:    head,
+	}
+}
+
+// newBlock writes a block holding the given uncles on top of the given parent,
+// whose state is the parent one modified by the given function. The block doesn't
+// become the chain head.
+func (b *testBackend) newBlock(t testing.TB, parent *types.Block, modify func(*state.StateDB), uncles ...*types.Header) *types.Block {
+	t.Helper()
+	statedb, err := b.chain.StateAt(parent.Root())
+	if err != nil {
+		t.Fatalf("failed to retrieve parent state: %v", err)
+	}
+	if modify != nil {
+		modify(statedb)
+	}
+	return writeTestBlock(t, b.db, b.config, parent, statedb, uncles)
+}
+
+// setHead makes the given block the chain head.
+func (b *testBackend) setHead(t testing.TB, block *types.Block) {
+	t.Helper()
+	if err := b.chain.SetCurrentHeader(block.Header()); err != nil {
+		t.Fatalf("failed to set chain head: %v", err)
+	}
+}
+
+// writeTestBlock commits the given state and writes a block holding it and the
+// given uncles on top of the given parent.
+func writeTestBlock(t testing.TB, db ethdb.Database, config *params.ChainConfig, parent *types.Block, statedb *state.StateDB, uncles []*types.Header) *types.Block {
+	t.Helper()
+	root, err := statedb.Commit(true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := statedb.Database().TrieDB().Commit(root, false, nil); err != nil {
+		t.Fatalf("failed to write state: %v", err)
+	}
+	header := types.EmptyHeader()
+	header.SetParentHash(parent.Hash())
+	header.SetNumber(new(big.Int).Add(parent.Number(), common.Big1))
+	header.SetTime(parent.Time() + 10)
+	header.SetDifficulty(new(big.Int).Set(parent.Difficulty()))
+	header.SetGasLimit(parent.GasLimit())
+	header.SetBaseFee(misc.CalcBaseFee(config, parent.Header()))
+	header.SetRoot(root)
+	header.SetLocation(common.NodeLocation)
+
+	block := types.NewBlock(header, nil, uncles, nil, nil, nil, trie.NewStackTrie(nil))
+	rawdb.WriteBlock(db, block)
+	rawdb.WriteTermini(db, block.Hash(), types.EmptyTermini())
+	rawdb.WriteEtxSet(db, block.Hash(), block.NumberU64(), types.NewEtxSet())
+	return block
+}
+
+// newTestWorker creates a worker sealing on a new test chain, which funds the
+// given accounts. The worker is credited to an etherbase of its own unless the
+// config sets one, and is closed along with the test.
+func newTestWorker(t testing.TB, config *Config, alloc map[common.InternalAddress]*big.Int) (*worker, *testBackend) {
+	t.Helper()
+	backend := newTestBackend(t, alloc)
+	if config == nil {
+		config = &Config{}
+	}
+	if config.Etherbase.Equal(common.Address{}) {
+		config.Etherbase = common.HexToAddress("0x0000000000000000000000000000000000000001")
+	}
+	w := newWorker(config, backend.config, backend.db, testEngine{}, backend.chain, backend.txPool, nil, false, true)
+	t.Cleanup(w.close)
+	return w, backend
+}
+
+// newTestTx creates a transaction of the given sender sending value to the given
+// recipient.
+func newTestTx(t testing.TB, key *ecdsa.PrivateKey, nonce uint64, to common.InternalAddress, gas uint64, tip, feeCap *big.Int, value *big.Int) *types.Transaction {
+	t.Helper()
+	recipient := common.NewAddressFromData(&to)
+	tx, err := types.SignNewTx(key, testSigner, &types.InternalTx{
+		ChainID:   testSigner.ChainID(),
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gas,
+		To:        &recipient,
+		Value:     value,
+	})
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	return tx
+}
+
+// testAccount is an account funded on the test chain.
+type testAccount struct {
+	key  *ecdsa.PrivateKey
+	addr common.InternalAddress
+}
+
+// address returns the address of the account.
+func (a testAccount) address() common.Address {
+	return common.NewAddressFromData(&a.addr)
+}
+
+// transfer creates a plain transfer of the account to itself, paying the given
+// tip on top of the base fee.
+func (a testAccount) transfer(t testing.TB, nonce uint64, tip int64) *types.Transaction {
+	t.Helper()
+	feeCap := new(big.Int).Add(big.NewInt(tip), big.NewInt(10*params.GWei))
+	return newTestTx(t, a.key, nonce, a.addr, params.TxGas, big.NewInt(tip), feeCap, common.Big1)
+}
+
+// newTestAccounts creates the given number of accounts, along with the allocation
+// funding them on the test chain.
+func newTestAccounts(t testing.TB, n int) ([]testAccount, map[common.InternalAddress]*big.Int) {
+	t.Helper()
+	common.NodeLocation = common.Location{0, 0}
+
+	accounts := make([]testAccount, n)
+	alloc := make(map[common.InternalAddress]*big.Int, n)
+	for i := range accounts {
+		accounts[i].key, accounts[i].addr = zoneKey(t)
+		alloc[accounts[i].addr] = testBalance
+	}
+	return accounts, alloc
+}
+
+// addTxs adds the given transactions to the pool as local ones, which the tip
+// floor of the pool doesn't apply to.
+func (b *testBackend) addTxs(t testing.TB, txs ...*types.Transaction) {
+	t.Helper()
+	for i, err := range b.txPool.AddLocals(txs) {
+		if err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+}
+
+// generatePending generates the pending block on top of the chain head, filled
+// with the pool transactions.
+func generatePending(t testing.TB, w *worker, b *testBackend) *types.Block {
+	t.Helper()
+	if _, err := w.GeneratePendingHeader(b.chain.CurrentBlock(), true); err != nil {
+		t.Fatalf("failed to generate pending header: %v", err)
+	}
+	return w.pending()
+}
+
+// newTestEnv prepares a sealing environment of the worker on top of the given
+// parent, with the gas of the block available to pack transactions.
+func newTestEnv(t testing.TB, w *worker, parent *types.Block) *environment {
+	t.Helper()
+	env, err := w.prepareWork(&generateParams{}, parent)
+	if err != nil {
+		t.Fatalf("failed to prepare sealing environment: %v", err)
+	}
+	t.Cleanup(env.release)
+	w.adjustGasLimit(nil, env, parent)
+	env.gasPool = new(GasPool).AddGas(env.header.GasLimit())
+	return env
+}
+
+// pendingBodyTestHeader creates a header of the given number along with the body
+// holding the given uncles.
+func pendingBodyTestHeader(number int64, uncles []*types.Header) (*types.Header, *types.Body) {
+	body := &types.Body{Uncles: uncles}
+	header := types.EmptyHeader()
+	header.SetNumber(big.NewInt(number))
+	header.SetUncleHash(types.CalcUncleHash(uncles))
+	return header, body
+}
+
+// newPendingBodyTestWorker creates a worker managing the pending block bodies
+// persisted in the given db, without any chain.
+func newPendingBodyTestWorker(db ethdb.Database) *worker {
+	w := &worker{
+		config:             &Config{},
+		workerDb:           db,
+		exitCh:             make(chan struct{}),
+		pendingBodyStoreCh: make(chan pendingBodyOp, pendingBodyStoreChanSize),
+	}
+	w.pendingBlockBody = w.newPendingBodyCache()
+	w.wg.Add(1)
+	go w.pendingBodyStoreLoop()
+	return w
+}
+
+// copyPending copies a pending set, which the packing consumes.
+func copyPending(pending map[common.AddressBytes]types.Transactions) map[common.AddressBytes]types.Transactions {
+	cpy := make(map[common.AddressBytes]types.Transactions, len(pending))
+	for sender, txs := range pending {
+		cpy[sender] = append(types.Transactions(nil), txs...)
+	}
+	return cpy
+}
+
+// storeValueCode stores the call value in the first storage slot.
+var storeValueCode = []byte{
+	byte(vm.CALLVALUE), byte(vm.PUSH1), 0x00, byte(vm.SSTORE), byte(vm.STOP),
+}
+
+// newParallelTestWorker creates a test worker packing on the given number of
+// threads, along with the pending transactions of the given number of senders.
+// Every sender alternates sending value to a recipient of its own and calling a
+// contract of its own storing the value it's sent, but for the ones of the last
+// pair which call the same contract and so conflict. The contracts are deployed
+// in a new chain head.
+func newParallelTestWorker(t testing.TB, threads int, senders int, txsPerSender int) (*worker, *testBackend, map[common.AddressBytes]types.Transactions) {
+	t.Helper()
+	accounts, alloc := newTestAccounts(t, senders)
+	w, b := newTestWorker(t, &Config{ParallelPackingThreads: threads}, alloc)
+
+	contracts := make([]common.InternalAddress, senders)
+	for i := range contracts {
+		_, contracts[i] = zoneKey(t)
+	}
+	if senders > 1 {
+		contracts[senders-1] = contracts[senders-2]
+	}
+	b.head = b.newBlock(t, b.head, func(statedb *state.StateDB) {
+		for _, contract := range contracts {
+			statedb.SetCode(contract, storeValueCode)
+		}
+	})
+	b.setHead(t, b.head)
+
+	pending := make(map[common.AddressBytes]types.Transactions, senders)
+	for i, account := range accounts {
+		_, recipient := zoneKey(t)
+		tip := big.NewInt(int64(i+1) * params.GWei)
+		feeCap := new(big.Int).Add(tip, big.NewInt(10*params.GWei))
+
+		txs := make(types.Transactions, txsPerSender)
+		for nonce := range txs {
+			if nonce%2 == 0 {
+				txs[nonce] = newTestTx(t, account.key, uint64(nonce), recipient, params.TxGas, tip, feeCap, common.Big1)
+			} else {
+				txs[nonce] = newTestTx(t, account.key, uint64(nonce), contracts[i], 100000, tip, feeCap, big.NewInt(int64(nonce)+1))
+			}
+		}
+		pending[account.address().Bytes20()] = txs
+	}
+	return w, b, pending
+}
+
+// txSenders returns the senders of the given transactions.
+func txSenders(t testing.TB, txs types.Transactions) []common.Address {
+	t.Helper()
+	senders := make([]common.Address, len(txs))
+	for i, tx := range txs {
+		from, err := types.Sender(testSigner, tx)
+		if err != nil {
+			t.Fatalf("failed to recover sender of transaction %d: %v", i, err)
+		}
+		senders[i] = from
+	}
+	return senders
+}
+
+// testPending groups the given transactions by sender, in the shape of the pool
+// pending set.
+func testPending(t testing.TB, txs ...*types.Transaction) map[common.AddressBytes]types.Transactions {
+	t.Helper()
+	pending := make(map[common.AddressBytes]types.Transactions)
+	for i, from := range txSenders(t, txs) {
+		pending[from.Bytes20()] = append(pending[from.Bytes20()], txs[i])
+	}
+	return pending
+}
+
+func TestResimulatePendingPreconditions(t *testing.T) {
+	coinbase := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	tests := []struct {
+		location common.Location
+		coinbase common.Address
+		baseFee  *big.Int
+	}{
+		{common.Location{0, 1}, coinbase, big.NewInt(1)}, // state not processed
+		{common.Location{0, 0}, coinbase, nil},           // no base fee
+		{common.Location{0, 0}, common.ZeroAddr, big.NewInt(1)},
+	}
+	for i, tt := range tests {
+		common.NodeLocation = common.Location{0, 0}
+		w := &worker{
+			config: &Config{},
+			hc:     &HeaderChain{bc: &BodyDb{slicesRunning: []common.Location{tt.location}}},
+		}
+		w.setEtherbase(tt.coinbase)
+		if block, _, err := w.ResimulatePending(tt.baseFee); err == nil || block != nil {
+			t.Errorf("test %d: pending resimulated: have block %v, err %v", i, block, err)
+		}
+		if w.current != nil {
+			t.Errorf("test %d: current environment modified", i)
+		}
+	}
+}
+
+// Tests that the pending block is resimulated under the given base fee, so that
+// a transaction whose fee cap lies between two base fees is only included under
+// the lower one, and that it's assembled like the pending block.
+func TestResimulatePendingBaseFee(t *testing.T) {
+	key, sender := zoneKey(t)
+	_, recipient := zoneKey(t)
+	w, b := newTestWorker(t, nil, map[common.InternalAddress]*big.Int{sender: testBalance})
+
+	tx := newTestTx(t, key, 0, recipient, params.TxGas, big.NewInt(params.GWei), big.NewInt(2*params.GWei), common.Big1)
+	if err := b.txPool.AddLocal(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	pending, err := w.GeneratePendingHeader(b.head, false)
+	if err != nil {
+		t.Fatalf("failed to generate pending header: %v", err)
+	}
+	tests := []struct {
+		baseFee  *big.Int
+		included bool
+	}{
+		{big.NewInt(params.GWei), true},
+		{big.NewInt(3 * params.GWei), false},
+	}
+	cached, cachedBytes := w.pendingBlockBody.Len(), atomic.LoadInt64(&w.pendingBodyBytes)
+	for i, tt := range tests {
+		block, receipts, err := w.ResimulatePending(tt.baseFee)
+		if err != nil {
+			t.Fatalf("test %d: failed to resimulate: %v", i, err)
+		}
+		if block.BaseFee().Cmp(tt.baseFee) != 0 {
+			t.Errorf("test %d: base fee mismatch: have %v, want %v", i, block.BaseFee(), tt.baseFee)
+		}
+		if included := len(block.Transactions()) == 1 && block.Transactions()[0].Hash() == tx.Hash(); included != tt.included {
+			t.Errorf("test %d: transaction inclusion mismatch: have %v, want %v", i, included, tt.included)
+		}
+		if len(receipts) != len(block.Transactions()) {
+			t.Errorf("test %d: receipt count mismatch: have %d, want %d", i, len(receipts), len(block.Transactions()))
+		}
+		if block.ManifestHash() != pending.ManifestHash() {
+			t.Errorf("test %d: manifest hash mismatch: have %x, want %x", i, block.ManifestHash(), pending.ManifestHash())
+		}
+		if block.EtxRollupHash() != pending.EtxRollupHash() {
+			t.Errorf("test %d: etx rollup hash mismatch: have %x, want %x", i, block.EtxRollupHash(), pending.EtxRollupHash())
+		}
+		if have, haveBytes := w.pendingBlockBody.Len(), atomic.LoadInt64(&w.pendingBodyBytes); have != cached || haveBytes != cachedBytes {
+			t.Errorf("test %d: resimulated body cached: have %d bodies (%d bytes), want %d (%d bytes)", i, have, haveBytes, cached, cachedBytes)
+		}
+	}
+}
+
+// Tests that only the transactions of the allowed senders are included in
+// allowlist mode, and that an empty allowlist leaves the block empty.
+func TestAllowlistMode(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 3)
+	w, b := newTestWorker(t, &Config{AllowlistMode: true}, alloc)
+	for _, account := range accounts {
+		b.addTxs(t, account.transfer(t, 0, params.GWei), account.transfer(t, 1, params.GWei))
+	}
+	allowed := accounts[0].address()
+	w.setAllowedSenders([]common.Address{allowed})
+
+	block := generatePending(t, w, b)
+	if len(block.Transactions()) != 2 {
+		t.Fatalf("included transactions mismatch: have %d, want %d", len(block.Transactions()), 2)
+	}
+	for i, from := range txSenders(t, block.Transactions()) {
+		if !from.Equal(allowed) {
+			t.Errorf("transaction %d from unlisted sender %v included", i, from)
+		}
+	}
+	// No transaction is included with an empty allowlist
+	w.setAllowedSenders(nil)
+	if block := generatePending(t, w, b); len(block.Transactions()) != 0 {
+		t.Errorf("included transactions mismatch: have %d, want %d", len(block.Transactions()), 0)
+	}
+	// All the senders are included with the mode disabled
+	w.mu.Lock()
+	w.config.AllowlistMode = false
+	w.mu.Unlock()
+	if block := generatePending(t, w, b); len(block.Transactions()) != 6 {
+		t.Errorf("included transactions mismatch: have %d, want %d", len(block.Transactions()), 6)
+	}
+}
+
+// Tests that the gas limit of the sealing block moves towards the ceiling of the
+// node context, and that the target is reported.
+func TestAdjustGasLimit(t *testing.T) {
+	defer func(old metrics.Gauge) { gasLimitTargetGauge = old }(gasLimitTargetGauge)
+	gasLimitTargetGauge = new(metrics.StandardGauge)
+
+	common.NodeLocation = common.Location{0, 0}
+	header := types.EmptyHeader()
+	header.SetNumber(big.NewInt(1))
+	header.SetGasLimit(10240000)
+	header.SetGasUsed(9000000)
+	parent := types.NewBlockWithHeader(header)
+
+	w := &worker{config: &Config{GasCeil: 80000000}}
+	env := &environment{header: types.EmptyHeader()}
+	w.adjustGasLimit(nil, env, parent)
+	if have, want := env.header.GasLimit(), uint64(10240000+9999); have != want {
+		t.Errorf("gas limit mismatch: have %d, want %d", have, want)
+	}
+	if have, want := gasLimitTargetGauge.Value(), int64(10240000+9999); have != want {
+		t.Errorf("reported target mismatch: have %d, want %d", have, want)
+	}
+	// The ceiling of the context caps the raise
+	w.config.GasCeilByContext = map[int]uint64{common.ZONE_CTX: 40000000}
+	w.adjustGasLimit(nil, env, parent)
+	if have, want := env.header.GasLimit(), uint64(10000000); have != want {
+		t.Errorf("capped gas limit mismatch: have %d, want %d", have, want)
+	}
+	if have, want := gasLimitTargetGauge.Value(), int64(10000000); have != want {
+		t.Errorf("capped reported target mismatch: have %d, want %d", have, want)
+	}
+}
+
+// Tests that the pending header carries the reported gas limit target.
+func TestPendingGasLimitTarget(t *testing.T) {
+	defer func(old metrics.Gauge) { gasLimitTargetGauge = old }(gasLimitTargetGauge)
+	gasLimitTargetGauge = new(metrics.StandardGauge)
+
+	w, b := newTestWorker(t, &Config{GasCeil: 80000000}, nil)
+	header, err := w.GeneratePendingHeader(b.head, false)
+	if err != nil {
+		t.Fatalf("failed to generate pending header: %v", err)
+	}
+	want := CalcGasLimit(b.head.Header(), 80000000)
+	if header.GasLimit() != want {
+		t.Errorf("gas limit mismatch: have %d, want %d", header.GasLimit(), want)
+	}
+	if have := gasLimitTargetGauge.Value(); have != int64(want) {
+		t.Errorf("reported target mismatch: have %d, want %d", have, want)
+	}
+}
+
+// Tests that the etherbase can be swapped while the sealing work reads it.
+func TestEtherbaseConcurrentUpdate(t *testing.T) {
+	w, _ := newTestWorker(t, nil, nil)
+	addrs := []common.Address{
+		common.HexToAddress("0x0000000000000000000000000000000000000001"),
+		common.HexToAddress("0x0000000000000000000000000000000000000002"),
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			w.setEtherbase(addrs[i%2])
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			if coinbase := w.etherbase(); !coinbase.Equal(addrs[0]) && !coinbase.Equal(addrs[1]) {
+				t.Errorf("unexpected etherbase %v", coinbase)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+}
+
+func TestSpeculativeHeaderWithoutEtherbase(t *testing.T) {
+	w, b := newTestWorker(t, nil, nil)
+	w.setEtherbase(common.ZeroAddr)
+	if _, err := w.GenerateSpeculativeHeader(b.head, false); err == nil {
+		t.Fatalf("speculative header generated without etherbase")
+	}
+}
+
+// Tests that a speculative header is assembled like the pending one on the same
+// parent, without replacing the pending snapshot.
+func TestSpeculativeHeaderAssembly(t *testing.T) {
+	w, b := newTestWorker(t, nil, nil)
+	pending, err := w.GeneratePendingHeader(b.head, false)
+	if err != nil {
+		t.Fatalf("failed to generate pending header: %v", err)
+	}
+	snapshot := w.pending()
+
+	header, err := w.GenerateSpeculativeHeader(b.head, false)
+	if err != nil {
+		t.Fatalf("failed to generate speculative header: %v", err)
+	}
+	if header.ManifestHash() != pending.ManifestHash() {
+		t.Errorf("manifest hash mismatch: have %x, want %x", header.ManifestHash(), pending.ManifestHash())
+	}
+	if header.EtxRollupHash() != pending.EtxRollupHash() {
+		t.Errorf("etx rollup hash mismatch: have %x, want %x", header.EtxRollupHash(), pending.EtxRollupHash())
+	}
+	if w.GetPendingBlockBody(header) == nil {
+		t.Errorf("speculative block body not cached")
+	}
+	if w.pending() != snapshot {
+		t.Errorf("pending snapshot replaced by the speculative header")
+	}
+}
+
+// Tests that speculative headers are generated on top of side blocks, and that
+// the ancestors of the head within the reorg window are built on, the side blocks
+// aside.
+func TestSpeculativeHeaderOnSideBlock(t *testing.T) {
+	_, alloc := newTestAccounts(t, 1)
+	w, b := newTestWorker(t, nil, alloc)
+	side := b.newBlock(t, b.genesis, nil)
+	if side.Hash() == b.head.Hash() {
+		t.Fatalf("side block is the canonical head")
+	}
+	header, err := w.GenerateSpeculativeHeader(side, false)
+	if err != nil {
+		t.Fatalf("failed to generate speculative header: %v", err)
+	}
+	if header.ParentHash() != side.Hash() || header.NumberU64() != side.NumberU64()+1 {
+		t.Errorf("speculative header mismatch: have #%d on %x, want #%d on %x", header.NumberU64(), header.ParentHash(), side.NumberU64()+1, side.Hash())
+	}
+	// Only the canonical ancestors can be built on by hash
+	b.setHead(t, b.newBlock(t, b.head, nil))
+	if _, err := w.GeneratePendingHeaderOnAncestor(side.Hash(), false); !errors.Is(err, ErrAncestorOutsideReorgWindow) {
+		t.Errorf("side block error mismatch: have %v, want %v", err, ErrAncestorOutsideReorgWindow)
+	}
+	header, err = w.GeneratePendingHeaderOnAncestor(b.head.Hash(), false)
+	if err != nil {
+		t.Fatalf("failed to generate pending header on ancestor: %v", err)
+	}
+	if header.ParentHash() != b.head.Hash() || header.NumberU64() != b.head.NumberU64()+1 {
+		t.Errorf("ancestor header mismatch: have #%d on %x, want #%d on %x", header.NumberU64(), header.ParentHash(), b.head.NumberU64()+1, b.head.Hash())
+	}
+}
+
+// Tests that the snapshot is rebuilt on top of the given block, replacing the
+// current environment along with it.
+func TestRebuildSnapshot(t *testing.T) {
+	w, b := newTestWorker(t, nil, nil)
+	if _, err := w.GeneratePendingHeader(b.head, false); err != nil {
+		t.Fatalf("failed to generate pending header: %v", err)
+	}
+	if have := w.pending().ParentHash(); have != b.head.Hash() {
+		t.Fatalf("pending parent mismatch: have %x, want %x", have, b.head.Hash())
+	}
+	block := b.newBlock(t, b.head, nil)
+	b.setHead(t, block)
+	if err := w.RebuildSnapshot(block); err != nil {
+		t.Fatalf("failed to rebuild snapshot: %v", err)
+	}
+	pending := w.pending()
+	if pending.ParentHash() != block.Hash() {
+		t.Errorf("rebuilt parent mismatch: have %x, want %x", pending.ParentHash(), block.Hash())
+	}
+	if pending.NumberU64() != block.NumberU64()+1 {
+		t.Errorf("rebuilt number mismatch: have %d, want %d", pending.NumberU64(), block.NumberU64()+1)
+	}
+	w.currentMu.Lock()
+	current := w.current.header.ParentHash()
+	w.currentMu.Unlock()
+	if current != block.Hash() {
+		t.Errorf("current environment parent mismatch: have %x, want %x", current, block.Hash())
+	}
+	if err := w.RebuildSnapshot(nil); err == nil {
+		t.Errorf("snapshot rebuilt without a block")
+	}
+}
+
+// Tests that a coinbase selector stands in for a missing etherbase.
+func TestEtherbaseFromSelector(t *testing.T) {
+	w, _ := newTestWorker(t, nil, nil)
+	for _, missing := range []common.Address{{}, common.ZeroAddr} {
+		w.setEtherbase(missing)
+		if w.hasEtherbase() {
+			t.Fatalf("etherbase reported without an etherbase or a selector")
+		}
+	}
+	w.setCoinbaseSelector(func(*big.Int) common.Address {
+		return common.HexToAddress("0x0000000000000000000000000000000000000001")
+	})
+	if !w.hasEtherbase() {
+		t.Errorf("etherbase not reported with a coinbase selector")
+	}
+	w.setCoinbaseSelector(nil)
+	w.setEtherbase(common.HexToAddress("0x0000000000000000000000000000000000000002"))
+	if !w.hasEtherbase() {
+		t.Errorf("etherbase not reported with an etherbase")
+	}
+}
+
+// Tests that the coinbase validator rejects sealing work for disallowed coinbases,
+// whether the worker is running or not, and accepts it for approved ones.
+func TestCoinbaseValidator(t *testing.T) {
+	w, b := newTestWorker(t, nil, nil)
+	approved := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	rejected := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	errRejected := errors.New("coinbase not approved")
+	w.setCoinbaseValidator(func(addr common.Address) error {
+		if !addr.Equal(approved) {
+			return errRejected
+		}
+		return nil
+	})
+	for _, running := range []bool{false, true} {
+		if running {
+			w.start()
+		}
+		w.setEtherbase(rejected)
+		if _, err := w.prepareWork(&generateParams{}, b.head); !errors.Is(err, errRejected) {
+			t.Errorf("running %v: rejected coinbase error mismatch: have %v, want %v", running, err, errRejected)
+		}
+		w.setEtherbase(approved)
+		work, err := w.prepareWork(&generateParams{}, b.head)
+		if err != nil {
+			t.Fatalf("running %v: failed to prepare work with an approved coinbase: %v", running, err)
+		}
+		if running && !work.header.Coinbase().Equal(approved) {
+			t.Errorf("running %v: coinbase mismatch: have %x, want %x", running, work.header.Coinbase(), approved)
+		}
+		work.release()
+	}
+	w.pause()
+}
+
+// uncleVerifyingEngine is a test engine rejecting the headers of the given hashes.
+type uncleVerifyingEngine struct {
+	testEngine
+	invalid map[common.Hash]bool
+}
+
+func (e uncleVerifyingEngine) VerifyHeaders(chain consensus.ChainHeaderReader, headers []*types.Header) (chan<- struct{}, <-chan error) {
+	abort, results := make(chan struct{}), make(chan error, len(headers))
+	for _, header := range headers {
+		if e.invalid[header.Hash()] {
+			results <- errors.New("invalid header")
+		} else {
+			results <- nil
+		}
+	}
+	return abort, results
+}
+
+// Tests that the verified uncle candidates are recorded with the peer they came
+// from and their reward estimate, the locally mined ones first.
+func TestAddUncleCandidates(t *testing.T) {
+	defer func(old metrics.Counter) { uncleRejectedCounter = old }(uncleRejectedCounter)
+	uncleRejectedCounter = metrics.NewCounterForced()
+
+	w, _ := newTestWorker(t, nil, nil)
+	block := func(extra byte, difficulty int64) *types.Block {
+		header := uncleTestBlock(1, extra).Header()
+		header.SetDifficulty(big.NewInt(difficulty))
+		return types.NewBlockWithHeader(header)
+	}
+	fetched, mined, invalid, announced := block(1, 100), block(2, 200), block(3, 300), block(4, 400)
+	w.setEngine(uncleVerifyingEngine{invalid: map[common.Hash]bool{invalid.Hash(): true}})
+	w.isLocalBlock = func(header *types.Header) bool { return header.Hash() == mined.Hash() }
+
+	if added := w.AddUncleCandidates([]*types.Block{fetched, mined, invalid}, "peer-1"); added != 2 {
+		t.Fatalf("added candidates mismatch: have %d, want %d", added, 2)
+	}
+	if count := uncleRejectedCounter.Count(); count != 1 {
+		t.Errorf("rejected candidates mismatch: have %d, want %d", count, 1)
+	}
+	// Side blocks imported without a peer are attributed the one they were received from
+	time.Sleep(time.Millisecond)
+	w.RecordBlockPeer(announced.Hash(), "peer-2")
+	if added := w.AddUncleCandidates([]*types.Block{announced, fetched}, ""); added != 1 {
+		t.Fatalf("added candidates mismatch: have %d, want %d", added, 1)
+	}
+	candidates := w.UncleCandidates()
+	want := []struct {
+		block *types.Block
+		local bool
+		peer  string
+	}{
+		{mined, true, "peer-1"},
+		{fetched, false, "peer-1"},
+		{announced, false, "peer-2"},
+	}
+	if len(candidates) != len(want) {
+		t.Fatalf("candidates mismatch: have %d, want %d", len(candidates), len(want))
+	}
+	for i, candidate := range candidates {
+		if candidate.Hash != want[i].block.Hash() || candidate.Local != want[i].local || candidate.Peer != want[i].peer {
+			t.Errorf("candidate %d mismatch: have %x (local %v, peer %q), want %x (local %v, peer %q)",
+				i, candidate.Hash, candidate.Local, candidate.Peer, want[i].block.Hash(), want[i].local, want[i].peer)
+		}
+		reward := new(big.Int).Div(misc.CalculateReward(want[i].block.Header()), uncleInclusionRewardDivisor)
+		if reward.Sign() <= 0 || candidate.RewardEstimate.Cmp(reward) != 0 {
+			t.Errorf("candidate %d reward estimate mismatch: have %v, want %v", i, candidate.RewardEstimate, reward)
+		}
+	}
+}
+
+// Tests that the worker prunes the possible uncles with the configured retention
+// depths, which default to the stale threshold.
+func TestPruneStaleUncles(t *testing.T) {
+	w, _ := newTestWorker(t, &Config{LocalUncleRetention: 7, RemoteUncleRetention: 3}, nil)
+	local, remote := uncleTestBlock(1, 0), uncleTestBlock(1, 1)
+	w.uncles.add(local, true, "")
+	w.uncles.add(remote, false, "")
+
+	w.pruneStaleUncles(nil)
+	if have := len(w.uncles.blocks()); have != 2 {
+		t.Fatalf("retained candidates mismatch: have %d, want %d", have, 2)
+	}
+	w.pruneStaleUncles(uncleTestBlock(4, 2))
+	candidates := w.uncles.candidates()
+	if len(candidates) != 1 || candidates[0].Hash != local.Hash() {
+		t.Fatalf("retained candidates mismatch: have %v, want local %x", candidates, local.Hash())
+	}
+
+	w, _ = newTestWorker(t, nil, nil)
+	if w.config.LocalUncleRetention != staleThreshold || w.config.RemoteUncleRetention != staleThreshold {
+		t.Errorf("default retention mismatch: have %d/%d, want %d", w.config.LocalUncleRetention, w.config.RemoteUncleRetention, staleThreshold)
+	}
+}
+
+// Tests that the transactions failing to apply are counted as reverted, and that
+// the count of the last pending block is reported.
+func TestRevertedCount(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 1)
+	w, b := newTestWorker(t, nil, alloc)
+	env := newTestEnv(t, w, b.head)
+	txs := []*types.Transaction{accounts[0].transfer(t, 0, params.GWei), accounts[0].transfer(t, 1, params.GWei)}
+
+	if _, err := w.commitTransaction(env, txs[1]); !errors.Is(err, ErrNonceTooHigh) {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrNonceTooHigh)
+	}
+	if env.reverted != 1 {
+		t.Errorf("reverted transactions mismatch: have %d, want %d", env.reverted, 1)
+	}
+	for _, tx := range txs {
+		if _, err := w.commitTransaction(env, tx); err != nil {
+			t.Fatalf("failed to commit transaction: %v", err)
+		}
+	}
+	if env.reverted != 1 {
+		t.Errorf("reverted transactions mismatch after commits: have %d, want %d", env.reverted, 1)
+	}
+	if _, err := w.finalizePending(env, b.head); err != nil {
+		t.Fatalf("failed to finalize pending block: %v", err)
+	}
+	if have := w.LastRevertedCount(); have != 1 {
+		t.Errorf("reported reverted transactions mismatch: have %d, want %d", have, 1)
+	}
+}
+
+// recordingSigner is a signer counting the senders it recovers.
+type recordingSigner struct {
+	types.Signer
+	senders int32
+}
+
+func (s *recordingSigner) Sender(tx *types.Transaction) (common.Address, error) {
+	atomic.AddInt32(&s.senders, 1)
+	return s.Signer.Sender(tx)
+}
+
+func (s *recordingSigner) Equal(s2 types.Signer) bool {
+	return s == s2
+}
+
+// Tests that the signer override creates the signer of the sealing environments,
+// which recovers the senders of the committed transactions.
+func TestSignerOverride(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 1)
+	w, b := newTestWorker(t, nil, alloc)
+	b.addTxs(t, accounts[0].transfer(t, 0, params.GWei))
+
+	if env := newTestEnv(t, w, b.head); !env.signer.Equal(types.MakeSigner(b.config, env.header.Number())) {
+		t.Errorf("default signer mismatch")
+	}
+	signer := &recordingSigner{Signer: testSigner}
+	var number *big.Int
+	w.setSignerOverride(func(config *params.ChainConfig, blockNumber *big.Int) types.Signer {
+		number = blockNumber
+		return signer
+	})
+	block := generatePending(t, w, b)
+	if len(block.Transactions()) != 1 {
+		t.Fatalf("included transactions mismatch: have %d, want %d", len(block.Transactions()), 1)
+	}
+	if number == nil || number.Cmp(block.Number()) != 0 {
+		t.Errorf("overridden signer block mismatch: have %v, want %v", number, block.Number())
+	}
+	if atomic.LoadInt32(&signer.senders) == 0 {
+		t.Errorf("overridden signer not used to recover the senders")
+	}
+	w.setSignerOverride(nil)
+	if env := newTestEnv(t, w, b.head); env.signer == types.Signer(signer) {
+		t.Errorf("signer override not cleared")
+	}
+}
+
+// Tests that the pending body misses are only warned about once per interval,
+// with the misses accumulated in between.
+func TestPendingBodyMissWarning(t *testing.T) {
+	w, _ := newTestWorker(t, nil, nil)
+
+	header, _ := pendingBodyTestHeader(2, nil)
+	w.GetPendingBlockBody(header)
+	if misses := atomic.LoadUint64(&w.pendingBodyMisses); misses != 0 {
+		t.Fatalf("first miss not warned about: %d misses pending", misses)
+	}
+	warned := atomic.LoadInt64(&w.pendingBodyMissWarned)
+	for i := 0; i < 3; i++ {
+		w.GetPendingBlockBody(header)
+	}
+	if misses := atomic.LoadUint64(&w.pendingBodyMisses); misses != 3 {
+		t.Fatalf("accumulated misses mismatch: have %d, want %d", misses, 3)
+	}
+	if atomic.LoadInt64(&w.pendingBodyMissWarned) != warned {
+		t.Fatalf("misses warned about within the interval")
+	}
+	// Once the interval elapsed, the accumulated misses are reported
+	atomic.StoreInt64(&w.pendingBodyMissWarned, warned-int64(pendingBodyMissLogInterval))
+	w.GetPendingBlockBody(header)
+	if misses := atomic.LoadUint64(&w.pendingBodyMisses); misses != 0 {
+		t.Errorf("accumulated misses not reported: %d misses pending", misses)
+	}
+}
+
+func BenchmarkPackingPreallocated(b *testing.B) { benchmarkPacking(b, 0) }
+func BenchmarkPackingGrowing(b *testing.B)      { benchmarkPacking(b, 1) }
+
+// benchmarkPacking measures packing a block full of plain transfers, with the
+// sealing environment preallocated for the given number of transactions, or for
+// the pending ones if zero.
+func benchmarkPacking(b *testing.B, capacityHint int) {
+	accounts, alloc := newTestAccounts(b, 200)
+	w, backend := newTestWorker(b, &Config{TxCapacityHint: capacityHint}, alloc)
+
+	pending := make(map[common.AddressBytes]types.Transactions, len(accounts))
+	for _, account := range accounts {
+		tx := account.transfer(b, 0, params.GWei)
+		backend.addTxs(b, tx)
+		pending[account.address().Bytes20()] = types.Transactions{tx}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		env, err := w.prepareWork(&generateParams{}, backend.head)
+		if err != nil {
+			b.Fatalf("failed to prepare sealing environment: %v", err)
+		}
+		w.adjustGasLimit(nil, env, backend.head)
+		txs := copyPending(pending)
+		b.StartTimer()
+
+		w.commitPending(env, txs, nil)
+
+		b.StopTimer()
+		if len(env.txs) != len(accounts) {
+			b.Fatalf("packed transactions mismatch: have %d, want %d", len(env.txs), len(accounts))
+		}
+		env.release()
+		b.StartTimer()
+	}
+}
+
+// Tests that the family set of the sealing block is made of its ancestors along
+// with their uncles, and that its size is reported.
+func TestFamilySetSize(t *testing.T) {
+	defer func(old metrics.Gauge) { uncleFamilySizeGauge = old }(uncleFamilySizeGauge)
+	uncleFamilySizeGauge = new(metrics.StandardGauge)
+
+	w, b := newTestWorker(t, nil, nil)
+	if size := w.FamilySetSize(); size != 0 {
+		t.Fatalf("family set size without a sealing block: have %d, want %d", size, 0)
+	}
+	block := b.newBlock(t, b.head, nil, uncleTestBlock(1, 1).Header(), uncleTestBlock(1, 2).Header())
+	b.setHead(t, block)
+	if _, err := w.GeneratePendingHeader(block, false); err != nil {
+		t.Fatalf("failed to generate pending header: %v", err)
+	}
+	// The genesis, the funding block and the new head, along with its uncles
+	if size := w.FamilySetSize(); size != 5 {
+		t.Errorf("family set size mismatch: have %d, want %d", size, 5)
+	}
+	if have := uncleFamilySizeGauge.Value(); have != 5 {
+		t.Errorf("reported family set size mismatch: have %d, want %d", have, 5)
+	}
+}
+
+// Tests that the ETX rollup of the pending block is neither collected nor set
+// with SkipEtxRollup, so that missing ancestors don't fail the generation.
+func TestSkipEtxRollup(t *testing.T) {
+	w, b := newTestWorker(t, nil, nil)
+	header, err := w.GeneratePendingHeader(b.head, false)
+	if err != nil {
+		t.Fatalf("failed to generate pending header: %v", err)
+	}
+	if header.EtxRollupHash() != types.EmptyRootHash {
+		t.Errorf("etx rollup hash mismatch: have %x, want %x", header.EtxRollupHash(), types.EmptyRootHash)
+	}
+	// Collecting the rollup of a block whose ancestor body is missing fails
+	ancestor := b.newBlock(t, b.head, nil)
+	parent := b.newBlock(t, ancestor, nil)
+	rawdb.DeleteBody(b.db, ancestor.Hash(), ancestor.NumberU64())
+	if _, err := w.GeneratePendingHeader(parent, false); err == nil {
+		t.Fatalf("etx rollup collected without the ancestor body")
+	}
+	w.config.SkipEtxRollup = true
+	if header, err = w.GeneratePendingHeader(parent, false); err != nil {
+		t.Fatalf("failed to generate pending header skipping the etx rollup: %v", err)
+	}
+	if header.EtxRollupHash() != (common.Hash{}) {
+		t.Errorf("skipped etx rollup hash mismatch: have %x, want zero", header.EtxRollupHash())
+	}
+}
+
+// Tests that the manifest hash of the pending block is the one computed for its
+// parent during the assembly.
+func TestPendingManifestHash(t *testing.T) {
+	w, b := newTestWorker(t, nil, nil)
+	if hash := w.PendingManifestHash(); hash != (common.Hash{}) {
+		t.Fatalf("manifest hash without a pending block: have %x, want zero", hash)
+	}
+	header, err := w.GeneratePendingHeader(b.head, false)
+	if err != nil {
+		t.Fatalf("failed to generate pending header: %v", err)
+	}
+	want := w.ComputeManifestHash(b.head.Header())
+	if header.ManifestHash() != want {
+		t.Errorf("pending header manifest hash mismatch: have %x, want %x", header.ManifestHash(), want)
+	}
+	if hash := w.PendingManifestHash(); hash != want {
+		t.Errorf("pending manifest hash mismatch: have %x, want %x", hash, want)
+	}
+}
+
+// Tests that the uncles sharing the parent of the sealing block are only included
+// with AllowSiblingUncles.
+func TestSiblingUncles(t *testing.T) {
+	for _, allow := range []bool{false, true} {
+		w, b := newTestWorker(t, &Config{AllowSiblingUncles: allow}, nil)
+		sibling := b.newBlock(t, b.head, nil)
+		w.uncles.add(sibling, false, "")
+
+		block := generatePending(t, w, b)
+		if included := len(block.Uncles()) == 1 && block.Uncles()[0].Hash() == sibling.Hash(); included != allow {
+			t.Errorf("sibling uncle inclusion mismatch with allowed siblings %v: have %v, want %v", allow, included, allow)
+		}
+	}
+}
+
+// Tests that the pending header carries the given timestamp, recapped to the one
+// following the parent unless forced.
+func TestGeneratePendingHeaderAt(t *testing.T) {
+	w, b := newTestWorker(t, nil, nil)
+	timestamp := b.head.Time() + 100
+
+	header, err := w.GeneratePendingHeaderAt(b.head, false, timestamp, true)
+	if err != nil {
+		t.Fatalf("failed to generate pending header: %v", err)
+	}
+	if header.Time() != timestamp {
+		t.Errorf("timestamp mismatch: have %d, want %d", header.Time(), timestamp)
+	}
+	if header, err = w.GeneratePendingHeaderAt(b.head, false, b.head.Time(), false); err != nil {
+		t.Fatalf("failed to generate pending header: %v", err)
+	}
+	if header.Time() != b.head.Time()+1 {
+		t.Errorf("recapped timestamp mismatch: have %d, want %d", header.Time(), b.head.Time()+1)
+	}
+	if _, err := w.GeneratePendingHeaderAt(b.head, false, b.head.Time(), true); err == nil {
+		t.Errorf("pending header generated with a forced timestamp not after the parent")
+	}
+}
+
+// Tests that a pending snapshot whose receipts don't match its transactions is
+// reported as inconsistent.
+func TestSnapshotSelfCheck(t *testing.T) {
+	defer func(old metrics.Meter) { snapshotInconsistentMeter = old }(snapshotInconsistentMeter)
+	snapshotInconsistentMeter = metrics.NewMeterForced()
+
+	accounts, alloc := newTestAccounts(t, 1)
+	w, b := newTestWorker(t, &Config{SnapshotSelfCheck: true}, alloc)
+	b.addTxs(t, accounts[0].transfer(t, 0, params.GWei))
+	block := generatePending(t, w, b)
+	if len(block.Transactions()) != 1 {
+		t.Fatalf("included transactions mismatch: have %d, want %d", len(block.Transactions()), 1)
+	}
+	if !w.checkSnapshot() {
+		t.Fatalf("consistent snapshot reported as inconsistent")
+	}
+	w.updateSnapshot(block, nil)
+	if w.checkSnapshot() {
+		t.Errorf("inconsistent snapshot not reported")
+	}
+	if count := snapshotInconsistentMeter.Count(); count != 1 {
+		t.Errorf("inconsistent snapshots mismatch: have %d, want %d", count, 1)
+	}
+}
+
+// recordingEngine counts the sealing blocks it prepares and assembles.
+type recordingEngine struct {
+	testEngine
+	prepared, assembled int32
+}
+
+func (e *recordingEngine) Prepare(chain consensus.ChainHeaderReader, header *types.Header, parent *types.Header) error {
+	atomic.AddInt32(&e.prepared, 1)
+	return e.testEngine.Prepare(chain, header, parent)
+}
+
+func (e *recordingEngine) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, etxs []*types.Transaction, manifest types.BlockManifest, receipts []*types.Receipt) (*types.Block, error) {
+	atomic.AddInt32(&e.assembled, 1)
+	return e.testEngine.FinalizeAndAssemble(chain, header, state, txs, uncles, etxs, manifest, receipts)
+}
+
+// Tests that the engine is only swapped once the generations in flight are done,
+// aborting the interruptible ones.
+func TestSetEngineWaitsForGeneration(t *testing.T) {
+	w, _ := newTestWorker(t, nil, nil)
+	interrupt := new(int32)
+	w.fillInterrupts[interrupt] = struct{}{}
+
+	// Hold the engine like a generation in flight
+	w.engineMu.RLock()
+	engine := &recordingEngine{}
+	done := make(chan error, 1)
+	go func() { done <- w.setEngine(engine) }()
+
+	select {
+	case <-done:
+		t.Fatalf("engine swapped during a generation")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if atomic.LoadInt32(interrupt) != commitInterruptNewHead {
+		t.Errorf("generation in flight not interrupted")
+	}
+	if w.getEngine() == engine {
+		t.Errorf("engine swapped during a generation")
+	}
+	w.engineMu.RUnlock()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("failed to swap engine: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("engine swap blocked after the generation")
+	}
+	if w.getEngine() != engine {
+		t.Errorf("engine not swapped")
+	}
+}
+
+// Tests that the generations after an engine swap prepare and assemble the
+// pending block with the new engine.
+func TestSetEngine(t *testing.T) {
+	w, b := newTestWorker(t, nil, nil)
+	if err := w.setEngine(nil); err == nil {
+		t.Fatalf("nil engine accepted")
+	}
+	generatePending(t, w, b)
+
+	engine := &recordingEngine{}
+	if err := w.setEngine(engine); err != nil {
+		t.Fatalf("failed to swap engine: %v", err)
+	}
+	if w.current != nil {
+		t.Errorf("environment of the previous engine not discarded")
+	}
+	generatePending(t, w, b)
+	if prepared := atomic.LoadInt32(&engine.prepared); prepared != 1 {
+		t.Errorf("prepared blocks mismatch: have %d, want %d", prepared, 1)
+	}
+	if assembled := atomic.LoadInt32(&engine.assembled); assembled != 1 {
+		t.Errorf("assembled blocks mismatch: have %d, want %d", assembled, 1)
+	}
+}
+
+// Tests that a transaction appearing twice in the pending set is only applied
+// once, without the duplicate failing on its nonce.
+func TestCommitDuplicateTransactions(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 1)
+	w, b := newTestWorker(t, nil, alloc)
+	env := newTestEnv(t, w, b.head)
+
+	tx0, tx1 := accounts[0].transfer(t, 0, params.GWei), accounts[0].transfer(t, 1, params.GWei)
+	pending := map[common.AddressBytes]types.Transactions{
+		accounts[0].address().Bytes20(): {tx0, tx0, tx1},
+	}
+	w.commitPending(env, pending, nil)
+
+	if len(env.txs) != 2 || env.txs[0].Hash() != tx0.Hash() || env.txs[1].Hash() != tx1.Hash() {
+		t.Fatalf("included transactions mismatch: have %d, want %d distinct", len(env.txs), 2)
+	}
+	if env.reverted != 0 {
+		t.Errorf("duplicate applied again: %d transactions reverted", env.reverted)
+	}
+}
+
+// Tests that the pending state root is the one of the pending block.
+func TestPendingStateRoot(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 1)
+	w, b := newTestWorker(t, nil, alloc)
+	if root := w.PendingStateRoot(); root != (common.Hash{}) {
+		t.Fatalf("state root without a pending block: %x", root)
+	}
+	b.addTxs(t, accounts[0].transfer(t, 0, params.GWei))
+	block := generatePending(t, w, b)
+
+	if root := w.PendingStateRoot(); root != block.Root() {
+		t.Errorf("state root mismatch: have %x, want %x", root, block.Root())
+	}
+	if block.Root() == b.head.Root() {
+		t.Errorf("pending state root not updated by the included transaction")
+	}
+}
+
+// Tests that the transactions are packed up to the block size limit.
+func TestBlockBytesCap(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 1)
+	txs := make(types.Transactions, 6)
+	for i := range txs {
+		txs[i] = accounts[0].transfer(t, uint64(i), params.GWei)
+	}
+	limit := txs[0].Size() + txs[1].Size() + txs[2].Size()
+
+	w, b := newTestWorker(t, &Config{MaxBlockBytes: int(limit)}, alloc)
+	env := newTestEnv(t, w, b.head)
+	w.commitPending(env, testPending(t, txs...), nil)
+
+	if len(env.txs) != 3 {
+		t.Fatalf("included transactions mismatch: have %d, want %d", len(env.txs), 3)
+	}
+	if env.size != limit {
+		t.Errorf("block size mismatch: have %v, want %v", env.size, limit)
+	}
+}
+
+// etxCapTestEtx creates an ETX of the given gas.
+func etxCapTestEtx(gas uint64) *types.Transaction {
+	return types.NewTx(&types.ExternalTx{Gas: gas})
+}
+
+// Tests that the ETXs emitted by a transaction are counted along with their gas.
+func TestEmittedEtxs(t *testing.T) {
+	etxs := []*types.Transaction{etxCapTestEtx(21000), etxCapTestEtx(50000)}
+
+	count, gas, _ := emittedEtxs(etxs, false)
+	if count != 2 || gas != 71000 {
+		t.Errorf("emitted etxs mismatch: have %d of %d gas, want %d of %d gas", count, gas, 2, 71000)
+	}
+	// The ETXs of a failed transaction are dropped and don't use the budget
+	if count, gas, _ := emittedEtxs(etxs, true); count != 0 || gas != 0 {
+		t.Errorf("failed transaction emitted etxs: have %d of %d gas", count, gas)
+	}
+}
+
+// Tests that the transactions emitting ETXs over the configured budget of the
+// block are refused.
+func TestEtxCap(t *testing.T) {
+	w := &worker{config: &Config{EtxCountCap: 2, EtxGasCap: 100000}}
+	env := &environment{etxs: types.Transactions{etxCapTestEtx(21000)}, etxGas: 21000}
+
+	tests := []struct {
+		count int
+		gas   uint64
+		fits  bool
+	}{
+		{0, 0, true},
+		{1, 79000, true},
+		{2, 42000, false}, // over the count cap
+		{1, 79001, false}, // over the gas cap
+	}
+	for i, tt := range tests {
+		err := w.checkEtxCap(env, tt.count, tt.gas)
+		if fits := err == nil; fits != tt.fits {
+			t.Errorf("test %d: budget mismatch: have %v, want %v", i, err, tt.fits)
+		}
+		if err != nil && !errors.Is(err, ErrEtxLimitReached) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, ErrEtxLimitReached)
+		}
+	}
+	// The caps adjusted at runtime take over the configured ones, zero lifting them
+	w.setEtxCap(0, 0)
+	if err := w.checkEtxCap(env, 100, 1<<40); err != nil {
+		t.Errorf("lifted etx cap enforced: %v", err)
+	}
+}
+
+// Tests that a transaction whose ETXs overflow the block size limit is rejected
+// before its state changes are applied.
+func TestBlockBytesEmittedEtxs(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 1)
+	tx := accounts[0].transfer(t, 0, params.GWei)
+	w, b := newTestWorker(t, &Config{MaxBlockBytes: int(tx.Size()) + 500, ParallelPacking: true, ParallelPackingThreads: 4}, alloc)
+	env := newTestEnv(t, w, b.head)
+	gas := env.gasPool.Gas()
+
+	_, speculated := w.speculateTransactions(env, testPending(t, tx))
+	spec := speculated[tx.Hash()]
+	if spec == nil {
+		t.Fatalf("transaction not speculated")
+	}
+	to := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	spec.etxs = types.Transactions{types.NewTx(&types.ExternalTx{To: &to, Gas: params.TxGas, Data: make([]byte, 1000)})}
+	env.speculated = speculated
+
+	if _, err := w.commitTransaction(env, tx); !errors.Is(err, errBlockBytesReached) {
+		t.Fatalf("error mismatch: have %v, want %v", err, errBlockBytesReached)
+	}
+	if len(env.txs) != 0 || env.size != 0 {
+		t.Fatalf("rejected transaction included: %d txs of %v", len(env.txs), env.size)
+	}
+	if have := env.gasPool.Gas(); have != gas {
+		t.Errorf("gas pool not restored: have %d, want %d", have, gas)
+	}
+	// Without the ETXs the transaction fits
+	spec.etxs = nil
+	if _, err := w.commitTransaction(env, tx); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+	if env.size != tx.Size() {
+		t.Errorf("block size mismatch: have %v, want %v", env.size, tx.Size())
+	}
+}
+
+// Tests that recovering the pruned state of a sealing parent is counted, whether
+// it succeeds or not.
+func TestParentStateRecovery(t *testing.T) {
+	defer func(recovered, failed metrics.Counter) {
+		stateRecoveredCounter, stateRecoveryFailedCounter = recovered, failed
+	}(stateRecoveredCounter, stateRecoveryFailedCounter)
+	stateRecoveredCounter, stateRecoveryFailedCounter = metrics.NewCounterForced(), metrics.NewCounterForced()
+
+	accounts, alloc := newTestAccounts(t, 1)
+	w, b := newTestWorker(t, nil, alloc)
+	if _, err := w.parentState(b.head, 8); err != nil {
+		t.Fatalf("failed to retrieve the parent state: %v", err)
+	}
+	if recovered := stateRecoveredCounter.Count(); recovered != 0 {
+		t.Fatalf("available state recovered %d times", recovered)
+	}
+	// The state of the pending block is never committed, so it's recovered by
+	// reexecuting the block on top of its parent.
+	b.addTxs(t, accounts[0].transfer(t, 0, params.GWei))
+	block := generatePending(t, w, b)
+	rawdb.WriteBlock(b.db, block)
+
+	if _, err := w.parentState(block, 8); err != nil {
+		t.Fatalf("failed to recover the parent state: %v", err)
+	}
+	if recovered := stateRecoveredCounter.Count(); recovered != 1 {
+		t.Errorf("recovered states mismatch: have %d, want %d", recovered, 1)
+	}
+	// Without the block its state can't be recovered
+	rawdb.DeleteBlock(b.db, block.Hash(), block.NumberU64())
+	if _, err := w.parentState(block, 8); err == nil {
+		t.Fatalf("state of a missing block recovered")
+	}
+	if failed := stateRecoveryFailedCounter.Count(); failed != 1 {
+		t.Errorf("failed recoveries mismatch: have %d, want %d", failed, 1)
+	}
+}
+
+// Tests that the pending logs are filtered by address and topics.
+func TestPendingLogs(t *testing.T) {
+	w, b := newTestWorker(t, nil, nil)
+	var (
+		addr1  = common.HexToAddress("0x0000000000000000000000000000000000000011")
+		addr2  = common.HexToAddress("0x0000000000000000000000000000000000000022")
+		topic1 = common.HexToHash("0x01")
+		topic2 = common.HexToHash("0x02")
+	)
+	w.updateSnapshot(b.head, types.Receipts{
+		{Logs: []*types.Log{{Address: addr1, Topics: []common.Hash{topic1}}, {Address: addr2, Topics: []common.Hash{topic1}}}},
+		{Logs: []*types.Log{{Address: addr1, Topics: []common.Hash{topic2}}, {Address: addr1}}},
+	})
+	tests := []struct {
+		addresses []common.Address
+		topics    [][]common.Hash
+		want      int
+	}{
+		{nil, nil, 4},
+		{[]common.Address{addr1}, nil, 3},
+		{[]common.Address{addr2}, nil, 1},
+		{[]common.Address{addr1}, [][]common.Hash{{topic2}}, 1},
+		{nil, [][]common.Hash{{topic1}}, 2},
+		{nil, [][]common.Hash{{topic1, topic2}}, 3},
+		{[]common.Address{addr2}, [][]common.Hash{{topic2}}, 0},
+	}
+	for i, tt := range tests {
+		logs := w.PendingLogs(tt.addresses, tt.topics)
+		if len(logs) != tt.want {
+			t.Errorf("test %d: logs mismatch: have %d, want %d", i, len(logs), tt.want)
+		}
+		for _, l := range logs {
+			if len(tt.addresses) > 0 && !l.Address.Equal(tt.addresses[0]) {
+				t.Errorf("test %d: log of unfiltered address %v", i, l.Address)
+			}
+		}
+	}
+	// The logs are copies of the pending ones
+	w.PendingLogs(nil, nil)[0].Address = addr2
+	if logs := w.PendingLogs([]common.Address{addr1}, nil); len(logs) != 3 {
+		t.Errorf("pending logs modified through a returned one")
+	}
+}
+
+// blockingSet is a set whose clearing waits until it's unblocked.
+type blockingSet struct {
+	mapset.Set
+	unblock chan struct{}
+	cleared chan struct{}
+}
+
+func (s *blockingSet) Clear() {
+	<-s.unblock
+	s.Set.Clear()
+	close(s.cleared)
+}
+
+// Tests that swapping the current environment doesn't wait for the replaced one
+// to be discarded, which still happens in the background.
+func TestSetCurrentAsyncDiscard(t *testing.T) {
+	// The discarded environment returns to the pool with the blocking set, so drop
+	// it once the worker is closed
+	t.Cleanup(func() { envPool = sync.Pool{New: envPool.New} })
+	w, _ := newTestWorker(t, nil, nil)
+	ancestors := &blockingSet{Set: mapset.NewSet(), unblock: make(chan struct{}), cleared: make(chan struct{})}
+	old := &environment{ancestors: ancestors, family: mapset.NewSet(), uncles: make(map[common.Hash]*types.Header)}
+	w.setCurrent(old)
+
+	done := make(chan struct{})
+	go func() {
+		w.setCurrent(nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("environment swap waited for the discard")
+	}
+	select {
+	case <-ancestors.cleared:
+		t.Fatalf("environment discarded while blocked")
+	default:
+	}
+	close(ancestors.unblock)
+	select {
+	case <-ancestors.cleared:
+	case <-time.After(time.Second):
+		t.Fatalf("replaced environment never discarded")
+	}
+}
+
+// Tests that the async pending header generation can be interrupted from several
+// goroutines at once, as the head loop, the rebuilds and the generations do,
+// without closing the interrupt twice.
+func TestInterruptAsyncPhGenConcurrent(t *testing.T) {
+	w, b := newTestWorker(t, nil, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				w.interruptAsyncPhGen()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				w.consumeAsyncPhGenInterrupt()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := w.rebuildPending(b.head); err != nil {
+				t.Errorf("failed to rebuild pending: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Tests that a new dom header interrupts the filling of a background pending
+// header generation in flight, which is then restarted and delivered.
+func TestDomHeaderRestartsGeneration(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 1)
+	w, b := newTestWorker(t, nil, alloc)
+	// Keep the pending transactions from rebuilding the snapshot in the background
+	w.start()
+	defer w.pause()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	var fills int32
+	w.setInclusionPolicy(func(tx *types.Transaction, env *environment) InclusionDecision {
+		if atomic.AddInt32(&fills, 1) == 1 {
+			close(started)
+			<-release
+		}
+		return InclusionInclude
+	})
+	tx := accounts[0].transfer(t, 0, params.GWei)
+	b.addTxs(t, tx)
+
+	headers := make(chan *types.Header, 1)
+	sub := w.SubscribeAsyncPendingHeader(headers)
+	defer sub.Unsubscribe()
+
+	w.asyncGeneratePendingHeader(b.head)
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatalf("pending header generation never started filling")
+	}
+	b.chain.domHeaderFeed.Send(DomHeaderEvent{Header: b.head.Header()})
+
+	// Wait for the dom header to interrupt the filling in flight before resuming it
+	interrupted := func() bool {
+		w.fillInterruptsMu.Lock()
+		defer w.fillInterruptsMu.Unlock()
+		for interrupt := range w.fillInterrupts {
+			if atomic.LoadInt32(interrupt) == commitInterruptNewHead {
+				return true
+			}
+		}
+		return false
+	}
+	for deadline := time.Now().Add(time.Second); !interrupted(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("filling not interrupted by the dom header")
+		}
+	}
+	close(release)
+
+	select {
+	case header := <-headers:
+		if header.TxHash() != types.DeriveSha(types.Transactions{tx}, trie.NewStackTrie(nil)) {
+			t.Errorf("restarted pending header misses the pending transaction")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("pending header generation not restarted")
+	}
+	if have := atomic.LoadInt32(&fills); have != 2 {
+		t.Errorf("filled transactions mismatch: have %d, want %d", have, 2)
+	}
+}
+
+// logHook records the messages of the log entries of the given levels.
+type logHook struct {
+	levels   []logrus.Level
+	lock     sync.Mutex
+	messages []string
+}
+
+func (h *logHook) Levels() []logrus.Level { return h.levels }
+
+func (h *logHook) Fire(entry *logrus.Entry) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.messages = append(h.messages, entry.Message)
+	return nil
+}
+
+// find returns the recorded messages containing all the given strings.
+func (h *logHook) find(strs ...string) []string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	var found []string
+	for _, msg := range h.messages {
+		match := true
+		for _, str := range strs {
+			if !strings.Contains(msg, str) {
+				match = false
+				break
+			}
+		}
+		if match {
+			found = append(found, msg)
+		}
+	}
+	return found
+}
+
+// Tests that transactions of unsupported types are skipped and counted, and only
+// logged at error level unless quieted.
+func TestUnsupportedTxType(t *testing.T) {
+	defer func(old metrics.Counter) { unsupportedTxTypeCounter = old }(unsupportedTxTypeCounter)
+	defer log.Log.ReplaceHooks(log.Log.ReplaceHooks(make(logrus.LevelHooks)))
+
+	for _, quiet := range []bool{false, true} {
+		unsupportedTxTypeCounter = metrics.NewCounterForced()
+		hook := &logHook{levels: []logrus.Level{logrus.ErrorLevel}}
+		log.Log.ReplaceHooks(make(logrus.LevelHooks))
+		log.Log.AddHook(hook)
+
+		accounts, alloc := newTestAccounts(t, 2)
+		w, b := newTestWorker(t, &Config{QuietUnsupportedTxType: quiet}, alloc)
+		env := newTestEnv(t, w, b.head)
+		// Sponsored transactions are only supported past their fork block
+		b.config.SponsoredTxBlock = big.NewInt(100)
+
+		to := accounts[0].address()
+		tx, err := types.SignTx(types.NewTx(&types.SponsoredTx{
+			ChainID:   testSigner.ChainID(),
+			GasTipCap: big.NewInt(params.GWei),
+			GasFeeCap: big.NewInt(2 * params.GWei),
+			Gas:       params.TxGas,
+			To:        &to,
+			Value:     big.NewInt(1),
+		}), testSigner, accounts[0].key)
+		if err != nil {
+			t.Fatalf("failed to sign as sender: %v", err)
+		}
+		if tx, err = types.SignPayer(tx, testSigner, accounts[1].key); err != nil {
+			t.Fatalf("failed to sign as fee payer: %v", err)
+		}
+		next := accounts[1].transfer(t, 0, params.GWei)
+		w.commitPending(env, testPending(t, tx, next), nil)
+
+		if len(env.txs) != 1 || env.txs[0].Hash() != next.Hash() {
+			t.Errorf("quiet %v: included transactions mismatch: have %d, want the supported one", quiet, len(env.txs))
+		}
+		if count := unsupportedTxTypeCounter.Count(); count != 1 {
+			t.Errorf("quiet %v: unsupported transactions mismatch: have %d, want %d", quiet, count, 1)
+		}
+		if logged := len(hook.find("Skipping unsupported transaction type")) > 0; logged == quiet {
+			t.Errorf("quiet %v: error logged %v", quiet, logged)
+		}
+	}
+}
+
+// Tests that generating the pending header schedules the next recommit.
+func TestTimeToNextRecommit(t *testing.T) {
+	w, b := newTestWorker(t, &Config{Recommit: time.Second}, nil)
+	if remaining := w.TimeToNextRecommit(); remaining != 0 {
+		t.Fatalf("recommit scheduled before any generation: %v", remaining)
+	}
+	generatePending(t, w, b)
+
+	first := w.TimeToNextRecommit()
+	if first <= 0 || first > time.Second {
+		t.Fatalf("time to next recommit out of range: %v", first)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if second := w.TimeToNextRecommit(); second >= first {
+		t.Errorf("time to next recommit not decreasing: have %v after %v", second, first)
+	}
+}
+
+// Tests that the transactions using no gas are only left out if configured.
+func TestExcludeZeroGasTxs(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 1)
+	tx := accounts[0].transfer(t, 0, params.GWei)
+	w, b := newTestWorker(t, nil, alloc)
+	env := newTestEnv(t, w, b.head)
+
+	if err := w.checkOutcome(env, tx, 0, false, nil); err != nil {
+		t.Fatalf("transaction using no gas excluded by default: %v", err)
+	}
+	w.config.ExcludeZeroGasTxs = true
+	if err := w.checkOutcome(env, tx, 0, false, nil); !errors.Is(err, errZeroGasUsed) {
+		t.Errorf("error mismatch: have %v, want %v", err, errZeroGasUsed)
+	}
+	if err := w.checkOutcome(env, tx, params.TxGas, false, nil); err != nil {
+		t.Errorf("transaction using gas excluded: %v", err)
+	}
+}
+
+// Tests that a new chain head discards the pending block built on the previous
+// one and notifies the subscribers.
+func TestPendingInvalidated(t *testing.T) {
+	w, b := newTestWorker(t, &Config{RegenerateDebounce: time.Hour}, nil)
+	generatePending(t, w, b)
+
+	ch := make(chan PendingInvalidatedEvent, 1)
+	sub := w.SubscribePendingInvalidated(ch)
+	defer sub.Unsubscribe()
+
+	// The chain head the pending block is built on invalidates nothing
+	w.chainHeadCh <- ChainHeadEvent{Block: b.head}
+	head := b.newBlock(t, b.head, nil)
+	w.chainHeadCh <- ChainHeadEvent{Block: head}
+
+	select {
+	case ev := <-ch:
+		if ev.OldNumber != b.head.NumberU64() || ev.NewNumber != head.NumberU64() {
+			t.Errorf("event mismatch: have %d -> %d, want %d -> %d", ev.OldNumber, ev.NewNumber, b.head.NumberU64(), head.NumberU64())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("pending invalidation not notified")
+	}
+	w.currentMu.RLock()
+	defer w.currentMu.RUnlock()
+	if w.current != nil {
+		t.Errorf("invalidated environment not discarded")
+	}
+}
+
+// stuckTxIterator is a pathological transaction source which never advances.
+type stuckTxIterator struct {
+	tx    *types.Transaction
+	peeks int
+}
+
+func (it *stuckTxIterator) Peek() *types.Transaction {
+	it.peeks++
+	return it.tx
+}
+func (it *stuckTxIterator) Shift(acc common.AddressBytes, sort bool) {}
+func (it *stuckTxIterator) PopNoSort()                               {}
+
+// Tests that committing from a transaction source which never advances is
+// aborted after the given number of attempts.
+func TestCommitTransactionsMaxAttempts(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 1)
+	w, b := newTestWorker(t, nil, alloc)
+	env := newTestEnv(t, w, b.head)
+
+	it := &stuckTxIterator{tx: accounts[0].transfer(t, 0, params.GWei)}
+	w.commitTransactions(env, it, 10, nil)
+
+	if it.peeks != 10 {
+		t.Errorf("attempts mismatch: have %d, want %d", it.peeks, 10)
+	}
+	if len(env.txs) != 1 {
+		t.Errorf("included transactions mismatch: have %d, want %d", len(env.txs), 1)
+	}
+}
+
+// Tests that the gas used by the current environment is broken down into the
+// internal and external transactions, without underflowing.
+func TestPendingEtxGasBreakdown(t *testing.T) {
+	w, b := newTestWorker(t, nil, nil)
+	if internal, external := w.PendingEtxGasBreakdown(); internal != 0 || external != 0 {
+		t.Fatalf("gas used without an environment: %d internal, %d external", internal, external)
+	}
+	env := newTestEnv(t, w, b.head)
+	env.header.SetGasUsed(50000)
+	env.externalGasUsed = 20000
+	w.setCurrent(env)
+
+	if internal, external := w.PendingEtxGasBreakdown(); internal != 30000 || external != 20000 {
+		t.Errorf("gas breakdown mismatch: have %d/%d, want %d/%d", internal, external, 30000, 20000)
+	}
+	env.externalGasUsed = 60000
+	if internal, external := w.PendingEtxGasBreakdown(); internal != 0 || external != 60000 {
+		t.Errorf("gas breakdown mismatch: have %d/%d, want %d/%d", internal, external, 0, 60000)
+	}
+}
+
+// Tests that a gas ceiling change is applied by regenerating the pending block,
+// without modifying the header handed out with the previous one.
+func TestRecomputeGasLimit(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 1)
+	w, b := newTestWorker(t, &Config{GasCeil: 20000000}, alloc)
+	if _, err := w.RecomputeGasLimit(); err == nil {
+		t.Fatalf("gas limit recomputed without a sealing environment")
+	}
+	// The gas ceiling only moves the gas limit on top of full enough blocks
+	header := types.CopyHeader(b.head.Header())
+	header.SetParentHash(b.head.Hash())
+	header.SetNumber(new(big.Int).Add(b.head.Number(), common.Big1))
+	header.SetTime(b.head.Time() + 10)
+	header.SetBaseFee(misc.CalcBaseFee(b.config, b.head.Header()))
+	header.SetGasUsed(header.GasLimit())
+	head := types.NewBlockWithHeader(header)
+	rawdb.WriteBlock(b.db, head)
+	rawdb.WriteTermini(b.db, head.Hash(), types.EmptyTermini())
+	rawdb.WriteEtxSet(b.db, head.Hash(), head.NumberU64(), types.NewEtxSet())
+	b.setHead(t, head)
+
+	b.addTxs(t, accounts[0].transfer(t, 0, params.GWei))
+	published := generatePending(t, w, b)
+	gasLimit := published.GasLimit()
+
+	w.config.GasCeilByContext = map[int]uint64{common.ZONE_CTX: 40000000}
+	want := CalcGasLimit(head.Header(), 40000000)
+	if want == gasLimit {
+		t.Fatalf("gas ceiling change doesn't move the gas limit")
+	}
+	have, err := w.RecomputeGasLimit()
+	if err != nil {
+		t.Fatalf("failed to recompute gas limit: %v", err)
+	}
+	if have != want {
+		t.Errorf("gas limit mismatch: have %d, want %d", have, want)
+	}
+	block := w.pending()
+	if block.GasLimit() != want || len(block.Transactions()) != 1 {
+		t.Errorf("pending block mismatch: have gas limit %d with %d txs, want %d with %d", block.GasLimit(), len(block.Transactions()), want, 1)
+	}
+	w.currentMu.RLock()
+	current := w.current.header.GasLimit()
+	w.currentMu.RUnlock()
+	if current != want {
+		t.Errorf("current gas limit mismatch: have %d, want %d", current, want)
+	}
+	if body := w.GetPendingBlockBody(block.Header()); body == nil || len(body.Transactions) != 1 {
+		t.Errorf("pending block body of the new gas limit not cached")
+	}
+	if published.GasLimit() != gasLimit {
+		t.Errorf("published header modified: have gas limit %d, want %d", published.GasLimit(), gasLimit)
+	}
+}
+
+// Tests that the pending block bodies persisted in the background are restored
+// after a restart, and the ones left incomplete by a crash are dropped.
+func TestPendingBodyRecovery(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	w := newPendingBodyTestWorker(db)
+
+	uncle := types.EmptyHeader()
+	uncle.SetNumber(big.NewInt(1))
+	header, body := pendingBodyTestHeader(2, []*types.Header{uncle})
+	evicted, evictedBody := pendingBodyTestHeader(3, nil)
+	w.AddPendingBlockBody(header, body)
+	w.AddPendingBlockBody(evicted, evictedBody)
+	w.pendingBlockBody.Remove(w.getPendingBlockBodyKey(evicted))
+	w.StorePendingBlockBody()
+
+	if rawdb.ReadPendingBody(db, w.getPendingBlockBodyKey(header)) == nil {
+		t.Fatalf("pending body not persisted")
+	}
+	if rawdb.ReadPendingBody(db, w.getPendingBlockBodyKey(evicted)) != nil {
+		t.Fatalf("evicted pending body still persisted")
+	}
+	// A crash between the writes of the body and of its metadata leaves a partial
+	// entry behind
+	partial := common.Hash{0xaa}
+	rawdb.WritePendingBodyMeta(db, partial, &rawdb.PendingBodyMeta{Number: 2})
+	w.close()
+
+	restarted := newPendingBodyTestWorker(db)
+	defer restarted.close()
+	restarted.LoadPendingBlockBody()
+
+	recovered := restarted.GetPendingBlockBody(header)
+	if recovered == nil || len(recovered.Uncles) != 1 || recovered.Uncles[0].Hash() != uncle.Hash() {
+		t.Fatalf("recovered pending body mismatch: have %v", recovered)
+	}
+	if restarted.GetPendingBlockBody(evicted) != nil {
+		t.Errorf("evicted pending body recovered")
+	}
+	if restarted.pendingBlockBody.Len() != 1 {
+		t.Errorf("recovered pending bodies mismatch: have %d, want %d", restarted.pendingBlockBody.Len(), 1)
+	}
+	if rawdb.ReadPendingBodyMeta(db, partial) != nil {
+		t.Errorf("partial pending body left in the db")
+	}
+	// The recovered entries are pruned like the generated ones
+	restarted.PruneStalePendingBodies(3)
+	restarted.StorePendingBlockBody()
+	if rawdb.ReadPendingBody(db, w.getPendingBlockBodyKey(header)) != nil {
+		t.Errorf("pruned pending body still persisted")
+	}
+}
+
+// Tests that the pending block bodies assembled below a number are pruned, along
+// with their size and persisted copy.
+func TestPruneStalePendingBodies(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	w := newPendingBodyTestWorker(db)
+	defer w.close()
+
+	var headers []*types.Header
+	for i := int64(1); i <= 5; i++ {
+		uncle := types.EmptyHeader()
+		uncle.SetNumber(big.NewInt(i))
+		header, body := pendingBodyTestHeader(i+1, []*types.Header{uncle})
+		w.AddPendingBlockBody(header, body)
+		headers = append(headers, header)
+	}
+	size := atomic.LoadInt64(&w.pendingBodyBytes) / int64(len(headers))
+
+	w.PruneStalePendingBodies(4)
+	w.StorePendingBlockBody()
+	for _, header := range headers {
+		key := w.getPendingBlockBodyKey(header)
+		_, cached := w.pendingBlockBody.Peek(key)
+		persisted := rawdb.ReadPendingBody(db, key) != nil
+		if stale := header.NumberU64() < 4; cached == stale || persisted == stale {
+			t.Errorf("number %d: cached %v, persisted %v", header.NumberU64(), cached, persisted)
+		}
+	}
+	if have, want := atomic.LoadInt64(&w.pendingBodyBytes), 3*size; have != want {
+		t.Errorf("cached size mismatch: have %d, want %d", have, want)
+	}
+}
+
+// Tests that the oldest pending block bodies are evicted once the cache grows
+// over its size limit.
+func TestPendingBodyMaxBytes(t *testing.T) {
+	w := newPendingBodyTestWorker(rawdb.NewMemoryDatabase())
+	defer w.close()
+
+	var (
+		headers []*types.Header
+		bodies  []*types.Body
+		sizes   []int64
+	)
+	for i := int64(1); i <= 3; i++ {
+		uncle := types.EmptyHeader()
+		uncle.SetNumber(big.NewInt(i))
+		header, body := pendingBodyTestHeader(i+1, []*types.Header{uncle})
+		headers, bodies = append(headers, header), append(bodies, body)
+		sizes = append(sizes, int64(newPendingBodyEntry(body, header.NumberU64()).size))
+	}
+	w.config.PendingBodyMaxBytes = int(sizes[0] + sizes[1] + sizes[2] - 1)
+	for i := range headers {
+		w.AddPendingBlockBody(headers[i], bodies[i])
+	}
+	if w.GetPendingBlockBody(headers[0]) != nil {
+		t.Errorf("oldest pending body not evicted")
+	}
+	for i := 1; i < len(headers); i++ {
+		if w.GetPendingBlockBody(headers[i]) == nil {
+			t.Errorf("pending body %d evicted", i)
+		}
+	}
+	if have, want := atomic.LoadInt64(&w.pendingBodyBytes), sizes[1]+sizes[2]; have != want {
+		t.Errorf("cached size mismatch: have %d, want %d", have, want)
+	}
+}
+
+// Tests that the stored pending block bodies are read back and compared to the
+// cached ones, reporting the ones corrupted in the db.
+func TestVerifyPbBodyPersistence(t *testing.T) {
+	defer func(old metrics.Meter) { pendingBodyPersistMeter = old }(pendingBodyPersistMeter)
+	pendingBodyPersistMeter = metrics.NewMeterForced()
+
+	db := rawdb.NewMemoryDatabase()
+	w := newPendingBodyTestWorker(db)
+	defer w.close()
+	w.config.VerifyPbBodyPersistence = true
+
+	uncle := types.EmptyHeader()
+	uncle.SetNumber(big.NewInt(1))
+	header, body := pendingBodyTestHeader(2, []*types.Header{uncle})
+	w.AddPendingBlockBody(header, body)
+	w.StorePendingBlockBody()
+	if count := pendingBodyPersistMeter.Count(); count != 0 {
+		t.Fatalf("intact bodies reported as mismatching: %d", count)
+	}
+	// Corrupt the stored body behind the worker's back
+	key := w.getPendingBlockBodyKey(header)
+	rawdb.WritePendingBody(db, key, &types.Body{}, rawdb.ReadPendingBodyMeta(db, key))
+	w.StorePendingBlockBody()
+	if count := pendingBodyPersistMeter.Count(); count != 1 {
+		t.Errorf("mismatching bodies meter mismatch: have %d, want %d", count, 1)
+	}
+}
+
+// Tests that the log messages of the sealing work carry the name of the worker.
+func TestWorkerNameInLogs(t *testing.T) {
+	defer log.Log.ReplaceHooks(log.Log.ReplaceHooks(make(logrus.LevelHooks)))
+	hook := &logHook{levels: []logrus.Level{logrus.InfoLevel}}
+	log.Log.AddHook(hook)
+
+	w, b := newTestWorker(t, &Config{Name: "zone-test"}, nil)
+	generatePending(t, w, b)
+	if len(hook.find("Commit new sealing work", "worker=zone-test")) == 0 {
+		t.Errorf("sealing work logged without the worker name")
+	}
+	// Without a name the worker is named after its location
+	w, b = newTestWorker(t, nil, nil)
+	generatePending(t, w, b)
+	if name := common.NodeLocation.Name(); w.config.Name != name || len(hook.find("Commit new sealing work", "worker="+name)) == 0 {
+		t.Errorf("sealing work logged without the default worker name %q", name)
+	}
+}
+
+// Tests that the ETXs of a failed transaction are dropped and counted rather than
+// included.
+func TestEtxsDroppedOnFailedTx(t *testing.T) {
+	defer func(old metrics.Counter) { etxDroppedOnFailedTxCounter = old }(etxDroppedOnFailedTxCounter)
+	etxDroppedOnFailedTxCounter = metrics.NewCounterForced()
+
+	accounts, alloc := newTestAccounts(t, 1)
+	tx := accounts[0].transfer(t, 0, params.GWei)
+	w, b := newTestWorker(t, &Config{ParallelPacking: true, ParallelPackingThreads: 4}, alloc)
+	env := newTestEnv(t, w, b.head)
+
+	// Make the outcome of the transaction a failure emitting an ETX, which the
+	// VM is never supposed to produce
+	_, speculated := w.speculateTransactions(env, testPending(t, tx))
+	spec := speculated[tx.Hash()]
+	if spec == nil {
+		t.Fatalf("transaction not speculated")
+	}
+	to := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	spec.failed = true
+	spec.etxs = types.Transactions{types.NewTx(&types.ExternalTx{To: &to, Gas: params.TxGas})}
+	env.speculated = speculated
+
+	if _, err := w.commitTransaction(env, tx); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+	if len(env.txs) != 1 || env.receipts[0].Status != types.ReceiptStatusFailed {
+		t.Fatalf("failed transaction not included")
+	}
+	if len(env.etxs) != 0 {
+		t.Errorf("etxs of the failed transaction included: %d", len(env.etxs))
+	}
+	if dropped := etxDroppedOnFailedTxCounter.Count(); dropped != 1 {
+		t.Errorf("dropped etxs mismatch: have %d, want %d", dropped, 1)
+	}
+}
+
+// countingTracer counts the calls it traces.
+type countingTracer struct {
+	starts int32
+}
+
+func (t *countingTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	atomic.AddInt32(&t.starts, 1)
+}
+func (t *countingTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+}
+func (t *countingTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+func (t *countingTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {}
+
+// Tests that the transactions are committed with the VM config override, and
+// with the processor's one once it's lifted.
+func TestVMConfigOverride(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 1)
+	w, b := newTestWorker(t, nil, alloc)
+	tracer := new(countingTracer)
+	w.setVMConfigOverride(&vm.Config{Debug: true, Tracer: tracer})
+
+	env := newTestEnv(t, w, b.head)
+	w.commitPending(env, testPending(t, accounts[0].transfer(t, 0, params.GWei)), nil)
+	if len(env.txs) != 1 {
+		t.Fatalf("included transactions mismatch: have %d, want %d", len(env.txs), 1)
+	}
+	if starts := atomic.LoadInt32(&tracer.starts); starts != 1 {
+		t.Fatalf("traced calls mismatch: have %d, want %d", starts, 1)
+	}
+	w.setVMConfigOverride(nil)
+	env = newTestEnv(t, w, b.head)
+	w.commitPending(env, testPending(t, accounts[0].transfer(t, 0, params.GWei)), nil)
+	if len(env.txs) != 1 {
+		t.Fatalf("included transactions mismatch: have %d, want %d", len(env.txs), 1)
+	}
+	if starts := atomic.LoadInt32(&tracer.starts); starts != 1 {
+		t.Errorf("calls traced without the override: have %d, want %d", starts, 1)
+	}
+}
+
+// Tests that a generation in flight is reported, and overlapping ones rejected
+// if configured.
+func TestIsGenerating(t *testing.T) {
+	w, b := newTestWorker(t, &Config{RejectConcurrentGeneration: true}, nil)
+	if w.IsGenerating() {
+		t.Fatalf("generation reported before any")
+	}
+	// Hold the engine to stall the generation
+	w.engineMu.Lock()
+	done := make(chan error, 1)
+	go func() {
+		_, err := w.GeneratePendingHeader(b.head, true)
+		done <- err
+	}()
+	deadline := time.Now().Add(time.Second)
+	for !w.IsGenerating() {
+		if time.Now().After(deadline) {
+			w.engineMu.Unlock()
+			t.Fatalf("generation in flight not reported")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := w.GeneratePendingHeader(b.head, true); !errors.Is(err, ErrGenerationInProgress) {
+		t.Errorf("overlapping generation error mismatch: have %v, want %v", err, ErrGenerationInProgress)
+	}
+	w.engineMu.Unlock()
+
+	if err := <-done; err != nil {
+		t.Fatalf("failed to generate pending header: %v", err)
+	}
+	if w.IsGenerating() {
+		t.Errorf("generation reported once done")
+	}
+}
+
+// Tests that the gas used by each transaction of the pending block is reported
+// as a copy.
+func TestLastTxGasUsed(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 2)
+	w, b := newTestWorker(t, nil, alloc)
+	b.addTxs(t, accounts[0].transfer(t, 0, params.GWei), accounts[1].transfer(t, 0, params.GWei))
+	block := generatePending(t, w, b)
+
+	gasUsed := w.LastTxGasUsed()
+	if len(gasUsed) != len(block.Transactions()) || len(gasUsed) != 2 {
+		t.Fatalf("transactions mismatch: have %d, want %d", len(gasUsed), 2)
+	}
+	_, receipts := w.pendingBlockAndReceipts()
+	for _, receipt := range receipts {
+		if have := gasUsed[receipt.TxHash]; have != receipt.GasUsed || have != params.TxGas {
+			t.Errorf("tx %x: gas used mismatch: have %d, want %d", receipt.TxHash, have, receipt.GasUsed)
+		}
+	}
+	for hash := range gasUsed {
+		gasUsed[hash] = 0
+	}
+	for hash, used := range w.LastTxGasUsed() {
+		if used == 0 {
+			t.Errorf("tx %x: gas used modified through the returned map", hash)
+		}
+	}
+}
+
+// Tests that a burst of transaction events is coalesced into batches instead of
+// being handled one at a time.
+func TestCoalesceTxs(t *testing.T) {
+	w := &worker{
+		config: &Config{TxBatchSize: 10},
+		txsCh:  make(chan NewTxsEvent, txChanSize),
+	}
+	for i := 0; i < 26; i++ {
+		w.txsCh <- NewTxsEvent{}
+	}
+	// The first event of every batch is received by the loop
+	var batches []int
+	for len(w.txsCh) > 0 {
+		<-w.txsCh
+		batches = append(batches, w.coalesceTxs())
+	}
+	if len(batches) != 3 || batches[0] != 10 || batches[1] != 10 || batches[2] != 6 {
+		t.Errorf("batches mismatch: have %v, want %v", batches, []int{10, 10, 6})
+	}
+	// The batches queued behind a pending rebuild are merged into it
+	w.snapshotRebuildCh = make(chan int, 1)
+	for _, batch := range batches {
+		w.requestSnapshotRebuild(batch)
+	}
+	if queued := <-w.snapshotRebuildCh; queued != 26 {
+		t.Errorf("queued events mismatch: have %d, want %d", queued, 26)
+	}
+}
+
+// Tests that the new transactions are applied to the pending state without
+// holding up the handling of the chain heads.
+func TestTxsHandlerNonBlocking(t *testing.T) {
+	w, b := newTestWorker(t, &Config{RegenerateDebounce: time.Hour}, nil)
+	snapshot := generatePending(t, w, b)
+
+	ch := make(chan PendingInvalidatedEvent, 1)
+	sub := w.SubscribePendingInvalidated(ch)
+	defer sub.Unsubscribe()
+
+	// Stall the pending state rebuilds by holding the engine
+	w.engineMu.Lock()
+	for i := 0; i < 3; i++ {
+		w.txsCh <- NewTxsEvent{}
+	}
+	w.chainHeadCh <- ChainHeadEvent{Block: b.newBlock(t, b.head, nil)}
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		w.engineMu.Unlock()
+		t.Fatalf("chain head held up by the pending state rebuild")
+	}
+	w.engineMu.Unlock()
+
+	deadline := time.Now().Add(time.Second)
+	for w.pending() == snapshot {
+		if time.Now().After(deadline) {
+			t.Fatalf("pending state not rebuilt with the new transactions")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Tests that the exported fixture of the current environment matches it.
+func TestExportCurrentEnv(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 1)
+	w, b := newTestWorker(t, nil, alloc)
+	if _, err := w.ExportCurrentEnv(); err == nil {
+		t.Fatalf("environment exported before any")
+	}
+	tx := accounts[0].transfer(t, 0, params.GWei)
+	b.addTxs(t, tx)
+	block := generatePending(t, w, b)
+
+	fixture, err := w.ExportCurrentEnv()
+	if err != nil {
+		t.Fatalf("failed to export environment: %v", err)
+	}
+	w.currentMu.RLock()
+	env := w.current
+	gas, coinbase := env.gasPool.Gas(), env.coinbase
+	w.currentMu.RUnlock()
+
+	if fixture.Header.Hash() != block.Hash() || fixture.StateRoot != block.Root() {
+		t.Errorf("header mismatch: have %x, want %x", fixture.Header.Hash(), block.Hash())
+	}
+	if len(fixture.TxHashes) != 1 || fixture.TxHashes[0] != tx.Hash() {
+		t.Errorf("transactions mismatch: have %x, want %x", fixture.TxHashes, tx.Hash())
+	}
+	if len(fixture.EtxHashes) != 0 || len(fixture.UncleHashes) != 0 {
+		t.Errorf("unexpected etxs or uncles: %d etxs, %d uncles", len(fixture.EtxHashes), len(fixture.UncleHashes))
+	}
+	if !fixture.Coinbase.Equal(coinbase) || !coinbase.Equal(w.etherbase()) {
+		t.Errorf("coinbase mismatch: have %v, want %v", fixture.Coinbase, w.etherbase())
+	}
+	if fixture.GasRemaining != gas {
+		t.Errorf("remaining gas mismatch: have %d, want %d", fixture.GasRemaining, gas)
+	}
+	if _, err := json.Marshal(fixture); err != nil {
+		t.Errorf("failed to encode fixture: %v", err)
+	}
+}
+
+// Tests that a running worker generates a pending header on the fixed interval
+// even with an empty pool, and none while stopped.
+func TestFixedBlockInterval(t *testing.T) {
+	_, alloc := newTestAccounts(t, 0)
+	w, b := newTestWorker(t, &Config{FixedBlockInterval: 10 * time.Millisecond}, alloc)
+
+	ch := make(chan *types.Header, 16)
+	sub := w.SubscribeAsyncPendingHeader(ch)
+	defer sub.Unsubscribe()
+
+	select {
+	case <-ch:
+		t.Fatalf("pending header generated while stopped")
+	case <-time.After(50 * time.Millisecond):
+	}
+	w.start()
+	for i := 0; i < 3; i++ {
+		select {
+		case header := <-ch:
+			if header.NumberU64() != b.head.NumberU64()+1 {
+				t.Errorf("header %d number mismatch: have %d, want %d", i, header.NumberU64(), b.head.NumberU64()+1)
+			}
+			if header.TxHash() != types.EmptyRootHash {
+				t.Errorf("header %d not empty", i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("pending header %d not generated on the fixed interval", i)
+		}
+	}
+}
+
+// Tests that the effective config reflects the settings changed at runtime, and
+// that callers can't mutate the ones of the worker through it.
+func TestEffectiveConfig(t *testing.T) {
+	_, alloc := newTestAccounts(t, 0)
+	w, _ := newTestWorker(t, &Config{GasCeil: params.GenesisGasLimit}, alloc)
+
+	etherbase := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	w.setEtherbase(etherbase)
+	w.setGasCeil(2 * params.GenesisGasLimit)
+	w.setGasPrice(big.NewInt(params.GWei))
+	w.setExtra([]byte("extra"))
+
+	config := w.EffectiveConfig()
+	if !config.Etherbase.Equal(etherbase) {
+		t.Errorf("etherbase mismatch: have %v, want %v", config.Etherbase, etherbase)
+	}
+	if config.GasCeil != 2*params.GenesisGasLimit {
+		t.Errorf("gas ceiling mismatch: have %d, want %d", config.GasCeil, 2*params.GenesisGasLimit)
+	}
+	if config.GasPrice == nil || config.GasPrice.Cmp(big.NewInt(params.GWei)) != 0 {
+		t.Errorf("gas price mismatch: have %v, want %v", config.GasPrice, params.GWei)
+	}
+	if string(config.ExtraData) != "extra" {
+		t.Errorf("extra data mismatch: have %q, want %q", config.ExtraData, "extra")
+	}
+
+	config.GasPrice.SetUint64(1)
+	config.ExtraData[0] = 'X'
+	config = w.EffectiveConfig()
+	if config.GasPrice.Cmp(big.NewInt(params.GWei)) != 0 {
+		t.Errorf("gas price mutated through the effective config: %v", config.GasPrice)
+	}
+	if string(config.ExtraData) != "extra" {
+		t.Errorf("extra data mutated through the effective config: %q", config.ExtraData)
+	}
+}
+
+// reverseOrdering is a transaction ordering strategy set programmatically, which
+// is never written to the config file.
+type reverseOrdering struct{}
+
+func (reverseOrdering) OrderPending(signer types.Signer, pending map[common.AddressBytes]types.Transactions, baseFee *big.Int) TxIterator {
+	return nil
+}
+
+// Tests that the miner config survives a dump and reload of the config file,
+// using the field naming of the node config.
+func TestMinerConfigTOML(t *testing.T) {
+	settings := toml.Config{
+		NormFieldName: func(rt reflect.Type, key string) string { return key },
+		FieldToKey:    func(rt reflect.Type, field string) string { return field },
+	}
+	cfg := Config{
+		GasCeil:          params.GenesisGasLimit,
+		GasPrice:         big.NewInt(params.GWei),
+		GasCeilByContext: map[int]uint64{0: 10000000, 2: 30000000},
+		TxOrdering:       reverseOrdering{},
+	}
+	out, err := settings.Marshal(&cfg)
+	if err != nil {
+		t.Fatalf("failed to dump config: %v", err)
+	}
+	var loaded Config
+	if err := settings.Unmarshal(out, &loaded); err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if loaded.GasCeil != cfg.GasCeil {
+		t.Errorf("gas ceiling mismatch: have %d, want %d", loaded.GasCeil, cfg.GasCeil)
+	}
+	if have := loaded.GasCeilByContext; len(have) != 2 || have[0] != 10000000 || have[2] != 30000000 {
+		t.Errorf("gas ceilings by context mismatch: have %v, want %v", have, cfg.GasCeilByContext)
+	}
+	if loaded.TxOrdering != nil {
+		t.Errorf("transaction ordering loaded from the config file")
+	}
+}
+
+// Tests that the pending header carries the forced gas limit, and that it bounds
+// the transactions packed into the pending block.
+func TestGasLimitOverride(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 3)
+	w, b := newTestWorker(t, nil, alloc)
+	if _, err := w.GeneratePendingHeaderWithGasLimit(b.chain.CurrentBlock(), true, 0); err == nil {
+		t.Fatalf("pending header generated without a gas limit")
+	}
+	for _, account := range accounts {
+		b.addTxs(t, account.transfer(t, 0, params.GWei))
+	}
+	gasLimit := 2*params.TxGas + params.TxGas/2
+	header, err := w.GeneratePendingHeaderWithGasLimit(b.chain.CurrentBlock(), true, gasLimit)
+	if err != nil {
+		t.Fatalf("failed to generate pending header: %v", err)
+	}
+	if header.GasLimit() != gasLimit {
+		t.Errorf("gas limit mismatch: have %d, want %d", header.GasLimit(), gasLimit)
+	}
+	if header.GasUsed() != 2*params.TxGas {
+		t.Errorf("gas used mismatch: have %d, want %d", header.GasUsed(), 2*params.TxGas)
+	}
+}
+
+// Tests that cached pending block bodies not matching the roots of the requested
+// header are detected when verification is enabled.
+func TestVerifyPendingBodies(t *testing.T) {
+	defer func(old metrics.Meter) { pendingBodyCorruptMeter = old }(pendingBodyCorruptMeter)
+	pendingBodyCorruptMeter = metrics.NewMeterForced()
+
+	w, _ := newTestWorker(t, &Config{VerifyPendingBodies: true}, nil)
+
+	uncle := types.EmptyHeader()
+	uncle.SetNumber(big.NewInt(1))
+	header, body := pendingBodyTestHeader(2, []*types.Header{uncle})
+	w.AddPendingBlockBody(header, body)
+	if have := w.GetPendingBlockBody(header); have != body {
+		t.Fatalf("matching body not returned")
+	}
+	// Store a body under the key of a header it doesn't match
+	corrupt, _ := pendingBodyTestHeader(3, nil)
+	corrupt.SetUncleHash(types.CalcUncleHash([]*types.Header{types.EmptyHeader()}))
+	w.AddPendingBlockBody(corrupt, body)
+	if have := w.GetPendingBlockBody(corrupt); have != nil {
+		t.Errorf("mismatching body returned")
+	}
+	if count := pendingBodyCorruptMeter.Count(); count != 1 {
+		t.Errorf("corrupt bodies mismatch: have %d, want %d", count, 1)
+	}
+	w.config.VerifyPendingBodies = false
+	if have := w.GetPendingBlockBody(corrupt); have != body {
+		t.Errorf("body not returned without verification")
+	}
+}
+
+// Tests that the timestamp of the pending block is reported, including when it
+// is recapped after the parent's.
+func TestPendingTimestamp(t *testing.T) {
+	_, alloc := newTestAccounts(t, 0)
+	w, b := newTestWorker(t, nil, alloc)
+	if timestamp := w.PendingTimestamp(); timestamp != 0 {
+		t.Fatalf("timestamp reported without a pending block: %d", timestamp)
+	}
+	parent := b.chain.CurrentBlock()
+	for _, tt := range []struct {
+		timestamp uint64
+		want      uint64
+	}{
+		{parent.Time(), parent.Time() + 1},
+		{parent.Time() + 10, parent.Time() + 10},
+	} {
+		if _, err := w.GeneratePendingHeaderAt(parent, false, tt.timestamp, false); err != nil {
+			t.Fatalf("failed to generate pending header: %v", err)
+		}
+		if timestamp := w.PendingTimestamp(); timestamp != tt.want {
+			t.Errorf("timestamp %d mismatch: have %d, want %d", tt.timestamp, timestamp, tt.want)
+		}
+	}
+}
+
+// Tests that the inclusion policy decides which transactions are applied, and
+// that the deferred ones are kept for a later block.
+func TestInclusionPolicy(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 3)
+	w, b := newTestWorker(t, nil, alloc)
+	env := newTestEnv(t, w, b.head)
+
+	include, skip, deferred := accounts[0].transfer(t, 0, params.GWei), accounts[1].transfer(t, 0, params.GWei), accounts[2].transfer(t, 0, params.GWei)
+	decisions := map[common.Hash]InclusionDecision{
+		include.Hash():  InclusionInclude,
+		skip.Hash():     InclusionSkip,
+		deferred.Hash(): InclusionDefer,
+	}
+	w.setInclusionPolicy(func(tx *types.Transaction, env *environment) InclusionDecision {
+		return decisions[tx.Hash()]
+	})
+	w.commitPending(env, testPending(t, include, skip, deferred), nil)
+
+	if len(env.txs) != 1 || env.txs[0].Hash() != include.Hash() {
+		t.Fatalf("included transactions mismatch: have %d, want %x", len(env.txs), include.Hash())
+	}
+	w.currentMu.Lock()
+	w.current = env
+	w.currentMu.Unlock()
+	if txs := w.DeferredTransactions(); len(txs) != 1 || txs[0].Hash() != deferred.Hash() {
+		t.Errorf("deferred transactions mismatch: have %d, want %x", len(txs), deferred.Hash())
+	}
+}
+
+// Tests that the fee summary of the pending block aggregates the fees paid by its
+// transactions.
+func TestPendingFeeSummary(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 2)
+	w, b := newTestWorker(t, &Config{FeeSummary: true}, alloc)
+	if summary := w.PendingFeeSummary(); summary != nil {
+		t.Fatalf("fee summary reported without a pending block")
+	}
+	b.addTxs(t, accounts[0].transfer(t, 0, params.GWei), accounts[1].transfer(t, 0, 2*params.GWei))
+	block := generatePending(t, w, b)
+
+	summary := w.PendingFeeSummary()
+	if summary == nil {
+		t.Fatalf("fee summary not reported")
+	}
+	if summary.TxCount != 2 {
+		t.Errorf("transaction count mismatch: have %d, want %d", summary.TxCount, 2)
+	}
+	fees, burned := new(big.Int), new(big.Int)
+	for _, tx := range block.Transactions() {
+		tip := new(big.Int).Sub(tx.GasFeeCap(), block.BaseFee())
+		if tip.Cmp(tx.GasTipCap()) > 0 {
+			tip = tx.GasTipCap()
+		}
+		fees.Add(fees, new(big.Int).Mul(tip, new(big.Int).SetUint64(params.TxGas)))
+		burned.Add(burned, new(big.Int).Mul(block.BaseFee(), new(big.Int).SetUint64(params.TxGas)))
+	}
+	if summary.TotalFees.Cmp(fees) != 0 {
+		t.Errorf("total fees mismatch: have %v, want %v", summary.TotalFees, fees)
+	}
+	if summary.BaseFeeBurned.Cmp(burned) != 0 {
+		t.Errorf("base fee burned mismatch: have %v, want %v", summary.BaseFeeBurned, burned)
+	}
+}
+
+// Tests that the transactions of a sender are included one after the other once
+// committed, each priced against the heads of the other senders.
+func TestCommitSenderTransactions(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 2)
+	w, b := newTestWorker(t, nil, alloc)
+	env := newTestEnv(t, w, b.head)
+
+	txs := types.Transactions{
+		accounts[0].transfer(t, 0, 3*params.GWei),
+		accounts[0].transfer(t, 1, 3*params.GWei),
+		accounts[1].transfer(t, 0, 2*params.GWei),
+		accounts[0].transfer(t, 2, params.GWei),
+	}
+	w.commitPending(env, testPending(t, txs...), nil)
+
+	if len(env.txs) != len(txs) {
+		t.Fatalf("included transactions mismatch: have %d, want %d", len(env.txs), len(txs))
+	}
+	for i, tx := range txs {
+		if env.txs[i].Hash() != tx.Hash() {
+			t.Errorf("transaction %d mismatch: have %x, want %x", i, env.txs[i].Hash(), tx.Hash())
+		}
+	}
+}
+
+// Tests that the transactions of a sender are included up to the configured cap,
+// leaving room for the other senders.
+func TestMaxTxPerSender(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 2)
+	w, b := newTestWorker(t, nil, alloc)
+
+	var txs types.Transactions
+	for _, account := range accounts {
+		for nonce := uint64(0); nonce < 10; nonce++ {
+			txs = append(txs, account.transfer(t, nonce, params.GWei))
+		}
+	}
+	env := newTestEnv(t, w, b.head)
+	w.commitPending(env, testPending(t, txs...), nil)
+	if len(env.txs) != 20 {
+		t.Fatalf("included transactions mismatch: have %d, want %d", len(env.txs), 20)
+	}
+	w.config.MaxTxPerSender = 3
+	env = newTestEnv(t, w, b.head)
+	w.commitPending(env, testPending(t, txs...), nil)
+	if len(env.txs) != 6 {
+		t.Fatalf("included transactions mismatch: have %d, want %d", len(env.txs), 6)
+	}
+	counts := make(map[common.AddressBytes]int)
+	for _, sender := range txSenders(t, env.txs) {
+		counts[sender.Bytes20()]++
+	}
+	for _, account := range accounts {
+		if count := counts[account.address().Bytes20()]; count != 3 {
+			t.Errorf("sender %v included transactions mismatch: have %d, want %d", account.address(), count, 3)
+		}
+	}
+}
+
+// Tests that only the most recent sealing results are kept.
+func TestSealingHistoryRetention(t *testing.T) {
+	history := newSealingHistory(3)
+	for i := uint64(0); i < 5; i++ {
+		history.add(SealingResult{Number: i})
+	}
+	// Only the most recent results are kept, from the oldest to the newest
+	results := history.list()
+	if len(results) != 3 {
+		t.Fatalf("kept results mismatch: have %d, want %d", len(results), 3)
+	}
+	for i, result := range results {
+		if result.Number != uint64(i+2) {
+			t.Errorf("result %d mismatch: have block %d, want %d", i, result.Number, i+2)
+		}
+	}
+}
+
+// Tests that the blocks assembled by the worker are recorded in the sealing
+// history once sealed, and only once.
+func TestRecordSealed(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 1)
+	w, b := newTestWorker(t, nil, alloc)
+	b.addTxs(t, accounts[0].transfer(t, 0, params.GWei))
+	sealed := generatePending(t, w, b).Header()
+
+	// Headers of blocks not assembled locally are not recorded
+	other := types.CopyHeader(sealed)
+	other.SetNumber(new(big.Int).Add(sealed.Number(), common.Big1))
+	w.recordSealed(other)
+	if have := len(w.RecentSealingResults()); have != 0 {
+		t.Fatalf("unknown block recorded: have %d results", have)
+	}
+	w.recordSealed(sealed)
+	w.recordSealed(sealed)
+	results := w.RecentSealingResults()
+	if len(results) != 1 {
+		t.Fatalf("sealed block records mismatch: have %d, want %d", len(results), 1)
+	}
+	if results[0].SealHash != sealed.SealHash() || results[0].Number != sealed.NumberU64() || results[0].Txs != 1 {
+		t.Errorf("recorded result mismatch: have %+v", results[0])
+	}
+}
+
+// Tests that the sealed blocks are logged once at the configured depth below the
+// chain head.
+func TestSealedAtDepth(t *testing.T) {
+	w, _ := newTestWorker(t, &Config{SealingLogDepth: 2}, nil)
+	for i := uint64(1); i <= 5; i++ {
+		w.sealingHistory.add(SealingResult{Number: i})
+	}
+	if results := w.sealedAtDepth(5); len(results) != 1 || results[0].Number != 3 {
+		t.Fatalf("blocks at depth 2 mismatch: have %+v, want block 3", results)
+	}
+	// The depth follows the configuration
+	w.config.SealingLogDepth = 4
+	if results := w.sealedAtDepth(5); len(results) != 1 || results[0].Number != 1 {
+		t.Fatalf("blocks at depth 4 mismatch: have %+v, want block 1", results)
+	}
+	if results := w.sealedAtDepth(10); len(results) != 0 {
+		t.Fatalf("blocks past the depth logged: have %+v", results)
+	}
+}
+
+// Tests that the commit veto rejects the assembled blocks it refuses.
+func TestCommitVeto(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 1)
+	w, _ := newTestWorker(t, nil, alloc)
+
+	txs := types.Transactions{accounts[0].transfer(t, 0, params.GWei), accounts[0].transfer(t, 1, params.GWei), accounts[0].transfer(t, 2, params.GWei)}
+	small := types.NewBlockWithHeader(types.EmptyHeader()).WithBody(txs[:1], nil, nil, nil)
+	large := types.NewBlockWithHeader(types.EmptyHeader()).WithBody(txs, nil, nil, nil)
+	if err := w.checkCommitVeto(large, nil); err != nil {
+		t.Fatalf("block refused without veto: %v", err)
+	}
+	w.setCommitVeto(func(block *types.Block, receipts types.Receipts) error {
+		if len(block.Transactions()) > 2 {
+			return errors.New("too many transactions")
+		}
+		return nil
+	})
+	if err := w.checkCommitVeto(small, nil); err != nil {
+		t.Errorf("block within the policy refused: %v", err)
+	}
+	if err := w.checkCommitVeto(large, nil); !errors.Is(err, ErrSealingWorkVetoed) {
+		t.Errorf("vetoed block error mismatch: have %v, want %v", err, ErrSealingWorkVetoed)
+	}
+}
+
+// Tests that the uncles of the locally sealed blocks are recorded in the
+// inclusion metrics once per block.
+func TestSealedUncleMetrics(t *testing.T) {
+	enabled := metrics.Enabled
+	metrics.Enabled = true
+	hist := metrics.NewHistogram(metrics.NewUniformSample(16))
+	metrics.Enabled = enabled
+
+	defer func(old metrics.Histogram) { uncleIncludedHist = old }(uncleIncludedHist)
+	uncleIncludedHist = hist
+
+	w, _ := newTestWorker(t, nil, nil)
+	for uncles := 0; uncles <= 2; uncles++ {
+		header := types.EmptyHeader()
+		header.SetNumber(big.NewInt(int64(uncles + 1)))
+		w.assembled.Add(header.SealHash(), SealingResult{Number: header.NumberU64(), Uncles: uncles})
+
+		// A block submitted twice is only recorded once
+		w.recordSealed(header)
+		w.recordSealed(header)
+	}
+	if have := hist.Count(); have != 3 {
+		t.Fatalf("recorded blocks mismatch: have %d, want %d", have, 3)
+	}
+	if have := hist.Sum(); have != 3 {
+		t.Errorf("recorded uncles mismatch: have %d, want %d", have, 3)
+	}
+	if have := hist.Max(); have != 2 {
+		t.Errorf("max uncles mismatch: have %d, want %d", have, 2)
+	}
+}
+
+// Tests that the sealing results are encoded with the fields of the RPC API.
+func TestSealingResultJSON(t *testing.T) {
+	enc, err := json.Marshal(SealingResult{Number: 7, Txs: 2, Fees: big.NewFloat(1.5)})
+	if err != nil {
+		t.Fatalf("failed to encode result: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(enc, &fields); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	for _, field := range []string{"number", "sealHash", "txs", "etxs", "uncles", "gasUsed", "fees", "createdAt"} {
+		if _, ok := fields[field]; !ok {
+			t.Errorf("field %q missing from %s", field, enc)
+		}
+	}
+}
+
+// Tests that the stats report the running state, the recommit interval and the
+// last result committed for sealing, following pauses and restarts.
+func TestWorkerStats(t *testing.T) {
+	w, _ := newTestWorker(t, nil, nil)
+	if stats := w.Stats(); stats.Running || stats.LastSealed != nil {
+		t.Fatalf("stats of an idle worker mismatch: %+v", stats)
+	}
+	w.start()
+	w.sealingHistory.add(SealingResult{Number: 1})
+	w.sealingHistory.add(SealingResult{Number: 2})
+
+	stats := w.Stats()
+	if !stats.Running {
+		t.Errorf("running worker reported stopped")
+	}
+	if want := time.Duration(atomic.LoadInt64(&w.recommit)); stats.Recommit != want {
+		t.Errorf("recommit interval mismatch: have %v, want %v", stats.Recommit, want)
+	}
+	if stats.LastSealed == nil || stats.LastSealed.Number != 2 {
+		t.Errorf("last sealed result mismatch: have %+v, want number %d", stats.LastSealed, 2)
+	}
+	w.pause()
+	if w.Stats().Running {
+		t.Errorf("paused worker reported running")
+	}
+	w.start()
+	if !w.Stats().Running {
+		t.Errorf("restarted worker reported stopped")
+	}
+}
+
+// Tests that only the failures which may not recur in a later block are retried.
+func TestTransientCommitError(t *testing.T) {
+	tests := []struct {
+		err       error
+		transient bool
+	}{
+		{ErrEtxLimitReached, true},
+		{fmt.Errorf("wrapped: %w", ErrInsufficientFunds), true},
+		{ErrNonceTooLow, false},
+		{ErrNonceTooHigh, false},
+		{ErrGasLimitReached, false},
+		{ErrTxTypeNotSupported, false},
+		{errZeroGasUsed, false},
+		{errors.New("execution reverted"), false},
+	}
+	for i, tt := range tests {
+		if have := isTransientCommitError(tt.err); have != tt.transient {
+			t.Errorf("test %d: transient mismatch for %v: have %v, want %v", i, tt.err, have, tt.transient)
+		}
+	}
+}
+
+// Tests that the transactions to retry are taken once, in nonce order, as long
+// as they are still pooled.
+func TestTakeRetryTxs(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 1)
+	w, b := newTestWorker(t, nil, alloc)
+
+	pooled, evicted, later := accounts[0].transfer(t, 0, params.GWei), accounts[0].transfer(t, 1, params.GWei), accounts[0].transfer(t, 2, params.GWei)
+	b.addTxs(t, pooled, later)
+	w.addRetryTxs([]*types.Transaction{later, evicted, pooled})
+
+	// Only the transactions still in the pool are retried, in nonce order
+	retries := w.takeRetryTxs(testSigner)
+	txs := retries[accounts[0].address().Bytes20()]
+	if len(retries) != 1 || len(txs) != 2 {
+		t.Fatalf("retried transactions mismatch: have %v, want 2 of one sender", retries)
+	}
+	if txs[0].Hash() != pooled.Hash() || txs[1].Hash() != later.Hash() {
+		t.Errorf("retried transactions order mismatch: have nonces %d, %d", txs[0].Nonce(), txs[1].Nonce())
+	}
+	// The retry set is emptied once taken
+	if retries := w.takeRetryTxs(testSigner); len(retries) != 0 {
+		t.Errorf("retry set not emptied: have %d senders left", len(retries))
+	}
+}
+
+// Tests that bundles are only accepted for the blocks from the next one to the
+// bundle lookahead.
+func TestValidateBundle(t *testing.T) {
+	accounts, _ := newTestAccounts(t, 1)
+	txs := types.Transactions{accounts[0].transfer(t, 0, params.GWei)}
+
+	tests := []struct {
+		bundle *Bundle
+		ok     bool
+	}{
+		{&Bundle{BlockNumber: 10}, false},                               // empty
+		{&Bundle{Txs: txs, BlockNumber: 9}, false},                      // past block
+		{&Bundle{Txs: txs, BlockNumber: 10}, true},                      // next block
+		{&Bundle{Txs: txs, BlockNumber: 10 + maxBundleLookahead}, true}, // furthest block
+		{&Bundle{Txs: txs, BlockNumber: 11 + maxBundleLookahead}, false},
+	}
+	for i, tt := range tests {
+		if err := validateBundle(tt.bundle, 10); (err == nil) != tt.ok {
+			t.Errorf("test %d: bundle for block %d validity mismatch: have %v, want ok %v", i, tt.bundle.BlockNumber, err, tt.ok)
+		}
+	}
+}
+
+// Tests that the bundles of a block are returned, and the ones of past blocks
+// dropped.
+func TestBundlesAt(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 1)
+	w, b := newTestWorker(t, nil, alloc)
+	txs := types.Transactions{accounts[0].transfer(t, 0, params.GWei)}
+
+	next := b.head.NumberU64() + 1
+	for _, number := range []uint64{next, next + 1, next + 2, next + 1} {
+		if err := w.addBundle(&Bundle{Txs: txs, BlockNumber: number}); err != nil {
+			t.Fatalf("failed to add bundle for block %d: %v", number, err)
+		}
+	}
+	if err := w.addBundle(&Bundle{Txs: txs, BlockNumber: next - 1}); err == nil {
+		t.Fatalf("bundle for past block added")
+	}
+	if have := w.bundlesAt(next + 1); len(have) != 2 {
+		t.Fatalf("bundles for block mismatch: have %d, want %d", len(have), 2)
+	}
+	// The bundles of past blocks are dropped, the others kept for regenerations
+	if len(w.bundles) != 3 {
+		t.Fatalf("kept bundles mismatch: have %d, want %d", len(w.bundles), 3)
+	}
+	for _, bundle := range w.bundles {
+		if bundle.BlockNumber < next+1 {
+			t.Errorf("bundle for past block %d kept", bundle.BlockNumber)
+		}
+	}
+}
+
+// Tests that the packing rules of the miner apply to every transaction of a
+// bundle.
+func TestBundlePolicyCheck(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 2)
+	w, b := newTestWorker(t, nil, alloc)
+	env := newTestEnv(t, w, b.head)
+
+	allowed, other := accounts[0], accounts[1]
+	bundle := &Bundle{Txs: types.Transactions{allowed.transfer(t, 0, params.GWei), allowed.transfer(t, 1, params.GWei)}, BlockNumber: 1}
+
+	// The allowlist applies to every transaction of the bundle
+	policy := &bundlePolicy{
+		allowlistMode:  true,
+		allowedSenders: map[common.AddressBytes]struct{}{allowed.address().Bytes20(): {}},
+	}
+	if err := policy.check(env, bundle); err != nil {
+		t.Fatalf("allowed bundle refused: %v", err)
+	}
+	mixed := &Bundle{Txs: types.Transactions{allowed.transfer(t, 0, params.GWei), other.transfer(t, 0, params.GWei)}, BlockNumber: 1}
+	if err := policy.check(env, mixed); err == nil {
+		t.Fatalf("bundle with unlisted sender accepted")
+	}
+	// The per sender cap counts the transactions already in the block
+	policy = &bundlePolicy{maxTxPerSender: 2}
+	if err := policy.check(env, bundle); err != nil {
+		t.Fatalf("bundle within sender cap refused: %v", err)
+	}
+	env.txs = types.Transactions{allowed.transfer(t, 5, params.GWei)}
+	if err := policy.check(env, bundle); err == nil {
+		t.Fatalf("bundle over sender cap accepted")
+	}
+	env.txs = nil
+
+	// Skipped and deferred transactions discard the bundle as a whole
+	for _, decision := range []InclusionDecision{InclusionSkip, InclusionDefer} {
+		decision := decision
+		policy = &bundlePolicy{inclusion: func(tx *types.Transaction, env *environment) InclusionDecision {
+			if tx.Nonce() == 1 {
+				return decision
+			}
+			return InclusionInclude
+		}}
+		if err := policy.check(env, bundle); err == nil {
+			t.Errorf("bundle with transaction refused by inclusion policy (%d) accepted", decision)
+		}
+	}
+}
+
+// Tests that a bundle failing after some of its transactions were applied rolls
+// the environment back to its state before the bundle, and that only the
+// transactions of the committed bundles are counted.
+func TestCommitBundleRollback(t *testing.T) {
+	defer func(old metrics.Counter) { txCommittedCounter = old }(txCommittedCounter)
+	txCommittedCounter = metrics.NewCounterForced()
+
+	accounts, alloc := newTestAccounts(t, 1)
+	w, b := newTestWorker(t, nil, alloc)
+	env := newTestEnv(t, w, b.head)
+	gas := env.gasPool.Gas()
+
+	txs := types.Transactions{accounts[0].transfer(t, 0, params.GWei), accounts[0].transfer(t, 1, params.GWei)}
+
+	// The second transaction reuses the nonce of the first, failing the bundle
+	// once the first one is applied
+	bundle := &Bundle{Txs: types.Transactions{txs[0], txs[0]}, BlockNumber: env.header.NumberU64()}
+	if err := w.commitBundle(env, bundle); err == nil {
+		t.Fatalf("failing bundle committed")
+	}
+	if len(env.txs) != 0 || len(env.receipts) != 0 || env.header.GasUsed() != 0 {
+		t.Fatalf("failing bundle left %d txs and %d gas used", len(env.txs), env.header.GasUsed())
+	}
+	if nonce := env.state.GetNonce(accounts[0].addr); nonce != 0 {
+		t.Errorf("sender nonce not rolled back: have %d, want %d", nonce, 0)
+	}
+	if have := env.gasPool.Gas(); have != gas {
+		t.Errorf("gas pool not rolled back: have %d, want %d", have, gas)
+	}
+	if count := txCommittedCounter.Count(); count != 0 {
+		t.Errorf("committed transactions mismatch after rollback: have %d, want %d", count, 0)
+	}
+	// Simulating a bundle leaves the environment and the counters untouched
+	coinbase, err := env.coinbase.InternalAddress()
+	if err != nil {
+		t.Fatalf("failed to resolve the coinbase: %v", err)
+	}
+	if _, err := w.simulateBundle(env, coinbase, &Bundle{Txs: txs, BlockNumber: env.header.NumberU64()}); err != nil {
+		t.Fatalf("failed to simulate bundle: %v", err)
+	}
+	if len(env.txs) != 0 || txCommittedCounter.Count() != 0 {
+		t.Fatalf("simulated bundle left %d txs and %d committed", len(env.txs), txCommittedCounter.Count())
+	}
+	// The environment is still usable after the rollback
+	if err := w.commitBundle(env, &Bundle{Txs: txs, BlockNumber: env.header.NumberU64()}); err != nil {
+		t.Fatalf("failed to commit bundle: %v", err)
+	}
+	if len(env.txs) != 2 {
+		t.Errorf("included transactions mismatch: have %d, want %d", len(env.txs), 2)
+	}
+	if count := txCommittedCounter.Count(); count != 2 {
+		t.Errorf("committed transactions mismatch: have %d, want %d", count, 2)
+	}
+}
+
+// Tests that packing with the outcome of the parallel speculative execution
+// produces the same block as executing the transactions sequentially.
+func TestParallelPackingMatchesSequential(t *testing.T) {
+	w, b, pending := newParallelTestWorker(t, 4, 8, 6)
+
+	sequential := newTestEnv(t, w, b.head)
+	w.commitPending(sequential, copyPending(pending), nil)
+
+	w.config.ParallelPacking = true
+	parallel := newTestEnv(t, w, b.head)
+	w.commitPending(parallel, copyPending(pending), nil)
+
+	if len(sequential.txs) != 8*6 {
+		t.Fatalf("sequential packing included %d transactions, want %d", len(sequential.txs), 8*6)
+	}
+	if len(parallel.txs) != len(sequential.txs) {
+		t.Fatalf("included transactions mismatch: have %d, want %d", len(parallel.txs), len(sequential.txs))
+	}
+	for i := range sequential.txs {
+		if parallel.txs[i].Hash() != sequential.txs[i].Hash() {
+			t.Fatalf("transaction %d mismatch: have %x, want %x", i, parallel.txs[i].Hash(), sequential.txs[i].Hash())
+		}
+		have, want := parallel.receipts[i], sequential.receipts[i]
+		if have.Status != want.Status || have.GasUsed != want.GasUsed || have.CumulativeGasUsed != want.CumulativeGasUsed ||
+			have.TransactionIndex != want.TransactionIndex || len(have.Logs) != len(want.Logs) {
+			t.Errorf("receipt %d mismatch: have %+v, want %+v", i, have, want)
+		}
+	}
+	if parallel.header.GasUsed() != sequential.header.GasUsed() {
+		t.Errorf("gas used mismatch: have %d, want %d", parallel.header.GasUsed(), sequential.header.GasUsed())
+	}
+	if have, want := parallel.state.IntermediateRoot(true), sequential.state.IntermediateRoot(true); have != want {
+		t.Errorf("state root mismatch: have %x, want %x", have, want)
+	}
+}
+
+// Tests that the outcome of a speculative execution is only applied while the
+// state it read is unchanged.
+func TestSpeculatedOutcomeValidity(t *testing.T) {
+	w, b, pending := newParallelTestWorker(t, 4, 4, 2)
+	w.config.ParallelPacking = true
+	env := newTestEnv(t, w, b.head)
+	kept, speculated := w.speculateTransactions(env, copyPending(pending))
+
+	var count int
+	for sender, txs := range pending {
+		if len(kept[sender]) != len(txs) {
+			t.Errorf("sender %x: kept transactions mismatch: have %d, want %d", sender, len(kept[sender]), len(txs))
+		}
+		count += len(txs)
+	}
+	if len(speculated) != count {
+		t.Fatalf("speculated transactions mismatch: have %d, want %d", len(speculated), count)
+	}
+	for sender, txs := range pending {
+		spec := speculated[txs[0].Hash()]
+		if !spec.access.valid(env.state) {
+			t.Fatalf("sender %x: outcome of the first transaction invalid on the unchanged state", sender)
+		}
+		// Changing the balance of the sender invalidates its outcome
+		internal, err := txSenders(t, txs[:1])[0].InternalAddress()
+		if err != nil {
+			t.Fatalf("sender outside of the zone: %v", err)
+		}
+		snap := env.state.Snapshot()
+		env.state.AddBalance(internal, big.NewInt(1))
+		if spec.access.valid(env.state) {
+			t.Errorf("sender %x: outcome valid on a state changed since", sender)
+		}
+		env.state.RevertToSnapshot(snap)
+	}
+}
+
+// Tests that the pending transactions bound to fail on top of a pending block are
+// recorded by its pre-execution, and that the pre-execution is waited for and not
+// started anymore once the worker is closed.
+func TestPreexecuteChild(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 2)
+	w, b := newTestWorker(t, nil, alloc)
+	included, pending := accounts[0].transfer(t, 0, params.GWei), accounts[1].transfer(t, 0, params.GWei)
+	b.addTxs(t, included, pending)
+
+	// The transaction included in the parent is still pending in the pool, but
+	// can't be applied again on top of it
+	env := newTestEnv(t, w, b.head)
+	w.commitPending(env, testPending(t, included), nil)
+	parent := types.NewBlockWithHeader(env.header)
+	w.preexecuteChild(parent, env.state, env.coinbase)
+
+	var result *speculativeResult
+	for deadline := time.Now().Add(time.Second); result == nil; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("pre-execution result never recorded")
+		}
+		result = w.speculative.get(parent.Header().SealHash())
+	}
+	if _, ok := result.failed[included.Hash()]; !ok || len(result.failed) != 1 {
+		t.Errorf("failed transactions mismatch: have %v, want %x", result.failed, included.Hash())
+	}
+
+	closed := newWorker(&Config{Etherbase: w.config.Etherbase}, b.config, b.db, testEngine{}, b.chain, b.txPool, nil, false, true)
+	closed.preexecuteChild(parent, env.state, env.coinbase)
+	closed.close()
+	if atomic.LoadInt32(&closed.preexecuting) != 0 {
+		t.Fatalf("pre-execution still running after the worker was closed")
+	}
+	closed.speculative.retain(common.Hash{})
+	closed.preexecuteChild(parent, env.state, env.coinbase)
+	if atomic.LoadInt32(&closed.preexecuting) != 0 || closed.speculative.get(parent.Header().SealHash()) != nil {
+		t.Errorf("pre-execution started on a closed worker")
+	}
+}
+
+func BenchmarkPackingDisjointSequential(b *testing.B) { benchmarkPackingDisjoint(b, false) }
+func BenchmarkPackingDisjointParallel(b *testing.B)   { benchmarkPackingDisjoint(b, true) }
+
+// benchmarkPackingDisjoint measures packing the transactions of senders touching
+// disjoint state, sequentially or with the outcome of the parallel speculative
+// execution.
+func benchmarkPackingDisjoint(b *testing.B, parallel bool) {
+	w, backend, pending := newParallelTestWorker(b, 4, 64, 8)
+	w.config.ParallelPacking = parallel
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		env, err := w.prepareWork(&generateParams{}, backend.head)
+		if err != nil {
+			b.Fatalf("failed to prepare sealing environment: %v", err)
+		}
+		w.adjustGasLimit(nil, env, backend.head)
+		txs := copyPending(pending)
+		b.StartTimer()
+
+		w.commitPending(env, txs, nil)
+
+		b.StopTimer()
+		env.release()
+		b.StartTimer()
+	}
+}
+
+// Tests that the headers prepared by the consensus engine are checked for the
+// fields required for sealing.
+func TestValidatePreparedHeader(t *testing.T) {
+	common.NodeLocation = common.Location{0, 0}
+
+	parent := types.EmptyHeader()
+	parent.SetNumber(big.NewInt(10))
+	tests := []struct {
+		number     int64
+		difficulty int64
+		valid      bool
+	}{
+		{11, 100, true},
+		{11, 0, false}, // difficulty left unset by the engine
+		{10, 100, false},
+		{12, 100, false},
+	}
+	for i, tt := range tests {
+		header := types.EmptyHeader()
+		header.SetNumber(big.NewInt(tt.number))
+		header.SetDifficulty(big.NewInt(tt.difficulty))
+		if err := validatePreparedHeader(header, parent); (err == nil) != tt.valid {
+			t.Errorf("test %d: validity mismatch: have %v, want %v", i, err, tt.valid)
+		}
+	}
+}
+
+// zeroDifficultyEngine is a test engine leaving the difficulty of the headers it
+// prepares unset.
+type zeroDifficultyEngine struct {
+	testEngine
+}
+
+func (zeroDifficultyEngine) Prepare(chain consensus.ChainHeaderReader, header *types.Header, parent *types.Header) error {
+	header.SetDifficulty(new(big.Int))
+	return nil
+}
+
+// Tests that generating a pending header fails right after an engine prepared an
+// invalid header.
+func TestInvalidPreparedHeader(t *testing.T) {
+	w, b := newTestWorker(t, nil, nil)
+	if err := w.setEngine(zeroDifficultyEngine{}); err != nil {
+		t.Fatalf("failed to swap engine: %v", err)
+	}
+	_, err := w.GeneratePendingHeader(b.chain.CurrentBlock(), true)
+	if err == nil || !strings.Contains(err.Error(), "difficulty") {
+		t.Fatalf("invalid prepared header error mismatch: have %v, want difficulty error", err)
+	}
+}
+
+// nilBlockEngine is a test engine assembling no block without reporting an error.
+type nilBlockEngine struct {
+	testEngine
+}
+
+func (nilBlockEngine) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, etxs []*types.Transaction, manifest types.BlockManifest, receipts []*types.Receipt) (*types.Block, error) {
+	return nil, nil
+}
+
+// Tests that generating a header fails gracefully when the engine assembles a
+// nil block instead of panicking.
+func TestNilAssembledBlock(t *testing.T) {
+	w, b := newTestWorker(t, nil, nil)
+	if err := w.setEngine(nilBlockEngine{}); err != nil {
+		t.Fatalf("failed to swap engine: %v", err)
+	}
+	if _, err := w.GeneratePendingHeader(b.chain.CurrentBlock(), true); !errors.Is(err, errNilBlock) {
+		t.Errorf("pending header error mismatch: have %v, want %v", err, errNilBlock)
+	}
+	if _, err := w.GenerateSpeculativeHeader(b.chain.CurrentBlock(), true); !errors.Is(err, errNilBlock) {
+		t.Errorf("speculative header error mismatch: have %v, want %v", err, errNilBlock)
+	}
+}
+
+// Tests that the transactions failing to be committed are reported along with
+// their error.
+func TestTxFailureEvents(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 1)
+	w, b := newTestWorker(t, nil, alloc)
+	env := newTestEnv(t, w, b.head)
+
+	ch := make(chan TxFailureEvent, 1)
+	sub := w.SubscribeTxFailures(ch)
+	defer sub.Unsubscribe()
+
+	tx := accounts[0].transfer(t, 1, params.GWei)
+	w.commitPending(env, testPending(t, tx), nil)
+
+	select {
+	case ev := <-ch:
+		if ev.Hash != tx.Hash() || !ev.Sender.Equal(accounts[0].address()) || ev.Number != env.header.NumberU64() {
+			t.Errorf("failure event mismatch: have %x from %v at %d, want %x from %v at %d", ev.Hash, ev.Sender, ev.Number, tx.Hash(), accounts[0].address(), env.header.NumberU64())
+		}
+		if !errors.Is(ev.Err, ErrNonceTooHigh) {
+			t.Errorf("failure error mismatch: have %v, want %v", ev.Err, ErrNonceTooHigh)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("commit failure not reported")
+	}
+}
+
+// Tests that the transactions arriving are counted until the count is reset.
+func TestNewTxCount(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 1)
+	w, _ := newTestWorker(t, nil, alloc)
+
+	w.txsCh <- NewTxsEvent{Txs: types.Transactions{accounts[0].transfer(t, 0, params.GWei), accounts[0].transfer(t, 1, params.GWei)}}
+	deadline := time.Now().Add(time.Second)
+	for w.NewTxCount() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("new transactions count mismatch: have %d, want %d", w.NewTxCount(), 2)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	w.ResetNewTxCount()
+	if count := w.NewTxCount(); count != 0 {
+		t.Errorf("new transactions count not reset: have %d", count)
+	}
+}
+
+// Tests that the pending header is regenerated on the new chain heads only if
+// automatic regeneration is enabled and the worker is running.
+func TestAutoRegenerate(t *testing.T) {
+	for _, tt := range []struct {
+		auto, running, regenerated bool
+	}{
+		{true, true, true},
+		{false, true, false},
+		{true, false, false},
+	} {
+		w, b := newTestWorker(t, &Config{AutoRegenerate: tt.auto, Recommit: time.Hour}, nil)
+		if tt.running {
+			w.start()
+		}
+		ch := make(chan *types.Header, 1)
+		sub := w.SubscribeAsyncPendingHeader(ch)
+
+		w.chainHeadCh <- ChainHeadEvent{Block: b.head}
+		select {
+		case header := <-ch:
+			if !tt.regenerated {
+				t.Errorf("auto %v, running %v: pending header regenerated", tt.auto, tt.running)
+			} else if header.ParentHash() != b.head.Hash() {
+				t.Errorf("auto %v, running %v: parent mismatch: have %x, want %x", tt.auto, tt.running, header.ParentHash(), b.head.Hash())
+			}
+		case <-time.After(200 * time.Millisecond):
+			if tt.regenerated {
+				t.Errorf("auto %v, running %v: pending header not regenerated", tt.auto, tt.running)
+			}
+		}
+		sub.Unsubscribe()
+	}
+}
+
+// Tests that the seal hash of the pending block is reported.
+func TestPendingSealHash(t *testing.T) {
+	w, b := newTestWorker(t, nil, nil)
+	if hash := w.PendingSealHash(); hash != (common.Hash{}) {
+		t.Fatalf("seal hash reported without a pending block: %x", hash)
+	}
+	header, err := w.GeneratePendingHeader(b.chain.CurrentBlock(), true)
+	if err != nil {
+		t.Fatalf("failed to generate pending header: %v", err)
+	}
+	if hash := w.PendingSealHash(); hash != header.SealHash() {
+		t.Errorf("seal hash mismatch: have %x, want %x", hash, header.SealHash())
+	}
+}
+
+// Tests that the entropy info of the pending block is the one the engine reports
+// for its parent.
+func TestPendingEntropyInfo(t *testing.T) {
+	w, b := newTestWorker(t, nil, nil)
+	if _, _, _, err := w.PendingEntropyInfo(); err == nil {
+		t.Fatalf("entropy info reported without a pending block")
+	}
+	header, err := w.GeneratePendingHeader(b.head, true)
+	if err != nil {
+		t.Fatalf("failed to generate pending header: %v", err)
+	}
+	order, entropy, deltaS, err := w.PendingEntropyInfo()
+	if err != nil {
+		t.Fatalf("failed to retrieve entropy info: %v", err)
+	}
+	parent := b.chain.GetHeaderByHash(header.ParentHash())
+	_, wantOrder, _ := w.getEngine().CalcOrder(parent)
+	if order != wantOrder {
+		t.Errorf("order mismatch: have %d, want %d", order, wantOrder)
+	}
+	if want := w.getEngine().TotalLogS(parent); entropy.Cmp(want) != 0 {
+		t.Errorf("parent entropy mismatch: have %v, want %v", entropy, want)
+	}
+	if want := w.getEngine().DeltaLogS(parent); deltaS.Cmp(want) != 0 {
+		t.Errorf("parent delta S mismatch: have %v, want %v", deltaS, want)
+	}
+}
+
+// Tests that the gas ceiling of the running context overrides the scalar one,
+// which is used for the contexts not set.
+func TestGasCeilByContext(t *testing.T) {
+	ceils := map[int]uint64{common.ZONE_CTX: 30000000, common.PRIME_CTX: 50000000}
+	w, _ := newTestWorker(t, &Config{GasCeil: 10000000, GasCeilByContext: ceils}, nil)
+	if ceil := w.gasCeil(); ceil != 30000000 {
+		t.Fatalf("gas ceiling mismatch: have %d, want %d", ceil, 30000000)
+	}
+	// Setting the ceiling overrides the one of the running context only
+	w.setGasCeil(40000000)
+	if ceil := w.gasCeil(); ceil != 40000000 {
+		t.Errorf("gas ceiling mismatch: have %d, want %d", ceil, 40000000)
+	}
+	if ceil := w.config.GasCeilByContext[common.PRIME_CTX]; ceil != 50000000 {
+		t.Errorf("prime gas ceiling mismatch: have %d, want %d", ceil, 50000000)
+	}
+	if ceil := ceils[common.ZONE_CTX]; ceil != 30000000 {
+		t.Errorf("gas ceilings of the config mutated: have %d, want %d", ceil, 30000000)
+	}
+	// Without a ceiling for the running context, the scalar one applies
+	w, _ = newTestWorker(t, &Config{GasCeil: 10000000, GasCeilByContext: map[int]uint64{common.PRIME_CTX: 50000000}}, nil)
+	if ceil := w.gasCeil(); ceil != 10000000 {
+		t.Errorf("fallback gas ceiling mismatch: have %d, want %d", ceil, 10000000)
+	}
+}
+
+// Tests that the gas remaining in the current environment reflects the gas used
+// by the transactions packed.
+func TestPendingGasPool(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 1)
+	w, b := newTestWorker(t, nil, alloc)
+	if _, _, ok := w.PendingGasPool(); ok {
+		t.Fatalf("gas pool reported without an environment")
+	}
+	b.addTxs(t, accounts[0].transfer(t, 0, params.GWei))
+	block := generatePending(t, w, b)
+
+	remaining, limit, ok := w.PendingGasPool()
+	if !ok {
+		t.Fatalf("gas pool not reported")
+	}
+	if limit != block.GasLimit() {
+		t.Errorf("gas limit mismatch: have %d, want %d", limit, block.GasLimit())
+	}
+	if remaining != limit-params.TxGas {
+		t.Errorf("remaining gas mismatch: have %d, want %d", remaining, limit-params.TxGas)
+	}
+}
+
+// stubPrefetcher is a state recording prefetch activity, reporting it as trie
+// prefetcher statistics.
+type stubPrefetcher struct {
+	tasks  []common.Hash
+	hits   uint64
+	misses uint64
+}
+
+func (s *stubPrefetcher) prefetch(root common.Hash) { s.tasks = append(s.tasks, root) }
+
+func (s *stubPrefetcher) deliver(root common.Hash) {
+	for i, task := range s.tasks {
+		if task == root {
+			s.tasks = append(s.tasks[:i], s.tasks[i+1:]...)
+			s.hits++
+			return
+		}
+	}
+	s.misses++
+}
+
+func (s *stubPrefetcher) PrefetcherStats() (pending int, hits, misses uint64, ok bool) {
+	return len(s.tasks), s.hits, s.misses, true
+}
+
+// Tests that the trie prefetcher statistics are reported from states exposing
+// them, and not without a prefetcher running.
+func TestPrefetcherStats(t *testing.T) {
+	w, b := newTestWorker(t, nil, nil)
+	if _, _, _, ok := w.PrefetcherStats(); ok {
+		t.Fatalf("prefetcher stats reported without an environment")
+	}
+	// The test states are not backed by snapshots, so no prefetcher is started
+	env := newTestEnv(t, w, b.head)
+	env.state.StartPrefetcher("miner")
+	w.setCurrent(env)
+	if _, _, _, ok := w.PrefetcherStats(); ok {
+		t.Errorf("prefetcher stats reported without a prefetcher")
+	}
+
+	state := new(stubPrefetcher)
+	state.prefetch(common.Hash{1})
+	state.prefetch(common.Hash{2})
+	state.deliver(common.Hash{1})
+	state.deliver(common.Hash{3})
+	pending, hits, misses, ok := prefetcherStats(state)
+	if !ok {
+		t.Fatalf("prefetcher stats not reported")
+	}
+	if pending != 1 || hits != 1 || misses != 1 {
+		t.Errorf("prefetcher stats mismatch: have %d/%d/%d, want %d/%d/%d", pending, hits, misses, 1, 1, 1)
+	}
+	if _, _, _, ok := prefetcherStats(struct{}{}); ok {
+		t.Errorf("prefetcher stats reported from a state without them")
+	}
+}
+
+// Tests that the gas reserve is kept from the transactions, unless not below the
+// gas limit.
+func TestGasPoolReserve(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 3)
+	w, b := newTestWorker(t, nil, alloc)
+
+	var txs types.Transactions
+	for _, account := range accounts {
+		txs = append(txs, account.transfer(t, 0, params.GWei))
+	}
+	env := newTestEnv(t, w, b.head)
+	env.gasPool = nil
+	w.config.GasPoolReserve = env.header.GasLimit() - 2*params.TxGas - params.TxGas/2
+	w.commitPending(env, testPending(t, txs...), nil)
+	if len(env.txs) != 2 {
+		t.Errorf("included transactions mismatch: have %d, want %d", len(env.txs), 2)
+	}
+	if gas := env.gasPool.Gas(); gas != params.TxGas/2 {
+		t.Errorf("remaining gas mismatch: have %d, want %d", gas, params.TxGas/2)
+	}
+	// A reserve not below the gas limit is ignored
+	env = newTestEnv(t, w, b.head)
+	env.gasPool = nil
+	w.config.GasPoolReserve = env.header.GasLimit()
+	w.commitPending(env, testPending(t, txs...), nil)
+	if len(env.txs) != 3 {
+		t.Errorf("included transactions mismatch: have %d, want %d", len(env.txs), 3)
+	}
+}
+
+// Tests that the accounts the pool considers local are reported.
+func TestLocalAccounts(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 2)
+	w, b := newTestWorker(t, nil, alloc)
+	if locals := w.LocalAccounts(); len(locals) != 0 {
+		t.Fatalf("local accounts reported without any: %v", locals)
+	}
+	b.addTxs(t, accounts[0].transfer(t, 0, params.GWei))
+	if errs := b.txPool.AddRemotes(types.Transactions{accounts[1].transfer(t, 0, params.GWei)}); errs[0] != nil {
+		t.Fatalf("failed to add remote transaction: %v", errs[0])
+	}
+	locals := w.LocalAccounts()
+	if len(locals) != 1 || !locals[0].Equal(accounts[0].address()) {
+		t.Errorf("local accounts mismatch: have %v, want %v", locals, accounts[0].address())
+	}
+}
+
+// Tests that no more transactions are packed once the fill deadline passed.
+func TestFillDeadline(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 2)
+	w, b := newTestWorker(t, nil, alloc)
+	txs := []*types.Transaction{accounts[0].transfer(t, 0, params.GWei), accounts[1].transfer(t, 0, params.GWei)}
+
+	env := newTestEnv(t, w, b.head)
+	env.fillDeadline = time.Now().Add(-time.Millisecond)
+	w.commitPending(env, testPending(t, txs...), nil)
+	if len(env.txs) != 0 {
+		t.Errorf("transactions packed past the deadline: %d", len(env.txs))
+	}
+	env = newTestEnv(t, w, b.head)
+	env.fillDeadline = time.Now().Add(time.Minute)
+	w.commitPending(env, testPending(t, txs...), nil)
+	if len(env.txs) != len(txs) {
+		t.Errorf("included transactions mismatch: have %d, want %d", len(env.txs), len(txs))
+	}
+}
+
+// Tests that the committed and rejected transactions are counted per outcome, and
+// the hit rate of the pending block body cache is reported.
+func TestWorkerMetrics(t *testing.T) {
+	defer func(old metrics.Counter) { txCommittedCounter = old }(txCommittedCounter)
+	defer func(old metrics.Counter) { txRejectedNonceHiCounter = old }(txRejectedNonceHiCounter)
+	defer func(old metrics.Meter) { pendingBodyHitMeter = old }(pendingBodyHitMeter)
+	defer func(old metrics.Meter) { pendingBodyMissMeter = old }(pendingBodyMissMeter)
+	defer func(old metrics.Gauge) { pendingBodyHitRateGauge = old }(pendingBodyHitRateGauge)
+	txCommittedCounter = metrics.NewCounterForced()
+	txRejectedNonceHiCounter = metrics.NewCounterForced()
+	pendingBodyHitMeter = metrics.NewMeterForced()
+	pendingBodyMissMeter = metrics.NewMeterForced()
+	pendingBodyHitRateGauge = &metrics.StandardGauge{}
+
+	accounts, alloc := newTestAccounts(t, 2)
+	w, b := newTestWorker(t, nil, alloc)
+	env := newTestEnv(t, w, b.head)
+	w.commitPending(env, testPending(t, accounts[0].transfer(t, 0, params.GWei), accounts[1].transfer(t, 1, params.GWei)), nil)
+
+	if count := txCommittedCounter.Count(); count != 1 {
+		t.Errorf("committed transactions mismatch: have %d, want %d", count, 1)
+	}
+	if count := txRejectedNonceHiCounter.Count(); count != 1 {
+		t.Errorf("rejected transactions mismatch: have %d, want %d", count, 1)
+	}
+	header, body := pendingBodyTestHeader(2, nil)
+	w.AddPendingBlockBody(header, body)
+	missing, _ := pendingBodyTestHeader(3, []*types.Header{types.EmptyHeader()})
+	for _, h := range []*types.Header{header, header, header, missing} {
+		w.GetPendingBlockBody(h)
+	}
+	if rate := pendingBodyHitRateGauge.Value(); rate != 75 {
+		t.Errorf("pending body hit rate mismatch: have %d, want %d", rate, 75)
+	}
+}
+
+// Tests that the coinbase rotates between the etherbases by block number, round-
+// robin or by weight, and that invalid rotations are refused.
+func TestEtherbaseRotation(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 3)
+	w, b := newTestWorker(t, nil, alloc)
+	addrs := []common.Address{accounts[0].address(), accounts[1].address(), accounts[2].address()}
+
+	if err := w.setEtherbases(nil, nil); err == nil {
+		t.Errorf("empty rotation accepted")
+	}
+	if err := w.setEtherbases(addrs, []uint64{1, 2}); err == nil {
+		t.Errorf("rotation with missing weights accepted")
+	}
+	if err := w.setEtherbases(addrs, []uint64{0, 0, 0}); err == nil {
+		t.Errorf("rotation with zero weights accepted")
+	}
+	tests := []struct {
+		weights []uint64
+		want    []int // Etherbase credited with the blocks numbered from 0
+	}{
+		{nil, []int{0, 1, 2, 0, 1, 2}},
+		{[]uint64{2, 0, 1}, []int{0, 0, 2, 0, 0, 2}},
+	}
+	for i, tt := range tests {
+		if err := w.setEtherbases(addrs, tt.weights); err != nil {
+			t.Fatalf("test %d: failed to set etherbases: %v", i, err)
+		}
+		for number, want := range tt.want {
+			if have := w.etherbaseAt(big.NewInt(int64(number))); !have.Equal(addrs[want]) {
+				t.Errorf("test %d: block %d etherbase mismatch: have %v, want %v", i, number, have, addrs[want])
+			}
+		}
+	}
+	// The pending block is credited to the etherbase of its number
+	w.start()
+	defer w.pause()
+	env := newTestEnv(t, w, b.head)
+	if want := addrs[env.header.NumberU64()%3]; !env.header.Coinbase().Equal(want) {
+		t.Errorf("pending coinbase mismatch: have %v, want %v", env.header.Coinbase(), want)
+	}
+	// Setting the primary etherbase keeps the rotation, any other drops it
+	w.setEtherbase(addrs[0])
+	if len(w.etherbases) != len(addrs) {
+		t.Errorf("rotation dropped setting the primary etherbase")
+	}
+	w.setEtherbase(addrs[1])
+	if have := w.etherbaseAt(big.NewInt(0)); len(w.etherbases) != 0 || !have.Equal(addrs[1]) {
+		t.Errorf("rotation kept setting a new etherbase: have %v, want %v", have, addrs[1])
+	}
+}
+
+// Tests that the transactions of the priority senders are committed ahead of the
+// better paying ones, within the priority gas cap.
+func TestPrioritySenders(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 2)
+	w, b := newTestWorker(t, nil, alloc)
+	w.setPrioritySenders([]common.Address{accounts[0].address()})
+	if senders := w.PrioritySenders(); len(senders) != 1 || !senders[0].Equal(accounts[0].address()) {
+		t.Fatalf("priority senders mismatch: have %v, want %v", senders, accounts[0].address())
+	}
+	cheap, rich := accounts[0].transfer(t, 0, params.GWei), accounts[1].transfer(t, 0, 10*params.GWei)
+	b.addTxs(t, cheap, rich)
+
+	block := generatePending(t, w, b)
+	if txs := block.Transactions(); len(txs) != 2 || txs[0].Hash() != cheap.Hash() {
+		t.Fatalf("priority transaction not committed first")
+	}
+	// The priority transactions are held to the cap, the rest of the gas left over
+	w.config.PriorityGasCap = params.TxGas
+	env := newTestEnv(t, w, b.head)
+	pending := testPending(t, cheap, accounts[0].transfer(t, 1, params.GWei), rich)
+	priority := w.takePriorityTxs(pending)
+	if len(priority) != 1 || len(pending) != 1 {
+		t.Fatalf("priority transactions not taken out of the pending set")
+	}
+	gas := env.gasPool.Gas()
+	w.commitPriorityTransactions(env, priority, nil)
+	if len(env.txs) != 1 || env.txs[0].Hash() != cheap.Hash() {
+		t.Errorf("included transactions mismatch: have %d, want the first priority one", len(env.txs))
+	}
+	if have, want := env.gasPool.Gas(), gas-params.TxGas; have != want {
+		t.Errorf("remaining gas mismatch: have %d, want %d", have, want)
+	}
+}
+
+// Tests that the remote transactions are kept out of the gas reserved for the
+// local ones, even when paying more.
+func TestLocalGasReserve(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 4)
+	for _, reserve := range []uint64{0, 1} {
+		w, b := newTestWorker(t, &Config{GasCeil: 100 * params.TxGas, LocalGasReserve: reserve}, alloc)
+		local := accounts[0].transfer(t, 0, params.GWei)
+		b.addTxs(t, local)
+
+		txs := []*types.Transaction{local}
+		for _, account := range accounts[1:] {
+			txs = append(txs, account.transfer(t, 0, 10*params.GWei))
+		}
+		env := newTestEnv(t, w, b.head)
+		env.gasPool = new(GasPool).AddGas(3 * params.TxGas)
+		w.commitPending(env, testPending(t, txs...), nil)
+
+		if len(env.txs) != 3 {
+			t.Fatalf("reserve %d%%: included transactions mismatch: have %d, want %d", reserve, len(env.txs), 3)
+		}
+		if included := env.txs[2].Hash() == local.Hash(); included != (reserve > 0) {
+			t.Errorf("reserve %d%%: local transaction inclusion mismatch: have %v, want %v", reserve, included, reserve > 0)
+		}
+	}
+}
+
+// Tests that only the journaled sealed blocks neither imported nor stale are
+// replayed after a restart, and that the imported ones are pruned.
+func TestSealedJournalReplay(t *testing.T) {
+	b := newTestBackend(t, nil)
+	sealed := func(number uint64) *types.Block {
+		header := types.EmptyHeader()
+		header.SetNumber(new(big.Int).SetUint64(number))
+		header.SetLocation(common.NodeLocation)
+		return types.NewBlockWithHeader(header)
+	}
+	head := sealed(staleThreshold + 1)
+	fresh, stale := sealed(head.NumberU64()), sealed(1)
+	imported := b.newBlock(t, b.head, nil)
+
+	journal := newSealedJournal(b.db)
+	for _, block := range []*types.Block{fresh, stale, imported} {
+		journal.add(block)
+	}
+	// Only the fresh block is replayed, the others are dropped from the journal
+	var replayed []*types.Block
+	restarted := newSealedJournal(b.db)
+	restarted.replay(b.chain, head, func(block *types.Block) { replayed = append(replayed, block) })
+	if len(replayed) != 1 || replayed[0].Hash() != fresh.Hash() {
+		t.Fatalf("replayed blocks mismatch: have %d, want the fresh one", len(replayed))
+	}
+	if hashes := rawdb.ReadAllSealedBlockHashes(b.db); len(hashes) != 1 || hashes[0] != fresh.Hash() {
+		t.Errorf("journaled blocks mismatch: have %v, want %v", hashes, fresh.Hash())
+	}
+	// Imported blocks are pruned, the pending ones only once stale
+	restarted.add(imported)
+	restarted.prune(b.chain, head)
+	if hashes := rawdb.ReadAllSealedBlockHashes(b.db); len(hashes) != 1 || hashes[0] != fresh.Hash() {
+		t.Errorf("imported block not pruned: %v", hashes)
+	}
+	restarted.prune(b.chain, sealed(head.NumberU64()+staleThreshold))
+	if hashes := rawdb.ReadAllSealedBlockHashes(b.db); len(hashes) != 0 {
+		t.Errorf("stale block not pruned: %v", hashes)
+	}
+}
+
+// Tests that the remote transactions tipping below the miner gas price are left
+// out of the block, while the local ones are exempted.
+func TestTipFloor(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 3)
+	w, b := newTestWorker(t, nil, alloc)
+	local := accounts[0].transfer(t, 0, params.GWei)
+	b.addTxs(t, local)
+	txs := []*types.Transaction{local, accounts[1].transfer(t, 0, params.GWei), accounts[2].transfer(t, 0, 3*params.GWei)}
+
+	w.setGasPrice(big.NewInt(2 * params.GWei))
+	env := newTestEnv(t, w, b.head)
+	w.commitPending(env, testPending(t, txs...), nil)
+	if len(env.txs) != 2 {
+		t.Fatalf("included transactions mismatch: have %d, want %d", len(env.txs), 2)
+	}
+	for _, tx := range env.txs {
+		if tx.Hash() == txs[1].Hash() {
+			t.Errorf("underpriced remote transaction included")
+		}
+	}
+	w.setGasPrice(nil)
+	env = newTestEnv(t, w, b.head)
+	w.commitPending(env, testPending(t, txs...), nil)
+	if len(env.txs) != len(txs) {
+		t.Errorf("included transactions mismatch without floor: have %d, want %d", len(env.txs), len(txs))
+	}
+}
+
+// Tests that a simulated block holds the given transactions on top of the pool
+// ones, and is assembled like the pending block, manifest and etx rollup included,
+// without caching its body.
+func TestSimulatePendingBlock(t *testing.T) {
+	accounts, alloc := newTestAccounts(t, 2)
+	w, b := newTestWorker(t, nil, alloc)
+	pooled := accounts[0].transfer(t, 0, params.GWei)
+	b.addTxs(t, pooled)
+
+	header, err := w.GeneratePendingHeader(b.chain.CurrentBlock(), true)
+	if err != nil {
+		t.Fatalf("failed to generate pending header: %v", err)
+	}
+	simulated := accounts[1].transfer(t, 0, params.GWei)
+	result, err := w.SimulatePendingBlock(types.Transactions{simulated})
+	if err != nil {
+		t.Fatalf("failed to simulate pending block: %v", err)
+	}
+	if txs := result.Block.Transactions(); len(txs) != 2 {
+		t.Fatalf("simulated transactions mismatch: have %d, want %d", len(txs), 2)
+	}
+	if have, want := result.Block.Header().ManifestHash(), header.ManifestHash(); have != want {
+		t.Errorf("manifest hash mismatch: have %x, want %x", have, want)
+	}
+	if have, want := result.Block.Header().EtxRollupHash(), header.EtxRollupHash(); have != want {
+		t.Errorf("etx rollup hash mismatch: have %x, want %x", have, want)
+	}
+	if pending := b.txPool.Get(simulated.Hash()); pending != nil {
+		t.Errorf("simulated transaction added to the pool")
+	}
+	if body := w.GetPendingBlockBody(result.Block.Header()); body != nil {
+		t.Errorf("simulated block body cached")
+	}
+	if body := rawdb.ReadPendingBody(b.db, w.getPendingBlockBodyKey(result.Block.Header())); body != nil {
+		t.Errorf("simulated block body persisted")
+	}
+}
+
+// notifyTestHeader creates a header of a work package to notify.
+func notifyTestHeader() *types.Header {
+	header := types.EmptyHeader()
+	header.SetNumber(big.NewInt(1))
+	header.SetDifficulty(big.NewInt(1000))
+	return header
+}
+
+// Tests that a work package is delivered once the endpoint recovers, and that
+// the health of the endpoint reflects the failed attempts.
+func TestWorkNotifierRetry(t *testing.T) {
+	common.NodeLocation = common.Location{0, 0}
+
+	var (
+		requests int32
+		work     [4]string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		blob, _ := io.ReadAll(r.Body)
+		json.Unmarshal(blob, &work)
+	}))
+	defer server.Close()
+
+	notifier := newWorkNotifier([]string{server.URL}, false)
+	notifier.backoff = time.Millisecond
+	header := notifyTestHeader()
+	notifier.notify(header)
+	notifier.wg.Wait()
+	notifier.stop()
+
+	if have := atomic.LoadInt32(&requests); have != 3 {
+		t.Fatalf("delivery attempts mismatch: have %d, want %d", have, 3)
+	}
+	if work[0] != header.SealHash().Hex() {
+		t.Errorf("delivered work mismatch: have %s, want %s", work[0], header.SealHash().Hex())
+	}
+	health := notifier.health()[0]
+	if health.Delivered != 1 || health.Failed != 0 || health.ConsecutiveFailures != 0 || health.LastError == "" {
+		t.Errorf("endpoint health mismatch: have %+v", health)
+	}
+}
+
+// Tests that the delivery to an unavailable endpoint is given up after all the
+// attempts are exhausted.
+func TestWorkNotifierGiveUp(t *testing.T) {
+	common.NodeLocation = common.Location{0, 0}
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	notifier := newWorkNotifier([]string{server.URL}, false)
+	notifier.backoff = time.Millisecond
+	for i := 0; i < 2; i++ {
+		notifier.notify(notifyTestHeader())
+		notifier.wg.Wait()
+	}
+	notifier.stop()
+
+	if have := atomic.LoadInt32(&requests); have != 2*notifyAttempts {
+		t.Fatalf("delivery attempts mismatch: have %d, want %d", have, 2*notifyAttempts)
+	}
+	if health := notifier.health()[0]; health.Delivered != 0 || health.Failed != 2 || health.ConsecutiveFailures != 2 {
+		t.Errorf("endpoint health mismatch: have %+v", health)
+	}
+}
+
+// Tests that the pending headers are withheld from the miners in dry-run mode.
+func TestDryRunWithholdsPendingHeaders(t *testing.T) {
+	w, _ := newTestWorker(t, nil, nil)
+	headerCh := make(chan *types.Header, 1)
+	sub := w.pendingHeaderFeed.Subscribe(headerCh)
+	defer sub.Unsubscribe()
+
+	w.setDryRun(true)
+	w.sendPendingHeader(types.EmptyHeader())
+	select {
+	case <-headerCh:
+		t.Fatalf("pending header handed to the miners in dry-run mode")
+	default:
+	}
+	w.setDryRun(false)
+	w.sendPendingHeader(types.EmptyHeader())
+	select {
+	case <-headerCh:
+	default:
+		t.Fatalf("pending header withheld out of dry-run mode")
+	}
+}
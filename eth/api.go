@@ -92,6 +92,100 @@ func NewPrivateMinerAPI(e *Quai) *PrivateMinerAPI {
 	return &PrivateMinerAPI{e: e}
 }
 
+// SendBundleArgs represents the arguments of a transaction bundle submission.
+type SendBundleArgs struct {
+	Txs         []hexutil.Bytes `json:"txs"`
+	BlockNumber hexutil.Uint64  `json:"blockNumber"`
+}
+
+// SendBundle submits a bundle of signed transactions to be included atomically
+// and in order into the block of the given number, at most a few blocks ahead.
+func (api *PrivateMinerAPI) SendBundle(args SendBundleArgs) error {
+	txs := make(types.Transactions, 0, len(args.Txs))
+	for i, encoded := range args.Txs {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(encoded); err != nil {
+			return fmt.Errorf("invalid bundle transaction %d: %v", i, err)
+		}
+		txs = append(txs, tx)
+	}
+	return api.e.Core().SendBundle(txs, uint64(args.BlockNumber))
+}
+
+// Start resumes committing sealing work.
+func (api *PrivateMinerAPI) Start() bool {
+	api.e.Core().StartSealing()
+	return true
+}
+
+// Stop pauses committing sealing work, it can be resumed with Start.
+func (api *PrivateMinerAPI) Stop() bool {
+	api.e.Core().StopSealing()
+	return true
+}
+
+// MinerStats is the runtime state of the miner returned by GetStats.
+type MinerStats struct {
+	Running          bool                `json:"running"`
+	DryRun           bool                `json:"dryRun"`
+	RecommitInterval hexutil.Uint64      `json:"recommitInterval"` // In milliseconds
+	LastSealed       *core.SealingResult `json:"lastSealed"`
+}
+
+// GetStats returns the running state, the recommit interval and the last block
+// sealed locally.
+func (api *PrivateMinerAPI) GetStats() MinerStats {
+	stats := api.e.Core().MinerStats()
+	return MinerStats{
+		Running:          stats.Running,
+		DryRun:           stats.DryRun,
+		RecommitInterval: hexutil.Uint64(stats.Recommit / time.Millisecond),
+		LastSealed:       stats.LastSealed,
+	}
+}
+
+// SetDryRun toggles the dry-run mode, in which blocks are assembled and the
+// pending state is kept up to date, but nothing is pushed for sealing.
+func (api *PrivateMinerAPI) SetDryRun(enabled bool) bool {
+	api.e.Core().SetMinerDryRun(enabled)
+	return true
+}
+
+// RecommitStats is the state of the recommit interval controller returned by
+// GetRecommitStats, all intervals in milliseconds.
+type RecommitStats struct {
+	Adaptive         bool           `json:"adaptive"`
+	Recommit         hexutil.Uint64 `json:"recommit"`
+	Min              hexutil.Uint64 `json:"min"`
+	Max              hexutil.Uint64 `json:"max"`
+	Target           hexutil.Uint64 `json:"target"`
+	AvgBlockInterval hexutil.Uint64 `json:"avgBlockInterval"`
+	AvgEtxs          float64        `json:"avgEtxs"`
+	Samples          hexutil.Uint64 `json:"samples"`
+}
+
+// GetRecommitStats returns the current recommit interval along with the block
+// statistics the adaptive controller derives it from.
+func (api *PrivateMinerAPI) GetRecommitStats() RecommitStats {
+	stats := api.e.Core().RecommitStats()
+	return RecommitStats{
+		Adaptive:         stats.Adaptive,
+		Recommit:         hexutil.Uint64(stats.Recommit / time.Millisecond),
+		Min:              hexutil.Uint64(stats.Min / time.Millisecond),
+		Max:              hexutil.Uint64(stats.Max / time.Millisecond),
+		Target:           hexutil.Uint64(stats.Target / time.Millisecond),
+		AvgBlockInterval: hexutil.Uint64(stats.AvgBlockInterval / time.Millisecond),
+		AvgEtxs:          stats.AvgEtxs,
+		Samples:          hexutil.Uint64(stats.Samples),
+	}
+}
+
+// GetNotifyHealth returns the delivery records of the endpoints notified of new
+// work packages.
+func (api *PrivateMinerAPI) GetNotifyHealth() []core.NotifyEndpointHealth {
+	return api.e.Core().NotifyHealth()
+}
+
 // SetExtra sets the extra data string that is included when this miner mines a block.
 func (api *PrivateMinerAPI) SetExtra(extra string) (bool, error) {
 	if err := api.e.Core().SetExtra([]byte(extra)); err != nil {
@@ -100,7 +194,8 @@ func (api *PrivateMinerAPI) SetExtra(extra string) (bool, error) {
 	return true, nil
 }
 
-// SetGasPrice sets the minimum accepted gas price for the miner.
+// SetGasPrice sets the minimum accepted gas price for the miner. The price is
+// enforced both by the transaction pool and when packing the sealing block.
 func (api *PrivateMinerAPI) SetGasPrice(gasPrice hexutil.Big) bool {
 	api.e.lock.Lock()
 	api.e.gasPrice = (*big.Int)(&gasPrice)
@@ -122,6 +217,38 @@ func (api *PrivateMinerAPI) SetEtherbase(etherbase common.Address) bool {
 	return true
 }
 
+// SetEtherbases sets the etherbases the block rewards rotate between, weighted by
+// the given weights or round-robin if none are given.
+func (api *PrivateMinerAPI) SetEtherbases(etherbases []common.Address, weights []hexutil.Uint64) (bool, error) {
+	var ws []uint64
+	for _, weight := range weights {
+		ws = append(ws, uint64(weight))
+	}
+	if err := api.e.Core().SetEtherbases(etherbases, ws); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SetPrioritySenders sets the senders whose pending transactions are committed
+// ahead of the price ordered ones, replacing the previous list.
+func (api *PrivateMinerAPI) SetPrioritySenders(addrs []common.Address) bool {
+	api.e.Core().SetPrioritySenders(addrs)
+	return true
+}
+
+// PrioritySenders returns the senders whose transactions are committed first.
+func (api *PrivateMinerAPI) PrioritySenders() []common.Address {
+	return api.e.Core().PrioritySenders()
+}
+
+// SetEtxCap sets the maximum number and total gas of the ETXs emitted by the
+// transactions of a block, zero lifting the respective limit.
+func (api *PrivateMinerAPI) SetEtxCap(count hexutil.Uint64, gas hexutil.Uint64) bool {
+	api.e.Core().SetEtxCap(uint64(count), uint64(gas))
+	return true
+}
+
 // SetRecommitInterval updates the interval for miner sealing work recommitting.
 func (api *PrivateMinerAPI) SetRecommitInterval(interval int) {
 	api.e.Core().SetRecommitInterval(time.Duration(interval) * time.Millisecond)
@@ -158,6 +158,14 @@ func (b *QuaiAPIBackend) PendingBlockAndReceipts() (*types.Block, types.Receipts
 	return b.eth.core.PendingBlockAndReceipts()
 }
 
+func (b *QuaiAPIBackend) SimulatePendingBlock(txs types.Transactions) (*core.SimulatedBlock, error) {
+	return b.eth.core.SimulatePendingBlock(txs)
+}
+
+func (b *QuaiAPIBackend) UncleCandidates() []core.UncleCandidate {
+	return b.eth.core.UncleCandidates()
+}
+
 func (b *QuaiAPIBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
 	nodeCtx := common.NodeLocation.Context()
 	if nodeCtx != common.ZONE_CTX {
@@ -349,6 +357,14 @@ func (b *QuaiAPIBackend) TxPoolContent() (map[common.InternalAddress]types.Trans
 	return b.eth.core.Content()
 }
 
+func (b *QuaiAPIBackend) TxPoolContentPage(queued bool, sender common.InternalAddress, nonce uint64, limit int) map[common.InternalAddress]types.Transactions {
+	nodeCtx := common.NodeLocation.Context()
+	if nodeCtx != common.ZONE_CTX {
+		return nil
+	}
+	return b.eth.core.ContentPage(queued, sender, nonce, limit)
+}
+
 func (b *QuaiAPIBackend) TxPoolContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
 	nodeCtx := common.NodeLocation.Context()
 	if nodeCtx != common.ZONE_CTX {
@@ -357,6 +373,34 @@ func (b *QuaiAPIBackend) TxPoolContentFrom(addr common.Address) (types.Transacti
 	return b.eth.core.ContentFrom(addr)
 }
 
+func (b *QuaiAPIBackend) TxPoolStuckAccounts() []core.StuckAccount {
+	nodeCtx := common.NodeLocation.Context()
+	if nodeCtx != common.ZONE_CTX {
+		return nil
+	}
+	return b.eth.core.TxPoolStuckAccounts()
+}
+
+func (b *QuaiAPIBackend) EtxPoolStatus() (core.EtxPoolStatus, error) {
+	nodeCtx := common.NodeLocation.Context()
+	if nodeCtx != common.ZONE_CTX {
+		return core.EtxPoolStatus{}, errors.New("etx pool status can only be retrieved in zone chain")
+	}
+	return b.eth.core.EtxPoolStatus(), nil
+}
+
+func (b *QuaiAPIBackend) TxPoolReplacementPolicy() core.ReplacementPolicy {
+	return b.eth.core.TxPoolReplacementPolicy()
+}
+
+func (b *QuaiAPIBackend) SetTxPoolReplacementPolicy(policy core.ReplacementPolicy) error {
+	nodeCtx := common.NodeLocation.Context()
+	if nodeCtx != common.ZONE_CTX {
+		return errors.New("replacement policy can only be set in zone chain")
+	}
+	return b.eth.core.SetTxPoolReplacementPolicy(policy)
+}
+
 func (b *QuaiAPIBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
 	nodeCtx := common.NodeLocation.Context()
 	if nodeCtx != common.ZONE_CTX {
@@ -460,6 +504,10 @@ func (b *QuaiAPIBackend) ConstructLocalMinedBlock(header *types.Header) (*types.
 	return b.eth.core.ConstructLocalMinedBlock(header)
 }
 
+func (b *QuaiAPIBackend) JournalSealedBlock(block *types.Block) {
+	b.eth.core.JournalSealedBlock(block)
+}
+
 func (b *QuaiAPIBackend) InsertBlock(ctx context.Context, block *types.Block) (int, error) {
 	return b.eth.core.InsertChain([]*types.Block{block})
 }
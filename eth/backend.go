@@ -182,6 +182,9 @@ func New(stack *node.Node, config *ethconfig.Config) (*Quai, error) {
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
 	}
+	if config.TxPool.RemoteJournal != "" {
+		config.TxPool.RemoteJournal = stack.ResolvePath(config.TxPool.RemoteJournal)
+	}
 
 	eth.core, err = core.NewCore(chainDb, &config.Miner, eth.isLocalBlock, &config.TxPool, &config.TxLookupLimit, chainConfig, eth.config.SlicesRunning, eth.config.DomUrl, eth.config.SubUrls, eth.engine, cacheConfig, vmConfig, config.Genesis)
 	if err != nil {
@@ -394,6 +397,10 @@ func (s *Quai) Start() error {
 	maxPeers := s.p2pServer.MaxPeers
 	// Start the networking layer
 	s.handler.Start(maxPeers)
+
+	// Import the blocks sealed before the last shutdown which did not make it
+	// into the chain
+	go s.core.ReplaySealedBlocks()
 	return nil
 }
 
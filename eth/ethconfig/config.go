@@ -71,6 +71,8 @@ var Defaults = Config{
 		GasCeil:  18000000,
 		GasPrice: big.NewInt(params.GWei),
 		Recommit: 3 * time.Second,
+
+		AutoRegenerate: true,
 	},
 	TxPool:      core.DefaultTxPoolConfig,
 	RPCGasCap:   50000000,
@@ -71,8 +71,9 @@ var errTerminated = errors.New("terminated")
 // blockRetrievalFn is a callback type for retrieving a block from the local chain.
 type blockRetrievalFn func(common.Hash) *types.Block
 
-// blockWriteFn is a callback type for retrieving a block from the local chain.
-type blockWriteFn func(*types.Block)
+// blockWriteFn is a callback type for writing a block received from a peer to the
+// local chain.
+type blockWriteFn func(block *types.Block, peer string)
 
 // headerRequesterFn is a callback type for sending a header retrieval request.
 type headerRequesterFn func(common.Hash) error
@@ -781,7 +782,7 @@ func (f *BlockFetcher) ImportBlocks(peer string, block *types.Block, relay bool)
 			return
 		}
 		// TODO: verify the Headers work to be in a certain threshold window
-		f.writeBlock(block)
+		f.writeBlock(block, peer)
 		// If import succeeded, broadcast the block
 		blockAnnounceOutTimer.UpdateSince(block.ReceivedAt)
 
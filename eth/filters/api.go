@@ -589,6 +589,9 @@ func decodeTopic(s string) (common.Hash, error) {
 }
 
 // PendingHeader sends a notification each time a new pending header is created.
+// Besides the header fields, every notification carries the seal hash and the
+// target the pow has to meet, so that external miners can start working on the
+// header without fetching the work package.
 func (api *PublicFilterAPI) PendingHeader(ctx context.Context) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
@@ -604,9 +607,7 @@ func (api *PublicFilterAPI) PendingHeader(ctx context.Context) (*rpc.Subscriptio
 		for {
 			select {
 			case b := <-header:
-				// Marshal the header data
-				marshalHeader := b.RPCMarshalHeader()
-				notifier.Notify(rpcSub.ID, marshalHeader)
+				notifier.Notify(rpcSub.ID, b.RPCMarshalPendingHeader())
 			case <-rpcSub.Err():
 				headerSub.Unsubscribe()
 				return
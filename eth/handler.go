@@ -193,8 +193,9 @@ func newHandler(config *handlerConfig) (*handler, error) {
 	currentDifficulty := func() *big.Int {
 		return h.core.CurrentHeader().Difficulty()
 	}
-	// writeBlock writes the block to the DB
-	writeBlock := func(block *types.Block) {
+	// writeBlock writes the block to the DB, noting the peer it was received from
+	writeBlock := func(block *types.Block, peer string) {
+		h.core.RecordBlockPeer(block.Hash(), peer)
 		if nodeCtx == common.ZONE_CTX && block.NumberU64()-1 == h.core.CurrentHeader().NumberU64() && h.core.ProcessingState() {
 			if atomic.LoadUint32(&h.acceptTxs) != 1 {
 				atomic.StoreUint32(&h.acceptTxs, 1)
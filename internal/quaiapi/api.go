@@ -193,13 +193,58 @@ func (s *PublicTxPoolAPI) ContentFrom(addr common.Address) map[string]map[string
 	return content
 }
 
-// Status returns the number of pending and queued transaction in the pool.
-func (s *PublicTxPoolAPI) Status() map[string]hexutil.Uint {
-	pending, queue := s.b.Stats()
+// Status returns the number of pending and queued transaction in the pool,
+// optionally restricted to the given senders.
+func (s *PublicTxPoolAPI) Status(filter *TxPoolFilterArgs) (map[string]hexutil.Uint, error) {
+	if filter == nil || len(filter.Addresses) == 0 {
+		pending, queue := s.b.Stats()
+		return map[string]hexutil.Uint{
+			"pending": hexutil.Uint(pending),
+			"queued":  hexutil.Uint(queue),
+		}, nil
+	}
+	pendingTxs, queuedTxs, err := txPoolContentFrom(s.b, filter.Addresses)
+	if err != nil {
+		return nil, err
+	}
+	var pending, queue int
+	for _, txs := range pendingTxs {
+		pending += len(txs)
+	}
+	for _, txs := range queuedTxs {
+		queue += len(txs)
+	}
 	return map[string]hexutil.Uint{
 		"pending": hexutil.Uint(pending),
 		"queued":  hexutil.Uint(queue),
+	}, nil
+}
+
+// StuckAccounts returns the accounts whose queued transactions can't be promoted,
+// along with the nonce blocking them and the balance they need.
+func (s *PublicTxPoolAPI) StuckAccounts() []map[string]interface{} {
+	accounts := s.b.TxPoolStuckAccounts()
+	result := make([]map[string]interface{}, len(accounts))
+	for i, account := range accounts {
+		result[i] = map[string]interface{}{
+			"address":  account.Address.Hex(),
+			"reason":   account.Reason,
+			"nonce":    hexutil.Uint64(account.Nonce),
+			"queued":   hexutil.Uint(account.Queued),
+			"balance":  (*hexutil.Big)(account.Balance),
+			"required": (*hexutil.Big)(account.Required),
+			"since":    hexutil.Uint64(account.Since.Unix()),
+		}
+	}
+	return result
+}
+
+// formatPoolTransaction flattens a pooled transaction into a string.
+func formatPoolTransaction(tx *types.Transaction) string {
+	if to := tx.To(); to != nil {
+		return fmt.Sprintf("%s: %v wei + %v gas × %v wei", tx.To().Hex(), tx.Value(), tx.Gas(), tx.GasPrice())
 	}
+	return fmt.Sprintf("contract creation: %v wei + %v gas × %v wei", tx.Value(), tx.Gas(), tx.GasPrice())
 }
 
 // Inspect retrieves the content of the transaction pool and flattens it into an
@@ -210,19 +255,11 @@ func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
 		"queued":  make(map[string]map[string]string),
 	}
 	pending, queue := s.b.TxPoolContent()
-
-	// Define a formatter to flatten a transaction into a string
-	var format = func(tx *types.Transaction) string {
-		if to := tx.To(); to != nil {
-			return fmt.Sprintf("%s: %v wei + %v gas × %v wei", tx.To().Hex(), tx.Value(), tx.Gas(), tx.GasPrice())
-		}
-		return fmt.Sprintf("contract creation: %v wei + %v gas × %v wei", tx.Value(), tx.Gas(), tx.GasPrice())
-	}
 	// Flatten the pending transactions
 	for account, txs := range pending {
 		dump := make(map[string]string)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = format(tx)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = formatPoolTransaction(tx)
 		}
 		content["pending"][account.Hex()] = dump
 	}
@@ -230,13 +267,58 @@ func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
 	for account, txs := range queue {
 		dump := make(map[string]string)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = format(tx)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = formatPoolTransaction(tx)
 		}
 		content["queued"][account.Hex()] = dump
 	}
 	return content
 }
 
+// PrivateTxPoolAPI offers the administrative methods of the transaction pool,
+// in the admin namespace.
+type PrivateTxPoolAPI struct {
+	b Backend
+}
+
+// NewPrivateTxPoolAPI creates a new tx pool service for administrating the
+// transaction pool.
+func NewPrivateTxPoolAPI(b Backend) *PrivateTxPoolAPI {
+	return &PrivateTxPoolAPI{b}
+}
+
+// ReplacementPolicyArgs are the rules a transaction must meet to replace a
+// pooled one with the same nonce.
+type ReplacementPolicyArgs struct {
+	PriceBump      hexutil.Uint64 `json:"priceBump"`      // Minimum price bump percentage
+	GasLimitOnly   bool           `json:"gasLimitOnly"`   // Accept gas limit raises by the price bump alone without price bump
+	LocalSamePrice bool           `json:"localSamePrice"` // Accept local replacements at the same price
+}
+
+// TxPoolReplacementPolicy returns the rules transactions must meet to replace
+// pooled ones with the same nonce.
+func (s *PrivateTxPoolAPI) TxPoolReplacementPolicy() ReplacementPolicyArgs {
+	policy := s.b.TxPoolReplacementPolicy()
+	return ReplacementPolicyArgs{
+		PriceBump:      hexutil.Uint64(policy.PriceBump),
+		GasLimitOnly:   policy.GasLimitOnly,
+		LocalSamePrice: policy.LocalSamePrice,
+	}
+}
+
+// SetTxPoolReplacementPolicy updates the rules transactions must meet to
+// replace pooled ones with the same nonce.
+func (s *PrivateTxPoolAPI) SetTxPoolReplacementPolicy(args ReplacementPolicyArgs) (bool, error) {
+	policy := core.ReplacementPolicy{
+		PriceBump:      uint64(args.PriceBump),
+		GasLimitOnly:   args.GasLimitOnly,
+		LocalSamePrice: args.LocalSamePrice,
+	}
+	if err := s.b.SetTxPoolReplacementPolicy(policy); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // PublicBlockChainAPI provides an API to access the Quai blockchain.
 // It offers only methods that operate on public data that is freely available to anyone.
 type PublicBlockChainAPI struct {
@@ -989,6 +1071,12 @@ type RPCTransaction struct {
 	// Optional fields only present for external transactions
 	Sender *common.Address `json:"sender,omitempty"`
 
+	// Optional fields only present for sponsored transactions
+	FeePayer *common.Address `json:"feePayer,omitempty"`
+	PayerV   *hexutil.Big    `json:"payerV,omitempty"`
+	PayerR   *hexutil.Big    `json:"payerR,omitempty"`
+	PayerS   *hexutil.Big    `json:"payerS,omitempty"`
+
 	ETXGasLimit   hexutil.Uint64    `json:"etxGasLimit,omitempty"`
 	ETXGasPrice   *hexutil.Big      `json:"etxGasPrice,omitempty"`
 	ETXGasTip     *hexutil.Big      `json:"etxGasTip,omitempty"`
@@ -1062,6 +1150,27 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 		result.ETXData = (*hexutil.Bytes)(&data)
 		eal := tx.ETXAccessList()
 		result.ETXAccessList = &eal
+	case types.SponsoredTxType:
+		result = &RPCTransaction{
+			Type:      hexutil.Uint64(tx.Type()),
+			From:      from,
+			Gas:       hexutil.Uint64(tx.Gas()),
+			Hash:      tx.Hash(),
+			Input:     hexutil.Bytes(tx.Data()),
+			Nonce:     hexutil.Uint64(tx.Nonce()),
+			To:        tx.To(),
+			Value:     (*hexutil.Big)(tx.Value()),
+			ChainID:   (*hexutil.Big)(tx.ChainId()),
+			GasFeeCap: (*hexutil.Big)(tx.GasFeeCap()),
+			GasTipCap: (*hexutil.Big)(tx.GasTipCap()),
+		}
+		if payer, err := types.Payer(signer, tx); err == nil {
+			result.FeePayer = &payer
+		}
+		v, r, s := tx.RawPayerSignatureValues()
+		result.PayerV = (*hexutil.Big)(v)
+		result.PayerR = (*hexutil.Big)(r)
+		result.PayerS = (*hexutil.Big)(s)
 	}
 	if blockHash != (common.Hash{}) {
 		result.BlockHash = &blockHash
@@ -1405,6 +1514,12 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 		"logsBloom":         receipt.Bloom,
 		"type":              hexutil.Uint(tx.Type()),
 	}
+	// Assign the fee payer charged for the gas of a sponsored transaction
+	if tx.Type() == types.SponsoredTxType {
+		if payer, err := types.Payer(signer, tx); err == nil {
+			fields["feePayer"] = payer
+		}
+	}
 	// Assign the effective gas price paid
 	header, err := s.b.HeaderByHash(ctx, blockHash)
 	if err != nil {
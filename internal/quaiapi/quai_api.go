@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"math/big"
 	"time"
 
@@ -116,6 +117,10 @@ func (s *PublicQuaiAPI) Syncing() (interface{}, error) {
 	}, nil
 }
 
+// maxSimulatedTxs is the maximum number of transactions given to a pending block
+// simulation.
+const maxSimulatedTxs = 256
+
 // PublicBlockChainQuaiAPI provides an API to access the Quai blockchain.
 // It offers only methods that operate on public data that is freely available to anyone.
 type PublicBlockChainQuaiAPI struct {
@@ -432,6 +437,106 @@ func (s *PublicBlockChainQuaiAPI) EstimateGas(ctx context.Context, args Transact
 	return DoEstimateGas(ctx, s.b, args, bNrOrHash, s.b.RPCGasCap())
 }
 
+// SimulatePendingBlock returns the block the node would build on top of the chain
+// head if the given raw transactions were in the pool, along with the receipts
+// of the included transactions and the fees paid to the coinbase. At most
+// maxSimulatedTxs transactions can be given.
+func (s *PublicBlockChainQuaiAPI) SimulatePendingBlock(ctx context.Context, txs []hexutil.Bytes) (map[string]interface{}, error) {
+	if len(txs) > maxSimulatedTxs {
+		return nil, fmt.Errorf("too many transactions to simulate: have %d, max %d", len(txs), maxSimulatedTxs)
+	}
+	decoded := make(types.Transactions, 0, len(txs))
+	for i, encoded := range txs {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(encoded); err != nil {
+			return nil, fmt.Errorf("invalid transaction %d: %v", i, err)
+		}
+		decoded = append(decoded, tx)
+	}
+	simulated, err := s.b.SimulatePendingBlock(decoded)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := RPCMarshalBlock(simulated.Block, true, true)
+	if err != nil {
+		return nil, err
+	}
+	signer := types.MakeSigner(s.b.ChainConfig(), simulated.Block.Number())
+	receipts := make([]map[string]interface{}, len(simulated.Receipts))
+	for i, receipt := range simulated.Receipts {
+		receipts[i] = rpcMarshalSimulatedReceipt(signer, simulated.Block.Transactions()[i], receipt, i)
+	}
+	fields["receipts"] = receipts
+	fields["fees"] = (*hexutil.Big)(simulated.Fees)
+	return fields, nil
+}
+
+// GetUncleCandidates returns the side blocks the miner keeps as possible uncles,
+// in the order they are considered for inclusion, along with where they came
+// from and the reward expected for including them.
+func (s *PublicBlockChainQuaiAPI) GetUncleCandidates(ctx context.Context) []map[string]interface{} {
+	candidates := s.b.UncleCandidates()
+	result := make([]map[string]interface{}, len(candidates))
+	for i, candidate := range candidates {
+		result[i] = map[string]interface{}{
+			"hash":           candidate.Hash,
+			"parentHash":     candidate.ParentHash,
+			"number":         hexutil.Uint64(candidate.Number),
+			"local":          candidate.Local,
+			"peer":           candidate.Peer,
+			"arrivedAt":      hexutil.Uint64(candidate.ArrivedAt.Unix()),
+			"rewardEstimate": (*hexutil.Big)(candidate.RewardEstimate),
+		}
+	}
+	return result
+}
+
+// EtxPoolStatus returns the state of the inbound ETXs destined to this zone at
+// their last selection for inclusion in a block.
+func (s *PublicBlockChainQuaiAPI) EtxPoolStatus(ctx context.Context) (map[string]interface{}, error) {
+	status, err := s.b.EtxPoolStatus()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"height":       hexutil.Uint64(status.Height),
+		"slots":        hexutil.Uint64(status.Slots),
+		"available":    hexutil.Uint(status.Available),
+		"offered":      hexutil.Uint(status.Offered),
+		"deferred":     hexutil.Uint(status.Deferred),
+		"overSlots":    hexutil.Uint(status.OverSlots),
+		"underpriced":  hexutil.Uint(status.Underpriced),
+		"expiring":     hexutil.Uint(status.Expiring),
+		"oldestHeight": hexutil.Uint64(status.OldestHeight),
+	}, nil
+}
+
+// rpcMarshalSimulatedReceipt converts the receipt of a transaction of a simulated
+// block to the RPC output, leaving out the fields of the unsealed block.
+func rpcMarshalSimulatedReceipt(signer types.Signer, tx *types.Transaction, receipt *types.Receipt, index int) map[string]interface{} {
+	from, _ := types.Sender(signer, tx)
+	fields := map[string]interface{}{
+		"transactionHash":   tx.Hash(),
+		"transactionIndex":  hexutil.Uint64(index),
+		"from":              from,
+		"to":                tx.To(),
+		"gasUsed":           hexutil.Uint64(receipt.GasUsed),
+		"cumulativeGasUsed": hexutil.Uint64(receipt.CumulativeGasUsed),
+		"contractAddress":   nil,
+		"logs":              receipt.Logs,
+		"etxs":              receipt.Etxs,
+		"status":            hexutil.Uint(receipt.Status),
+		"type":              hexutil.Uint(tx.Type()),
+	}
+	if receipt.Logs == nil {
+		fields["logs"] = []*types.Log{}
+	}
+	if !receipt.ContractAddress.Equal(common.ZeroAddr) {
+		fields["contractAddress"] = receipt.ContractAddress
+	}
+	return fields
+}
+
 // RPCMarshalBlock converts the given block to the RPC output which depends on fullTx. If inclTx is true transactions are
 // returned. When fullTx is true the returned block contains full transaction details, otherwise it will only contain
 // transaction hashes.
@@ -606,6 +711,8 @@ func (s *PublicBlockChainQuaiAPI) ReceiveMinedHeader(ctx context.Context, raw js
 	} else if err != nil {
 		return err
 	}
+	// Keep the sealed block around until it is imported, in case of a restart
+	s.b.JournalSealedBlock(block)
 	s.b.WriteBlock(block)
 	// Broadcast the block and announce chain insertion event
 	if block.Header() != nil {
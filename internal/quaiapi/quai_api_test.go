@@ -0,0 +1,51 @@
+package quaiapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/common/hexutil"
+	"github.com/dominant-strategies/go-quai/core"
+)
+
+// uncleTestBackend serves a static list of uncle candidates.
+type uncleTestBackend struct {
+	Backend
+	candidates []core.UncleCandidate
+}
+
+func (b *uncleTestBackend) UncleCandidates() []core.UncleCandidate { return b.candidates }
+
+// Tests that the uncle candidates are returned in the order of the miner, along
+// with their provenance and reward estimate.
+func TestGetUncleCandidates(t *testing.T) {
+	arrived := time.Unix(1700000000, 0)
+	candidates := []core.UncleCandidate{
+		{Hash: common.Hash{1}, ParentHash: common.Hash{0xa}, Number: 5, Local: true, ArrivedAt: arrived, RewardEstimate: big.NewInt(300)},
+		{Hash: common.Hash{2}, ParentHash: common.Hash{0xb}, Number: 4, Peer: "peer-1", ArrivedAt: arrived.Add(time.Second), RewardEstimate: big.NewInt(100)},
+	}
+	api := NewPublicBlockChainQuaiAPI(&uncleTestBackend{candidates: candidates})
+
+	result := api.GetUncleCandidates(context.Background())
+	if len(result) != len(candidates) {
+		t.Fatalf("candidates mismatch: have %d, want %d", len(result), len(candidates))
+	}
+	for i, want := range candidates {
+		fields := result[i]
+		if fields["hash"] != want.Hash || fields["parentHash"] != want.ParentHash || fields["number"] != hexutil.Uint64(want.Number) {
+			t.Errorf("candidate %d mismatch: have %x #%v, want %x #%d", i, fields["hash"], fields["number"], want.Hash, want.Number)
+		}
+		if fields["local"] != want.Local || fields["peer"] != want.Peer {
+			t.Errorf("candidate %d provenance mismatch: have local %v peer %q, want local %v peer %q", i, fields["local"], fields["peer"], want.Local, want.Peer)
+		}
+		if have := fields["arrivedAt"]; have != hexutil.Uint64(want.ArrivedAt.Unix()) {
+			t.Errorf("candidate %d arrival mismatch: have %v, want %d", i, have, want.ArrivedAt.Unix())
+		}
+		if have := fields["rewardEstimate"].(*hexutil.Big); have.ToInt().Cmp(want.RewardEstimate) != 0 {
+			t.Errorf("candidate %d reward estimate mismatch: have %v, want %v", i, have, want.RewardEstimate)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package quaiapi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/common/hexutil"
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+const (
+	defaultTxPoolPageLimit = 256  // Transactions returned per page if no limit is given
+	maxTxPoolPageLimit     = 1024 // Maximum number of transactions returned per page
+)
+
+// errInvalidTxPoolCursor is returned if a page is requested with a cursor which
+// wasn't returned by a previous page.
+var errInvalidTxPoolCursor = errors.New("invalid txpool cursor")
+
+// TxPoolFilterArgs restricts the transaction pool queries to the given senders.
+type TxPoolFilterArgs struct {
+	Addresses []common.Address `json:"addresses"` // Senders to return the transactions of, all if empty
+}
+
+// TxPoolPageArgs selects a page of the transaction pool content, the pending
+// transactions first and then the queued ones, each ordered by sender and nonce.
+type TxPoolPageArgs struct {
+	Addresses []common.Address `json:"addresses"` // Senders to return the transactions of, all if empty
+	Cursor    string           `json:"cursor"`    // Position of the page, as returned by the previous page, empty for the first page
+	Limit     hexutil.Uint64   `json:"limit"`     // Maximum number of transactions in the page
+}
+
+// TxPoolContentPage is a page of the transaction pool content.
+type TxPoolContentPage struct {
+	Pending map[string]map[string]*RPCTransaction `json:"pending"`
+	Queued  map[string]map[string]*RPCTransaction `json:"queued"`
+	Next    string                                `json:"next,omitempty"` // Cursor of the next page, empty if this is the last one
+}
+
+// TxPoolInspectPage is a page of the flattened transaction pool content.
+type TxPoolInspectPage struct {
+	Pending map[string]map[string]string `json:"pending"`
+	Queued  map[string]map[string]string `json:"queued"`
+	Next    string                       `json:"next,omitempty"` // Cursor of the next page, empty if this is the last one
+}
+
+// txPoolPosition is the position of a transaction in the paginated pool content.
+type txPoolPosition struct {
+	queued bool
+	sender common.InternalAddress
+	nonce  uint64
+}
+
+// less returns whether the position comes before the other one.
+func (p txPoolPosition) less(other txPoolPosition) bool {
+	if p.queued != other.queued {
+		return !p.queued
+	}
+	if cmp := bytes.Compare(p.sender[:], other.sender[:]); cmp != 0 {
+		return cmp < 0
+	}
+	return p.nonce < other.nonce
+}
+
+// cursor encodes the position as an opaque page cursor.
+func (p txPoolPosition) cursor() string {
+	section := "p"
+	if p.queued {
+		section = "q"
+	}
+	return fmt.Sprintf("%s:%x:%d", section, p.sender[:], p.nonce)
+}
+
+// parseTxPoolCursor decodes a page cursor into the position of the first
+// transaction of the page.
+func parseTxPoolCursor(cursor string) (txPoolPosition, error) {
+	parts := strings.Split(cursor, ":")
+	if len(parts) != 3 || (parts[0] != "p" && parts[0] != "q") {
+		return txPoolPosition{}, errInvalidTxPoolCursor
+	}
+	sender, err := hexutil.Decode("0x" + parts[1])
+	if err != nil || len(sender) != common.AddressLength {
+		return txPoolPosition{}, errInvalidTxPoolCursor
+	}
+	nonce, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return txPoolPosition{}, errInvalidTxPoolCursor
+	}
+	pos := txPoolPosition{queued: parts[0] == "q", nonce: nonce}
+	copy(pos.sender[:], sender)
+	return pos, nil
+}
+
+// txPoolEntry is a pooled transaction along with its position.
+type txPoolEntry struct {
+	pos txPoolPosition
+	tx  *types.Transaction
+}
+
+// txPoolPager is the part of the backend the transaction pool pages are read
+// from.
+type txPoolPager interface {
+	TxPoolContentFrom(addr common.Address) (types.Transactions, types.Transactions)
+	TxPoolContentPage(queued bool, sender common.InternalAddress, nonce uint64, limit int) map[common.InternalAddress]types.Transactions
+}
+
+// txPoolContentFrom retrieves the pending and queued transactions of the given
+// senders.
+func txPoolContentFrom(b txPoolPager, addrs []common.Address) (map[common.InternalAddress]types.Transactions, map[common.InternalAddress]types.Transactions, error) {
+	pending := make(map[common.InternalAddress]types.Transactions)
+	queued := make(map[common.InternalAddress]types.Transactions)
+	for _, addr := range addrs {
+		internal, err := addr.InternalAddress()
+		if err != nil {
+			return nil, nil, err
+		}
+		if p, q := b.TxPoolContentFrom(addr); len(p) > 0 || len(q) > 0 {
+			if len(p) > 0 {
+				pending[internal] = p
+			}
+			if len(q) > 0 {
+				queued[internal] = q
+			}
+		}
+	}
+	return pending, queued, nil
+}
+
+// txPoolPage selects the transactions of the requested page and returns them in
+// order, along with the cursor of the next page.
+func txPoolPage(b txPoolPager, args TxPoolPageArgs) ([]txPoolEntry, string, error) {
+	limit := int(args.Limit)
+	if limit == 0 {
+		limit = defaultTxPoolPageLimit
+	}
+	if limit > maxTxPoolPageLimit {
+		limit = maxTxPoolPageLimit
+	}
+	var start txPoolPosition
+	if args.Cursor != "" {
+		pos, err := parseTxPoolCursor(args.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		start = pos
+	}
+	var entries []txPoolEntry
+	collect := func(content map[common.InternalAddress]types.Transactions, isQueued bool) {
+		for sender, txs := range content {
+			for _, tx := range txs {
+				pos := txPoolPosition{queued: isQueued, sender: sender, nonce: tx.Nonce()}
+				if pos.less(start) {
+					continue
+				}
+				entries = append(entries, txPoolEntry{pos: pos, tx: tx})
+			}
+		}
+	}
+	if len(args.Addresses) > 0 {
+		pending, queued, err := txPoolContentFrom(b, args.Addresses)
+		if err != nil {
+			return nil, "", err
+		}
+		collect(pending, false)
+		collect(queued, true)
+	} else {
+		// Retrieve one transaction over the limit to know where the next page starts
+		if !start.queued {
+			collect(b.TxPoolContentPage(false, start.sender, start.nonce, limit+1), false)
+			if len(entries) <= limit {
+				collect(b.TxPoolContentPage(true, common.InternalAddress{}, 0, limit+1-len(entries)), true)
+			}
+		} else {
+			collect(b.TxPoolContentPage(true, start.sender, start.nonce, limit+1), true)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].pos.less(entries[j].pos) })
+	if len(entries) > limit {
+		return entries[:limit], entries[limit].pos.cursor(), nil
+	}
+	return entries, "", nil
+}
+
+// ContentPage returns a page of the transactions contained within the
+// transaction pool, optionally restricted to the given senders.
+func (s *PublicTxPoolAPI) ContentPage(args TxPoolPageArgs) (*TxPoolContentPage, error) {
+	entries, next, err := txPoolPage(s.b, args)
+	if err != nil {
+		return nil, err
+	}
+	page := &TxPoolContentPage{
+		Pending: make(map[string]map[string]*RPCTransaction),
+		Queued:  make(map[string]map[string]*RPCTransaction),
+		Next:    next,
+	}
+	curHeader := s.b.CurrentHeader()
+	for _, entry := range entries {
+		content := page.Pending
+		if entry.pos.queued {
+			content = page.Queued
+		}
+		account := entry.pos.sender.Hex()
+		if content[account] == nil {
+			content[account] = make(map[string]*RPCTransaction)
+		}
+		content[account][fmt.Sprintf("%d", entry.pos.nonce)] = newRPCPendingTransaction(entry.tx, curHeader, s.b.ChainConfig())
+	}
+	return page, nil
+}
+
+// InspectPage returns a page of the transaction pool content flattened into an
+// easily inspectable list, optionally restricted to the given senders.
+func (s *PublicTxPoolAPI) InspectPage(args TxPoolPageArgs) (*TxPoolInspectPage, error) {
+	entries, next, err := txPoolPage(s.b, args)
+	if err != nil {
+		return nil, err
+	}
+	page := &TxPoolInspectPage{
+		Pending: make(map[string]map[string]string),
+		Queued:  make(map[string]map[string]string),
+		Next:    next,
+	}
+	for _, entry := range entries {
+		content := page.Pending
+		if entry.pos.queued {
+			content = page.Queued
+		}
+		account := entry.pos.sender.Hex()
+		if content[account] == nil {
+			content[account] = make(map[string]string)
+		}
+		content[account][fmt.Sprintf("%d", entry.pos.nonce)] = formatPoolTransaction(entry.tx)
+	}
+	return page, nil
+}
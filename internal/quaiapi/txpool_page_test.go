@@ -0,0 +1,175 @@
+package quaiapi
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/common/hexutil"
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+// testTxPoolPager serves the pages of a static pool content.
+type testTxPoolPager struct {
+	pending map[common.InternalAddress]types.Transactions
+	queued  map[common.InternalAddress]types.Transactions
+}
+
+func (p *testTxPoolPager) TxPoolContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	internal, _ := addr.InternalAddress()
+	return p.pending[internal], p.queued[internal]
+}
+
+func (p *testTxPoolPager) TxPoolContentPage(queued bool, sender common.InternalAddress, nonce uint64, limit int) map[common.InternalAddress]types.Transactions {
+	content := p.pending
+	if queued {
+		content = p.queued
+	}
+	senders := make([]common.InternalAddress, 0, len(content))
+	for addr := range content {
+		senders = append(senders, addr)
+	}
+	sort.Slice(senders, func(i, j int) bool { return bytes.Compare(senders[i][:], senders[j][:]) < 0 })
+
+	page := make(map[common.InternalAddress]types.Transactions)
+	for _, addr := range senders {
+		for _, tx := range content[addr] {
+			if limit == 0 {
+				return page
+			}
+			if cmp := bytes.Compare(addr[:], sender[:]); cmp < 0 || (cmp == 0 && tx.Nonce() < nonce) {
+				continue
+			}
+			page[addr] = append(page[addr], tx)
+			limit--
+		}
+	}
+	return page
+}
+
+func txPoolPageTestTxs(nonces ...uint64) types.Transactions {
+	txs := make(types.Transactions, len(nonces))
+	for i, nonce := range nonces {
+		txs[i] = types.NewTx(&types.InternalTx{Nonce: nonce})
+	}
+	return txs
+}
+
+func TestTxPoolCursorRoundTrip(t *testing.T) {
+	for _, pos := range []txPoolPosition{
+		{queued: false, sender: common.InternalAddress{1}, nonce: 0},
+		{queued: true, sender: common.InternalAddress{0xff, 2}, nonce: 1 << 40},
+	} {
+		parsed, err := parseTxPoolCursor(pos.cursor())
+		if err != nil {
+			t.Fatalf("failed to parse cursor %s: %v", pos.cursor(), err)
+		}
+		if parsed != pos {
+			t.Errorf("cursor round trip mismatch: have %+v, want %+v", parsed, pos)
+		}
+	}
+	for _, cursor := range []string{"x:00:1", "p:00:1", "q:" + common.InternalAddress{}.Hex()[2:] + ":-1"} {
+		if _, err := parseTxPoolCursor(cursor); err != errInvalidTxPoolCursor {
+			t.Errorf("cursor %s error mismatch: have %v, want %v", cursor, err, errInvalidTxPoolCursor)
+		}
+	}
+	// Walking the pages returns every transaction exactly once, pending first
+	pager := &testTxPoolPager{
+		pending: map[common.InternalAddress]types.Transactions{
+			{2}: txPoolPageTestTxs(0, 1),
+			{1}: txPoolPageTestTxs(3, 4),
+		},
+		queued: map[common.InternalAddress]types.Transactions{
+			{1}: txPoolPageTestTxs(7, 8),
+		},
+	}
+	var (
+		have   []txPoolPosition
+		cursor string
+	)
+	for {
+		entries, next, err := txPoolPage(pager, TxPoolPageArgs{Cursor: cursor, Limit: 3})
+		if err != nil {
+			t.Fatalf("failed to retrieve page: %v", err)
+		}
+		for _, entry := range entries {
+			have = append(have, entry.pos)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	want := []txPoolPosition{
+		{false, common.InternalAddress{1}, 3}, {false, common.InternalAddress{1}, 4},
+		{false, common.InternalAddress{2}, 0}, {false, common.InternalAddress{2}, 1},
+		{true, common.InternalAddress{1}, 7}, {true, common.InternalAddress{1}, 8},
+	}
+	if len(have) != len(want) {
+		t.Fatalf("walked transactions mismatch: have %d, want %d", len(have), len(want))
+	}
+	for i := range want {
+		if have[i] != want[i] {
+			t.Errorf("transaction %d position mismatch: have %+v, want %+v", i, have[i], want[i])
+		}
+	}
+}
+
+// Tests that a page resumes at the next transaction if the one its cursor points
+// to was removed from the pool in between.
+func TestTxPoolPageResumeAfterRemoval(t *testing.T) {
+	pager := &testTxPoolPager{
+		pending: map[common.InternalAddress]types.Transactions{
+			{1}: txPoolPageTestTxs(0, 1, 2),
+			{2}: txPoolPageTestTxs(0),
+		},
+	}
+	entries, next, err := txPoolPage(pager, TxPoolPageArgs{Limit: 2})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("first page mismatch: have %d entries, err %v", len(entries), err)
+	}
+	// Drop the transaction the cursor points to
+	pager.pending[common.InternalAddress{1}] = pager.pending[common.InternalAddress{1}][:2]
+
+	entries, next, err = txPoolPage(pager, TxPoolPageArgs{Cursor: next, Limit: 2})
+	if err != nil {
+		t.Fatalf("failed to resume: %v", err)
+	}
+	if len(entries) != 1 || entries[0].pos != (txPoolPosition{sender: common.InternalAddress{2}}) {
+		t.Fatalf("resumed page mismatch: have %+v", entries)
+	}
+	if next != "" {
+		t.Errorf("last page cursor mismatch: have %s, want none", next)
+	}
+}
+
+func TestTxPoolPageLimit(t *testing.T) {
+	nonces := make([]uint64, 2*maxTxPoolPageLimit)
+	for i := range nonces {
+		nonces[i] = uint64(i)
+	}
+	pager := &testTxPoolPager{
+		pending: map[common.InternalAddress]types.Transactions{{1}: txPoolPageTestTxs(nonces...)},
+	}
+	tests := []struct {
+		limit hexutil.Uint64
+		want  int
+	}{
+		{0, defaultTxPoolPageLimit},
+		{10, 10},
+		{maxTxPoolPageLimit + 1, maxTxPoolPageLimit},
+	}
+	for _, tt := range tests {
+		entries, next, err := txPoolPage(pager, TxPoolPageArgs{Limit: tt.limit})
+		if err != nil {
+			t.Fatalf("limit %d: failed to retrieve page: %v", tt.limit, err)
+		}
+		if len(entries) != tt.want {
+			t.Errorf("limit %d: page size mismatch: have %d, want %d", tt.limit, len(entries), tt.want)
+		}
+		if want := (txPoolPosition{sender: common.InternalAddress{1}, nonce: uint64(tt.want)}).cursor(); next != want {
+			t.Errorf("limit %d: next cursor mismatch: have %s, want %s", tt.limit, next, want)
+		}
+	}
+}
@@ -112,9 +112,9 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllProgpowProtocolChanges = &ChainConfig{big.NewInt(1337), "progpow", new(Blake3powConfig), new(ProgpowConfig), common.Hash{}, common.NodeLocation}
+	AllProgpowProtocolChanges = &ChainConfig{big.NewInt(1337), "progpow", new(Blake3powConfig), new(ProgpowConfig), common.Hash{}, common.NodeLocation, big.NewInt(0)}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), "progpow", new(Blake3powConfig), new(ProgpowConfig), common.Hash{}, common.NodeLocation}
+	TestChainConfig = &ChainConfig{big.NewInt(1), "progpow", new(Blake3powConfig), new(ProgpowConfig), common.Hash{}, common.NodeLocation, big.NewInt(0)}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -131,6 +131,8 @@ type ChainConfig struct {
 	Progpow         *ProgpowConfig   `json:"progpow,omitempty"`
 	GenesisHash     common.Hash
 	Location        common.Location
+
+	SponsoredTxBlock *big.Int `json:"sponsoredTxBlock,omitempty"` // Sponsored transactions switch block (nil = not scheduled)
 }
 
 // SetLocation sets the location on the chain config
@@ -172,6 +174,21 @@ func (c *ChainConfig) String() string {
 	)
 }
 
+// IsSponsoredTx returns whether num is either equal to the sponsored transactions
+// fork block or greater.
+func (c *ChainConfig) IsSponsoredTx(num *big.Int) bool {
+	return isForked(c.SponsoredTxBlock, num)
+}
+
+// isForked returns whether a fork scheduled at block s is active at the given
+// head block.
+func isForked(s, head *big.Int) bool {
+	if s == nil || head == nil {
+		return false
+	}
+	return s.Cmp(head) <= 0
+}
+
 func configNumEqual(x, y *big.Int) bool {
 	if x == nil {
 		return y == nil
@@ -51,12 +51,19 @@ func (s *senderFromServer) Sender(tx *types.Transaction) (common.Address, error)
 	return s.addr, nil
 }
 
+func (s *senderFromServer) Payer(tx *types.Transaction) (common.Address, error) {
+	return common.ZeroAddr, errNotCached
+}
+
 func (s *senderFromServer) ChainID() *big.Int {
 	panic("can't sign with senderFromServer")
 }
 func (s *senderFromServer) Hash(tx *types.Transaction) common.Hash {
 	panic("can't sign with senderFromServer")
 }
+func (s *senderFromServer) PayerHash(tx *types.Transaction) common.Hash {
+	panic("can't sign with senderFromServer")
+}
 func (s *senderFromServer) SignatureValues(tx *types.Transaction, sig []byte) (R, S, V *big.Int, err error) {
 	panic("can't sign with senderFromServer")
 }